@@ -1,26 +1,92 @@
 package main
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/llm-net/llm-api-plugin/cmd/ark-cli/provider"
+	"github.com/llm-net/llm-api-plugin/internal/assets"
+	"github.com/llm-net/llm-api-plugin/internal/buildinfo"
+	"github.com/llm-net/llm-api-plugin/internal/cassette"
 	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/doctor"
+	"github.com/llm-net/llm-api-plugin/internal/dryrun"
+	"github.com/llm-net/llm-api-plugin/internal/events"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/input"
+	"github.com/llm-net/llm-api-plugin/internal/keypool"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+	"github.com/llm-net/llm-api-plugin/internal/notify"
+	"github.com/llm-net/llm-api-plugin/internal/output"
+	"github.com/llm-net/llm-api-plugin/internal/probe"
+	"github.com/llm-net/llm-api-plugin/internal/redact"
+	"github.com/llm-net/llm-api-plugin/internal/selfupdate"
+	"github.com/llm-net/llm-api-plugin/internal/storage"
+	"github.com/llm-net/llm-api-plugin/internal/timing"
+	"github.com/llm-net/llm-api-plugin/pkg/jimeng"
 )
 
+// notifier fires completion notifications for the current generate command.
+var notifier *notify.Notifier
+
+// stager holds the input files staged to object storage by generate, and
+// publish uploads its output there too. dryRun prints the request instead of
+// sending it; noDownload prints the provider's result URL instead of
+// downloading it. strict fails generate when the downloaded video does not
+// match the request.
+var (
+	stager     *storage.Stager
+	publish    bool
+	dryRun     bool
+	noDownload bool
+	strict     bool
+)
+
+// timings measures the phases of the current generate command; showTimings
+// (--timings) prints them and saves them next to the output.
+var (
+	timings     *timing.Timings
+	showTimings bool
+)
+
+// emitter writes the --events progress stream for agents.
+var emitter *events.Emitter
+
 func usage() {
 	fmt.Fprintf(os.Stderr, `ark-cli - CLI for Volcano Ark (火山方舟) Video Generation API
 
 Usage:
   %[1]s generate <prompt> [flags]                    Generate video from text prompt
   %[1]s models [<model-name>]                        List available models (JSON)
-  %[1]s config set-key <API_KEY>                     Set Ark API key
-  %[1]s config set-keys <ACCESS_KEY_ID> <SECRET_KEY> Set Jimeng access keys
-  %[1]s config show                                  Show current config
+  %[1]s doctor                                       Check credentials and connectivity of each provider
+  %[1]s assets <cmd>                                 Manage the asset library: reusable portraits, voices, videos (asset:<name>)
+  %[1]s probe <file.mp4>                             Print duration, resolution, frame rate, codecs and audio of a video (JSON)
+  %[1]s self-update [flags]                          Install the latest release, checksum-verified (--help for flags)
+  %[1]s version                                      Print version and build info (also --version)
+  %[1]s config set-key [<API_KEY>]                   Set Ark API key
+  %[1]s config set-keys <AK> [<SECRET_KEY>]          Set Jimeng access keys
+  %[1]s config show [--effective]                    Show current config (--effective: merged project defaults)
+  %[1]s config notify <cmd>                          Manage completion notification targets
+  %[1]s config profile <cmd>                         Manage named credential profiles
+  %[1]s config migrate                               Move plaintext secrets to the OS keyring / encrypted file
+  %[1]s config storage <cmd>                         Configure the object storage bucket for staging and publishing
+
+Global flags:
+  --profile <name>   Credential profile to use (or LLM_API_PROFILE)
+  --record <dir>     Save every HTTP request and response to a cassette directory
+  --replay <dir>     Serve HTTP responses from a cassette instead of the network
+  --har <file>       Export the HTTP traffic as a HAR file
+  --verbose          Log debug details: HTTP requests, raw provider responses (redacted)
+  --quiet            Only log warnings and errors
+  --log-format <f>   Log format: text (default) or json
+  --log-file <path>  Append logs to a file instead of stderr
 
 Flags for generate:
   --model <model>              Model name                                  [default: doubao-seedance-1-5-pro-251215]
@@ -30,16 +96,29 @@ Flags for generate:
   --no-audio                   Disable audio generation                                      (Ark models)
   --frames <num>               Total frames: 121 (5s) or 241 (10s)         [default: 121]    (Jimeng models)
   --seed <num>                 Random seed (-1 for random)                                   (Jimeng models)
-  --image <url>                First frame image URL                                         (Jimeng i2v models)
-  --image-file <path>          First frame image from local file (auto base64-encoded)        (Jimeng i2v models)
-  --end-image <url>            Last frame image URL                                          (Jimeng i2v-startend)
-  --end-image-file <path>      Last frame image from local file (auto base64-encoded)         (Jimeng i2v-startend)
-  --output <path>              Output file path                            [default: output_<timestamp>.mp4]
+  --image <input>              First frame image                                             (i2v models)
+  --end-image <input>          Last frame image                                              (Jimeng i2v-startend)
+  --output <path>              Output file path, or - to write the video to stdout [default: output_<timestamp>.mp4]
+  --output-dir <dir>           Directory for generated files               [default: output_dir config or .]
+  --output-template <tmpl>     File name template: {model} {seed} {task_id} {index} {date} {prompt_slug} {ext}
+  --no-download                Print the provider's video URL and its expiry instead of downloading it
+  --strict                     Fail when the video does not match the requested ratio, resolution, duration, frames or audio
+  --notify <target>            Notify on completion: configured target name, "desktop" or webhook URL (repeatable)
+  --stage                      Upload local images to object storage instead of inlining them as base64
+  --publish                    Upload the output to object storage and print a shareable URL
+  --dry-run                    Print the request (secrets redacted) and a curl command without sending it
+  --timings                    Print per-phase timings and save them to <output>.timings.json
+  --events <spec>              Emit NDJSON progress events: ndjson (stderr), ndjson:<file> or ndjson:fd:<n>
+
+Image flags take a local path, http(s) URL, data URI, - for stdin or asset:<name> (see
+'assets'); --image-file and --end-image-file are aliases. Jimeng models inline local images as base64; Ark models
+only accept URLs, so local images are staged via object storage (see 'config storage').
 
 Examples:
   %[1]s generate "A cat playing piano in a jazz bar"
   %[1]s generate "Ocean waves at sunset" --duration 10 --resolution 1080p
   %[1]s generate "Dancing robot" --ratio 9:16 --no-audio
+  %[1]s generate "The cat starts to dance" --image cat.png --publish
   %[1]s generate "A dreamy forest" --model jimeng-t2v-3-pro
   %[1]s generate "Expand this image" --model jimeng-i2v-3-pro --image https://example.com/photo.jpg
   %[1]s generate "Morph between" --model jimeng-i2v-startend-3-pro --image https://a.jpg --end-image https://b.jpg
@@ -49,6 +128,25 @@ Examples:
 }
 
 func main() {
+	args, err := config.SelectProfile(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+	args, err = cassette.Setup(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+	args, err = logging.Setup(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+
 	if len(os.Args) < 2 {
 		usage()
 		os.Exit(1)
@@ -61,6 +159,25 @@ func main() {
 		handleGenerate()
 	case "models":
 		handleModels()
+	case "doctor":
+		handleDoctor()
+	case "probe":
+		if err := probe.Command(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "assets":
+		if err := assets.Command(os.Args[2:], input.Load); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "self-update":
+		if err := selfupdate.Command("ark-cli", os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "version", "--version":
+		fmt.Println(buildinfo.String("ark-cli"))
 	case "help", "--help", "-h":
 		usage()
 	default:
@@ -72,74 +189,115 @@ func main() {
 
 func handleConfig() {
 	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Usage: config set-key <KEY> | config set-keys <AK> <SK> | config show")
+		fmt.Fprintln(os.Stderr, "Usage: config set-key <KEY> | config set-keys <AK> [<SK>] | config show [--effective] | config notify <cmd> | config profile <cmd> | config storage <cmd> | config migrate")
 		os.Exit(1)
 	}
 	switch os.Args[2] {
 	case "set-key":
-		if len(os.Args) < 4 {
-			fmt.Fprintln(os.Stderr, "Usage: config set-key <API_KEY>")
+		value, err := config.SecretArg(os.Args, 3, "Ark API key: ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg, err := config.LoadOrCreate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		where, err := cfg.SetSecret(config.ServiceArk, config.FieldAPIKey, value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		cfg, _ := config.LoadOrCreate()
-		cfg.Ark = &config.ServiceConfig{APIKey: os.Args[3]}
 		if err := config.Save(cfg); err != nil {
 			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("Ark API key saved to %s\n", config.Path())
+		fmt.Printf("Ark API key saved to %s\n", cfg.Location(where))
 	case "set-keys":
-		if len(os.Args) < 5 {
-			fmt.Fprintln(os.Stderr, "Usage: config set-keys <ACCESS_KEY_ID> <SECRET_ACCESS_KEY>")
+		if len(os.Args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: config set-keys <ACCESS_KEY_ID> [<SECRET_ACCESS_KEY>]")
 			os.Exit(1)
 		}
-		cfg, _ := config.LoadOrCreate()
-		cfg.Jimeng = &config.ServiceConfig{
-			AccessKeyID:    os.Args[3],
-			SecretAccessKey: os.Args[4],
+		sk, err := config.SecretArg(os.Args, 4, "Jimeng SecretAccessKey: ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg, err := config.LoadOrCreate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := cfg.SetSecret(config.ServiceJimeng, config.FieldAccessKeyID, os.Args[3]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		where, err := cfg.SetSecret(config.ServiceJimeng, config.FieldSecretAccessKey, sk)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
 		if err := config.Save(cfg); err != nil {
 			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("Jimeng access keys saved to %s\n", config.Path())
+		fmt.Printf("Jimeng access keys saved to %s\n", cfg.Location(where))
 	case "show":
-		cfg, _ := config.LoadOrCreate()
+		cfg, err := config.LoadOrCreate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(os.Args) > 3 && os.Args[3] == "--effective" {
+			config.ShowEffective(cfg, provider.Registry)
+			fmt.Println()
+		}
+
+		profile, profileSource := cfg.ActiveProfile()
+		if n := cfg.PlaintextSecrets(); n > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d plaintext secret(s) in %s; run '%s config migrate' to move them to secure storage\n", n, config.Path(), filepath.Base(os.Args[0]))
+		}
+		fmt.Printf("Config: %s\nProfile: %s (source: %s)\n\n", config.Path(), profile, profileSource)
 
 		// Ark config
-		apiKey := config.ResolveAPIKey("ARK_API_KEY", cfg.Ark)
-		fmt.Printf("Config: %s\n\n", config.Path())
+		apiKey, source := config.Lookup(cfg, config.ServiceArk, config.FieldAPIKey, "ARK_API_KEY")
 		if apiKey == "" {
 			fmt.Println("Ark: not configured")
 		} else {
-			source := "config file"
-			if os.Getenv("ARK_API_KEY") != "" {
-				source = "env ARK_API_KEY"
-			}
-			masked := apiKey
-			if len(masked) > 8 {
-				masked = masked[:4] + "..." + masked[len(masked)-4:]
-			}
-			fmt.Printf("Ark API Key: %s (source: %s)\n", masked, source)
+			fmt.Printf("Ark API Key: %s (source: %s)\n", keypool.Mask(apiKey), source)
 		}
 
 		fmt.Println()
 
 		// Jimeng config
-		ak, sk := config.ResolveAccessKeys("JIMENG_ACCESS_KEY_ID", "JIMENG_SECRET_ACCESS_KEY", cfg.Jimeng)
+		ak, akSource := config.Lookup(cfg, config.ServiceJimeng, config.FieldAccessKeyID, "JIMENG_ACCESS_KEY_ID")
+		sk, skSource := config.Lookup(cfg, config.ServiceJimeng, config.FieldSecretAccessKey, "JIMENG_SECRET_ACCESS_KEY")
 		if ak == "" && sk == "" {
 			fmt.Println("Jimeng: not configured")
 		} else {
-			akSource := "config file"
-			if os.Getenv("JIMENG_ACCESS_KEY_ID") != "" {
-				akSource = "env JIMENG_ACCESS_KEY_ID"
-			}
-			skSource := "config file"
-			if os.Getenv("JIMENG_SECRET_ACCESS_KEY") != "" {
-				skSource = "env JIMENG_SECRET_ACCESS_KEY"
-			}
-			fmt.Printf("Jimeng AccessKeyID: %s (source: %s)\n", maskSecret(ak), akSource)
-			fmt.Printf("Jimeng SecretAccessKey: %s (source: %s)\n", maskSecret(sk), skSource)
+			fmt.Printf("Jimeng AccessKeyID: %s (source: %s)\n", keypool.Mask(ak), akSource)
+			fmt.Printf("Jimeng SecretAccessKey: %s (source: %s)\n", keypool.Mask(sk), skSource)
+		}
+	case "notify":
+		if err := notify.ConfigCommand(os.Args[3:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "profile":
+		if err := config.ProfileCommand(os.Args[3:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "migrate":
+		if err := config.MigrateCommand(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "storage":
+		if err := storage.ConfigCommand(os.Args[3:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown config command: %s\n", os.Args[2])
@@ -147,14 +305,27 @@ func handleConfig() {
 	}
 }
 
+func handleDoctor() {
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	profile, source := cfg.ActiveProfile()
+	fmt.Printf("Profile: %s (source: %s)\n\n", profile, source)
+	if doctor.Run(os.Stdout, provider.DoctorChecks(cfg)) > 0 {
+		os.Exit(1)
+	}
+}
+
 func handleModels() {
-	data, err := registry.JSON()
+	data, err := provider.Registry.JSON()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 	if len(os.Args) >= 3 {
-		m := registry.FindModel(os.Args[2])
+		m := provider.Registry.FindModel(os.Args[2])
 		if m == nil {
 			fmt.Fprintf(os.Stderr, "Unknown model: %s\n", os.Args[2])
 			os.Exit(1)
@@ -172,24 +343,45 @@ func handleGenerate() {
 		os.Exit(1)
 	}
 
+	// Project and user defaults seed the flags; explicit flags override them.
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	args := os.Args[2:]
+	model := config.ArgValue(args, "--model")
+	if model == "" {
+		capability := "text-to-video"
+		if config.ArgValue(args, "--image") != "" || config.ArgValue(args, "--image-file") != "" {
+			capability = "image-to-video"
+		}
+		model, _ = cfg.ModelFor(capability, provider.Registry)
+	}
+	if model == "" {
+		model = provider.DefaultModel
+	}
+
 	var prompt string
-	model := ""
 	// Ark flags
-	duration := "5"
-	resolution := "720p"
-	ratio := "16:9"
-	audio := "true"
+	duration := cfg.Param(model, "duration", "5")
+	resolution := cfg.Param(model, "resolution", "720p")
+	ratio := cfg.Param(model, "ratio", "16:9")
+	audio := cfg.Param(model, "audio", "true")
 	// Jimeng flags
-	frames := 0
-	seed := 0
+	frames, _ := strconv.Atoi(cfg.Param(model, "frames", "0"))
+	seed, _ := strconv.Atoi(cfg.Param(model, "seed", "0"))
 	image := ""
 	imageFile := ""
 	endImage := ""
 	endImageFile := ""
 	// Common
-	output := ""
+	var out output.Options
+	eventsSpec := ""
+	var notifyTargets []string
+	stage := false
+	publish = storage.PublishEnabled(cfg)
 
-	args := os.Args[2:]
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
 		case "--model":
@@ -251,7 +443,39 @@ func handleGenerate() {
 		case "--output":
 			i++
 			if i < len(args) {
-				output = args[i]
+				out.Path = args[i]
+			}
+		case "--output-dir":
+			i++
+			if i < len(args) {
+				out.Dir = args[i]
+			}
+		case "--output-template":
+			i++
+			if i < len(args) {
+				out.Template = args[i]
+			}
+		case "--notify":
+			i++
+			if i < len(args) {
+				notifyTargets = append(notifyTargets, args[i])
+			}
+		case "--stage":
+			stage = true
+		case "--publish":
+			publish = true
+		case "--no-download":
+			noDownload = true
+		case "--strict":
+			strict = true
+		case "--dry-run":
+			dryRun = true
+		case "--timings":
+			showTimings = true
+		case "--events":
+			i++
+			if i < len(args) {
+				eventsSpec = args[i]
 			}
 		default:
 			if prompt == "" {
@@ -267,105 +491,197 @@ func handleGenerate() {
 		os.Exit(1)
 	}
 
-	if output == "" {
-		output = fmt.Sprintf("output_%s.mp4", time.Now().Format("20060102_150405"))
-	}
-
-	// Read image files and base64-encode them
-	var imageBase64, endImageBase64 string
-	if imageFile != "" {
-		data, err := os.ReadFile(imageFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading image file %s: %v\n", imageFile, err)
-			os.Exit(1)
-		}
-		imageBase64 = base64.StdEncoding.EncodeToString(data)
+	out, err = out.Defaults(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-	if endImageFile != "" {
-		data, err := os.ReadFile(endImageFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading end image file %s: %v\n", endImageFile, err)
-			os.Exit(1)
-		}
-		endImageBase64 = base64.StdEncoding.EncodeToString(data)
+	if publish && (noDownload || out.ToStdout()) {
+		logging.Warnf("--publish ignored: the video is not saved locally")
+		publish = false
 	}
 
-	// Determine model and provider
+	// Determine provider
 	modelName := model
-	if modelName == "" {
-		modelName = defaultModel
-	}
-
-	provider, ok := modelProvider[modelName]
+	backend, ok := provider.ModelProvider[modelName]
 	if !ok {
 		fmt.Fprintf(os.Stderr, "Error: unknown model %q. Run 'ark-cli models' to see available models.\n", modelName)
 		os.Exit(1)
 	}
+	// Ark only accepts image URLs, so local files are always staged there
+	if backend == "ark" {
+		stage = true
+	}
+
+	// Resolve inputs: local path, URL, data URI or - for stdin
+	imageIn := resolveInput("--image", image, imageFile)
+	endImageIn := resolveInput("--end-image", endImage, endImageFile)
+
+	notifier, err = notify.New(cfg, notifyTargets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	notifier.Tool, notifier.Model = "ark-cli", modelName
+	timings = timing.New("ark-cli", modelName)
+	notifier.Timings = timings
+	emitter, err = events.Open(eventsSpec, "ark-cli", modelName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	switch provider {
+	// Jimeng takes images as URLs or inline base64; anything that is not a
+	// URL is staged through object storage instead when requested
+	stager = storage.NewStager(cfg)
+	var imageBase64, endImageBase64 *httpclient.Base64
+	if stage {
+		image, endImage = stageInput(imageIn), stageInput(endImageIn)
+	} else {
+		if image, imageBase64, err = imageIn.URLOrBase64(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading image %s: %v\n", imageIn, err)
+			os.Exit(1)
+		}
+		if endImage, endImageBase64, err = endImageIn.URLOrBase64(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading end image %s: %v\n", endImageIn, err)
+			os.Exit(1)
+		}
+	}
+
+	switch backend {
 	case "ark":
-		generateWithArk(modelName, prompt, resolution, duration, ratio, audio, output)
+		generateWithArk(modelName, prompt, image, resolution, duration, ratio, audio, out)
 	case "jimeng":
-		generateWithJimeng(modelName, prompt, ratio, frames, seed, image, imageBase64, endImage, endImageBase64, output)
+		generateWithJimeng(modelName, prompt, ratio, frames, seed, image, imageBase64, endImage, endImageBase64, out)
 	}
 }
 
-func generateWithArk(model, prompt, resolution, duration, ratio, audio, output string) {
-	cfg, _ := config.LoadOrCreate()
-	apiKey := config.ResolveAPIKey("ARK_API_KEY", cfg.Ark)
+func generateWithArk(model, prompt, image, resolution, duration, ratio, audio string, out output.Options) {
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	apiKey := config.ResolveAPIKey(cfg, config.ServiceArk, "ARK_API_KEY")
+	if apiKey == "" && dryRun {
+		apiKey = dryrun.MissingKey
+	}
 	if apiKey == "" {
 		fmt.Fprintf(os.Stderr, "Error: Ark API key not set.\n  Option 1: export ARK_API_KEY=<KEY>\n  Option 2: ark-cli config set-key <KEY>\n")
+		cleanupStaged()
 		os.Exit(1)
 	}
 
-	fmt.Fprintf(os.Stderr, "Creating task with model %s...\n", model)
+	keys, err := keypool.New(cfg, config.ServiceArk, apiKey, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		cleanupStaged()
+		os.Exit(1)
+	}
 
-	taskID, err := createTask(apiKey, model, prompt, resolution, duration, ratio, audio)
+	if dryRun {
+		printDryRun(provider.CreateTaskDryRun(keys.Get("").Value, model, prompt, image, resolution, duration, ratio, audio))
+		return
+	}
+
+	logging.Infof("Creating task with model %s...", model)
+
+	// The task belongs to the key that created it, so it is polled with
+	// that key too
+	start := time.Now()
+	var taskID string
+	key, err := keys.Try(func(k keypool.Key) error {
+		var err error
+		taskID, err = provider.CreateTask(context.Background(), k.Value, model, prompt, image, resolution, duration, ratio, audio)
+		return err
+	})
+	apiKey = key.Value
+	timings.Key(config.ServiceArk, key.Masked())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating task: %v\n", err)
+		cleanupStaged()
+		failed(err)
 		os.Exit(1)
 	}
-	fmt.Fprintf(os.Stderr, "Task created: %s\n", taskID)
-	fmt.Fprintf(os.Stderr, "Polling for result (timeout %v)...\n", pollTimeout)
-
-	result, err := waitForTask(apiKey, taskID)
+	timings.Measure(timing.Submit, start, 0)
+	timings.Wait()
+	logging.Infof("Task created: %s", taskID)
+	notifier.TaskID = taskID
+	emitter.Submitted(taskID)
+	logging.Infof("Polling for result (timeout %v)...", provider.PollTimeout)
+
+	result, err := provider.Seedance(apiKey).WaitForTask(context.Background(), taskID, printStatus)
+	timings.EndWait()
+	cleanupStaged()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		failed(err)
 		os.Exit(1)
 	}
 
-	if result.Content == nil || result.Content.VideoURL == "" {
+	if result.VideoURL() == "" {
 		fmt.Fprintln(os.Stderr, "Error: task succeeded but no video URL in response")
+		failed(errors.New("task succeeded but no video URL in response"))
 		os.Exit(1)
 	}
 
-	fmt.Fprintf(os.Stderr, "Downloading video...\n")
-	size, err := downloadVideo(result.Content.VideoURL, output)
+	if noDownload {
+		keepRemote(result.VideoURL())
+		return
+	}
+
+	logging.Info("Downloading video...")
+	start = time.Now()
+	path, size, err := output.Download(context.Background(), provider.SeedanceAPI, result.VideoURL(), out,
+		output.Vars{Model: model, Prompt: prompt, TaskID: taskID, Ext: "mp4"}, emitter.Progress)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		failed(err)
 		os.Exit(1)
 	}
-
-	fmt.Fprintf(os.Stderr, "Video saved: %s (%d bytes)\n", output, size)
+	timings.Measure(timing.Download, start, size)
+
+	logging.Infof("Video saved: %s (%d bytes)", output.Describe(path), size)
+	want := probe.Expect{Resolution: resolution, Audio: probe.Bool(audio != "false")}
+	want.Duration, _ = strconv.ParseFloat(duration, 64)
+	// An image-to-video clip takes the shape of its first frame
+	if image == "" {
+		want.Ratio = ratio
+	}
+	verifyVideo(path, want)
+	emitter.Emit(events.Event{Type: events.ArtifactSaved, Path: path, Bytes: size})
+	publishOutput(path)
+	done(path)
 }
 
-func generateWithJimeng(model, prompt, ratio string, frames, seed int, image, imageBase64, endImage, endImageBase64, output string) {
-	cfg, _ := config.LoadOrCreate()
-	ak, sk := config.ResolveAccessKeys("JIMENG_ACCESS_KEY_ID", "JIMENG_SECRET_ACCESS_KEY", cfg.Jimeng)
+func generateWithJimeng(model, prompt, ratio string, frames, seed int, image string, imageBase64 *httpclient.Base64, endImage string, endImageBase64 *httpclient.Base64, out output.Options) {
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	ak, sk := config.ResolveAccessKeys(cfg, config.ServiceJimeng, "JIMENG_ACCESS_KEY_ID", "JIMENG_SECRET_ACCESS_KEY")
+	if (ak == "" || sk == "") && dryRun {
+		ak, sk = dryrun.MissingKey, dryrun.MissingKey
+	}
 	if ak == "" || sk == "" {
 		fmt.Fprintf(os.Stderr, "Error: Jimeng access keys not set.\n"+
 			"  Option 1: export JIMENG_ACCESS_KEY_ID=<AK> && export JIMENG_SECRET_ACCESS_KEY=<SK>\n"+
 			"  Option 2: ark-cli config set-keys <ACCESS_KEY_ID> <SECRET_ACCESS_KEY>\n")
+		cleanupStaged()
 		os.Exit(1)
 	}
 
-	reqKey := jimengReqKey[model]
+	reqKey := provider.JimengReqKey[model]
 
-	p := newJimengProvider(ak, sk)
-
-	fmt.Fprintf(os.Stderr, "Submitting video generation task (%s)...\n", model)
+	keys, err := keypool.New(cfg, config.ServiceJimeng, ak, sk)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		cleanupStaged()
+		os.Exit(1)
+	}
 
-	taskID, err := p.submitTask(jimengSubmitOpts{
+	opts := provider.JimengSubmitOpts{
 		ReqKey:           reqKey,
 		Prompt:           prompt,
 		FirstFrameImage:  image,
@@ -375,31 +691,255 @@ func generateWithJimeng(model, prompt, ratio string, frames, seed int, image, im
 		AspectRatio:      ratio,
 		Frames:           frames,
 		Seed:             seed,
+	}
+	if imageBase64 != nil || endImageBase64 != nil {
+		opts.Progress = uploadProgress("image", "")
+	}
+	if dryRun {
+		printDryRun(provider.JimengSubmitDryRun(opts))
+		return
+	}
+
+	logging.Infof("Submitting video generation task (%s)...", model)
+
+	// The task belongs to the key pair that submitted it, so client keeps
+	// polling with that pair
+	start := time.Now()
+	var client *jimeng.Client
+	var taskID string
+	key, err := keys.Try(func(k keypool.Key) error {
+		var err error
+		client = provider.Jimeng(k.Value, k.Secret)
+		taskID, err = client.SubmitVideo(context.Background(), opts)
+		return err
 	})
+	timings.Key(config.ServiceJimeng, key.Masked())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error submitting task: %v\n", err)
+		cleanupStaged()
+		failed(err)
 		os.Exit(1)
 	}
-	fmt.Fprintf(os.Stderr, "Task created: %s\n", taskID)
-	fmt.Fprintf(os.Stderr, "Polling for result (timeout %v)...\n", pollTimeout)
-
-	result, err := p.jimengWaitForTask(reqKey, taskID)
+	timings.Measure(timing.Submit, start, 0)
+	timings.Wait()
+	logging.Infof("Task created: %s", taskID)
+	notifier.TaskID = taskID
+	emitter.Submitted(taskID)
+	logging.Infof("Polling for result (timeout %v)...", provider.PollTimeout)
+
+	result, err := client.WaitVideo(context.Background(), reqKey, taskID, printStatus)
+	timings.EndWait()
+	cleanupStaged()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		failed(err)
 		os.Exit(1)
 	}
 
 	if result.VideoURL == "" {
 		fmt.Fprintln(os.Stderr, "Error: task succeeded but no video URL in response")
+		failed(errors.New("task succeeded but no video URL in response"))
 		os.Exit(1)
 	}
 
-	fmt.Fprintf(os.Stderr, "Downloading video...\n")
-	size, err := downloadVideo(result.VideoURL, output)
+	if noDownload {
+		keepRemote(result.VideoURL)
+		return
+	}
+
+	logging.Info("Downloading video...")
+	start = time.Now()
+	path, size, err := output.Download(context.Background(), provider.JimengAPI, result.VideoURL, out,
+		output.Vars{Model: model, Prompt: prompt, TaskID: taskID, Seed: output.Seed(seed), Ext: "mp4"}, emitter.Progress)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		failed(err)
 		os.Exit(1)
 	}
+	timings.Measure(timing.Download, start, size)
+
+	logging.Infof("Video saved: %s (%d bytes)", output.Describe(path), size)
+	want := probe.Expect{Frames: frames}
+	if image == "" && endImage == "" && imageBase64 == nil && endImageBase64 == nil {
+		want.Ratio = ratio
+	}
+	verifyVideo(path, want)
+	emitter.Emit(events.Event{Type: events.ArtifactSaved, Path: path, Bytes: size})
+	publishOutput(path)
+	done(path)
+}
+
+// keepRemote ends generate with --no-download: it prints the provider's
+// video URL on stdout, and its expiry on stderr, instead of downloading it.
+func keepRemote(url string) {
+	logging.Infof("Video left at the provider (%s):", output.ExpiryNote(url))
+	ev := events.Event{Type: events.ArtifactRemote, URL: url}
+	if t, ok := output.Expiry(url); ok {
+		ev.ExpiresAt = &t
+	}
+	emitter.Emit(ev)
+	fmt.Println(url)
+	done(url)
+}
 
-	fmt.Fprintf(os.Stderr, "Video saved: %s (%d bytes)\n", output, size)
+// failed and done end the generate command: they report its timings and
+// fire the completion notification.
+func failed(err error) {
+	emitter.Emit(events.Event{Type: events.Error, Message: err.Error()})
+	reportTimings(notify.Classify(err))
+	notifier.Failed(err)
+}
+
+func done(outputs ...string) {
+	reportTimings(notify.Success, outputs...)
+	notifier.Done(outputs...)
+}
+
+// verifyVideo probes the video saved at path against the request. A file
+// that is not a usable MP4 fails generate; mismatches are warnings unless
+// --strict.
+func verifyVideo(path string, want probe.Expect) {
+	if !output.Local(path) {
+		return
+	}
+	_, problems, err := probe.Verify(path, want)
+	if err == nil && strict && len(problems) > 0 {
+		err = fmt.Errorf("video does not match the request: %s", strings.Join(problems, "; "))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		failed(err)
+		os.Exit(1)
+	}
+}
+
+// reportTimings prints the --timings summary, saves it next to the first
+// output and exports it to the configured metrics backends.
+func reportTimings(outcome string, outputs ...string) {
+	if showTimings {
+		timings.Summary(os.Stderr)
+		if len(outputs) > 0 && output.Local(outputs[0]) {
+			path := timing.SidecarPath(outputs[0])
+			if err := timings.Save(path); err != nil {
+				logging.Warnf("failed to save timings: %v", err)
+			} else {
+				logging.Infof("Timings saved: %s", path)
+			}
+		}
+	}
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		logging.Warnf("failed to export metrics: %v", err)
+		return
+	}
+	if err := timings.Export(cfg, outcome); err != nil {
+		logging.Warnf("failed to export metrics: %v", err)
+	}
+}
+
+// printStatus reports an unfinished poll on stderr.
+func printStatus(status string) {
+	timings.Status(status)
+	emitter.Status(status)
+	logging.Infof("  Status: %s, waiting %v...", status, provider.PollInterval)
+}
+
+// printDryRun prints the request generate would send, for --dry-run.
+func printDryRun(req dryrun.Request) {
+	if err := dryrun.Print(os.Stdout, req); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// resolveInput resolves a media flag, preferring its legacy -file alias when
+// given, and exits on failure.
+func resolveInput(flag, value, fileValue string) *input.Input {
+	if fileValue != "" {
+		value = fileValue
+	}
+	in, err := input.Resolve(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", flag, err)
+		os.Exit(1)
+	}
+	return in
+}
+
+// stageInput returns the URL of a media input, uploading it to object
+// storage unless it already is a URL, and exits on failure.
+func stageInput(in *input.Input) string {
+	if in != nil && !in.IsURL() {
+		if dryRun {
+			if err := stager.Check(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return fmt.Sprintf("<presigned URL of %s>", in)
+		}
+		logging.Infof("Staging %s to object storage...", in)
+		emitter.Emit(events.Event{Type: events.UploadStarted, Source: in.String()})
+		stager.Progress = uploadProgress("", in.String())
+	}
+	start := time.Now()
+	url, err := in.StageURL(stager)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		emitter.Emit(events.Event{Type: events.Error, Message: err.Error()})
+		cleanupStaged()
+		os.Exit(1)
+	}
+	if in != nil && !in.IsURL() {
+		timings.Measure(timing.Stage, start, 0)
+		emitter.Emit(events.Event{Type: events.UploadDone, Source: in.String(), URL: redact.URL(url)})
+	}
+	return url
+}
+
+// uploadProgress reports the progress of an upload on stderr, at most every
+// few seconds, and as upload.progress events.
+func uploadProgress(input, source string) func(sent, total int64) {
+	print := httpclient.Throttle(2*time.Second, func(sent, total int64) {
+		logging.Infof("  Uploaded %s", httpclient.FormatProgress(sent, total))
+	})
+	return func(sent, total int64) {
+		emitter.UploadProgress(input, source, sent, total)
+		print(sent, total)
+	}
+}
+
+// cleanupStaged deletes the staged input files once the task has ended.
+func cleanupStaged() {
+	if !stager.Staged() {
+		return
+	}
+	if err := stager.Cleanup(); err != nil {
+		logging.Warnf("failed to delete staged files: %v", err)
+		return
+	}
+	logging.Info("Staged files deleted")
+}
+
+// publishOutput uploads an output file to object storage and prints its
+// shareable URL on stdout. Failures only warn: the local file is kept.
+func publishOutput(path string) {
+	if !publish {
+		return
+	}
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		logging.Warnf("publish failed: %v", err)
+		return
+	}
+	logging.Infof("Publishing %s...", path)
+	start := time.Now()
+	url, err := storage.Publish(cfg, path)
+	if err != nil {
+		logging.Warnf("publish failed: %v", err)
+		return
+	}
+	timings.Measure(timing.Publish, start, 0)
+	emitter.Emit(events.Event{Type: events.ArtifactPublished, Path: path, URL: url})
+	logging.Infof("Published: %s", url)
+	fmt.Println(url)
 }
@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/doctor"
+	"github.com/llm-net/llm-api-plugin/internal/keypool"
+)
+
+// DoctorChecks returns the 'doctor' checks for Ark and the Jimeng video
+// models served through the Volcengine visual API.
+func DoctorChecks(cfg *config.Config) []doctor.Check {
+	key := doctor.Cred(cfg, "api_key", config.ServiceArk, config.FieldAPIKey, "ARK_API_KEY")
+	ak := doctor.Cred(cfg, "access_key_id", config.ServiceJimeng, config.FieldAccessKeyID, "JIMENG_ACCESS_KEY_ID")
+	sk := doctor.Cred(cfg, "secret_key", config.ServiceJimeng, config.FieldSecretAccessKey, "JIMENG_SECRET_ACCESS_KEY")
+	return []doctor.Check{
+		{
+			Name:        "Ark",
+			URL:         BaseURL,
+			Credentials: []doctor.Credential{key},
+			SetupHint:   "export ARK_API_KEY=<KEY> or run 'ark-cli config set-key'",
+			AccessHint:  "activate the Seedance models in the Ark console (模型推理 > 开通管理)",
+			Probe: func() error {
+				return keypool.Each(key.Value, "", func(k keypool.Key) error {
+					return Seedance(k.Value).ListTasks(context.Background())
+				})
+			},
+		},
+		{
+			Name:        "Jimeng video (Volcengine visual)",
+			URL:         VisualURL,
+			Credentials: []doctor.Credential{ak, sk},
+			Signed:      true,
+			SetupHint:   "export JIMENG_ACCESS_KEY_ID/JIMENG_SECRET_ACCESS_KEY or run 'ark-cli config set-keys'",
+			AccessHint:  "enable 即梦 video generation in the Volcengine visual console and grant the IAM user the visual service policy",
+			Probe: func() error {
+				return keypool.Each(ak.Value, sk.Value, func(k keypool.Key) error {
+					return Jimeng(k.Value, k.Secret).CheckSignature(context.Background(), JimengReqKey["jimeng-t2v-3-pro"])
+				})
+			},
+		},
+	}
+}
@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/dryrun"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+	"github.com/llm-net/llm-api-plugin/pkg/jimeng"
+	"github.com/volcengine/volc-sdk-golang/service/visual"
+)
+
+// JimengAPI sends the Volcengine visual requests and downloads their
+// results. Requests are signed per call, so only the transport side of
+// the stack (retry, rate limit, --record/--replay/--har) applies.
+var JimengAPI = httpclient.NewClient("jimeng", httpclient.Retry(3, time.Second), httpclient.RateLimit(200*time.Millisecond))
+
+// Jimeng returns the pkg/jimeng client of the CLIs: signing with the given
+// access keys, sending through JimengAPI and logging to the shared logger.
+func Jimeng(accessKeyID, secretAccessKey string) *jimeng.Client {
+	return jimeng.New(accessKeyID, secretAccessKey, jimeng.WithHTTPClient(JimengAPI.HTTP()), jimeng.WithLogger(logging.Logger()))
+}
+
+// JimengSubmitOpts holds all parameters for a jimeng video generation task.
+type JimengSubmitOpts = jimeng.VideoRequest
+
+// JimengSubmitDryRun returns the request SubmitVideo would send. The
+// signature is computed at send time, so only its placement is shown.
+func JimengSubmitDryRun(opts JimengSubmitOpts) dryrun.Request {
+	api := visual.ApiInfoList["CVSync2AsyncSubmitTask"]
+	return dryrun.Request{
+		Method: api.Method,
+		URL:    VisualURL + api.Path + "?" + api.Query.Encode(),
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Authorization": "HMAC-SHA256 <signature>",
+		},
+		Body: opts.Body(),
+	}
+}
+
+// VisualURL is the Volcengine visual API endpoint, probed by 'doctor'.
+const VisualURL = jimeng.DefaultEndpoint
@@ -1,17 +1,17 @@
-package main
+package provider
 
 import "github.com/llm-net/llm-api-plugin/internal/models"
 
-// modelProvider maps model name to its backend provider.
-var modelProvider = map[string]string{
+// ModelProvider maps model name to its backend provider.
+var ModelProvider = map[string]string{
 	"doubao-seedance-1-5-pro-251215": "ark",
 	"jimeng-t2v-3-pro":               "jimeng",
 	"jimeng-i2v-3-pro":               "jimeng",
 	"jimeng-i2v-startend-3-pro":      "jimeng",
 }
 
-// jimengReqKey maps jimeng model name to its API req_key.
-var jimengReqKey = map[string]string{
+// JimengReqKey maps jimeng model name to its API req_key.
+var JimengReqKey = map[string]string{
 	"jimeng-t2v-3-pro":          "jimeng_t2v_v30_pro",
 	"jimeng-i2v-3-pro":          "jimeng_ti2v_v30_pro",
 	"jimeng-i2v-startend-3-pro": "jimeng_ti2v_v30_pro",
@@ -38,7 +38,8 @@ var jimengCommonParams = map[string]models.Param{
 	},
 }
 
-var registry = &models.Registry{
+// Registry lists the models served by ark-cli.
+var Registry = &models.Registry{
 	Tool: "ark-cli",
 	Models: []models.Model{
 		{
@@ -70,6 +71,14 @@ var registry = &models.Registry{
 					Options:     []string{"true", "false"},
 					Default:     "true",
 				},
+				"image": {
+					Description: "First frame image (image-to-video): URL, local path or data URI (local images are staged via object storage, see 'config storage')",
+					Type:        "string",
+				},
+				"image-file": {
+					Description: "Alias of image",
+					Type:        "string",
+				},
 			},
 		},
 		{
@@ -84,11 +93,11 @@ var registry = &models.Registry{
 			Capabilities: []string{"image-to-video"},
 			Params: mergeParams(jimengCommonParams, map[string]models.Param{
 				"image": {
-					Description: "First frame image URL",
+					Description: "First frame image: URL, local path or data URI (local images are base64-encoded)",
 					Type:        "string",
 				},
 				"image-file": {
-					Description: "First frame image from local file (auto base64-encoded)",
+					Description: "Alias of image",
 					Type:        "string",
 				},
 			}),
@@ -99,19 +108,19 @@ var registry = &models.Registry{
 			Capabilities: []string{"image-to-video"},
 			Params: mergeParams(jimengCommonParams, map[string]models.Param{
 				"image": {
-					Description: "First frame image URL",
+					Description: "First frame image: URL, local path or data URI (local images are base64-encoded)",
 					Type:        "string",
 				},
 				"image-file": {
-					Description: "First frame image from local file (auto base64-encoded)",
+					Description: "Alias of image",
 					Type:        "string",
 				},
 				"end-image": {
-					Description: "Last frame image URL",
+					Description: "Last frame image: URL, local path or data URI (local images are base64-encoded)",
 					Type:        "string",
 				},
 				"end-image-file": {
-					Description: "Last frame image from local file (auto base64-encoded)",
+					Description: "Alias of end-image",
 					Type:        "string",
 				},
 			}),
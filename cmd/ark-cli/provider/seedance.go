@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/dryrun"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+	"github.com/llm-net/llm-api-plugin/pkg/ark"
+)
+
+const (
+	DefaultModel = ark.DefaultModel
+	baseURL      = ark.DefaultBaseURL
+	PollInterval = ark.DefaultPollInterval
+	PollTimeout  = ark.DefaultPollTimeout
+)
+
+// TaskResult is the state of a Seedance task.
+type TaskResult = ark.TaskResult
+
+// SeedanceAPI sends the Ark requests and downloads their results, retried
+// and rate limited.
+var SeedanceAPI = httpclient.NewClient("seedance", httpclient.Retry(3, time.Second), httpclient.RateLimit(200*time.Millisecond))
+
+// Seedance returns the pkg/ark client of the CLIs: authenticated with
+// apiKey, sending through SeedanceAPI and logging to the shared logger.
+func Seedance(apiKey string) *ark.Client {
+	return ark.New(apiKey, ark.WithHTTPClient(SeedanceAPI.HTTP()), ark.WithLogger(logging.Logger()))
+}
+
+// newVideoRequest maps the CLI flags to a Seedance request. A non-empty
+// imageURL is used as the first frame (image-to-video); audio "false"
+// generates a silent video.
+func newVideoRequest(model, prompt, imageURL, resolution, duration, ratio, audio string) ark.VideoRequest {
+	return ark.VideoRequest{
+		Model:      model,
+		Prompt:     prompt,
+		ImageURL:   imageURL,
+		Resolution: resolution,
+		Duration:   duration,
+		Ratio:      ratio,
+		NoAudio:    audio == "false",
+	}
+}
+
+// CreateTaskDryRun returns the request CreateTask would send.
+func CreateTaskDryRun(apiKey, model, prompt, imageURL, resolution, duration, ratio, audio string) dryrun.Request {
+	return dryrun.Request{
+		Method: http.MethodPost,
+		URL:    Seedance(apiKey).TasksURL(),
+		Headers: map[string]string{
+			"Authorization": "Bearer " + apiKey,
+			"Content-Type":  "application/json",
+		},
+		Body: newVideoRequest(model, prompt, imageURL, resolution, duration, ratio, audio).Body(),
+	}
+}
+
+// CreateTask submits a Seedance video generation task and returns the task ID.
+// A non-empty imageURL is used as the first frame (image-to-video).
+func CreateTask(ctx context.Context, apiKey, model, prompt, imageURL, resolution, duration, ratio, audio string) (string, error) {
+	return Seedance(apiKey).CreateTask(ctx, newVideoRequest(model, prompt, imageURL, resolution, duration, ratio, audio))
+}
+
+// BaseURL is the Ark API endpoint, probed by 'doctor'.
+const BaseURL = baseURL
@@ -1,43 +1,123 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/llm-net/llm-api-plugin/cmd/gemini-cli/provider"
+	"github.com/llm-net/llm-api-plugin/internal/assets"
+	"github.com/llm-net/llm-api-plugin/internal/buildinfo"
+	"github.com/llm-net/llm-api-plugin/internal/cassette"
 	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/doctor"
+	"github.com/llm-net/llm-api-plugin/internal/dryrun"
+	"github.com/llm-net/llm-api-plugin/internal/events"
+	"github.com/llm-net/llm-api-plugin/internal/input"
+	"github.com/llm-net/llm-api-plugin/internal/keypool"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+	"github.com/llm-net/llm-api-plugin/internal/notify"
+	"github.com/llm-net/llm-api-plugin/internal/output"
+	"github.com/llm-net/llm-api-plugin/internal/probe"
+	"github.com/llm-net/llm-api-plugin/internal/selfupdate"
+	"github.com/llm-net/llm-api-plugin/internal/storage"
+	"github.com/llm-net/llm-api-plugin/internal/timing"
+	"github.com/llm-net/llm-api-plugin/pkg/gemini"
 )
 
+// notifier fires completion notifications for the current generate command.
+var notifier *notify.Notifier
+
+// timings measures the phases of the current generate command; showTimings
+// (--timings) prints them and saves them next to the output.
+var (
+	timings     *timing.Timings
+	showTimings bool
+)
+
+// emitter writes the --events progress stream for agents.
+var emitter *events.Emitter
+
 func usage() {
 	fmt.Fprintf(os.Stderr, `gemini-cli - CLI for Google Gemini API
 
 Usage:
   %[1]s generate <prompt> [flags]    Generate image from text prompt
   %[1]s models [<model-name>]        List available models (JSON)
-  %[1]s config set-key <API_KEY>     Set Gemini API key
-  %[1]s config show                  Show current config
+  %[1]s doctor                       Check credentials and connectivity of each provider
+  %[1]s assets <cmd>                 Manage the asset library: reusable portraits, voices, videos (asset:<name>)
+  %[1]s probe <file.mp4>             Print duration, resolution, frame rate, codecs and audio of a video (JSON)
+  %[1]s self-update [flags]          Install the latest release, checksum-verified (--help for flags)
+  %[1]s version                      Print version and build info (also --version)
+  %[1]s config set-key [<API_KEY>]   Set Gemini API key
+  %[1]s config show [--effective]    Show current config (--effective: merged project defaults)
+  %[1]s config notify <cmd>          Manage completion notification targets
+  %[1]s config profile <cmd>         Manage named credential profiles
+  %[1]s config migrate               Move plaintext secrets to the OS keyring / encrypted file
+  %[1]s config storage <cmd>         Configure the object storage bucket for staging and publishing
+
+Global flags:
+  --profile <name>   Credential profile to use (or LLM_API_PROFILE)
+  --record <dir>     Save every HTTP request and response to a cassette directory
+  --replay <dir>     Serve HTTP responses from a cassette instead of the network
+  --har <file>       Export the HTTP traffic as a HAR file
+  --verbose          Log debug details: HTTP requests, raw provider responses (redacted)
+  --quiet            Only log warnings and errors
+  --log-format <f>   Log format: text (default) or json
+  --log-file <path>  Append logs to a file instead of stderr
 
 Flags for generate:
   --model <model>    Model name                            [default: gemini-3-pro-image-preview]
   --ratio <ratio>    Aspect ratio (e.g. 16:9, 1:1, 4:3)   [default: 1:1]
   --size <size>      Image size: 1K, 2K, 4K                [default: 2K]
-  --output <path>    Output file path                      [default: output_<timestamp>.png]
+  --reference <img>  Reference image whose character or style to keep: path, URL, data URI, - or asset:<name> (repeatable)
+  --output <path>    Output file path, or - to write the first image to stdout [default: output_<timestamp>.png]
+  --output-dir <dir> Directory for generated files         [default: current directory]
+  --output-template <tmpl>
+                     File name template, e.g. "{prompt_slug}_{index}.{ext}" (see README)
   --text-only        Only return text, no image
+  --publish          Upload images to object storage and print shareable URLs
+  --notify <target>  Notify on completion: configured target name, "desktop" or webhook URL (repeatable)
+  --dry-run          Print the request (secrets redacted) and a curl command without sending it
+  --timings          Print per-phase timings and save them to <output>.timings.json
+  --events <spec>    Emit NDJSON progress events: ndjson (stderr), ndjson:<file> or ndjson:fd:<n>
 
 Examples:
   %[1]s generate "A cat riding a bicycle in watercolor style"
   %[1]s generate "Infographic about climate change" --ratio 16:9 --size 4K
   %[1]s generate "Explain quantum computing" --text-only
+  %[1]s generate "The same character surfing at sunset" --reference character.png
   %[1]s models
   %[1]s models gemini-3-pro-image-preview
 `, filepath.Base(os.Args[0]))
 }
 
 func main() {
+	args, err := config.SelectProfile(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+	args, err = cassette.Setup(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+	args, err = logging.Setup(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+
 	if len(os.Args) < 2 {
 		usage()
 		os.Exit(1)
@@ -50,6 +130,25 @@ func main() {
 		handleGenerate()
 	case "models":
 		handleModels()
+	case "doctor":
+		handleDoctor()
+	case "probe":
+		if err := probe.Command(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "assets":
+		if err := assets.Command(os.Args[2:], input.Load); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "self-update":
+		if err := selfupdate.Command("gemini-cli", os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "version", "--version":
+		fmt.Println(buildinfo.String("gemini-cli"))
 	case "help", "--help", "-h":
 		usage()
 	default:
@@ -61,52 +160,99 @@ func main() {
 
 func handleConfig() {
 	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Usage: config set-key <KEY> | config show")
+		fmt.Fprintln(os.Stderr, "Usage: config set-key <KEY> | config show [--effective] | config notify <cmd> | config profile <cmd> | config storage <cmd> | config migrate")
 		os.Exit(1)
 	}
 	switch os.Args[2] {
 	case "set-key":
-		if len(os.Args) < 4 {
-			fmt.Fprintln(os.Stderr, "Usage: config set-key <API_KEY>")
+		value, err := config.SecretArg(os.Args, 3, "Gemini API key: ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg, err := config.LoadOrCreate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		where, err := cfg.SetSecret(config.ServiceGemini, config.FieldAPIKey, value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		cfg, _ := config.LoadOrCreate()
-		cfg.Gemini = &config.ServiceConfig{APIKey: os.Args[3]}
 		if err := config.Save(cfg); err != nil {
 			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("Gemini API key saved to %s\n", config.Path())
+		fmt.Printf("Gemini API key saved to %s\n", cfg.Location(where))
 	case "show":
-		cfg, _ := config.LoadOrCreate()
-		apiKey := config.ResolveAPIKey("GEMINI_API_KEY", cfg.Gemini)
+		cfg, err := config.LoadOrCreate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(os.Args) > 3 && os.Args[3] == "--effective" {
+			config.ShowEffective(cfg, provider.Registry)
+			fmt.Println()
+		}
+		profile, profileSource := cfg.ActiveProfile()
+		if n := cfg.PlaintextSecrets(); n > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d plaintext secret(s) in %s; run '%s config migrate' to move them to secure storage\n", n, config.Path(), filepath.Base(os.Args[0]))
+		}
+		fmt.Printf("Profile: %s (source: %s)\n", profile, profileSource)
+		apiKey, source := config.Lookup(cfg, config.ServiceGemini, config.FieldAPIKey, "GEMINI_API_KEY")
 		if apiKey == "" {
 			fmt.Println("Gemini: not configured")
 			return
 		}
-		source := "config file"
-		if os.Getenv("GEMINI_API_KEY") != "" {
-			source = "env GEMINI_API_KEY"
+		fmt.Printf("Config: %s\nGemini API Key: %s (source: %s)\n", config.Path(), keypool.Mask(apiKey), source)
+	case "notify":
+		if err := notify.ConfigCommand(os.Args[3:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		masked := apiKey
-		if len(masked) > 8 {
-			masked = masked[:4] + "..." + masked[len(masked)-4:]
+	case "profile":
+		if err := config.ProfileCommand(os.Args[3:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "migrate":
+		if err := config.MigrateCommand(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "storage":
+		if err := storage.ConfigCommand(os.Args[3:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		fmt.Printf("Config: %s\nGemini API Key: %s (source: %s)\n", config.Path(), masked, source)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown config command: %s\n", os.Args[2])
 		os.Exit(1)
 	}
 }
 
+func handleDoctor() {
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	profile, source := cfg.ActiveProfile()
+	fmt.Printf("Profile: %s (source: %s)\n\n", profile, source)
+	if doctor.Run(os.Stdout, provider.DoctorChecks(cfg)) > 0 {
+		os.Exit(1)
+	}
+}
+
 func handleModels() {
-	data, err := registry.JSON()
+	data, err := provider.Registry.JSON()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 	if len(os.Args) >= 3 {
-		m := registry.FindModel(os.Args[2])
+		m := provider.Registry.FindModel(os.Args[2])
 		if m == nil {
 			fmt.Fprintf(os.Stderr, "Unknown model: %s\n", os.Args[2])
 			os.Exit(1)
@@ -124,14 +270,35 @@ func handleGenerate() {
 		os.Exit(1)
 	}
 
+	// Project and user defaults seed the flags; explicit flags override them.
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	args := os.Args[2:]
+	model := config.ArgValue(args, "--model")
+	if model == "" {
+		model, _ = cfg.ModelFor("text-to-image", provider.Registry)
+	}
+	if model == "" {
+		model = provider.DefaultModel
+	}
+
 	var prompt string
-	model := ""
-	ratio := "1:1"
-	size := "2K"
-	output := ""
+	ratio := cfg.Param(model, "ratio", "1:1")
+	size := cfg.Param(model, "size", "2K")
+	var references []string
+	if ref := cfg.Param(model, "reference", ""); ref != "" {
+		references = append(references, ref)
+	}
+	var out output.Options
 	textOnly := false
+	eventsSpec := ""
+	var notifyTargets []string
+	publish := storage.PublishEnabled(cfg)
+	dryRun := false
 
-	args := os.Args[2:]
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
 		case "--model":
@@ -149,13 +316,47 @@ func handleGenerate() {
 			if i < len(args) {
 				size = args[i]
 			}
+		case "--reference":
+			i++
+			if i < len(args) {
+				references = append(references, args[i])
+			}
 		case "--output":
 			i++
 			if i < len(args) {
-				output = args[i]
+				out.Path = args[i]
+			}
+		case "--output-dir":
+			i++
+			if i < len(args) {
+				out.Dir = args[i]
+			}
+		case "--output-template":
+			i++
+			if i < len(args) {
+				out.Template = args[i]
 			}
 		case "--text-only":
 			textOnly = true
+		case "--publish":
+			publish = true
+		case "--no-download":
+			fmt.Fprintln(os.Stderr, "Error: --no-download is not supported: Gemini returns images inline, not as URLs")
+			os.Exit(1)
+		case "--dry-run":
+			dryRun = true
+		case "--timings":
+			showTimings = true
+		case "--events":
+			i++
+			if i < len(args) {
+				eventsSpec = args[i]
+			}
+		case "--notify":
+			i++
+			if i < len(args) {
+				notifyTargets = append(notifyTargets, args[i])
+			}
 		default:
 			if prompt == "" {
 				prompt = args[i]
@@ -170,37 +371,111 @@ func handleGenerate() {
 		os.Exit(1)
 	}
 
-	cfg, _ := config.LoadOrCreate()
-	apiKey := config.ResolveAPIKey("GEMINI_API_KEY", cfg.Gemini)
+	apiKey := config.ResolveAPIKey(cfg, config.ServiceGemini, "GEMINI_API_KEY")
+	if apiKey == "" && dryRun {
+		apiKey = dryrun.MissingKey
+	}
 	if apiKey == "" {
 		fmt.Fprintf(os.Stderr, "Error: Gemini API key not set.\n  Option 1: export GEMINI_API_KEY=<KEY>\n  Option 2: gemini-cli config set-key <KEY>\n")
 		os.Exit(1)
 	}
+	keys, err := keypool.New(cfg, config.ServiceGemini, apiKey, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	out, err = out.Defaults(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if publish && out.ToStdout() {
+		logging.Warnf("--publish ignored: the images are not saved locally")
+		publish = false
+	}
 
 	if textOnly {
 		ratio = ""
 		size = ""
 	}
 
-	modelName := model
-	if modelName == "" {
-		modelName = defaultModel
+	req := gemini.ImageRequest{Model: model, Prompt: prompt, AspectRatio: ratio, ImageSize: size}
+	var assetRefs []*input.Input
+	for _, ref := range references {
+		// Assets are uploaded once and referred to by URI; a dry run shows
+		// them inline instead of uploading.
+		if assets.IsRef(ref) && !dryRun {
+			in, err := provider.ReferenceAsset(ref)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: --reference: %v\n", err)
+				os.Exit(1)
+			}
+			assetRefs = append(assetRefs, in)
+			continue
+		}
+		data, err := provider.Reference(ref)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --reference: %v\n", err)
+			os.Exit(1)
+		}
+		req.References = append(req.References, data)
+	}
+
+	if dryRun {
+		if err := dryrun.Print(os.Stdout, provider.GenerateContentDryRun(keys.Get("").Value, req)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
-	fmt.Fprintf(os.Stderr, "Generating with model %s...\n", modelName)
 
-	resp, err := generateContent(apiKey, model, prompt, ratio, size)
+	notifier, err = notify.New(cfg, notifyTargets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	notifier.Tool, notifier.Model = "gemini-cli", model
+	timings = timing.New("gemini-cli", model)
+	notifier.Timings = timings
+	emitter, err = events.Open(eventsSpec, "gemini-cli", model)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
+	logging.Infof("Generating with model %s...", model)
+
+	start := time.Now()
+	var resp *provider.Response
+	key, err := keys.Try(func(k keypool.Key) error {
+		client := provider.Client(k.Value)
+		keyReq := req
+		var err error
+		keyReq.ReferenceFiles, err = provider.ReferenceFiles(context.Background(), client, k.ID(), assetRefs)
+		if err != nil {
+			return err
+		}
+		resp, err = client.GenerateContent(context.Background(), keyReq)
+		return err
+	})
+	timings.Key(config.ServiceGemini, key.Masked())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		failed(err)
+		os.Exit(1)
+	}
+	timings.Measure(timing.Generate, start, 0)
+
 	if len(resp.Candidates) == 0 {
 		fmt.Fprintln(os.Stderr, "Error: no candidates in response")
+		failed(errors.New("no candidates in response"))
 		os.Exit(1)
 	}
 
 	var texts []string
 	var imageCount int
+	var saved []string
 
 	for _, part := range resp.Candidates[0].Content.Parts {
 		if part.Text != "" {
@@ -208,30 +483,94 @@ func handleGenerate() {
 		}
 		if part.InlineData != nil && strings.HasPrefix(part.InlineData.MIMEType, "image/") {
 			imageCount++
+			// Standard output carries a single image
+			if out.ToStdout() && len(saved) > 0 {
+				logging.Warnf("image %d not written: --output - writes only the first image", imageCount)
+				continue
+			}
 			imgData, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error decoding image: %v\n", err)
 				continue
 			}
 
-			outPath := output
-			if outPath == "" {
-				ext := "png"
-				if strings.Contains(part.InlineData.MIMEType, "jpeg") {
-					ext = "jpg"
-				}
-				outPath = fmt.Sprintf("output_%s_%d.%s", time.Now().Format("20060102_150405"), imageCount, ext)
-			}
-
-			if err := os.WriteFile(outPath, imgData, 0644); err != nil {
+			// Each image gets a name of its own: {index} in the template,
+			// or a numeric suffix when the name is taken
+			outPath, err := output.Save(out, output.Vars{Model: model, Prompt: prompt, Index: imageCount, Ext: "png"},
+				imgData, part.InlineData.MIMEType)
+			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error saving image: %v\n", err)
 				continue
 			}
-			fmt.Fprintf(os.Stderr, "Image saved: %s (%d bytes)\n", outPath, len(imgData))
+			logging.Infof("Image saved: %s (%d bytes)", output.Describe(outPath), len(imgData))
+			emitter.Emit(events.Event{Type: events.ArtifactSaved, Path: outPath, Bytes: int64(len(imgData))})
+			saved = append(saved, outPath)
+			if publish {
+				publishOutput(cfg, outPath)
+			}
 		}
 	}
+	done(saved...)
 
 	if len(texts) > 0 {
-		fmt.Println(strings.Join(texts, "\n"))
+		// Keep the text out of an image streamed to stdout
+		if out.ToStdout() && len(saved) > 0 {
+			logging.Info(strings.Join(texts, "\n"))
+		} else {
+			fmt.Println(strings.Join(texts, "\n"))
+		}
+	}
+}
+
+// publishOutput uploads an output file to object storage and prints its
+// shareable URL on stdout. Failures only warn: the local file is kept.
+func publishOutput(cfg *config.Config, path string) {
+	logging.Infof("Publishing %s...", path)
+	start := time.Now()
+	url, err := storage.Publish(cfg, path)
+	if err != nil {
+		logging.Warnf("publish failed: %v", err)
+		return
+	}
+	timings.Measure(timing.Publish, start, 0)
+	emitter.Emit(events.Event{Type: events.ArtifactPublished, Path: path, URL: url})
+	logging.Infof("Published: %s", url)
+	fmt.Println(url)
+}
+
+// failed and done end the generate command: they report its timings and
+// fire the completion notification.
+func failed(err error) {
+	emitter.Emit(events.Event{Type: events.Error, Message: err.Error()})
+	reportTimings(notify.Classify(err))
+	notifier.Failed(err)
+}
+
+func done(outputs ...string) {
+	reportTimings(notify.Success, outputs...)
+	notifier.Done(outputs...)
+}
+
+// reportTimings prints the --timings summary, saves it next to the first
+// output and exports it to the configured metrics backends.
+func reportTimings(outcome string, outputs ...string) {
+	if showTimings {
+		timings.Summary(os.Stderr)
+		if len(outputs) > 0 && output.Local(outputs[0]) {
+			path := timing.SidecarPath(outputs[0])
+			if err := timings.Save(path); err != nil {
+				logging.Warnf("failed to save timings: %v", err)
+			} else {
+				logging.Infof("Timings saved: %s", path)
+			}
+		}
+	}
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		logging.Warnf("failed to export metrics: %v", err)
+		return
+	}
+	if err := timings.Export(cfg, outcome); err != nil {
+		logging.Warnf("failed to export metrics: %v", err)
 	}
 }
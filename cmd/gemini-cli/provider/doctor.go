@@ -0,0 +1,24 @@
+package provider
+
+import (
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/doctor"
+	"github.com/llm-net/llm-api-plugin/internal/keypool"
+)
+
+// DoctorChecks returns the 'doctor' checks for the Gemini API.
+func DoctorChecks(cfg *config.Config) []doctor.Check {
+	key := doctor.Cred(cfg, "api_key", config.ServiceGemini, config.FieldAPIKey, "GEMINI_API_KEY")
+	return []doctor.Check{{
+		Name:        "Gemini",
+		URL:         BaseURL,
+		Credentials: []doctor.Credential{key},
+		SetupHint:   "export GEMINI_API_KEY=<KEY> or run 'gemini-cli config set-key'",
+		AccessHint:  "enable the Generative Language API for the key's Google Cloud project and check the key's API restrictions",
+		Probe: func() error {
+			return keypool.Each(key.Value, "", func(k keypool.Key) error {
+				return GetModel(k.Value, DefaultModel)
+			})
+		},
+	}}
+}
@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/assets"
+	"github.com/llm-net/llm-api-plugin/internal/dryrun"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/input"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+	"github.com/llm-net/llm-api-plugin/pkg/gemini"
+)
+
+const (
+	DefaultModel = gemini.DefaultModel
+	baseURL      = gemini.DefaultBaseURL
+)
+
+// Response is the parsed generateContent response.
+type Response = gemini.Response
+
+// GenerateContentDryRun returns the request GenerateContent would send.
+func GenerateContentDryRun(apiKey string, req gemini.ImageRequest) dryrun.Request {
+	return dryrun.Request{
+		Method:  http.MethodPost,
+		URL:     gemini.New(apiKey).GenerateURL(req.Model),
+		Headers: map[string]string{"Content-Type": "application/json", "x-goog-api-key": apiKey},
+		Body:    req.Body(),
+	}
+}
+
+// API sends the Gemini requests. Generation is retried only when the quota
+// rejected it; metadata lookups also on transient errors.
+var API = httpclient.NewClient("gemini", httpclient.Retry(3, 2*time.Second))
+
+// Client returns the pkg/gemini client of the CLIs: authenticated with
+// apiKey, sending through API and logging to the shared logger.
+func Client(apiKey string) *gemini.Client {
+	return gemini.New(apiKey, gemini.WithHTTPClient(API.HTTP()), gemini.WithLogger(logging.Logger()))
+}
+
+// Reference loads a reference image for ImageRequest.References: a local
+// path, URL, data URI or "-" for stdin.
+func Reference(value string) (gemini.InlineData, error) {
+	in, err := input.Resolve(value)
+	if err != nil {
+		return gemini.InlineData{}, err
+	}
+	data, err := in.Bytes()
+	if err != nil {
+		return gemini.InlineData{}, err
+	}
+	mimeType, err := in.MIMEType()
+	if err != nil {
+		return gemini.InlineData{}, err
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return gemini.InlineData{}, fmt.Errorf("%s is not an image (%s)", in, mimeType)
+	}
+	return gemini.InlineData{MIMEType: mimeType, Data: base64.StdEncoding.EncodeToString(data)}, nil
+}
+
+// ReferenceAsset resolves an "asset:<name>" reference image. Unlike inline
+// references, assets are uploaded with the File API once per key; see
+// ReferenceFiles.
+func ReferenceAsset(value string) (*input.Input, error) {
+	in, err := input.Resolve(value)
+	if err != nil {
+		return nil, err
+	}
+	if in.Asset.Kind != "image" {
+		return nil, fmt.Errorf("%s is not an image (%s)", in, in.Asset.MIMEType)
+	}
+	return in, nil
+}
+
+// ReferenceFiles returns the File API copies of asset references for the
+// key of client, identified by account. Copies cached in the asset library
+// are reused until they expire; the others are uploaded and cached.
+func ReferenceFiles(ctx context.Context, client *gemini.Client, account string, refs []*input.Input) ([]gemini.FileData, error) {
+	lib := assets.Open()
+	var files []gemini.FileData
+	for _, in := range refs {
+		a := in.Asset
+		if h := lib.Lookup(a.Name, assets.ProviderGemini, account); h.Fresh() {
+			files = append(files, gemini.FileData{MIMEType: a.MIMEType, FileURI: h.URL})
+			continue
+		}
+		data, err := in.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		logging.Infof("Uploading %s to the Gemini File API...", in)
+		f, err := client.UploadFile(ctx, data, a.MIMEType, a.Name)
+		if err != nil {
+			return nil, fmt.Errorf("upload %s: %w", in, err)
+		}
+		h := assets.Handle{Provider: assets.ProviderGemini, Account: account, ID: f.Name, URL: f.URI}
+		if !f.ExpirationTime.IsZero() {
+			h.ExpiresAt = &f.ExpirationTime
+		}
+		if err := lib.SetHandle(a.Name, a.SHA256, h); err != nil {
+			logging.Warnf("cannot cache the upload of %s: %v", in, err)
+		}
+		files = append(files, f.FileData())
+	}
+	return files, nil
+}
+
+// BaseURL is the Gemini API endpoint, probed by 'doctor'.
+const BaseURL = baseURL
+
+// GetModel fetches a model's metadata. It is the cheapest authenticated call
+// and is used by 'doctor' to verify the API key.
+func GetModel(apiKey, model string) error {
+	return Client(apiKey).GetModel(context.Background(), model)
+}
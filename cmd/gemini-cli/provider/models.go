@@ -1,8 +1,9 @@
-package main
+package provider
 
 import "github.com/llm-net/llm-api-plugin/internal/models"
 
-var registry = &models.Registry{
+// Registry lists the models served by gemini-cli.
+var Registry = &models.Registry{
 	Tool: "gemini-cli",
 	Models: []models.Model{
 		{
@@ -22,6 +23,10 @@ var registry = &models.Registry{
 					Options:     []string{"1K", "2K", "4K"},
 					Default:     "2K",
 				},
+				"reference": {
+					Description: "Reference image whose character or style to keep: URL, local path or data URI",
+					Type:        "string",
+				},
 			},
 		},
 		{
@@ -41,6 +46,10 @@ var registry = &models.Registry{
 					Options:     []string{"1K", "2K", "4K"},
 					Default:     "2K",
 				},
+				"reference": {
+					Description: "Reference image whose character or style to keep: URL, local path or data URI",
+					Type:        "string",
+				},
 			},
 		},
 	},
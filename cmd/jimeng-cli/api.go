@@ -2,47 +2,196 @@ package main
 
 import (
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"strings"
 	"time"
-)
 
-const (
-	pollInterval = 5 * time.Second
-	pollTimeout  = 300 * time.Second
+	"github.com/llm-net/llm-api-plugin/cmd/jimeng-cli/provider"
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/dryrun"
+	"github.com/llm-net/llm-api-plugin/internal/events"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/input"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+	"github.com/llm-net/llm-api-plugin/internal/notify"
+	"github.com/llm-net/llm-api-plugin/internal/output"
+	"github.com/llm-net/llm-api-plugin/internal/probe"
+	"github.com/llm-net/llm-api-plugin/internal/redact"
+	"github.com/llm-net/llm-api-plugin/internal/storage"
+	"github.com/llm-net/llm-api-plugin/internal/timing"
 )
 
-// maskSecret masks a secret string for logging, showing only first 4 and last 4 chars.
-func maskSecret(s string) string {
-	if len(s) <= 8 {
-		return strings.Repeat("*", len(s))
+// printStatus reports an unfinished poll on stderr.
+func printStatus(status string) {
+	timings.Status(status)
+	emitter.Status(status)
+	logging.Infof("  Status: %s, waiting %v...", status, provider.PollInterval)
+}
+
+// printDryRun prints the request generate would send, for --dry-run.
+func printDryRun(req dryrun.Request) {
+	if err := dryrun.Print(os.Stdout, req); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-	return s[:4] + strings.Repeat("*", len(s)-8) + s[len(s)-4:]
 }
 
-func downloadVideo(url, outputPath string) (int64, error) {
-	resp, err := http.Get(url)
+// resolveInput resolves a media flag, preferring its legacy -file alias when
+// given, and exits on failure.
+func resolveInput(flag, value, fileValue string) *input.Input {
+	if fileValue != "" {
+		value = fileValue
+	}
+	in, err := input.Resolve(value)
 	if err != nil {
-		return 0, fmt.Errorf("download video: %w", err)
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", flag, err)
+		os.Exit(1)
 	}
-	defer resp.Body.Close()
+	return in
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
+// stageInput returns the URL of a media input, uploading it to object
+// storage unless it already is a URL, and exits on failure.
+func stageInput(in *input.Input) string {
+	if in != nil && !in.IsURL() {
+		if dryRun {
+			if err := stager.Check(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return fmt.Sprintf("<presigned URL of %s>", in)
+		}
+		logging.Infof("Staging %s to object storage...", in)
+		emitter.Emit(events.Event{Type: events.UploadStarted, Source: in.String()})
+		stager.Progress = uploadProgress("", in.String())
 	}
+	start := time.Now()
+	url, err := in.StageURL(stager)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		emitter.Emit(events.Event{Type: events.Error, Message: err.Error()})
+		cleanupStaged()
+		os.Exit(1)
+	}
+	if in != nil && !in.IsURL() {
+		timings.Measure(timing.Stage, start, 0)
+		emitter.Emit(events.Event{Type: events.UploadDone, Source: in.String(), URL: redact.URL(url)})
+	}
+	return url
+}
 
-	f, err := os.Create(outputPath)
+// uploadProgress reports the progress of an upload on stderr, at most every
+// few seconds, and as upload.progress events.
+func uploadProgress(input, source string) func(sent, total int64) {
+	print := httpclient.Throttle(2*time.Second, func(sent, total int64) {
+		logging.Infof("  Uploaded %s", httpclient.FormatProgress(sent, total))
+	})
+	return func(sent, total int64) {
+		emitter.UploadProgress(input, source, sent, total)
+		print(sent, total)
+	}
+}
+
+// cleanupStaged deletes the staged input files once the task has ended.
+func cleanupStaged() {
+	if !stager.Staged() {
+		return
+	}
+	if err := stager.Cleanup(); err != nil {
+		logging.Warnf("failed to delete staged files: %v", err)
+		return
+	}
+	logging.Info("Staged files deleted")
+}
+
+// publishOutput uploads an output file to object storage and prints its
+// shareable URL on stdout. Failures only warn: the local file is kept.
+func publishOutput(path string) {
+	if !publish {
+		return
+	}
+	cfg, err := config.LoadOrCreate()
 	if err != nil {
-		return 0, fmt.Errorf("create file: %w", err)
+		logging.Warnf("publish failed: %v", err)
+		return
 	}
-	defer f.Close()
+	logging.Infof("Publishing %s...", path)
+	start := time.Now()
+	url, err := storage.Publish(cfg, path)
+	if err != nil {
+		logging.Warnf("publish failed: %v", err)
+		return
+	}
+	timings.Measure(timing.Publish, start, 0)
+	emitter.Emit(events.Event{Type: events.ArtifactPublished, Path: path, URL: url})
+	logging.Infof("Published: %s", url)
+	fmt.Println(url)
+}
 
-	n, err := io.Copy(f, resp.Body)
+// keepRemote ends generate with --no-download: it prints the provider's
+// video URL on stdout, and its expiry on stderr, instead of downloading it.
+func keepRemote(url string) {
+	logging.Infof("Video left at the provider (%s):", output.ExpiryNote(url))
+	ev := events.Event{Type: events.ArtifactRemote, URL: url}
+	if t, ok := output.Expiry(url); ok {
+		ev.ExpiresAt = &t
+	}
+	emitter.Emit(ev)
+	fmt.Println(url)
+	done(url)
+}
+
+// verifyVideo probes the video saved at path against the request. A file
+// that is not a usable MP4 fails generate; mismatches are warnings unless
+// --strict.
+func verifyVideo(path string, want probe.Expect) {
+	if !output.Local(path) {
+		return
+	}
+	_, problems, err := probe.Verify(path, want)
+	if err == nil && strict && len(problems) > 0 {
+		err = fmt.Errorf("video does not match the request: %s", strings.Join(problems, "; "))
+	}
 	if err != nil {
-		return 0, fmt.Errorf("write file: %w", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		failed(err)
+		os.Exit(1)
 	}
+}
 
-	return n, nil
+// failed and done end the generate command: they report its timings and
+// fire the completion notification.
+func failed(err error) {
+	emitter.Emit(events.Event{Type: events.Error, Message: err.Error()})
+	reportTimings(notify.Classify(err))
+	notifier.Failed(err)
+}
+
+func done(outputs ...string) {
+	reportTimings(notify.Success, outputs...)
+	notifier.Done(outputs...)
+}
+
+// reportTimings prints the --timings summary, saves it next to the first
+// output and exports it to the configured metrics backends.
+func reportTimings(outcome string, outputs ...string) {
+	if showTimings {
+		timings.Summary(os.Stderr)
+		if len(outputs) > 0 && output.Local(outputs[0]) {
+			path := timing.SidecarPath(outputs[0])
+			if err := timings.Save(path); err != nil {
+				logging.Warnf("failed to save timings: %v", err)
+			} else {
+				logging.Infof("Timings saved: %s", path)
+			}
+		}
+	}
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		logging.Warnf("failed to export metrics: %v", err)
+		return
+	}
+	if err := timings.Export(cfg, outcome); err != nil {
+		logging.Warnf("failed to export metrics: %v", err)
+	}
 }
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -11,32 +10,106 @@ import (
 	"time"
 
 	"github.com/llm-net/llm-api-plugin/cmd/jimeng-cli/provider"
+	"github.com/llm-net/llm-api-plugin/internal/assets"
+	"github.com/llm-net/llm-api-plugin/internal/buildinfo"
+	"github.com/llm-net/llm-api-plugin/internal/cassette"
 	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/doctor"
+	"github.com/llm-net/llm-api-plugin/internal/dryrun"
+	"github.com/llm-net/llm-api-plugin/internal/events"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/input"
+	"github.com/llm-net/llm-api-plugin/internal/keypool"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+	"github.com/llm-net/llm-api-plugin/internal/notify"
+	"github.com/llm-net/llm-api-plugin/internal/output"
+	"github.com/llm-net/llm-api-plugin/internal/probe"
+	"github.com/llm-net/llm-api-plugin/internal/selfupdate"
+	"github.com/llm-net/llm-api-plugin/internal/storage"
+	"github.com/llm-net/llm-api-plugin/internal/timing"
+	"github.com/llm-net/llm-api-plugin/pkg/jimeng"
 )
 
+// notifier fires completion notifications for the current generate command.
+var notifier *notify.Notifier
+
+// stager holds the input files staged to object storage by generate, and
+// publish uploads its output there too. dryRun prints the request instead of
+// sending it; noDownload prints the provider's result URL instead of
+// downloading it. strict fails generate when the downloaded video does not
+// match the request.
+var (
+	stager     *storage.Stager
+	publish    bool
+	dryRun     bool
+	noDownload bool
+	strict     bool
+)
+
+// timings measures the phases of the current generate command; showTimings
+// (--timings) prints them and saves them next to the output.
+var (
+	timings     *timing.Timings
+	showTimings bool
+)
+
+// emitter writes the --events progress stream for agents.
+var emitter *events.Emitter
+
 func usage() {
 	fmt.Fprintf(os.Stderr, `jimeng-cli - CLI for Jimeng Video Generation APIs (即梦视频生成)
 
 Usage:
   %[1]s generate <prompt> [flags]                    Generate video from text/image prompt
   %[1]s models [<model-name>]                        List available models (JSON)
-  %[1]s config set-keys <ACCESS_KEY_ID> <SECRET_KEY> Set Jimeng access keys
-  %[1]s config show                                  Show current config
+  %[1]s doctor                                       Check credentials and connectivity of each provider
+  %[1]s assets <cmd>                                 Manage the asset library: reusable portraits, voices, videos (asset:<name>)
+  %[1]s probe <file.mp4>                             Print duration, resolution, frame rate, codecs and audio of a video (JSON)
+  %[1]s self-update [flags]                          Install the latest release, checksum-verified (--help for flags)
+  %[1]s version                                      Print version and build info (also --version)
+  %[1]s config set-keys <AK> [<SECRET_KEY>]          Set Jimeng access keys
+  %[1]s config show [--effective]                    Show current config (--effective: merged project defaults)
+  %[1]s config notify <cmd>                          Manage completion notification targets
+  %[1]s config profile <cmd>                         Manage named credential profiles
+  %[1]s config migrate                               Move plaintext secrets to the OS keyring / encrypted file
+  %[1]s config storage <cmd>                         Configure the object storage bucket for staging and publishing
+
+Global flags:
+  --profile <name>   Credential profile to use (or LLM_API_PROFILE)
+  --record <dir>     Save every HTTP request and response to a cassette directory
+  --replay <dir>     Serve HTTP responses from a cassette instead of the network
+  --har <file>       Export the HTTP traffic as a HAR file
+  --verbose          Log debug details: HTTP requests, raw provider responses (redacted)
+  --quiet            Only log warnings and errors
+  --log-format <f>   Log format: text (default) or json
+  --log-file <path>  Append logs to a file instead of stderr
 
 Flags for generate:
   --model <model>          Model name                                  [default: jimeng-action-imitation-v2]
-  --output <path>          Output file path                            [default: output_<timestamp>.mp4]
+  --output <path>          Output file path, or - to write the video to stdout [default: output_<timestamp>.mp4]
+  --output-dir <dir>       Directory for generated files               [default: current directory]
+  --output-template <tmpl> File name template, e.g. "{model}_{seed}.{ext}" (see README)
+  --no-download            Print the provider's video URL and its expiry instead of downloading it
+  --strict                 Fail when the video does not match the requested resolution or lacks the input audio
+  --notify <target>        Notify on completion: configured target name, "desktop" or webhook URL (repeatable)
+  --stage                  Upload a local image to object storage instead of inlining it as base64
+  --publish                Upload the output to object storage and print a shareable URL
+  --dry-run                Print the request (secrets redacted) and a curl command without sending it
+  --timings                Print per-phase timings and save them to <output>.timings.json
+  --events <spec>          Emit NDJSON progress events: ndjson (stderr), ndjson:<file> or ndjson:fd:<n>
+
+Media flags take a local path, http(s) URL, data URI, - for stdin or
+asset:<name> (see 'assets'). Local images are inlined as base64; local video and audio are staged via object
+storage. --image-file, --video-file and --audio-file are aliases.
 
 Flags for jimeng-action-imitation-v2:
-  --image <url>            Person image URL (required)
-  --image-file <path>      Person image from local file (auto base64-encoded)
-  --video <url>            Template video URL (required)
+  --image <input>          Person image (required)
+  --video <input>          Template video (required)
   --cut-first-second       Whether to cut the first second of result     [default: true]
 
 Flags for jimeng-omnihuman:
-  --image <url>            Portrait image URL (required)
-  --image-file <path>      Portrait image from local file (auto base64-encoded)
-  --audio <url>            Audio URL, under 60s (required)
+  --image <input>          Portrait image (required)
+  --audio <input>          Audio, under 60s (required)
   --resolution <num>       Output resolution: 720 or 1080               [default: 1080]
   --fast-mode              Enable fast mode (trades quality for speed)
   --seed <num>             Random seed (-1 for random)
@@ -44,12 +117,33 @@ Flags for jimeng-omnihuman:
 Examples:
   %[1]s generate --model jimeng-action-imitation-v2 --image https://example.com/person.jpg --video https://example.com/dance.mp4
   %[1]s generate "Hello world" --model jimeng-omnihuman --image https://example.com/portrait.jpg --audio https://example.com/speech.wav
+  %[1]s generate --model jimeng-omnihuman --image portrait.jpg --audio speech.wav --publish
+  %[1]s generate --model jimeng-omnihuman --image portrait.jpg --audio - < speech.wav
   %[1]s models
   %[1]s models jimeng-action-imitation-v2
 `, filepath.Base(os.Args[0]))
 }
 
 func main() {
+	args, err := config.SelectProfile(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+	args, err = cassette.Setup(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+	args, err = logging.Setup(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+
 	if len(os.Args) < 2 {
 		usage()
 		os.Exit(1)
@@ -62,6 +156,25 @@ func main() {
 		handleGenerate()
 	case "models":
 		handleModels()
+	case "doctor":
+		handleDoctor()
+	case "probe":
+		if err := probe.Command(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "assets":
+		if err := assets.Command(os.Args[2:], input.Load); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "self-update":
+		if err := selfupdate.Command("jimeng-cli", os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "version", "--version":
+		fmt.Println(buildinfo.String("jimeng-cli"))
 	case "help", "--help", "-h":
 		usage()
 	default:
@@ -73,57 +186,110 @@ func main() {
 
 func handleConfig() {
 	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Usage: config set-keys <ACCESS_KEY_ID> <SECRET_KEY> | config show")
+		fmt.Fprintln(os.Stderr, "Usage: config set-keys <ACCESS_KEY_ID> [<SECRET_KEY>] | config show [--effective] | config notify <cmd> | config profile <cmd> | config storage <cmd> | config migrate")
 		os.Exit(1)
 	}
 	switch os.Args[2] {
 	case "set-keys":
-		if len(os.Args) < 5 {
-			fmt.Fprintln(os.Stderr, "Usage: config set-keys <ACCESS_KEY_ID> <SECRET_ACCESS_KEY>")
+		if len(os.Args) < 4 {
+			fmt.Fprintln(os.Stderr, "Usage: config set-keys <ACCESS_KEY_ID> [<SECRET_ACCESS_KEY>]")
 			os.Exit(1)
 		}
-		cfg, _ := config.LoadOrCreate()
-		cfg.Jimeng = &config.ServiceConfig{
-			AccessKeyID:    os.Args[3],
-			SecretAccessKey: os.Args[4],
+		sk, err := config.SecretArg(os.Args, 4, "Jimeng SecretAccessKey: ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg, err := config.LoadOrCreate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := cfg.SetSecret(config.ServiceJimeng, config.FieldAccessKeyID, os.Args[3]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		where, err := cfg.SetSecret(config.ServiceJimeng, config.FieldSecretAccessKey, sk)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
 		if err := config.Save(cfg); err != nil {
 			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("Jimeng access keys saved to %s\n", config.Path())
+		fmt.Printf("Jimeng access keys saved to %s\n", cfg.Location(where))
 	case "show":
-		cfg, _ := config.LoadOrCreate()
-		ak, sk := config.ResolveAccessKeys("JIMENG_ACCESS_KEY_ID", "JIMENG_SECRET_ACCESS_KEY", cfg.Jimeng)
+		cfg, err := config.LoadOrCreate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(os.Args) > 3 && os.Args[3] == "--effective" {
+			config.ShowEffective(cfg, provider.Registry)
+			fmt.Println()
+		}
+		profile, profileSource := cfg.ActiveProfile()
+		if n := cfg.PlaintextSecrets(); n > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d plaintext secret(s) in %s; run '%s config migrate' to move them to secure storage\n", n, config.Path(), filepath.Base(os.Args[0]))
+		}
+		fmt.Printf("Profile: %s (source: %s)\n", profile, profileSource)
+		ak, akSource := config.Lookup(cfg, config.ServiceJimeng, config.FieldAccessKeyID, "JIMENG_ACCESS_KEY_ID")
+		sk, skSource := config.Lookup(cfg, config.ServiceJimeng, config.FieldSecretAccessKey, "JIMENG_SECRET_ACCESS_KEY")
 		if ak == "" && sk == "" {
 			fmt.Println("Jimeng: not configured")
 			return
 		}
-		akSource := "config file"
-		if os.Getenv("JIMENG_ACCESS_KEY_ID") != "" {
-			akSource = "env JIMENG_ACCESS_KEY_ID"
+		fmt.Printf("Config: %s\n", config.Path())
+		fmt.Printf("AccessKeyID: %s (source: %s)\n", keypool.Mask(ak), akSource)
+		fmt.Printf("SecretAccessKey: %s (source: %s)\n", keypool.Mask(sk), skSource)
+	case "notify":
+		if err := notify.ConfigCommand(os.Args[3:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		skSource := "config file"
-		if os.Getenv("JIMENG_SECRET_ACCESS_KEY") != "" {
-			skSource = "env JIMENG_SECRET_ACCESS_KEY"
+	case "profile":
+		if err := config.ProfileCommand(os.Args[3:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "migrate":
+		if err := config.MigrateCommand(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "storage":
+		if err := storage.ConfigCommand(os.Args[3:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		fmt.Printf("Config: %s\n", config.Path())
-		fmt.Printf("AccessKeyID: %s (source: %s)\n", maskSecret(ak), akSource)
-		fmt.Printf("SecretAccessKey: %s (source: %s)\n", maskSecret(sk), skSource)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown config command: %s\n", os.Args[2])
 		os.Exit(1)
 	}
 }
 
+func handleDoctor() {
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	profile, source := cfg.ActiveProfile()
+	fmt.Printf("Profile: %s (source: %s)\n\n", profile, source)
+	if doctor.Run(os.Stdout, provider.DoctorChecks(cfg)) > 0 {
+		os.Exit(1)
+	}
+}
+
 func handleModels() {
-	data, err := registry.JSON()
+	data, err := provider.Registry.JSON()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 	if len(os.Args) >= 3 {
-		m := registry.FindModel(os.Args[2])
+		m := provider.Registry.FindModel(os.Args[2])
 		if m == nil {
 			fmt.Fprintf(os.Stderr, "Unknown model: %s\n", os.Args[2])
 			os.Exit(1)
@@ -136,21 +302,47 @@ func handleModels() {
 }
 
 func handleGenerate() {
+	// Project and user defaults seed the flags; explicit flags override them.
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	args := os.Args[2:]
+	modelName := config.ArgValue(args, "--model")
+	if modelName == "" {
+		capability := "image+video-to-video"
+		if config.ArgValue(args, "--audio") != "" || config.ArgValue(args, "--audio-file") != "" {
+			capability = "image+audio-to-video"
+		}
+		modelName, _ = cfg.ModelFor(capability, provider.Registry)
+	}
+	if modelName == "" {
+		modelName = provider.DefaultModel
+	}
+
 	// Parse all flags
 	var prompt string
-	modelName := defaultModel
-	seed := 0
+	seed, _ := strconv.Atoi(cfg.Param(modelName, "seed", "0"))
 	image := ""
 	imageFile := ""
 	video := ""
+	videoFile := ""
 	audio := ""
-	resolution := 0
-	fastMode := false
+	audioFile := ""
+	stage := false
+	publish = storage.PublishEnabled(cfg)
+	resolution, _ := strconv.Atoi(cfg.Param(modelName, "resolution", "0"))
+	fastMode := cfg.Param(modelName, "fast-mode", "false") == "true"
 	cutFirstSecond := true
 	cutFirstSecondSet := false
-	output := ""
+	if v, err := strconv.ParseBool(cfg.Param(modelName, "cut-first-second", "")); err == nil {
+		cutFirstSecond, cutFirstSecondSet = v, true
+	}
+	var out output.Options
+	eventsSpec := ""
+	var notifyTargets []string
 
-	args := os.Args[2:]
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
 		case "--model":
@@ -180,11 +372,38 @@ func handleGenerate() {
 			if i < len(args) {
 				video = args[i]
 			}
+		case "--video-file":
+			i++
+			if i < len(args) {
+				videoFile = args[i]
+			}
 		case "--audio":
 			i++
 			if i < len(args) {
 				audio = args[i]
 			}
+		case "--audio-file":
+			i++
+			if i < len(args) {
+				audioFile = args[i]
+			}
+		case "--stage":
+			stage = true
+		case "--publish":
+			publish = true
+		case "--no-download":
+			noDownload = true
+		case "--strict":
+			strict = true
+		case "--dry-run":
+			dryRun = true
+		case "--timings":
+			showTimings = true
+		case "--events":
+			i++
+			if i < len(args) {
+				eventsSpec = args[i]
+			}
 		case "--resolution":
 			i++
 			if i < len(args) {
@@ -205,7 +424,22 @@ func handleGenerate() {
 		case "--output":
 			i++
 			if i < len(args) {
-				output = args[i]
+				out.Path = args[i]
+			}
+		case "--output-dir":
+			i++
+			if i < len(args) {
+				out.Dir = args[i]
+			}
+		case "--output-template":
+			i++
+			if i < len(args) {
+				out.Template = args[i]
+			}
+		case "--notify":
+			i++
+			if i < len(args) {
+				notifyTargets = append(notifyTargets, args[i])
 			}
 		default:
 			if prompt == "" {
@@ -216,63 +450,98 @@ func handleGenerate() {
 		}
 	}
 
-	// Read image file and base64-encode it
-	var imageBase64 string
-	if imageFile != "" {
-		data, err := os.ReadFile(imageFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading image file %s: %v\n", imageFile, err)
-			os.Exit(1)
-		}
-		imageBase64 = base64.StdEncoding.EncodeToString(data)
-	}
+	// Resolve inputs: local path, URL, data URI or - for stdin
+	imageIn := resolveInput("--image", image, imageFile)
+	videoIn := resolveInput("--video", video, videoFile)
+	audioIn := resolveInput("--audio", audio, audioFile)
 
 	// Validate model name
-	providerKey, ok := modelProvider[modelName]
+	providerKey, ok := provider.ModelProvider[modelName]
 	if !ok {
 		fmt.Fprintf(os.Stderr, "Error: unknown model %q\nRun `jimeng-cli models` to list available models.\n", modelName)
 		os.Exit(1)
 	}
 
-	// Default output path
-	if output == "" {
-		output = fmt.Sprintf("output_%s.mp4", time.Now().Format("20060102_150405"))
+	// Output directory and file name template
+	out, err = out.Defaults(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if publish && (noDownload || out.ToStdout()) {
+		logging.Warnf("--publish ignored: the video is not saved locally")
+		publish = false
 	}
 
 	// Resolve credentials (shared by all models)
-	cfg, _ := config.LoadOrCreate()
-	ak, sk := config.ResolveAccessKeys("JIMENG_ACCESS_KEY_ID", "JIMENG_SECRET_ACCESS_KEY", cfg.Jimeng)
+	ak, sk := config.ResolveAccessKeys(cfg, config.ServiceJimeng, "JIMENG_ACCESS_KEY_ID", "JIMENG_SECRET_ACCESS_KEY")
+	if (ak == "" || sk == "") && dryRun {
+		ak, sk = dryrun.MissingKey, dryrun.MissingKey
+	}
 	if ak == "" || sk == "" {
 		fmt.Fprintf(os.Stderr, "Error: Jimeng access keys not set.\n"+
 			"  Option 1: export JIMENG_ACCESS_KEY_ID=<AK> && export JIMENG_SECRET_ACCESS_KEY=<SK>\n"+
 			"  Option 2: jimeng-cli config set-keys <ACCESS_KEY_ID> <SECRET_ACCESS_KEY>\n")
 		os.Exit(1)
 	}
+	keys, err := keypool.New(cfg, config.ServiceJimeng, ak, sk)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	notifier, err = notify.New(cfg, notifyTargets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	notifier.Tool, notifier.Model = "jimeng-cli", modelName
+	timings = timing.New("jimeng-cli", modelName)
+	notifier.Timings = timings
+	emitter, err = events.Open(eventsSpec, "jimeng-cli", modelName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The API takes the image as a URL or inline base64, but video and audio
+	// only as URLs, so anything else is staged through object storage
+	stager = storage.NewStager(cfg)
+	var imageBase64 *httpclient.Base64
+	if stage {
+		image = stageInput(imageIn)
+	} else if image, imageBase64, err = imageIn.URLOrBase64(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading image %s: %v\n", imageIn, err)
+		os.Exit(1)
+	}
+	video = stageInput(videoIn)
+	audio = stageInput(audioIn)
 
 	// Dispatch to model-specific function
 	switch providerKey {
 	case "action-imitation-v2":
-		generateWithActionImitationV2(ak, sk, image, imageBase64, video, cutFirstSecond, cutFirstSecondSet, output)
+		generateWithActionImitationV2(keys, image, imageBase64, video, cutFirstSecond, cutFirstSecondSet, out)
 	case "omnihuman":
-		generateWithOmniHuman(ak, sk, prompt, image, imageBase64, audio, resolution, fastMode, seed, output)
+		generateWithOmniHuman(keys, prompt, image, imageBase64, audio, resolution, fastMode, seed, out)
 	}
 }
 
 // generateWithActionImitationV2 handles jimeng-action-imitation-v2 model.
-func generateWithActionImitationV2(ak, sk, image, imageBase64, video string, cutFirstSecond, cutFirstSecondSet bool, output string) {
-	if image == "" && imageBase64 == "" {
-		fmt.Fprintln(os.Stderr, "Error: --image or --image-file is required for jimeng-action-imitation-v2")
+func generateWithActionImitationV2(keys *keypool.Pool, image string, imageBase64 *httpclient.Base64, video string, cutFirstSecond, cutFirstSecondSet bool, out output.Options) {
+	if image == "" && imageBase64 == nil {
+		fmt.Fprintln(os.Stderr, "Error: --image is required for jimeng-action-imitation-v2")
+		cleanupStaged()
 		os.Exit(1)
 	}
 	if video == "" {
 		fmt.Fprintln(os.Stderr, "Error: --video is required for jimeng-action-imitation-v2")
+		cleanupStaged()
 		os.Exit(1)
 	}
 
-	p := provider.NewJimengActionImitationV2Provider(ak, sk)
 	ctx := context.Background()
 
-	req := &provider.ActionImitationV2Request{
+	req := provider.ActionImitationV2Request{
 		ImageURL:    image,
 		ImageBase64: imageBase64,
 		VideoURL:    video,
@@ -280,69 +549,90 @@ func generateWithActionImitationV2(ak, sk, image, imageBase64, video string, cut
 	if cutFirstSecondSet {
 		req.CutFirstSecond = &cutFirstSecond
 	}
+	if imageBase64 != nil {
+		req.Progress = uploadProgress("image", "")
+	}
 
-	fmt.Fprintf(os.Stderr, "Submitting action imitation task...\n")
+	if dryRun {
+		printDryRun(provider.ActionImitationV2DryRun(req))
+		return
+	}
 
-	submitResult, err := p.SubmitTask(ctx, req)
+	logging.Info("Submitting action imitation task...")
+
+	// The task belongs to the key pair that submitted it, so client keeps
+	// polling with that pair
+	start := time.Now()
+	var client *jimeng.Client
+	var taskID string
+	key, err := keys.Try(func(k keypool.Key) error {
+		var err error
+		client = provider.Client(k.Value, k.Secret)
+		taskID, err = client.SubmitActionImitation(ctx, req)
+		return err
+	})
+	timings.Key(config.ServiceJimeng, key.Masked())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error submitting task: %v\n", err)
+		cleanupStaged()
+		failed(err)
+		os.Exit(1)
+	}
+	timings.Measure(timing.Submit, start, 0)
+	timings.Wait()
+	logging.Infof("Task created: %s", taskID)
+	notifier.TaskID = taskID
+	emitter.Submitted(taskID)
+	logging.Infof("Polling for result (timeout %v)...", provider.PollTimeout)
+
+	result, err := client.WaitActionImitation(ctx, taskID, printStatus)
+	timings.EndWait()
+	cleanupStaged()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		failed(err)
 		os.Exit(1)
 	}
-	fmt.Fprintf(os.Stderr, "Task created: %s\n", submitResult.TaskID)
-	fmt.Fprintf(os.Stderr, "Polling for result (timeout %v)...\n", pollTimeout)
-
-	deadline := time.Now().Add(pollTimeout)
-	for {
-		qr, err := p.QueryTask(ctx, submitResult.TaskID)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-		switch qr.Status {
-		case "done":
-			if qr.VideoURL == "" {
-				fmt.Fprintln(os.Stderr, "Error: task succeeded but no video URL in response")
-				os.Exit(1)
-			}
-			fmt.Fprintf(os.Stderr, "Downloading video...\n")
-			size, err := downloadVideo(qr.VideoURL, output)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
-			}
-			fmt.Fprintf(os.Stderr, "Video saved: %s (%d bytes)\n", output, size)
-			return
-		case "failed":
-			fmt.Fprintf(os.Stderr, "Error: task failed: %s\n", qr.Message)
-			os.Exit(1)
-		}
 
-		if time.Now().After(deadline) {
-			fmt.Fprintf(os.Stderr, "Error: timeout after %v, task still in status: %s\n", pollTimeout, qr.Status)
-			os.Exit(1)
-		}
+	if noDownload {
+		keepRemote(result.VideoURL)
+		return
+	}
 
-		fmt.Fprintf(os.Stderr, "  Status: %s, waiting %v...\n", qr.Status, pollInterval)
-		time.Sleep(pollInterval)
+	logging.Info("Downloading video...")
+	start = time.Now()
+	path, size, err := output.Download(ctx, provider.API, result.VideoURL, out,
+		output.Vars{Model: "jimeng-action-imitation-v2", TaskID: taskID, Ext: "mp4"}, emitter.Progress)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		failed(err)
+		os.Exit(1)
 	}
+	timings.Measure(timing.Download, start, size)
+	logging.Infof("Video saved: %s (%d bytes)", output.Describe(path), size)
+	// The clip follows the template video, so only its integrity is checked
+	verifyVideo(path, probe.Expect{})
+	emitter.Emit(events.Event{Type: events.ArtifactSaved, Path: path, Bytes: size})
+	publishOutput(path)
+	done(path)
 }
 
 // generateWithOmniHuman handles jimeng-omnihuman model.
-func generateWithOmniHuman(ak, sk, prompt, image, imageBase64, audio string, resolution int, fastMode bool, seed int, output string) {
-	if image == "" && imageBase64 == "" {
-		fmt.Fprintln(os.Stderr, "Error: --image or --image-file is required for jimeng-omnihuman")
+func generateWithOmniHuman(keys *keypool.Pool, prompt, image string, imageBase64 *httpclient.Base64, audio string, resolution int, fastMode bool, seed int, out output.Options) {
+	if image == "" && imageBase64 == nil {
+		fmt.Fprintln(os.Stderr, "Error: --image is required for jimeng-omnihuman")
+		cleanupStaged()
 		os.Exit(1)
 	}
 	if audio == "" {
 		fmt.Fprintln(os.Stderr, "Error: --audio is required for jimeng-omnihuman")
+		cleanupStaged()
 		os.Exit(1)
 	}
 
-	p := provider.NewJimengOmniHumanProvider(ak, sk)
 	ctx := context.Background()
 
-	req := &provider.OmniHumanRequest{
+	req := provider.OmniHumanRequest{
 		ImageURL:         image,
 		ImageBase64:      imageBase64,
 		AudioURL:         audio,
@@ -351,50 +641,73 @@ func generateWithOmniHuman(ak, sk, prompt, image, imageBase64, audio string, res
 		OutputResolution: resolution,
 		FastMode:         fastMode,
 	}
+	if imageBase64 != nil {
+		req.Progress = uploadProgress("image", "")
+	}
 
-	fmt.Fprintf(os.Stderr, "Submitting OmniHuman task...\n")
+	if dryRun {
+		printDryRun(provider.OmniHumanDryRun(req))
+		return
+	}
 
-	submitResult, err := p.SubmitTask(ctx, req)
+	logging.Info("Submitting OmniHuman task...")
+
+	// The task belongs to the key pair that submitted it, so client keeps
+	// polling with that pair
+	start := time.Now()
+	var client *jimeng.Client
+	var taskID string
+	key, err := keys.Try(func(k keypool.Key) error {
+		var err error
+		client = provider.Client(k.Value, k.Secret)
+		taskID, err = client.SubmitOmniHuman(ctx, req)
+		return err
+	})
+	timings.Key(config.ServiceJimeng, key.Masked())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error submitting task: %v\n", err)
+		cleanupStaged()
+		failed(err)
+		os.Exit(1)
+	}
+	timings.Measure(timing.Submit, start, 0)
+	timings.Wait()
+	logging.Infof("Task created: %s", taskID)
+	notifier.TaskID = taskID
+	emitter.Submitted(taskID)
+	logging.Infof("Polling for result (timeout %v)...", provider.PollTimeout)
+
+	result, err := client.WaitOmniHuman(ctx, taskID, printStatus)
+	timings.EndWait()
+	cleanupStaged()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		failed(err)
 		os.Exit(1)
 	}
-	fmt.Fprintf(os.Stderr, "Task created: %s\n", submitResult.TaskID)
-	fmt.Fprintf(os.Stderr, "Polling for result (timeout %v)...\n", pollTimeout)
-
-	deadline := time.Now().Add(pollTimeout)
-	for {
-		qr, err := p.QueryTask(ctx, submitResult.TaskID)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-		switch qr.Status {
-		case "done":
-			if qr.VideoURL == "" {
-				fmt.Fprintln(os.Stderr, "Error: task succeeded but no video URL in response")
-				os.Exit(1)
-			}
-			fmt.Fprintf(os.Stderr, "Downloading video...\n")
-			size, err := downloadVideo(qr.VideoURL, output)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
-			}
-			fmt.Fprintf(os.Stderr, "Video saved: %s (%d bytes)\n", output, size)
-			return
-		case "failed":
-			fmt.Fprintf(os.Stderr, "Error: task failed: %s\n", qr.Message)
-			os.Exit(1)
-		}
 
-		if time.Now().After(deadline) {
-			fmt.Fprintf(os.Stderr, "Error: timeout after %v, task still in status: %s\n", pollTimeout, qr.Status)
-			os.Exit(1)
-		}
+	if noDownload {
+		keepRemote(result.VideoURL)
+		return
+	}
 
-		fmt.Fprintf(os.Stderr, "  Status: %s, waiting %v...\n", qr.Status, pollInterval)
-		time.Sleep(pollInterval)
+	logging.Info("Downloading video...")
+	start = time.Now()
+	path, size, err := output.Download(ctx, provider.API, result.VideoURL, out,
+		output.Vars{Model: "jimeng-omnihuman", Prompt: prompt, TaskID: taskID, Seed: output.Seed(seed), Ext: "mp4"}, emitter.Progress)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		failed(err)
+		os.Exit(1)
+	}
+	timings.Measure(timing.Download, start, size)
+	logging.Infof("Video saved: %s (%d bytes)", output.Describe(path), size)
+	want := probe.Expect{Audio: probe.Bool(true)}
+	if resolution > 0 {
+		want.Resolution = strconv.Itoa(resolution)
 	}
+	verifyVideo(path, want)
+	emitter.Emit(events.Event{Type: events.ArtifactSaved, Path: path, Bytes: size})
+	publishOutput(path)
+	done(path)
 }
@@ -0,0 +1,16 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/llm-net/llm-api-plugin/pkg/jimeng"
+)
+
+// VisualURL 火山引擎视觉服务地址，供 doctor 探测
+const VisualURL = jimeng.DefaultEndpoint
+
+// CheckSignature 用给定密钥查询一个不存在的 OmniHuman 任务，供 doctor 使用
+// 只要返回业务响应即说明密钥与请求签名有效；code=50400 表示账号无权使用该服务
+func CheckSignature(accessKeyID, secretAccessKey string) error {
+	return Client(accessKeyID, secretAccessKey).CheckSignature(context.Background(), jimeng.ReqKeyOmniHuman)
+}
@@ -0,0 +1,26 @@
+package provider
+
+import (
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/doctor"
+	"github.com/llm-net/llm-api-plugin/internal/keypool"
+)
+
+// DoctorChecks 返回 doctor 对火山引擎视觉服务（OmniHuman 等）的检查项
+func DoctorChecks(cfg *config.Config) []doctor.Check {
+	ak := doctor.Cred(cfg, "access_key_id", config.ServiceJimeng, config.FieldAccessKeyID, "JIMENG_ACCESS_KEY_ID")
+	sk := doctor.Cred(cfg, "secret_key", config.ServiceJimeng, config.FieldSecretAccessKey, "JIMENG_SECRET_ACCESS_KEY")
+	return []doctor.Check{{
+		Name:        "Jimeng OmniHuman (Volcengine visual)",
+		URL:         VisualURL,
+		Credentials: []doctor.Credential{ak, sk},
+		Signed:      true,
+		SetupHint:   "export JIMENG_ACCESS_KEY_ID/JIMENG_SECRET_ACCESS_KEY or run 'jimeng-cli config set-keys'",
+		AccessHint:  "enable OmniHuman and 动作模仿 in the Volcengine visual console and grant the IAM user the visual service policy",
+		Probe: func() error {
+			return keypool.Each(ak.Value, sk.Value, func(k keypool.Key) error {
+				return CheckSignature(k.Value, k.Secret)
+			})
+		},
+	}}
+}
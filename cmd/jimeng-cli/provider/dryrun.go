@@ -0,0 +1,31 @@
+package provider
+
+import (
+	"github.com/llm-net/llm-api-plugin/internal/dryrun"
+	"github.com/volcengine/volc-sdk-golang/service/visual"
+)
+
+// volcDryRun 构造视觉服务接口 api 的请求描述，供 --dry-run 打印
+// 签名在发送时才计算，这里只标出其位置
+func volcDryRun(api string, body map[string]interface{}) dryrun.Request {
+	info := visual.ApiInfoList[api]
+	return dryrun.Request{
+		Method: info.Method,
+		URL:    VisualURL + info.Path + "?" + info.Query.Encode(),
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Authorization": "HMAC-SHA256 <signature>",
+		},
+		Body: body,
+	}
+}
+
+// OmniHumanDryRun 返回提交 OmniHuman 任务将发送的请求，不访问网络
+func OmniHumanDryRun(req OmniHumanRequest) dryrun.Request {
+	return volcDryRun("CVSubmitTask", req.Body())
+}
+
+// ActionImitationV2DryRun 返回提交动作模仿2.0任务将发送的请求，不访问网络
+func ActionImitationV2DryRun(req ActionImitationV2Request) dryrun.Request {
+	return volcDryRun("CVSync2AsyncSubmitTask", req.Body())
+}
@@ -1,16 +1,18 @@
-package main
+package provider
 
 import "github.com/llm-net/llm-api-plugin/internal/models"
 
-const defaultModel = "jimeng-action-imitation-v2"
+// DefaultModel is the model used when --model is not given.
+const DefaultModel = "jimeng-action-imitation-v2"
 
-// modelProvider maps model name to a dispatch key used in handleGenerate.
-var modelProvider = map[string]string{
+// ModelProvider maps model name to a dispatch key used in handleGenerate.
+var ModelProvider = map[string]string{
 	"jimeng-action-imitation-v2": "action-imitation-v2",
 	"jimeng-omnihuman":           "omnihuman",
 }
 
-var registry = &models.Registry{
+// Registry lists the models served by jimeng-cli.
+var Registry = &models.Registry{
 	Tool: "jimeng-cli",
 	Models: []models.Model{
 		{
@@ -19,17 +21,20 @@ var registry = &models.Registry{
 			Capabilities: []string{"image+video-to-video"},
 			Params: map[string]models.Param{
 				"image": {
-					Description: "Person image URL",
+					Description: "Person image: URL, local path or data URI (local images are base64-encoded)",
 					Type:        "string",
 				},
 				"image-file": {
-					Description: "Person image from local file (auto base64-encoded)",
+					Description: "Alias of image",
 					Type:        "string",
 				},
 				"video": {
-					Description: "Template video URL with actions to imitate (required)",
+					Description: "Template video with actions to imitate: URL, local path or data URI (local videos are staged via object storage, see 'config storage')",
+					Type:        "string",
+				},
+				"video-file": {
+					Description: "Alias of video",
 					Type:        "string",
-					Required:    true,
 				},
 				"cut-first-second": {
 					Description: "Whether to cut the first second of result video",
@@ -44,17 +49,20 @@ var registry = &models.Registry{
 			Capabilities: []string{"image+audio-to-video"},
 			Params: map[string]models.Param{
 				"image": {
-					Description: "Portrait image URL",
+					Description: "Portrait image: URL, local path or data URI (local images are base64-encoded)",
 					Type:        "string",
 				},
 				"image-file": {
-					Description: "Portrait image from local file (auto base64-encoded)",
+					Description: "Alias of image",
 					Type:        "string",
 				},
 				"audio": {
-					Description: "Audio URL, must be under 60 seconds (required)",
+					Description: "Audio under 60 seconds: URL, local path or data URI (local audio is staged via object storage, see 'config storage')",
+					Type:        "string",
+				},
+				"audio-file": {
+					Description: "Alias of audio",
 					Type:        "string",
-					Required:    true,
 				},
 				"resolution": {
 					Description: "Output video resolution",
@@ -0,0 +1,9 @@
+package provider
+
+import "github.com/llm-net/llm-api-plugin/pkg/jimeng"
+
+// 轮询间隔与超时，与 pkg/jimeng 的默认值一致
+const (
+	PollInterval = jimeng.DefaultPollInterval
+	PollTimeout  = jimeng.DefaultPollTimeout
+)
@@ -0,0 +1,24 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+	"github.com/llm-net/llm-api-plugin/pkg/jimeng"
+)
+
+// API 发送视觉服务请求并下载生成结果
+// 请求在发送时逐个签名，这里只提供重试、限速和 --record/--replay/--har 等传输层中间件
+var API = httpclient.NewClient("jimeng", httpclient.Retry(3, time.Second), httpclient.RateLimit(200*time.Millisecond))
+
+// Client 返回 CLI 使用的 pkg/jimeng 客户端：以给定密钥签名，请求经由 API 发送，日志写入共享 logger
+func Client(accessKeyID, secretAccessKey string) *jimeng.Client {
+	return jimeng.New(accessKeyID, secretAccessKey, jimeng.WithHTTPClient(API.HTTP()), jimeng.WithLogger(logging.Logger()))
+}
+
+// OmniHumanRequest OmniHuman1.5 请求参数
+type OmniHumanRequest = jimeng.OmniHumanRequest
+
+// ActionImitationV2Request 动作模仿2.0请求参数
+type ActionImitationV2Request = jimeng.ActionImitationRequest
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/notify"
+)
+
+// Job states.
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// maxJobEvents caps the event history persisted with each job.
+const maxJobEvents = 200
+
+// jobEvent is one entry of a job's event stream.
+type jobEvent struct {
+	Seq     int       `json:"seq"`
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"` // state, submitted, progress
+	State   string    `json:"state,omitempty"`
+	TaskID  string    `json:"task_id,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// job is a queued or executed generateRequest. Jobs are persisted as one JSON
+// file each so that a restarted server can resume them.
+type job struct {
+	ID         string          `json:"id"`
+	Request    generateRequest `json:"request"`
+	Provider   string          `json:"provider"`
+	State      string          `json:"state"`
+	TaskID     string          `json:"task_id,omitempty"`
+	KeyID      string          `json:"key_id,omitempty"`
+	Staged     []string        `json:"staged,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Result     *generateResult `json:"result,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	Events     []jobEvent      `json:"events,omitempty"`
+}
+
+func (j *job) terminal() bool {
+	return j.State == jobSucceeded || j.State == jobFailed || j.State == jobCancelled
+}
+
+// jobQueue runs jobs on a bounded worker pool with a per-provider limit and
+// keeps every job's state in dir.
+type jobQueue struct {
+	dir         string
+	workers     int
+	concurrency map[string]int // per provider (CLI name); missing entries use defaultConc
+	defaultConc int
+	notify      []string // notification targets added to every job
+
+	mu       sync.Mutex
+	jobs     map[string]*job
+	pending  []string // queued job IDs in submission order
+	active   map[string]int
+	running  int
+	cancels  map[string]context.CancelFunc
+	aborted  map[string]bool // running jobs cancelled by a client
+	watchers map[string][]chan jobEvent
+	wake     chan struct{}
+	inflight sync.WaitGroup
+}
+
+func newJobQueue(dir string, workers, defaultConc int, concurrency map[string]int, notifyTargets []string) (*jobQueue, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "jobs"), 0700); err != nil {
+		return nil, err
+	}
+	q := &jobQueue{
+		dir:         dir,
+		workers:     workers,
+		concurrency: concurrency,
+		defaultConc: defaultConc,
+		notify:      notifyTargets,
+		jobs:        map[string]*job{},
+		active:      map[string]int{},
+		cancels:     map[string]context.CancelFunc{},
+		aborted:     map[string]bool{},
+		watchers:    map[string][]chan jobEvent{},
+		wake:        make(chan struct{}, 1),
+	}
+	if err := q.recover(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// recover loads persisted jobs. Jobs that were running when the server
+// stopped are queued again; those with a task ID resume polling it instead of
+// submitting a new task.
+func (q *jobQueue) recover() error {
+	files, err := filepath.Glob(filepath.Join(q.dir, "jobs", "*.json"))
+	if err != nil {
+		return err
+	}
+	var resumed []*job
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		var j job
+		if err := json.Unmarshal(data, &j); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping unreadable job %s: %v\n", f, err)
+			continue
+		}
+		q.jobs[j.ID] = &j
+		if j.State == jobQueued || j.State == jobRunning {
+			resumed = append(resumed, &j)
+		}
+	}
+	sort.Slice(resumed, func(a, b int) bool { return resumed[a].CreatedAt.Before(resumed[b].CreatedAt) })
+	for _, j := range resumed {
+		if j.State == jobRunning {
+			msg := "server restarted, resubmitting"
+			if j.TaskID != "" {
+				msg = "server restarted, resuming task " + j.TaskID
+			}
+			j.State = jobQueued
+			q.addEvent(j, jobEvent{Type: "state", State: jobQueued, Message: msg})
+			q.save(j)
+		}
+		q.pending = append(q.pending, j.ID)
+	}
+	if len(resumed) > 0 {
+		fmt.Fprintf(os.Stderr, "Recovered %d unfinished job(s)\n", len(resumed))
+	}
+	return nil
+}
+
+// submit validates req and queues it.
+func (q *jobQueue) submit(req generateRequest) (*job, error) {
+	t := findTool(req.Model)
+	if t == nil {
+		return nil, fmt.Errorf("unknown model %q", req.Model)
+	}
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	artifactDir := filepath.Join(q.dir, "artifacts", id)
+	if err := os.MkdirAll(artifactDir, 0700); err != nil {
+		return nil, err
+	}
+	// Outputs always land in the job's artifact directory; a client-supplied
+	// output is only used as the file name, a template only below it.
+	if req.Output != "" {
+		req.Output = filepath.Join(artifactDir, filepath.Base(req.Output))
+	}
+	if req.OutputTemplate == "" {
+		req.OutputTemplate = "output.{ext}"
+	} else if !filepath.IsLocal(req.OutputTemplate) {
+		return nil, fmt.Errorf("output_template %q must stay inside the artifact directory", req.OutputTemplate)
+	}
+	req.OutputDir = artifactDir
+	req.TaskID, req.KeyID, req.Staged = "", "", nil
+	cfg, err := loadConfig(&req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := notify.New(cfg, req.Notify); err != nil {
+		return nil, err
+	}
+
+	j := &job{
+		ID:        id,
+		Request:   req,
+		Provider:  t.CLI,
+		State:     jobQueued,
+		CreatedAt: time.Now(),
+	}
+
+	q.mu.Lock()
+	q.jobs[id] = j
+	q.pending = append(q.pending, id)
+	q.addEvent(j, jobEvent{Type: "state", State: jobQueued})
+	q.save(j)
+	q.mu.Unlock()
+
+	q.signal()
+	return q.get(id), nil
+}
+
+// get returns a snapshot of the job, or nil.
+func (q *jobQueue) get(id string) *job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return nil
+	}
+	snapshot := *j
+	snapshot.Events = append([]jobEvent(nil), j.Events...)
+	return &snapshot
+}
+
+// list returns job snapshots without events, newest first, optionally
+// filtered by state.
+func (q *jobQueue) list(state string) []*job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []*job
+	for _, j := range q.jobs {
+		if state != "" && j.State != state {
+			continue
+		}
+		snapshot := *j
+		snapshot.Events = nil
+		out = append(out, &snapshot)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].CreatedAt.After(out[b].CreatedAt) })
+	return out
+}
+
+// cancel stops a queued or running job.
+func (q *jobQueue) cancel(id string) (*job, error) {
+	q.mu.Lock()
+	j, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return nil, nil
+	}
+	switch {
+	case j.terminal():
+		q.mu.Unlock()
+		return nil, fmt.Errorf("job %s already %s", id, j.State)
+	case j.State == jobQueued:
+		for i, pid := range q.pending {
+			if pid == id {
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				break
+			}
+		}
+		q.finish(j, jobCancelled, nil, "cancelled by client")
+	default:
+		if cancel, ok := q.cancels[id]; ok {
+			q.aborted[id] = true
+			cancel()
+		}
+	}
+	q.mu.Unlock()
+	return q.get(id), nil
+}
+
+// watch returns the job's past events and a channel receiving new ones. The
+// channel is closed when the job reaches a terminal state; it is nil if the
+// job already has.
+func (q *jobQueue) watch(id string) ([]jobEvent, chan jobEvent, func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return nil, nil, func() {}
+	}
+	past := append([]jobEvent(nil), j.Events...)
+	if j.terminal() {
+		return past, nil, func() {}
+	}
+	ch := make(chan jobEvent, 64)
+	q.watchers[id] = append(q.watchers[id], ch)
+	stop := func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		ws := q.watchers[id]
+		for i, w := range ws {
+			if w == ch {
+				q.watchers[id] = append(ws[:i], ws[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+	}
+	return past, ch, stop
+}
+
+// run dispatches queued jobs until ctx is done, then waits for running jobs
+// to stop. Jobs interrupted this way stay running on disk and are resumed by
+// the next server.
+func (q *jobQueue) run(ctx context.Context) {
+	for {
+		q.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			q.inflight.Wait()
+			return
+		case <-q.wake:
+		}
+	}
+}
+
+// dispatch starts every queued job that fits the worker and provider limits.
+func (q *jobQueue) dispatch(ctx context.Context) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := 0; i < len(q.pending) && q.running < q.workers; {
+		j := q.jobs[q.pending[i]]
+		if q.active[j.Provider] >= q.limit(j.Provider) {
+			i++
+			continue
+		}
+		q.pending = append(q.pending[:i], q.pending[i+1:]...)
+		q.running++
+		q.active[j.Provider]++
+
+		now := time.Now()
+		j.State = jobRunning
+		j.StartedAt = &now
+		q.addEvent(j, jobEvent{Type: "state", State: jobRunning, TaskID: j.TaskID})
+		q.save(j)
+
+		jctx, cancel := context.WithCancel(ctx)
+		q.cancels[j.ID] = cancel
+		q.inflight.Add(1)
+		go q.execute(jctx, j.ID)
+	}
+}
+
+func (q *jobQueue) limit(provider string) int {
+	if n, ok := q.concurrency[provider]; ok && n > 0 {
+		return n
+	}
+	return q.defaultConc
+}
+
+// execute runs one job to completion.
+func (q *jobQueue) execute(ctx context.Context, id string) {
+	defer q.inflight.Done()
+
+	q.mu.Lock()
+	j := q.jobs[id]
+	req := j.Request
+	req.TaskID, req.KeyID, req.Staged = j.TaskID, j.KeyID, j.Staged
+	q.mu.Unlock()
+
+	h := &hooks{
+		Progress: func(message string) {
+			q.mu.Lock()
+			q.addEvent(j, jobEvent{Type: "progress", Message: message})
+			q.mu.Unlock()
+		},
+		Submitted: func(taskID, keyID string, staged []string) {
+			q.mu.Lock()
+			j.TaskID, j.KeyID, j.Staged = taskID, keyID, staged
+			q.addEvent(j, jobEvent{Type: "submitted", TaskID: taskID})
+			q.save(j)
+			q.mu.Unlock()
+		},
+	}
+	result, err := generate(ctx, &req, h)
+
+	q.mu.Lock()
+	var ev *notify.Event
+	var abandoned []string
+	switch {
+	case ctx.Err() != nil && q.aborted[id]:
+		q.finish(j, jobCancelled, nil, "cancelled by client")
+		abandoned = j.Staged
+	case ctx.Err() != nil:
+		q.addEvent(j, jobEvent{Type: "progress", Message: "server stopping, job will resume on restart"})
+		q.save(j)
+	case err != nil:
+		q.finish(j, jobFailed, nil, err.Error())
+		ev = &notify.Event{Event: notify.Classify(err), Error: err.Error()}
+	default:
+		q.finish(j, jobSucceeded, result, "")
+		ev = &notify.Event{Event: notify.Success, Timings: result.Timings}
+		for _, a := range result.Artifacts {
+			if a.Remote {
+				ev.Outputs = append(ev.Outputs, a.SourceURL)
+				continue
+			}
+			ev.Outputs = append(ev.Outputs, a.Path)
+		}
+	}
+	delete(q.cancels, id)
+	delete(q.aborted, id)
+	q.running--
+	q.active[j.Provider]--
+	var n *notify.Notifier
+	if ev != nil {
+		ev.JobID, ev.TaskID, ev.Tool, ev.Model = j.ID, j.TaskID, j.Provider, j.Request.Model
+		ev.DurationMS = j.FinishedAt.Sub(j.CreatedAt).Milliseconds()
+		n = q.notifier(j)
+	}
+	q.mu.Unlock()
+
+	q.signal()
+	if err := dropStaged(&req, abandoned); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: job %s: failed to delete staged files: %v\n", j.ID, err)
+	}
+	if n != nil {
+		n.Fire(*ev)
+	}
+}
+
+// notifier resolves the server-wide and per-job notification targets for j.
+func (q *jobQueue) notifier(j *job) *notify.Notifier {
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: job %s: %v\n", j.ID, err)
+		return nil
+	}
+	n, err := notify.New(cfg, append(append([]string{}, q.notify...), j.Request.Notify...))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: job %s: %v\n", j.ID, err)
+		return nil
+	}
+	return n
+}
+
+// finish moves j to a terminal state. q.mu must be held.
+func (q *jobQueue) finish(j *job, state string, result *generateResult, message string) {
+	now := time.Now()
+	j.State = state
+	j.FinishedAt = &now
+	j.Result = result
+	if state != jobSucceeded {
+		j.Error = message
+	}
+	q.addEvent(j, jobEvent{Type: "state", State: state, TaskID: j.TaskID, Message: message})
+	q.save(j)
+	for _, ch := range q.watchers[j.ID] {
+		close(ch)
+	}
+	delete(q.watchers, j.ID)
+}
+
+// addEvent appends an event and fans it out to watchers. q.mu must be held.
+func (q *jobQueue) addEvent(j *job, ev jobEvent) {
+	ev.Time = time.Now()
+	if n := len(j.Events); n > 0 {
+		ev.Seq = j.Events[n-1].Seq + 1
+	}
+	j.Events = append(j.Events, ev)
+	if len(j.Events) > maxJobEvents {
+		j.Events = j.Events[len(j.Events)-maxJobEvents:]
+	}
+	for _, ch := range q.watchers[j.ID] {
+		select {
+		case ch <- ev:
+		default: // slow watcher; it can re-read the history
+		}
+	}
+}
+
+// save persists j atomically. q.mu must be held.
+func (q *jobQueue) save(j *job) {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: marshal job %s: %v\n", j.ID, err)
+		return
+	}
+	path := filepath.Join(q.dir, "jobs", j.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: save job %s: %v\n", j.ID, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: save job %s: %v\n", j.ID, err)
+	}
+}
+
+func (q *jobQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	arkprovider "github.com/llm-net/llm-api-plugin/cmd/ark-cli/provider"
+	geminiprovider "github.com/llm-net/llm-api-plugin/cmd/gemini-cli/provider"
+	jimengprovider "github.com/llm-net/llm-api-plugin/cmd/jimeng-cli/provider"
+	topviewprovider "github.com/llm-net/llm-api-plugin/cmd/topview-cli/provider"
+	"github.com/llm-net/llm-api-plugin/internal/assets"
+	"github.com/llm-net/llm-api-plugin/internal/buildinfo"
+	"github.com/llm-net/llm-api-plugin/internal/cassette"
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/doctor"
+	"github.com/llm-net/llm-api-plugin/internal/input"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+	"github.com/llm-net/llm-api-plugin/internal/models"
+	"github.com/llm-net/llm-api-plugin/internal/probe"
+	"github.com/llm-net/llm-api-plugin/internal/selfupdate"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `llm-api - Unified entry point for all llm-api-plugin generators
+
+Usage:
+  %[1]s mcp                      Run an MCP server on stdio exposing every model as a tool
+  %[1]s serve [flags]            Run a local HTTP job server
+  %[1]s run <pipeline.yaml>      Run a multi-step pipeline of models
+  %[1]s storyboard <script.md>   Turn a script into shots, keyframes and video clips
+  %[1]s models [<model-name>]    List models of all CLIs (JSON)
+  %[1]s doctor                   Check credentials and connectivity of every provider
+  %[1]s assets <cmd>             Manage the asset library: reusable portraits, voices, videos (asset:<name>)
+  %[1]s probe <file.mp4>         Print duration, resolution, frame rate, codecs and audio of a video (JSON)
+  %[1]s self-update [flags]      Install the latest release, checksum-verified (--help for flags)
+  %[1]s version                  Print version and build info (also --version)
+
+Global flags:
+  --profile <name>             Credential profile to use (or LLM_API_PROFILE)
+  --record <dir>               Save every HTTP request and response to a cassette directory
+  --replay <dir>               Serve HTTP responses from a cassette instead of the network
+  --har <file>                 Export the HTTP traffic as a HAR file
+  --verbose                    Log debug details: HTTP requests, raw provider responses (redacted)
+  --quiet                      Only log warnings and errors
+  --log-format <text|json>     Log format (default text)
+  --log-file <path>            Append logs to a file instead of stderr
+
+Flags for serve:
+  --addr <host:port>           Listen address                        [default: 127.0.0.1:8787]
+  --data-dir <dir>             Job state and artifacts directory     [default: ~/.config/llm-api-plugin/serve]
+  --workers <n>                Maximum jobs running at once          [default: 4]
+  --concurrency <n>            Default per-provider limit            [default: 2]
+  --concurrency <cli>=<n>      Limit for one provider, e.g. ark-cli=3
+  --notify <target>            Notify on every finished job (repeatable; see 'config notify')
+  --token <token|->            Require "Authorization: Bearer <token>"; - reads it from stdin
+                               (or set LLM_API_SERVE_TOKEN; required off loopback)
+
+Flags for run:
+  --run-dir <dir>              Artifacts, step state and summary     [default: runs/<name> next to the file]
+  --var <name>=<value>         Set a pipeline var (repeatable)
+  --parallel <n>               Maximum steps running at once         [default: 4]
+  --force                      Ignore cached steps and run everything again
+
+Flags for storyboard:
+  --dir <dir>                  Storyboard, keyframes and clips       [default: storyboards/<name> next to the script]
+  --style <text>               Visual style added to every keyframe prompt
+  --reference <image>          Character or style reference image for every keyframe
+  --ratio <ratio>              Aspect ratio of keyframes and clips   [default: 16:9]
+  --shots <n>                  Number of shots to plan               [default: chosen by the model]
+  --text-model <model>         Gemini model planning the shots       [default: gemini-2.5-flash]
+  --image-model <model>        Keyframe model                        [default: gemini-3-pro-image-preview]
+  --video-model <model>        Image-to-video model                  [default: jimeng-i2v-3-pro]
+  --transitions                Animate each keyframe into the next one (--no-transitions to turn off)
+  --transition-model <model>   First/last-frame model for transitions [default: jimeng-i2v-startend-3-pro]
+  --plan                       Only plan the shots and write storyboard.json
+  --replan                     Plan again from the script, discarding edits to storyboard.json
+  --shot <id|n>                Regenerate one shot even if cached (repeatable)
+  --parallel <n>               Maximum generations at once           [default: 4]
+
+REST API (serve):
+  POST   /v1/jobs                          Submit (Content-Type: application/json) {"model", "prompt", "params", "output", "output_template", "no_download", "notify", "profile", "publish"}
+  GET    /v1/jobs[?state=<state>]          List jobs
+  GET    /v1/jobs/{id}                     Job state, task ID, result and events
+  DELETE /v1/jobs/{id}                     Cancel a queued or running job (202; poll for the final state)
+  GET    /v1/jobs/{id}/events              Event stream (SSE)
+  GET    /v1/jobs/{id}/artifacts/{index}   Download an artifact (redirects to the provider for a remote one)
+  GET    /v1/models                        All models
+
+Every model from gemini-cli, ark-cli, jimeng-cli and topview-cli is exposed as
+an MCP tool whose input schema is derived from its 'models' params. Credentials
+are resolved exactly as the individual CLIs do (environment variables first,
+then the active profile in ~/.config/llm-api-plugin/config.json). Jobs and
+tool calls may pick another profile with "profile".
+
+Examples:
+  %[1]s mcp
+  %[1]s serve --concurrency ark-cli=3
+  curl -H 'Content-Type: application/json' -d '{"model":"jimeng-t2v-3-pro","prompt":"A dreamy forest"}' localhost:8787/v1/jobs
+  claude mcp add llm-api -- %[1]s mcp
+  %[1]s run keyframe-to-video.yaml --var subject="a red fox"
+  %[1]s storyboard promo.md --reference mascot.png --transitions
+  %[1]s storyboard promo.md --shot 3
+  %[1]s models jimeng-omnihuman
+  %[1]s --profile work doctor
+`, filepath.Base(os.Args[0]))
+}
+
+func main() {
+	args, err := config.SelectProfile(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+	args, err = cassette.Setup(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+	args, err = logging.Setup(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "mcp":
+		handleMCP()
+	case "serve":
+		handleServe()
+	case "run":
+		handleRun()
+	case "storyboard":
+		handleStoryboard()
+	case "models":
+		handleModels()
+	case "doctor":
+		handleDoctor()
+	case "probe":
+		if err := probe.Command(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "assets":
+		if err := assets.Command(os.Args[2:], input.Load); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "self-update":
+		if err := selfupdate.Command("llm-api", os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "version", "--version":
+		fmt.Println(buildinfo.String("llm-api"))
+	case "help", "--help", "-h":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", os.Args[1])
+		usage()
+		os.Exit(1)
+	}
+}
+
+func handleMCP() {
+	s := newMCPServer(os.Stdout)
+	if err := s.serve(os.Stdin); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func handleDoctor() {
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	profile, source := cfg.ActiveProfile()
+	fmt.Printf("Profile: %s (source: %s)\n\n", profile, source)
+
+	var checks []doctor.Check
+	checks = append(checks, geminiprovider.DoctorChecks(cfg)...)
+	checks = append(checks, arkprovider.DoctorChecks(cfg)...)
+	checks = append(checks, jimengprovider.DoctorChecks(cfg)...)
+	checks = append(checks, topviewprovider.DoctorChecks(cfg)...)
+	if doctor.Run(os.Stdout, checks) > 0 {
+		os.Exit(1)
+	}
+}
+
+func handleModels() {
+	registries := []*models.Registry{
+		geminiprovider.Registry,
+		arkprovider.Registry,
+		jimengprovider.Registry,
+		topviewprovider.Registry,
+	}
+	if len(os.Args) >= 3 {
+		for _, r := range registries {
+			if m := r.FindModel(os.Args[2]); m != nil {
+				single, _ := json.MarshalIndent(m, "", "  ")
+				fmt.Println(string(single))
+				return
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Unknown model: %s\n", os.Args[2])
+		os.Exit(1)
+	}
+	data, err := json.MarshalIndent(registries, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/llm-net/llm-api-plugin/internal/buildinfo"
+	"github.com/llm-net/llm-api-plugin/internal/models"
+	"github.com/llm-net/llm-api-plugin/internal/output"
+)
+
+// MCP protocol versions this server speaks, newest first.
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type mcpTool struct {
+	Name        string                 `json:"name"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+type mcpContent struct {
+	Type        string `json:"type"`
+	Text        string `json:"text,omitempty"`
+	URI         string `json:"uri,omitempty"`
+	Name        string `json:"name,omitempty"`
+	MIMEType    string `json:"mimeType,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type mcpCallResult struct {
+	Content           []mcpContent    `json:"content"`
+	StructuredContent *generateResult `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+}
+
+// mcpServer serves every registry model as an MCP tool over stdio.
+type mcpServer struct {
+	out   io.Writer
+	outMu sync.Mutex
+
+	tools   map[string]*tool // keyed by MCP tool name
+	names   []string
+	running sync.WaitGroup
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc // in-flight tools/call by request ID
+}
+
+func newMCPServer(out io.Writer) *mcpServer {
+	s := &mcpServer{
+		out:     out,
+		tools:   map[string]*tool{},
+		cancels: map[string]context.CancelFunc{},
+	}
+	for _, t := range allTools() {
+		name := mcpToolName(t.Model.Name)
+		s.tools[name] = t
+		s.names = append(s.names, name)
+	}
+	return s
+}
+
+// maxMCPMessage bounds one JSON-RPC message; tool arguments may inline
+// base64 images.
+const maxMCPMessage = 64 << 20
+
+// serve reads newline-delimited JSON-RPC messages from in until EOF. A
+// malformed message is answered with an error and skipped; the running tool
+// calls are waited for before returning.
+func (s *mcpServer) serve(in io.Reader) error {
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 0, 64*1024), maxMCPMessage)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			s.replyError(json.RawMessage("null"), rpcParseError, "parse error: invalid JSON")
+			continue
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			// Answer with the ID when it can still be told
+			var head struct {
+				ID json.RawMessage `json:"id"`
+			}
+			id := json.RawMessage("null")
+			if json.Unmarshal(line, &head) == nil && len(head.ID) > 0 {
+				id = head.ID
+			}
+			s.replyError(id, rpcInvalidRequest, "invalid request: "+err.Error())
+			continue
+		}
+		s.handle(&msg)
+	}
+	s.running.Wait()
+	return sc.Err()
+}
+
+func (s *mcpServer) handle(msg *rpcMessage) {
+	isRequest := len(msg.ID) > 0
+	switch msg.Method {
+	case "initialize":
+		s.reply(msg.ID, s.initialize(msg.Params))
+	case "ping":
+		s.reply(msg.ID, struct{}{})
+	case "tools/list":
+		s.reply(msg.ID, map[string]interface{}{"tools": s.listTools()})
+	case "tools/call":
+		s.callTool(msg.ID, msg.Params)
+	case "notifications/cancelled":
+		var p struct {
+			RequestID json.RawMessage `json:"requestId"`
+		}
+		if json.Unmarshal(msg.Params, &p) == nil {
+			s.mu.Lock()
+			if cancel, ok := s.cancels[string(p.RequestID)]; ok {
+				cancel()
+			}
+			s.mu.Unlock()
+		}
+	default:
+		if msg.Method == "" && isRequest {
+			s.replyError(msg.ID, rpcInvalidRequest, "missing method")
+		} else if isRequest {
+			s.replyError(msg.ID, rpcMethodNotFound, "method not found: "+msg.Method)
+		}
+		// Unknown notifications (e.g. notifications/initialized) need no answer.
+	}
+}
+
+func (s *mcpServer) initialize(params json.RawMessage) map[string]interface{} {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	_ = json.Unmarshal(params, &p)
+	negotiated := mcpProtocolVersions[0]
+	for _, v := range mcpProtocolVersions {
+		if v == p.ProtocolVersion {
+			negotiated = v
+		}
+	}
+	return map[string]interface{}{
+		"protocolVersion": negotiated,
+		"capabilities": map[string]interface{}{
+			"tools": map[string]interface{}{},
+		},
+		"serverInfo": map[string]interface{}{
+			"name":    "llm-api",
+			"version": buildinfo.Version,
+		},
+		"instructions": "Each tool runs one generation model. Video tools submit a task and poll until it finishes " +
+			"(up to 10 minutes), sending progress notifications when a progressToken is given. " +
+			"Generated files are saved locally and returned as resource links.",
+	}
+}
+
+func (s *mcpServer) listTools() []mcpTool {
+	tools := make([]mcpTool, 0, len(s.names))
+	for _, name := range s.names {
+		t := s.tools[name]
+		tools = append(tools, mcpTool{
+			Name:        name,
+			Title:       t.Model.Name,
+			Description: fmt.Sprintf("%s (%s; %s)", t.Model.Description, t.CLI, strings.Join(t.Model.Capabilities, ", ")),
+			InputSchema: inputSchema(t),
+		})
+	}
+	return tools
+}
+
+func (s *mcpServer) callTool(id json.RawMessage, params json.RawMessage) {
+	var p struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+		Meta      struct {
+			ProgressToken json.RawMessage `json:"progressToken"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		s.replyError(id, rpcInvalidParams, err.Error())
+		return
+	}
+	t, ok := s.tools[p.Name]
+	if !ok {
+		s.replyError(id, rpcInvalidParams, "unknown tool: "+p.Name)
+		return
+	}
+
+	req := &generateRequest{Model: t.Model.Name, Params: map[string]string{}}
+	for k, v := range p.Arguments {
+		sv := argString(v)
+		switch k {
+		case "prompt":
+			req.Prompt = sv
+		case "output":
+			req.Output = sv
+		case "output_dir":
+			req.OutputDir = sv
+		case "output_template":
+			req.OutputTemplate = sv
+		case "profile":
+			req.Profile = sv
+		case "publish":
+			req.Publish = sv == "true"
+		case "no_download":
+			req.NoDownload = sv == "true"
+		default:
+			req.Params[k] = sv
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancels[string(id)] = cancel
+	s.mu.Unlock()
+
+	var staged []string
+	h := &hooks{Submitted: func(_, _ string, keys []string) { staged = keys }}
+	if len(p.Meta.ProgressToken) > 0 {
+		var step int
+		h.Progress = func(message string) {
+			step++
+			s.send(rpcMessage{JSONRPC: "2.0", Method: "notifications/progress", Params: mustJSON(map[string]interface{}{
+				"progressToken": p.Meta.ProgressToken,
+				"progress":      step,
+				"message":       message,
+			})})
+		}
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.cancels, string(id))
+			s.mu.Unlock()
+			cancel()
+		}()
+
+		result, err := generate(ctx, req, h)
+		if ctx.Err() != nil {
+			// The client cancelled the request and expects no response. The
+			// task is not resumed, so its staged inputs go now.
+			if err := dropStaged(req, staged); err != nil {
+				fmt.Fprintf(os.Stderr, "mcp: failed to delete staged files: %v\n", err)
+			}
+			return
+		}
+		if err != nil {
+			s.reply(id, mcpCallResult{
+				Content: []mcpContent{{Type: "text", Text: "Error: " + err.Error()}},
+				IsError: true,
+			})
+			return
+		}
+		s.reply(id, callResult(result))
+	}()
+}
+
+// callResult renders a generateResult as MCP content: a text summary followed
+// by one resource link per artifact.
+func callResult(r *generateResult) mcpCallResult {
+	var summary strings.Builder
+	if r.TaskID != "" {
+		fmt.Fprintf(&summary, "Task %s finished.\n", r.TaskID)
+	}
+	for _, a := range r.Artifacts {
+		if a.Remote {
+			fmt.Fprintf(&summary, "Not downloaded: %s (%s, %s)\n", a.SourceURL, a.MIMEType, output.ExpiryNote(a.SourceURL))
+			continue
+		}
+		fmt.Fprintf(&summary, "Saved %s (%s, %d bytes)\n", a.Path, a.MIMEType, a.Size)
+		if a.URL != "" {
+			fmt.Fprintf(&summary, "Published %s\n", a.URL)
+		}
+	}
+	if r.Text != "" {
+		summary.WriteString(r.Text)
+	}
+
+	res := mcpCallResult{
+		Content:           []mcpContent{{Type: "text", Text: strings.TrimSpace(summary.String())}},
+		StructuredContent: r,
+	}
+	for _, a := range r.Artifacts {
+		if a.Remote {
+			res.Content = append(res.Content, mcpContent{
+				Type:        "resource_link",
+				URI:         a.SourceURL,
+				Name:        path.Base(strings.SplitN(a.SourceURL, "?", 2)[0]),
+				MIMEType:    a.MIMEType,
+				Description: fmt.Sprintf("%s output at the provider (%s)", r.Model, output.ExpiryNote(a.SourceURL)),
+			})
+			continue
+		}
+		res.Content = append(res.Content, mcpContent{
+			Type:        "resource_link",
+			URI:         (&url.URL{Scheme: "file", Path: filepath.ToSlash(a.Path)}).String(),
+			Name:        filepath.Base(a.Path),
+			MIMEType:    a.MIMEType,
+			Description: fmt.Sprintf("%s output (%d bytes)", r.Model, a.Size),
+		})
+	}
+	return res
+}
+
+// inputSchema derives a JSON Schema for a tool from its registry params.
+func inputSchema(t *tool) map[string]interface{} {
+	props := map[string]interface{}{}
+	var required []string
+
+	if t.Prompt != promptNone {
+		props["prompt"] = map[string]interface{}{"type": "string", "description": "Text prompt"}
+		if t.Prompt == promptRequired {
+			required = append(required, "prompt")
+		}
+	}
+	for name, p := range t.Model.Params {
+		prop := map[string]interface{}{"type": schemaType(p), "description": p.Description}
+		if len(p.Options) > 0 {
+			prop["enum"] = p.Options
+		}
+		if p.Default != "" {
+			prop["default"] = schemaDefault(p)
+		}
+		props[name] = prop
+		if p.Required {
+			required = append(required, name)
+		}
+	}
+	props["output"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Output file path [default: named by output_template in output_dir]",
+	}
+	props["output_dir"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Directory for the outputs [default: the configured output_dir, else the server's working directory]",
+	}
+	props["output_template"] = map[string]interface{}{
+		"type":        "string",
+		"description": "File name template with {model} {seed} {task_id} {index} {date} {prompt_slug} {ext} [default: output_{date}.{ext}]",
+	}
+	props["profile"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Credential profile (see 'config profile') [default: the server's active profile]",
+	}
+	props["no_download"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Return the provider's result URL and its expiry instead of downloading the output (the artifact is then remote only)",
+	}
+	props["publish"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Upload the outputs to object storage (see 'config storage') and return shareable URLs",
+	}
+
+	sort.Strings(required)
+	schema := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func schemaType(p models.Param) string {
+	switch p.Type {
+	case "integer", "boolean":
+		return p.Type
+	default:
+		return "string"
+	}
+}
+
+func schemaDefault(p models.Param) interface{} {
+	switch p.Type {
+	case "integer":
+		if v, err := strconv.Atoi(p.Default); err == nil {
+			return v
+		}
+	case "boolean":
+		if v, err := strconv.ParseBool(p.Default); err == nil {
+			return v
+		}
+	}
+	return p.Default
+}
+
+// mcpToolName maps a model name onto the MCP tool name alphabet.
+func mcpToolName(model string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, model)
+}
+
+// argString converts a JSON argument value to the string form the CLIs use.
+func argString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	default:
+		return string(mustJSON(x))
+	}
+}
+
+func (s *mcpServer) reply(id json.RawMessage, result interface{}) {
+	s.send(rpcMessage{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *mcpServer) replyError(id json.RawMessage, code int, message string) {
+	s.send(rpcMessage{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: message}})
+}
+
+func (s *mcpServer) send(msg rpcMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mcp: marshal message: %v\n", err)
+		return
+	}
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
+
+func mustJSON(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
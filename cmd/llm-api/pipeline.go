@@ -0,0 +1,798 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/llm-net/llm-api-plugin/internal/assets"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+)
+
+const (
+	defaultPipelineParallel = 4
+	// pipelineTemplate names the outputs of a step inside its directory.
+	pipelineTemplate = "{model}_{index}.{ext}"
+)
+
+// Step states of a pipeline run.
+const (
+	stepPending   = "pending"
+	stepRunning   = "running"
+	stepSucceeded = "succeeded"
+	stepCached    = "cached"
+	stepFailed    = "failed"
+	stepSkipped   = "skipped"
+	stepCancelled = "cancelled"
+)
+
+// pipeline is a parsed pipeline file: a DAG of registry model invocations.
+type pipeline struct {
+	Name string `yaml:"name"`
+	// Vars are substituted for {{vars.<name>}}; --var overrides them.
+	Vars map[string]string `yaml:"vars"`
+	// RunDir is relative to the pipeline file.
+	RunDir string `yaml:"run_dir"`
+	// Profile is the credential profile of steps that name none.
+	Profile string          `yaml:"profile"`
+	Steps   []*pipelineStep `yaml:"steps"`
+}
+
+// pipelineStep is one model invocation. Prompt and Params may reference
+// the outputs of other steps, which makes them dependencies.
+type pipelineStep struct {
+	ID     string            `yaml:"id"`
+	Model  string            `yaml:"model"`
+	Prompt string            `yaml:"prompt"`
+	Params map[string]string `yaml:"params"`
+	// Needs lists dependencies that are not referenced in a template.
+	Needs          []string `yaml:"needs"`
+	NoDownload     bool     `yaml:"no_download"`
+	OutputTemplate string   `yaml:"output_template"`
+	Profile        string   `yaml:"profile"`
+
+	// literalPrompt keeps braces in Prompt as text instead of templates,
+	// for prompts written by a model rather than a pipeline author.
+	literalPrompt bool
+
+	// deps are the IDs of Needs and of referenced steps, sorted.
+	deps []string
+}
+
+var (
+	stepIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// templateRef matches {{ ... }} references in prompts and params.
+	templateRef = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+	// stepRef is a reference to another step's results.
+	stepRef = regexp.MustCompile(`^steps\.([A-Za-z0-9_-]+)\.(outputs\[(\d+)\]|text|task_id)$`)
+	varRef  = regexp.MustCompile(`^vars\.([A-Za-z0-9_-]+)$`)
+)
+
+// loadPipeline parses and validates a pipeline file. vars override the
+// file's vars.
+func loadPipeline(path string, vars map[string]string) (*pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var p pipeline
+	if err := dec.Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if p.Vars == nil {
+		p.Vars = map[string]string{}
+	}
+	for name, value := range vars {
+		p.Vars[name] = value
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// validate checks models, params and references, substitutes vars and
+// derives the dependencies of every step, so that a typo fails before
+// anything is generated.
+func (p *pipeline) validate() error {
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("no steps")
+	}
+	byID := map[string]*pipelineStep{}
+	for i, s := range p.Steps {
+		if s == nil || s.ID == "" {
+			return fmt.Errorf("step %d: id is required", i+1)
+		}
+		if !stepIDPattern.MatchString(s.ID) {
+			return fmt.Errorf("step %q: id may only contain letters, digits, '-' and '_'", s.ID)
+		}
+		if byID[s.ID] != nil {
+			return fmt.Errorf("step %q is defined twice", s.ID)
+		}
+		byID[s.ID] = s
+	}
+
+	for _, s := range p.Steps {
+		t := findTool(s.Model)
+		if t == nil {
+			return fmt.Errorf("step %q: unknown model %q (see 'llm-api models')", s.ID, s.Model)
+		}
+		for name := range s.Params {
+			if _, ok := t.Model.Params[name]; !ok {
+				return fmt.Errorf("step %q: model %s has no param %q", s.ID, s.Model, name)
+			}
+		}
+
+		deps := map[string]bool{}
+		for _, id := range s.Needs {
+			if byID[id] == nil {
+				return fmt.Errorf("step %q needs unknown step %q", s.ID, id)
+			}
+			deps[id] = true
+		}
+		// Substitute vars now; step references are resolved when the step runs.
+		resolve := func(value string) (string, error) {
+			var err error
+			out := templateRef.ReplaceAllStringFunc(value, func(m string) string {
+				expr := templateRef.FindStringSubmatch(m)[1]
+				if v := varRef.FindStringSubmatch(expr); v != nil {
+					value, ok := p.Vars[v[1]]
+					if !ok && err == nil {
+						err = fmt.Errorf("undefined var %q", v[1])
+					}
+					return value
+				}
+				if r := stepRef.FindStringSubmatch(expr); r != nil {
+					if byID[r[1]] == nil && err == nil {
+						err = fmt.Errorf("reference to unknown step %q", r[1])
+					}
+					deps[r[1]] = true
+					return m
+				}
+				if err == nil {
+					err = fmt.Errorf("invalid reference %s (want steps.<id>.outputs[<n>], steps.<id>.text, steps.<id>.task_id or vars.<name>)", m)
+				}
+				return m
+			})
+			return out, err
+		}
+		var err error
+		if !s.literalPrompt {
+			if s.Prompt, err = resolve(s.Prompt); err != nil {
+				return fmt.Errorf("step %q: prompt: %w", s.ID, err)
+			}
+		}
+		for name, value := range s.Params {
+			if s.Params[name], err = resolve(value); err != nil {
+				return fmt.Errorf("step %q: %s: %w", s.ID, name, err)
+			}
+		}
+		if deps[s.ID] {
+			return fmt.Errorf("step %q depends on itself", s.ID)
+		}
+		for id := range deps {
+			s.deps = append(s.deps, id)
+		}
+		sort.Strings(s.deps)
+	}
+	return p.checkCycles(byID)
+}
+
+// checkCycles reports the first dependency cycle, e.g. "a -> b -> a".
+func (p *pipeline) checkCycles(byID map[string]*pipelineStep) error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := map[string]int{}
+	var path []string
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch marks[id] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for i, p := range path {
+				if p == id {
+					start = i
+				}
+			}
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path[start:], " -> "), id)
+		}
+		marks[id] = visiting
+		path = append(path, id)
+		for _, dep := range byID[id].deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		marks[id] = visited
+		return nil
+	}
+	for _, s := range p.Steps {
+		if err := visit(s.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stepRecord is the state of a step, persisted as <run-dir>/<id>/step.json.
+// It caches a succeeded step and lets an interrupted one resume polling.
+type stepRecord struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+	// Key digests the resolved request and its input files; a step whose
+	// key changed is run again.
+	Key        string          `json:"key"`
+	Request    generateRequest `json:"request"`
+	TaskID     string          `json:"task_id,omitempty"`
+	KeyID      string          `json:"key_id,omitempty"`
+	Staged     []string        `json:"staged,omitempty"`
+	Result     *generateResult `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// pipelineSummary is written to <run-dir>/summary.json and stdout.
+type pipelineSummary struct {
+	Pipeline   string        `json:"pipeline"`
+	RunDir     string        `json:"run_dir"`
+	State      string        `json:"state"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	DurationMS int64         `json:"duration_ms"`
+	Steps      []stepSummary `json:"steps"`
+}
+
+type stepSummary struct {
+	ID         string     `json:"id"`
+	Model      string     `json:"model"`
+	State      string     `json:"state"`
+	TaskID     string     `json:"task_id,omitempty"`
+	Outputs    []string   `json:"outputs,omitempty"`
+	Text       string     `json:"text,omitempty"`
+	Artifacts  []artifact `json:"artifacts,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	DurationMS int64      `json:"duration_ms,omitempty"`
+}
+
+// pipelineRun executes a pipeline in a run directory.
+type pipelineRun struct {
+	p   *pipeline
+	dir string
+	// force lists steps that run again even when cached.
+	force    map[string]bool
+	parallel int
+
+	mu      sync.Mutex
+	records map[string]*stepRecord
+	states  map[string]string
+	done    map[string]chan struct{}
+}
+
+func newPipelineRun(p *pipeline, dir string, force map[string]bool, parallel int) *pipelineRun {
+	r := &pipelineRun{
+		p:        p,
+		dir:      dir,
+		force:    force,
+		parallel: parallel,
+		records:  map[string]*stepRecord{},
+		states:   map[string]string{},
+		done:     map[string]chan struct{}{},
+	}
+	for _, s := range p.Steps {
+		r.states[s.ID] = stepPending
+		r.done[s.ID] = make(chan struct{})
+	}
+	return r
+}
+
+// run executes every step once its dependencies succeeded, at most
+// r.parallel at a time. Steps whose dependencies failed are skipped.
+func (r *pipelineRun) run(ctx context.Context) {
+	sem := make(chan struct{}, r.parallel)
+	var wg sync.WaitGroup
+	for _, s := range r.p.Steps {
+		wg.Add(1)
+		go func(s *pipelineStep) {
+			defer wg.Done()
+			defer close(r.done[s.ID])
+			for _, dep := range s.deps {
+				<-r.done[dep]
+				state := r.state(dep)
+				if ctx.Err() != nil {
+					r.setState(s.ID, stepCancelled)
+					return
+				}
+				if state != stepSucceeded && state != stepCached {
+					r.setState(s.ID, stepSkipped)
+					logging.Warnf("[%s] skipped: %s %s", s.ID, dep, state)
+					return
+				}
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				r.setState(s.ID, stepCancelled)
+				return
+			}
+			defer func() { <-sem }()
+			r.runStep(ctx, s)
+		}(s)
+	}
+	wg.Wait()
+}
+
+func (r *pipelineRun) state(id string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.states[id]
+}
+
+func (r *pipelineRun) setState(id, state string) {
+	r.mu.Lock()
+	r.states[id] = state
+	r.mu.Unlock()
+}
+
+func (r *pipelineRun) stepDir(id string) string {
+	return filepath.Join(r.dir, id)
+}
+
+// runStep resolves the references of s, then reuses its cached result or
+// generates it.
+func (r *pipelineRun) runStep(ctx context.Context, s *pipelineStep) {
+	fail := func(err error) {
+		r.setState(s.ID, stepFailed)
+		logging.Warnf("[%s] failed: %v", s.ID, err)
+	}
+
+	req := generateRequest{
+		Model:          s.Model,
+		Params:         map[string]string{},
+		OutputDir:      r.stepDir(s.ID),
+		OutputTemplate: s.OutputTemplate,
+		NoDownload:     s.NoDownload,
+		Profile:        s.Profile,
+	}
+	if req.OutputTemplate == "" {
+		req.OutputTemplate = pipelineTemplate
+	}
+	if req.Profile == "" {
+		req.Profile = r.p.Profile
+	}
+	var err error
+	req.Prompt = s.Prompt
+	if !s.literalPrompt {
+		if req.Prompt, err = r.resolve(s.Prompt); err != nil {
+			fail(fmt.Errorf("prompt: %w", err))
+			return
+		}
+	}
+	for name, value := range s.Params {
+		if req.Params[name], err = r.resolve(value); err != nil {
+			fail(fmt.Errorf("%s: %w", name, err))
+			return
+		}
+	}
+	key, err := cacheKey(&req)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	prev := r.load(s.ID)
+	if prev != nil && prev.Key == key && !r.force[s.ID] {
+		switch {
+		case prev.State == stepSucceeded && cachedArtifacts(prev.Result):
+			r.mu.Lock()
+			r.records[s.ID] = prev
+			r.states[s.ID] = stepCached
+			r.mu.Unlock()
+			logging.Infof("[%s] cached", s.ID)
+			return
+		case prev.State == stepRunning && prev.TaskID != "":
+			// Interrupted while polling: resume the submitted task.
+			req.TaskID, req.KeyID, req.Staged = prev.TaskID, prev.KeyID, prev.Staged
+			logging.Infof("[%s] resuming task %s", s.ID, prev.TaskID)
+		}
+	}
+	if prev != nil && prev.Result != nil && req.TaskID == "" {
+		r.removeArtifacts(s.ID, prev.Result)
+	}
+
+	now := time.Now()
+	rec := &stepRecord{ID: s.ID, State: stepRunning, Key: key, Request: req, TaskID: req.TaskID, KeyID: req.KeyID, Staged: req.Staged, StartedAt: &now}
+	r.mu.Lock()
+	r.records[s.ID] = rec
+	r.states[s.ID] = stepRunning
+	r.save(rec)
+	r.mu.Unlock()
+	logging.Infof("[%s] running %s", s.ID, s.Model)
+
+	h := &hooks{
+		Progress: func(message string) {
+			logging.Infof("[%s] %s", s.ID, message)
+		},
+		Submitted: func(taskID, keyID string, staged []string) {
+			r.mu.Lock()
+			rec.TaskID, rec.KeyID, rec.Staged = taskID, keyID, staged
+			r.save(rec)
+			r.mu.Unlock()
+		},
+	}
+	result, err := generate(ctx, &req, h)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	finished := time.Now()
+	switch {
+	case ctx.Err() != nil:
+		// Keep the record running so that the next run resumes the task.
+		r.states[s.ID] = stepCancelled
+		return
+	case err != nil:
+		rec.State, rec.Error = stepFailed, err.Error()
+		logging.Warnf("[%s] failed: %v", s.ID, err)
+	default:
+		rec.State, rec.Result = stepSucceeded, result
+		logging.Infof("[%s] done", s.ID)
+	}
+	rec.FinishedAt = &finished
+	r.states[s.ID] = rec.State
+	r.save(rec)
+}
+
+// resolve substitutes the step references of a template value. It is only
+// called once the referenced steps succeeded.
+func (r *pipelineRun) resolve(value string) (string, error) {
+	var err error
+	out := templateRef.ReplaceAllStringFunc(value, func(m string) string {
+		ref := stepRef.FindStringSubmatch(templateRef.FindStringSubmatch(m)[1])
+		if ref == nil {
+			return m
+		}
+		r.mu.Lock()
+		rec := r.records[ref[1]]
+		r.mu.Unlock()
+		if rec == nil || rec.Result == nil {
+			if err == nil {
+				err = fmt.Errorf("%s: step has no result", m)
+			}
+			return ""
+		}
+		switch ref[2] {
+		case "text":
+			return rec.Result.Text
+		case "task_id":
+			return rec.Result.TaskID
+		}
+		n, _ := strconv.Atoi(ref[3])
+		if n >= len(rec.Result.Artifacts) {
+			if err == nil {
+				err = fmt.Errorf("%s: step %s produced %d output(s)", m, ref[1], len(rec.Result.Artifacts))
+			}
+			return ""
+		}
+		return artifactRef(rec.Result.Artifacts[n])
+	})
+	return out, err
+}
+
+// artifactRef is what an output reference expands to: the local file, or
+// the provider URL of a remote artifact.
+func artifactRef(a artifact) string {
+	if a.Remote {
+		return a.SourceURL
+	}
+	return a.Path
+}
+
+// cacheKey digests the resolved request together with the content of the
+// local files it references, so that a re-generated input invalidates the
+// steps built on it.
+func cacheKey(req *generateRequest) (string, error) {
+	h := sha256.New()
+	data, err := json.Marshal(struct {
+		Model          string            `json:"model"`
+		Prompt         string            `json:"prompt"`
+		Params         map[string]string `json:"params"`
+		NoDownload     bool              `json:"no_download"`
+		OutputTemplate string            `json:"output_template"`
+		Profile        string            `json:"profile"`
+	}{req.Model, req.Prompt, req.Params, req.NoDownload, req.OutputTemplate, req.Profile})
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+
+	names := make([]string, 0, len(req.Params))
+	for name := range req.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// An asset is keyed by its content hash, so replacing it re-runs
+		// the steps using it.
+		if value := req.Params[name]; assets.IsRef(value) {
+			a, err := assets.Open().Resolve(value)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", name, err)
+			}
+			fmt.Fprintf(h, "\x00%s\x00%s", name, a.SHA256)
+			continue
+		}
+		info, err := os.Stat(req.Params[name])
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		f, err := os.Open(req.Params[name])
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+		fmt.Fprintf(h, "\x00%s\x00", name)
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cachedArtifacts reports whether the artifacts of a cached result are
+// still usable: local files exist and remote URLs have not expired.
+func cachedArtifacts(result *generateResult) bool {
+	if result == nil {
+		return false
+	}
+	for _, a := range result.Artifacts {
+		if a.Remote {
+			if a.ExpiresAt != nil && time.Now().After(*a.ExpiresAt) {
+				return false
+			}
+			continue
+		}
+		if _, err := os.Stat(a.Path); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// removeArtifacts deletes the local files of a stale result of step id
+// before it is generated again. Files outside the step directory, e.g. of a
+// run directory that was moved, are left alone.
+func (r *pipelineRun) removeArtifacts(id string, result *generateResult) {
+	dir := r.stepDir(id) + string(filepath.Separator)
+	for _, a := range result.Artifacts {
+		if a.Path != "" && strings.HasPrefix(a.Path, dir) {
+			os.Remove(a.Path)
+		}
+	}
+}
+
+// load reads the record of a previous run of the step, or nil.
+func (r *pipelineRun) load(id string) *stepRecord {
+	data, err := os.ReadFile(filepath.Join(r.stepDir(id), "step.json"))
+	if err != nil {
+		return nil
+	}
+	var rec stepRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		logging.Warnf("[%s] ignoring unreadable step.json: %v", id, err)
+		return nil
+	}
+	return &rec
+}
+
+// save persists rec atomically. r.mu must be held.
+func (r *pipelineRun) save(rec *stepRecord) {
+	if err := writeJSONFile(filepath.Join(r.stepDir(rec.ID), "step.json"), rec); err != nil {
+		logging.Warnf("[%s] save step: %v", rec.ID, err)
+	}
+}
+
+// writeJSONFile writes v as indented JSON via a temporary file.
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// summary reports the outcome of every step in file order.
+func (r *pipelineRun) summary(started time.Time) pipelineSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	finished := time.Now()
+	sum := pipelineSummary{
+		Pipeline:   r.p.Name,
+		RunDir:     r.dir,
+		State:      stepSucceeded,
+		StartedAt:  started,
+		FinishedAt: finished,
+		DurationMS: finished.Sub(started).Milliseconds(),
+	}
+	for _, s := range r.p.Steps {
+		st := stepSummary{ID: s.ID, Model: s.Model, State: r.states[s.ID]}
+		if rec := r.records[s.ID]; rec != nil {
+			st.TaskID, st.Error = rec.TaskID, rec.Error
+			if rec.Result != nil {
+				st.Text, st.Artifacts = rec.Result.Text, rec.Result.Artifacts
+				for _, a := range rec.Result.Artifacts {
+					st.Outputs = append(st.Outputs, artifactRef(a))
+				}
+			}
+			if rec.StartedAt != nil && rec.FinishedAt != nil && st.State != stepCached {
+				st.DurationMS = rec.FinishedAt.Sub(*rec.StartedAt).Milliseconds()
+			}
+		}
+		switch st.State {
+		case stepSucceeded, stepCached:
+		case stepCancelled:
+			sum.State = stepCancelled
+		default:
+			if sum.State != stepCancelled {
+				sum.State = stepFailed
+			}
+		}
+		sum.Steps = append(sum.Steps, st)
+	}
+	return sum
+}
+
+// executePipeline runs p in dir until every step finished or the process
+// is interrupted, and writes <dir>/summary.json.
+func executePipeline(p *pipeline, dir string, force map[string]bool, parallel int) pipelineSummary {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logging.Infof("Running pipeline %s (%d steps, run dir: %s)", p.Name, len(p.Steps), dir)
+	started := time.Now()
+	r := newPipelineRun(p, dir, force, parallel)
+	r.run(ctx)
+
+	sum := r.summary(started)
+	if err := writeJSONFile(filepath.Join(dir, "summary.json"), sum); err != nil {
+		logging.Warnf("write summary: %v", err)
+	}
+	return sum
+}
+
+// defaultRunDir is where a pipeline keeps its runs unless the file or
+// --run-dir says otherwise: runs/<name> next to the pipeline file.
+func defaultRunDir(path string, p *pipeline) string {
+	base := filepath.Dir(path)
+	if p.RunDir != "" {
+		if filepath.IsAbs(p.RunDir) {
+			return p.RunDir
+		}
+		return filepath.Join(base, p.RunDir)
+	}
+	return filepath.Join(base, "runs", p.Name)
+}
+
+func handleRun() {
+	var path, runDir string
+	var force bool
+	parallel := defaultPipelineParallel
+	vars := map[string]string{}
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--run-dir":
+			i++
+			if i < len(args) {
+				runDir = args[i]
+			}
+		case "--var":
+			i++
+			if i < len(args) {
+				name, value, found := strings.Cut(args[i], "=")
+				if !found || name == "" {
+					fmt.Fprintf(os.Stderr, "Error: invalid --var %q (want <name>=<value>)\n", args[i])
+					os.Exit(1)
+				}
+				vars[name] = value
+			}
+		case "--parallel":
+			i++
+			if i < len(args) {
+				v, err := strconv.Atoi(args[i])
+				if err != nil || v <= 0 {
+					fmt.Fprintf(os.Stderr, "Error: invalid --parallel %q\n", args[i])
+					os.Exit(1)
+				}
+				parallel = v
+			}
+		case "--force":
+			force = true
+		default:
+			if strings.HasPrefix(args[i], "-") || path != "" {
+				fmt.Fprintf(os.Stderr, "Unknown flag: %s\n", args[i])
+				os.Exit(1)
+			}
+			path = args[i]
+		}
+	}
+	if path == "" {
+		fmt.Fprintf(os.Stderr, "Error: usage: llm-api run <pipeline.yaml> [flags]\n")
+		os.Exit(1)
+	}
+
+	p, err := loadPipeline(path, vars)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if runDir == "" {
+		runDir = defaultRunDir(path, p)
+	}
+	if runDir, err = filepath.Abs(runDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	// Keep the pipeline as run next to its artifacts.
+	if data, err := os.ReadFile(path); err == nil {
+		if err := os.WriteFile(filepath.Join(runDir, "pipeline.yaml"), data, 0644); err != nil {
+			logging.Warnf("copy pipeline: %v", err)
+		}
+	}
+
+	forced := map[string]bool{}
+	for _, s := range p.Steps {
+		forced[s.ID] = force
+	}
+	sum := executePipeline(p, runDir, forced, parallel)
+	data, _ := json.MarshalIndent(sum, "", "  ")
+	fmt.Println(string(data))
+
+	switch sum.State {
+	case stepCancelled:
+		fmt.Fprintf(os.Stderr, "Error: pipeline cancelled; re-run to resume\n")
+		os.Exit(1)
+	case stepFailed:
+		fmt.Fprintf(os.Stderr, "Error: pipeline failed; fix the failed steps and re-run (completed steps are cached)\n")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/notify"
+)
+
+const (
+	defaultServeAddr        = "127.0.0.1:8787"
+	defaultServeWorkers     = 4
+	defaultServeConcurrency = 2
+)
+
+// serveTokenEnv supplies the bearer token clients of 'serve' must send.
+const serveTokenEnv = "LLM_API_SERVE_TOKEN"
+
+func handleServe() {
+	addr := defaultServeAddr
+	dataDir := filepath.Join(filepath.Dir(config.Path()), "serve")
+	workers := defaultServeWorkers
+	defaultConc := defaultServeConcurrency
+	concurrency := map[string]int{}
+	var notifyTargets []string
+	token := os.Getenv(serveTokenEnv)
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--addr":
+			i++
+			if i < len(args) {
+				addr = args[i]
+			}
+		case "--data-dir":
+			i++
+			if i < len(args) {
+				dataDir = args[i]
+			}
+		case "--workers":
+			i++
+			if i < len(args) {
+				if v, err := strconv.Atoi(args[i]); err == nil && v > 0 {
+					workers = v
+				}
+			}
+		case "--concurrency":
+			// --concurrency <n> sets the default, --concurrency <cli>=<n> one provider.
+			i++
+			if i < len(args) {
+				name, n, found := strings.Cut(args[i], "=")
+				if !found {
+					n = name
+				}
+				v, err := strconv.Atoi(n)
+				if err != nil || v <= 0 {
+					fmt.Fprintf(os.Stderr, "Error: invalid --concurrency %q\n", args[i])
+					os.Exit(1)
+				}
+				if found {
+					concurrency[name] = v
+				} else {
+					defaultConc = v
+				}
+			}
+		case "--notify":
+			i++
+			if i < len(args) {
+				notifyTargets = append(notifyTargets, args[i])
+			}
+		case "--token":
+			// --token <token>, or --token - to read it from stdin.
+			i++
+			v, err := config.SecretArg(args, i, "Bearer token: ")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: --token: %v\n", err)
+				os.Exit(1)
+			}
+			token = v
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown flag: %s\n", args[i])
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := notify.New(cfg, notifyTargets); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	q, err := newJobQueue(dataDir, workers, defaultConc, concurrency, notifyTargets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if host, _, err := net.SplitHostPort(addr); err != nil || (!isLoopback(host) && token == "") {
+		fmt.Fprintf(os.Stderr, "Error: listening on %s requires a bearer token (--token or %s)\n", addr, serveTokenEnv)
+		os.Exit(1)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Handler: guardServe(newServeMux(q), token)}
+	queueDone := make(chan struct{})
+	go func() {
+		q.run(ctx)
+		close(queueDone)
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving on http://%s (data: %s, workers: %d)\n", ln.Addr(), dataDir, workers)
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	<-queueDone
+}
+
+func newServeMux(q *jobQueue) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		var ms []interface{}
+		for _, t := range allTools() {
+			ms = append(ms, map[string]interface{}{"tool": t.CLI, "model": t.Model})
+		}
+		writeJSON(w, http.StatusOK, ms)
+	})
+
+	mux.HandleFunc("POST /v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+			return
+		}
+		var req generateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		j, err := q.submit(req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.Header().Set("Location", "/v1/jobs/"+j.ID)
+		writeJSON(w, http.StatusAccepted, j)
+	})
+
+	mux.HandleFunc("GET /v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		jobs := q.list(r.URL.Query().Get("state"))
+		if jobs == nil {
+			jobs = []*job{}
+		}
+		writeJSON(w, http.StatusOK, jobs)
+	})
+
+	mux.HandleFunc("GET /v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		j := q.get(r.PathValue("id"))
+		if j == nil {
+			writeError(w, http.StatusNotFound, "job not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, j)
+	})
+
+	mux.HandleFunc("DELETE /v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		j, err := q.cancel(r.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if j == nil {
+			writeError(w, http.StatusNotFound, "job not found")
+			return
+		}
+		// A running job stops once its provider call returns; poll the job
+		// or its events for the final state.
+		w.Header().Set("Location", "/v1/jobs/"+j.ID)
+		writeJSON(w, http.StatusAccepted, j)
+	})
+
+	mux.HandleFunc("GET /v1/jobs/{id}/events", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if q.get(id) == nil {
+			writeError(w, http.StatusNotFound, "job not found")
+			return
+		}
+		serveEvents(w, r, q, id)
+	})
+
+	mux.HandleFunc("GET /v1/jobs/{id}/artifacts/{index}", func(w http.ResponseWriter, r *http.Request) {
+		j := q.get(r.PathValue("id"))
+		if j == nil {
+			writeError(w, http.StatusNotFound, "job not found")
+			return
+		}
+		index, err := strconv.Atoi(r.PathValue("index"))
+		if err != nil || j.Result == nil || index < 0 || index >= len(j.Result.Artifacts) {
+			writeError(w, http.StatusNotFound, "artifact not found")
+			return
+		}
+		a := j.Result.Artifacts[index]
+		if a.Remote {
+			http.Redirect(w, r, a.SourceURL, http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", a.MIMEType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(a.Path)))
+		http.ServeFile(w, r, a.Path)
+	})
+
+	return mux
+}
+
+// guardServe rejects requests a browser page could forge: those from a
+// non-loopback Origin and, unless a token is required, those addressed to a
+// non-loopback Host (DNS rebinding). With a token, every request must carry
+// "Authorization: Bearer <token>".
+func guardServe(next http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !isLoopback(u.Hostname()) {
+				writeError(w, http.StatusForbidden, "cross-origin requests are not allowed")
+				return
+			}
+		}
+		if token == "" {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if !isLoopback(host) {
+				writeError(w, http.StatusForbidden, "Host must be a loopback address")
+				return
+			}
+		} else {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopback reports whether host is localhost or a loopback IP.
+func isLoopback(host string) bool {
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// serveEvents streams a job's events as Server-Sent Events, starting with the
+// stored history (or after Last-Event-ID), until the job finishes.
+func serveEvents(w http.ResponseWriter, r *http.Request, q *jobQueue, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	after := -1
+	if v, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil {
+		after = v
+	}
+
+	past, ch, stop := q.watch(id)
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(ev jobEvent) {
+		if ev.Seq <= after {
+			return
+		}
+		data, _ := json.Marshal(ev)
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Type, data)
+		after = ev.Seq
+	}
+	for _, ev := range past {
+		write(ev)
+	}
+	flusher.Flush()
+	if ch == nil {
+		return
+	}
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				// Deliver anything dropped for a slow reader, then end the stream.
+				if j := q.get(id); j != nil {
+					for _, ev := range j.Events {
+						write(ev)
+					}
+				}
+				flusher.Flush()
+				return
+			}
+			write(ev)
+			flusher.Flush()
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	geminiprovider "github.com/llm-net/llm-api-plugin/cmd/gemini-cli/provider"
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/keypool"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+	"github.com/llm-net/llm-api-plugin/internal/output"
+	"github.com/llm-net/llm-api-plugin/pkg/gemini"
+)
+
+const (
+	defaultStoryboardImageModel      = "gemini-3-pro-image-preview"
+	defaultStoryboardVideoModel      = "jimeng-i2v-3-pro"
+	defaultStoryboardTransitionModel = "jimeng-i2v-startend-3-pro"
+	defaultStoryboardRatio           = "16:9"
+)
+
+// storyboard is the editable plan of a script, kept as
+// <dir>/storyboard.json. Re-running reads it instead of planning again, so
+// edited prompts and reordered shots take effect.
+type storyboard struct {
+	Title  string `json:"title,omitempty"`
+	Script string `json:"script"`
+	// ScriptSHA256 tells whether the script changed since it was planned.
+	ScriptSHA256 string `json:"script_sha256"`
+	// Style is added to every keyframe prompt; Reference is an image whose
+	// character or style every keyframe keeps.
+	Style      string `json:"style,omitempty"`
+	Reference  string `json:"reference,omitempty"`
+	Ratio      string `json:"ratio,omitempty"`
+	ImageModel string `json:"image_model"`
+	VideoModel string `json:"video_model"`
+	// Transitions animates each shot from its keyframe to the next one with
+	// TransitionModel; the last shot uses VideoModel.
+	Transitions     bool             `json:"transitions,omitempty"`
+	TransitionModel string           `json:"transition_model,omitempty"`
+	Shots           []storyboardShot `json:"shots"`
+}
+
+// storyboardShot is one shot, in clip order.
+type storyboardShot struct {
+	ID             string `json:"id"`
+	Description    string `json:"description,omitempty"`
+	KeyframePrompt string `json:"keyframe_prompt"`
+	MotionPrompt   string `json:"motion_prompt"`
+	// VideoParams override params of the video model, e.g. {"frames": "241"}.
+	VideoParams map[string]string `json:"video_params,omitempty"`
+
+	// Keyframe, Clip and State are filled in by the last run.
+	Keyframe string `json:"keyframe,omitempty"`
+	Clip     string `json:"clip,omitempty"`
+	State    string `json:"state,omitempty"`
+}
+
+const storyboardSystem = `You are a storyboard artist for short marketing videos.
+Break the script into consecutive shots of about 5 seconds each. For every shot write:
+- description: what happens in the shot, for the editor
+- keyframe_prompt: a self-contained image prompt for the first frame: subject, setting, composition, lighting; repeat the look of recurring characters in every shot
+- motion_prompt: a short video prompt for how the shot moves: camera movement and action
+Keep characters, wardrobe and visual style consistent across shots. Answer in the language of the script.`
+
+// storyboardSchema is the JSON answer of the planner.
+var storyboardSchema = map[string]interface{}{
+	"type": "OBJECT",
+	"properties": map[string]interface{}{
+		"title": map[string]interface{}{"type": "STRING"},
+		"shots": map[string]interface{}{
+			"type": "ARRAY",
+			"items": map[string]interface{}{
+				"type": "OBJECT",
+				"properties": map[string]interface{}{
+					"description":     map[string]interface{}{"type": "STRING"},
+					"keyframe_prompt": map[string]interface{}{"type": "STRING"},
+					"motion_prompt":   map[string]interface{}{"type": "STRING"},
+				},
+				"required": []string{"description", "keyframe_prompt", "motion_prompt"},
+			},
+		},
+	},
+	"required": []string{"title", "shots"},
+}
+
+// planStoryboard breaks script into shots with Gemini text generation.
+// shots, if positive, asks for that many shots.
+func planStoryboard(ctx context.Context, model, script string, shots int) (*storyboard, error) {
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		return nil, err
+	}
+	apiKey := config.ResolveAPIKey(cfg, config.ServiceGemini, "GEMINI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("Gemini API key not set: export GEMINI_API_KEY=<KEY> or run 'gemini-cli config set-key <KEY>'")
+	}
+	keys, err := keypool.New(cfg, config.ServiceGemini, apiKey, "")
+	if err != nil {
+		return nil, err
+	}
+
+	prompt := "Script:\n\n" + script
+	if shots > 0 {
+		prompt = fmt.Sprintf("Use exactly %d shots.\n\n%s", shots, prompt)
+	}
+	logging.Infof("Planning shots with %s...", model)
+	var resp *gemini.Response
+	_, err = keys.Try(func(k keypool.Key) error {
+		var err error
+		resp, err = geminiprovider.Client(k.Value).GenerateText(ctx, gemini.TextRequest{
+			Model:  model,
+			Prompt: prompt,
+			System: storyboardSystem,
+			Schema: storyboardSchema,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("plan shots: %w", err)
+	}
+
+	var sb storyboard
+	if err := json.Unmarshal([]byte(resp.Text()), &sb); err != nil {
+		return nil, fmt.Errorf("plan shots: unexpected answer: %w\nraw: %s", err, resp.Text())
+	}
+	if len(sb.Shots) == 0 {
+		return nil, fmt.Errorf("plan shots: no shots in answer")
+	}
+	return &sb, nil
+}
+
+// number assigns IDs to shots that have none, e.g. added by hand, and
+// checks that IDs are unique.
+func (sb *storyboard) number() error {
+	seen := map[string]bool{}
+	for _, s := range sb.Shots {
+		if s.ID != "" {
+			seen[s.ID] = true
+		}
+	}
+	next := 1
+	for i := range sb.Shots {
+		if sb.Shots[i].ID != "" {
+			continue
+		}
+		for seen[fmt.Sprintf("shot-%02d", next)] {
+			next++
+		}
+		sb.Shots[i].ID = fmt.Sprintf("shot-%02d", next)
+		seen[sb.Shots[i].ID] = true
+	}
+	ids := map[string]bool{}
+	for _, s := range sb.Shots {
+		if ids[s.ID] {
+			return fmt.Errorf("shot %q is defined twice", s.ID)
+		}
+		ids[s.ID] = true
+	}
+	return nil
+}
+
+// shotIndex resolves a --shot value: a shot ID or a 1-based position.
+func (sb *storyboard) shotIndex(value string) (int, error) {
+	for i, s := range sb.Shots {
+		if s.ID == value {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(value); err == nil && n >= 1 && n <= len(sb.Shots) {
+		return n - 1, nil
+	}
+	return 0, fmt.Errorf("no shot %q (%d shots)", value, len(sb.Shots))
+}
+
+// keyframePrompt is the image prompt of s with the storyboard's style.
+func (sb *storyboard) keyframePrompt(s storyboardShot) string {
+	prompt := s.KeyframePrompt
+	if sb.Style != "" {
+		prompt += "\n\nStyle: " + sb.Style
+	}
+	if sb.Reference != "" {
+		prompt += "\n\nKeep the character and visual style of the reference image."
+	}
+	return prompt
+}
+
+// pipeline turns the shots into pipeline steps: a keyframe per shot and a
+// clip animating it, from the keyframe to the next one with transitions.
+func (sb *storyboard) pipeline(name string) (*pipeline, error) {
+	p := &pipeline{Name: name, Vars: map[string]string{}}
+	imageTool, videoTool := findTool(sb.ImageModel), findTool(sb.VideoModel)
+	if imageTool == nil {
+		return nil, fmt.Errorf("unknown image model %q (see 'llm-api models')", sb.ImageModel)
+	}
+	if videoTool == nil {
+		return nil, fmt.Errorf("unknown video model %q (see 'llm-api models')", sb.VideoModel)
+	}
+	keyframeRef := func(i int) string {
+		return "{{steps." + sb.Shots[i].ID + "-keyframe.outputs[0]}}"
+	}
+
+	for i, s := range sb.Shots {
+		// The prompts come from the planner, so any "{{" in them is text.
+		keyframe := &pipelineStep{
+			ID:            s.ID + "-keyframe",
+			Model:         sb.ImageModel,
+			Prompt:        sb.keyframePrompt(s),
+			Params:        map[string]string{},
+			literalPrompt: true,
+		}
+		if _, ok := imageTool.Model.Params["ratio"]; ok && sb.Ratio != "" {
+			keyframe.Params["ratio"] = sb.Ratio
+		}
+		if sb.Reference != "" {
+			keyframe.Params["reference"] = sb.Reference
+		}
+
+		clip := &pipelineStep{
+			ID:            s.ID + "-clip",
+			Model:         sb.VideoModel,
+			Prompt:        s.MotionPrompt,
+			Params:        map[string]string{"image": keyframeRef(i)},
+			literalPrompt: true,
+		}
+		t := videoTool
+		if sb.Transitions && i+1 < len(sb.Shots) {
+			if t = findTool(sb.TransitionModel); t == nil {
+				return nil, fmt.Errorf("unknown transition model %q (see 'llm-api models')", sb.TransitionModel)
+			}
+			clip.Model = sb.TransitionModel
+			clip.Params["end-image"] = keyframeRef(i + 1)
+		}
+		if _, ok := t.Model.Params["ratio"]; ok && sb.Ratio != "" {
+			clip.Params["ratio"] = sb.Ratio
+		}
+		for name, value := range s.VideoParams {
+			clip.Params[name] = value
+		}
+		p.Steps = append(p.Steps, keyframe, clip)
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// record fills in the keyframes, clips and states of a run.
+func (sb *storyboard) record(sum pipelineSummary) {
+	steps := map[string]stepSummary{}
+	for _, st := range sum.Steps {
+		steps[st.ID] = st
+	}
+	first := func(st stepSummary) string {
+		if len(st.Outputs) == 0 {
+			return ""
+		}
+		return st.Outputs[0]
+	}
+	for i := range sb.Shots {
+		s := &sb.Shots[i]
+		keyframe, clip := steps[s.ID+"-keyframe"], steps[s.ID+"-clip"]
+		s.Keyframe, s.Clip = first(keyframe), first(clip)
+		s.State = clip.State
+		if keyframe.State != stepSucceeded && keyframe.State != stepCached {
+			s.State = keyframe.State
+		}
+	}
+}
+
+// clipList writes the local clips in shot order as an ffmpeg concat list:
+//
+//	ffmpeg -f concat -safe 0 -i clips.txt -c copy video.mp4
+//
+// Clips left at the provider are skipped with a warning: concat would need
+// -protocol_whitelist for them, and their URLs expire.
+func (sb *storyboard) clipList(path string) error {
+	var b strings.Builder
+	for _, s := range sb.Shots {
+		if s.Clip == "" {
+			continue
+		}
+		if !output.Local(s.Clip) {
+			logging.Warnf("%s: clip %s is not a local file; left out of %s", s.ID, s.Clip, filepath.Base(path))
+			continue
+		}
+		fmt.Fprintf(&b, "file '%s'\n", strings.ReplaceAll(s.Clip, "'", `'\''`))
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+func handleStoryboard() {
+	var scriptPath, dir, textModel, style, reference, ratio, imageModel, videoModel, transitionModel string
+	var planOnly, replan, transitions, noTransitions bool
+	var shotValues []string
+	shots := 0
+	parallel := defaultPipelineParallel
+
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--dir":
+			i++
+			if i < len(args) {
+				dir = args[i]
+			}
+		case "--text-model":
+			i++
+			if i < len(args) {
+				textModel = args[i]
+			}
+		case "--style":
+			i++
+			if i < len(args) {
+				style = args[i]
+			}
+		case "--reference":
+			i++
+			if i < len(args) {
+				reference = args[i]
+			}
+		case "--ratio":
+			i++
+			if i < len(args) {
+				ratio = args[i]
+			}
+		case "--image-model":
+			i++
+			if i < len(args) {
+				imageModel = args[i]
+			}
+		case "--video-model":
+			i++
+			if i < len(args) {
+				videoModel = args[i]
+			}
+		case "--transition-model":
+			i++
+			if i < len(args) {
+				transitionModel = args[i]
+			}
+		case "--shot":
+			i++
+			if i < len(args) {
+				shotValues = append(shotValues, args[i])
+			}
+		case "--shots", "--parallel":
+			flag := args[i]
+			i++
+			if i < len(args) {
+				v, err := strconv.Atoi(args[i])
+				if err != nil || v <= 0 {
+					fmt.Fprintf(os.Stderr, "Error: invalid %s %q\n", flag, args[i])
+					os.Exit(1)
+				}
+				if flag == "--shots" {
+					shots = v
+				} else {
+					parallel = v
+				}
+			}
+		case "--transitions":
+			transitions = true
+		case "--no-transitions":
+			noTransitions = true
+		case "--plan":
+			planOnly = true
+		case "--replan":
+			replan = true
+		default:
+			if strings.HasPrefix(args[i], "-") || scriptPath != "" {
+				fmt.Fprintf(os.Stderr, "Unknown flag: %s\n", args[i])
+				os.Exit(1)
+			}
+			scriptPath = args[i]
+		}
+	}
+	if scriptPath == "" {
+		fmt.Fprintf(os.Stderr, "Error: usage: llm-api storyboard <script.md> [flags]\n")
+		os.Exit(1)
+	}
+
+	script, err := os.ReadFile(scriptPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	sum256 := sha256.Sum256(script)
+	scriptHash := hex.EncodeToString(sum256[:])
+	name := strings.TrimSuffix(filepath.Base(scriptPath), filepath.Ext(scriptPath))
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(scriptPath), "storyboards", name)
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	boardPath := filepath.Join(dir, "storyboard.json")
+
+	// An existing storyboard may have been edited; it wins over the script
+	// unless --replan.
+	var sb *storyboard
+	if data, err := os.ReadFile(boardPath); err == nil && !replan {
+		sb = &storyboard{}
+		if err := json.Unmarshal(data, sb); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", boardPath, err)
+			os.Exit(1)
+		}
+		if sb.ScriptSHA256 != scriptHash {
+			logging.Warnf("%s changed since the storyboard was planned; pass --replan to plan it again", scriptPath)
+		}
+		logging.Infof("Using %s (%d shots)", boardPath, len(sb.Shots))
+	} else {
+		if textModel == "" {
+			textModel = gemini.DefaultTextModel
+		}
+		sb, err = planStoryboard(context.Background(), textModel, string(script), shots)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		sb.ScriptSHA256 = scriptHash
+		sb.ImageModel = defaultStoryboardImageModel
+		sb.VideoModel = defaultStoryboardVideoModel
+		sb.TransitionModel = defaultStoryboardTransitionModel
+		sb.Ratio = defaultStoryboardRatio
+	}
+	if abs, err := filepath.Abs(scriptPath); err == nil {
+		sb.Script = abs
+	}
+
+	// Flags override the saved settings and are saved with them.
+	if style != "" {
+		sb.Style = style
+	}
+	if reference != "" {
+		// Local references are kept absolute, so re-runs work from anywhere.
+		if _, err := os.Stat(reference); err == nil {
+			reference, _ = filepath.Abs(reference)
+		}
+		sb.Reference = reference
+	}
+	if ratio != "" {
+		sb.Ratio = ratio
+	}
+	if imageModel != "" {
+		sb.ImageModel = imageModel
+	}
+	if videoModel != "" {
+		sb.VideoModel = videoModel
+	}
+	if transitionModel != "" {
+		sb.TransitionModel = transitionModel
+	}
+	if transitions {
+		sb.Transitions = true
+	}
+	if noTransitions {
+		sb.Transitions = false
+	}
+	if sb.Transitions && sb.TransitionModel == "" {
+		sb.TransitionModel = defaultStoryboardTransitionModel
+	}
+	if err := sb.number(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", boardPath, err)
+		os.Exit(1)
+	}
+
+	p, err := sb.pipeline(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", boardPath, err)
+		os.Exit(1)
+	}
+	force := map[string]bool{}
+	for _, v := range shotValues {
+		i, err := sb.shotIndex(v)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --shot: %v\n", err)
+			os.Exit(1)
+		}
+		force[sb.Shots[i].ID+"-keyframe"] = true
+		force[sb.Shots[i].ID+"-clip"] = true
+	}
+	if err := writeJSONFile(boardPath, sb); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if planOnly {
+		logging.Infof("Storyboard saved: %s (edit it, then run again without --plan)", boardPath)
+		data, _ := json.MarshalIndent(sb, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	sum := executePipeline(p, dir, force, parallel)
+	sb.record(sum)
+	if err := writeJSONFile(boardPath, sb); err != nil {
+		logging.Warnf("save storyboard: %v", err)
+	}
+	if err := sb.clipList(filepath.Join(dir, "clips.txt")); err != nil {
+		logging.Warnf("write clip list: %v", err)
+	}
+	data, _ := json.MarshalIndent(sb, "", "  ")
+	fmt.Println(string(data))
+
+	switch sum.State {
+	case stepCancelled:
+		fmt.Fprintf(os.Stderr, "Error: storyboard cancelled; re-run to resume\n")
+		os.Exit(1)
+	case stepFailed:
+		fmt.Fprintf(os.Stderr, "Error: some shots failed; re-run to retry them (finished shots are cached)\n")
+		os.Exit(1)
+	}
+}
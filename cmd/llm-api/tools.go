@@ -0,0 +1,929 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	arkprovider "github.com/llm-net/llm-api-plugin/cmd/ark-cli/provider"
+	geminiprovider "github.com/llm-net/llm-api-plugin/cmd/gemini-cli/provider"
+	jimengprovider "github.com/llm-net/llm-api-plugin/cmd/jimeng-cli/provider"
+	topviewprovider "github.com/llm-net/llm-api-plugin/cmd/topview-cli/provider"
+	"github.com/llm-net/llm-api-plugin/internal/assets"
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/input"
+	"github.com/llm-net/llm-api-plugin/internal/keypool"
+	"github.com/llm-net/llm-api-plugin/internal/models"
+	"github.com/llm-net/llm-api-plugin/internal/notify"
+	"github.com/llm-net/llm-api-plugin/internal/output"
+	"github.com/llm-net/llm-api-plugin/internal/probe"
+	"github.com/llm-net/llm-api-plugin/internal/storage"
+	"github.com/llm-net/llm-api-plugin/internal/timing"
+	"github.com/llm-net/llm-api-plugin/pkg/gemini"
+	"github.com/llm-net/llm-api-plugin/pkg/topview"
+)
+
+// Prompt usage of a tool.
+const (
+	promptNone     = ""
+	promptOptional = "optional"
+	promptRequired = "required"
+)
+
+// hooks receives notifications while a tool runs. Nil fields are ignored.
+type hooks struct {
+	// Progress receives human-readable progress messages.
+	Progress func(message string)
+	// Submitted is called once the provider has accepted the task, with the
+	// ID of the pooled key that submitted it and the object keys of the
+	// inputs staged for it.
+	Submitted func(taskID, keyID string, staged []string)
+
+	// timings measures the phases of the run; generate sets it.
+	timings *timing.Timings
+}
+
+// timer returns the Timings of the run, or nil.
+func (h *hooks) timer() *timing.Timings {
+	if h == nil {
+		return nil
+	}
+	return h.timings
+}
+
+func (h *hooks) progress(message string) {
+	if h != nil && h.Progress != nil {
+		h.Progress(message)
+	}
+}
+
+// uploadProgress returns a progress func reporting an upload as progress
+// messages, at most every few seconds.
+func (h *hooks) uploadProgress() func(sent, total int64) {
+	return httpclient.Throttle(2*time.Second, func(sent, total int64) {
+		h.progress("Uploaded " + httpclient.FormatProgress(sent, total))
+	})
+}
+
+func (h *hooks) submitted(taskID string, key keypool.Key, staged []string) {
+	h.progress("Task created: " + taskID)
+	if h != nil && h.Submitted != nil {
+		h.Submitted(taskID, key.ID(), staged)
+	}
+}
+
+// generateRequest is one invocation of a registry model.
+// Params are keyed by the registry param names, which match the CLI flags.
+type generateRequest struct {
+	Model  string            `json:"model"`
+	Prompt string            `json:"prompt,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+	Output string            `json:"output,omitempty"`
+	// OutputDir and OutputTemplate name the outputs when Output is empty,
+	// like --output-dir and --output-template.
+	OutputDir      string `json:"output_dir,omitempty"`
+	OutputTemplate string `json:"output_template,omitempty"`
+	// NoDownload returns the provider's result URLs as remote artifacts
+	// instead of downloading them.
+	NoDownload bool `json:"no_download,omitempty"`
+	// TaskID resumes polling an already submitted task instead of submitting
+	// a new one. Synchronous models ignore it.
+	TaskID string `json:"task_id,omitempty"`
+	// KeyID names the pooled key that submitted TaskID, which the task is
+	// polled with (see keypool).
+	KeyID string `json:"key_id,omitempty"`
+	// Staged lists the object keys of the inputs staged for TaskID, which
+	// the resumed run deletes once the task has ended. Callers recording the
+	// task set it; it is never taken from a client.
+	Staged []string `json:"-"`
+	// Notify lists completion notification targets (see 'config notify').
+	Notify []string `json:"notify,omitempty"`
+	// Profile selects a credential profile instead of the server's default.
+	Profile string `json:"profile,omitempty"`
+	// Publish uploads the artifacts to object storage (see 'config storage').
+	Publish bool `json:"publish,omitempty"`
+}
+
+// artifact is a file produced by a tool.
+type artifact struct {
+	// Path is the local file; it is empty for a remote artifact.
+	Path      string `json:"path,omitempty"`
+	MIMEType  string `json:"mime_type"`
+	Size      int64  `json:"size,omitempty"`
+	SourceURL string `json:"source_url,omitempty"`
+	// Remote marks an artifact that exists only at SourceURL (no_download),
+	// which stops working at ExpiresAt when the URL's signature tells.
+	Remote    bool       `json:"remote,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// URL is the shareable object storage URL of a published artifact.
+	URL string `json:"url,omitempty"`
+	// Video is the probe of a downloaded video; Warnings lists where it
+	// differs from the request.
+	Video    *probe.Info `json:"video,omitempty"`
+	Warnings []string    `json:"warnings,omitempty"`
+}
+
+// generateResult is the outcome of a successful generateRequest.
+type generateResult struct {
+	Tool      string     `json:"tool"`
+	Model     string     `json:"model"`
+	TaskID    string     `json:"task_id,omitempty"`
+	Text      string     `json:"text,omitempty"`
+	Artifacts []artifact `json:"artifacts"`
+	// Timings breaks the run down into phases (upload, submit, remote
+	// statuses, download, ...).
+	Timings *timing.Report `json:"timings,omitempty"`
+}
+
+// tool binds one registry model to the provider code that runs it.
+type tool struct {
+	CLI    string
+	Model  *models.Model
+	Prompt string
+	run    func(ctx context.Context, t *tool, req *generateRequest, h *hooks) (*generateResult, error)
+}
+
+// allTools returns one tool per model of every CLI registry.
+func allTools() []*tool {
+	var tools []*tool
+	for i := range geminiprovider.Registry.Models {
+		tools = append(tools, &tool{
+			CLI:    geminiprovider.Registry.Tool,
+			Model:  &geminiprovider.Registry.Models[i],
+			Prompt: promptRequired,
+			run:    runGemini,
+		})
+	}
+	for i := range arkprovider.Registry.Models {
+		m := &arkprovider.Registry.Models[i]
+		t := &tool{CLI: arkprovider.Registry.Tool, Model: m, Prompt: promptRequired, run: runArk}
+		if arkprovider.ModelProvider[m.Name] == "jimeng" {
+			t.run = runArkJimeng
+		}
+		tools = append(tools, t)
+	}
+	for i := range jimengprovider.Registry.Models {
+		m := &jimengprovider.Registry.Models[i]
+		t := &tool{CLI: jimengprovider.Registry.Tool, Model: m}
+		switch jimengprovider.ModelProvider[m.Name] {
+		case "action-imitation-v2":
+			t.run = runActionImitationV2
+		case "omnihuman":
+			t.Prompt = promptOptional
+			t.run = runOmniHuman
+		}
+		tools = append(tools, t)
+	}
+	for i := range topviewprovider.Registry.Models {
+		tools = append(tools, &tool{
+			CLI:   topviewprovider.Registry.Tool,
+			Model: &topviewprovider.Registry.Models[i],
+			run:   runTopView,
+		})
+	}
+	return tools
+}
+
+// findTool returns the tool serving the given model, or nil.
+func findTool(model string) *tool {
+	for _, t := range allTools() {
+		if t.Model.Name == model {
+			return t
+		}
+	}
+	return nil
+}
+
+// generate runs req against its model and blocks until the artifacts are saved.
+func generate(ctx context.Context, req *generateRequest, h *hooks) (*generateResult, error) {
+	t := findTool(req.Model)
+	if t == nil {
+		return nil, fmt.Errorf("unknown model %q", req.Model)
+	}
+	if t.Prompt == promptRequired && req.Prompt == "" {
+		return nil, fmt.Errorf("prompt is required for %s", req.Model)
+	}
+	if req.Output == output.Stdout {
+		return nil, fmt.Errorf("output: stdout output is not supported by llm-api")
+	}
+	if req.NoDownload && req.Publish {
+		return nil, fmt.Errorf("publish needs the artifacts downloaded; it cannot be combined with no_download")
+	}
+	names := make([]string, 0, len(t.Model.Params))
+	for name := range t.Model.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// Fill unset params from the project and user defaults.
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if req.Params[name] != "" {
+			continue
+		}
+		if v, source := cfg.ParamDefault(req.Model, name); source != "" {
+			if req.Params == nil {
+				req.Params = map[string]string{}
+			}
+			req.Params[name] = v
+		}
+	}
+	for _, name := range names {
+		if t.Model.Params[name].Required && req.Params[name] == "" {
+			return nil, fmt.Errorf("%s is required for %s", name, req.Model)
+		}
+	}
+	var run hooks
+	if h != nil {
+		run = *h
+	}
+	run.timings = timing.New(t.CLI, req.Model)
+	h = &run
+
+	result, err := t.run(ctx, t, req, h)
+	if err == nil && !req.NoDownload && (req.Publish || storage.PublishEnabled(cfg)) {
+		err = publish(req, result, h)
+	}
+	// A cancelled run did not finish, so it is not a data point.
+	if ctx.Err() == nil {
+		if err := run.timings.Export(cfg, notify.Classify(err)); err != nil {
+			h.progress(fmt.Sprintf("Warning: failed to export metrics: %v", err))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Timings = run.timings.Report()
+	return result, nil
+}
+
+// publish uploads the artifacts of result to object storage.
+func publish(req *generateRequest, result *generateResult, h *hooks) error {
+	cfg, err := loadConfig(req)
+	if err != nil {
+		return err
+	}
+	for i := range result.Artifacts {
+		a := &result.Artifacts[i]
+		h.progress(fmt.Sprintf("Publishing %s...", a.Path))
+		start := time.Now()
+		if a.URL, err = storage.Publish(cfg, a.Path); err != nil {
+			return fmt.Errorf("publish %s: %w", a.Path, err)
+		}
+		h.timer().Measure(timing.Publish, start, 0)
+		h.progress("Published: " + a.URL)
+	}
+	return nil
+}
+
+// param returns the named parameter, falling back to the registry default.
+func (t *tool) param(req *generateRequest, name string) string {
+	if v := req.Params[name]; v != "" {
+		return v
+	}
+	return t.Model.Params[name].Default
+}
+
+// intParam is param parsed as an integer; unparsable values yield 0.
+func (t *tool) intParam(req *generateRequest, name string) int {
+	v, _ := strconv.Atoi(t.param(req, name))
+	return v
+}
+
+// outputOptions returns where the outputs of req go, falling back to the
+// output defaults of the configuration.
+func outputOptions(req *generateRequest) (output.Options, error) {
+	cfg, err := loadConfig(req)
+	if err != nil {
+		return output.Options{}, err
+	}
+	o := output.Options{Path: req.Output, Dir: req.OutputDir, Template: req.OutputTemplate}
+	return o.Defaults(cfg)
+}
+
+// inputParam resolves the media param name, or its legacy <name>-file alias:
+// a local path, URL, data URI or asset:<name>. Stdin carries the MCP
+// transport, so "-" is rejected.
+func inputParam(req *generateRequest, name string) (*input.Input, error) {
+	value := req.Params[name]
+	if v := req.Params[name+"-file"]; v != "" {
+		value = v
+	}
+	if value == "-" {
+		return nil, fmt.Errorf("%s: stdin input is not supported by llm-api", name)
+	}
+	in, err := input.Resolve(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return in, nil
+}
+
+// imageParam resolves an image param for providers that take either a URL
+// or inline base64.
+func imageParam(req *generateRequest, name string) (url string, b64 *httpclient.Base64, err error) {
+	in, err := inputParam(req, name)
+	if err != nil {
+		return "", nil, err
+	}
+	return in.URLOrBase64()
+}
+
+// stageParam resolves a media param for providers that only accept URLs,
+// uploading anything that is not a URL to object storage.
+func stageParam(s *storage.Stager, req *generateRequest, name string, h *hooks) (string, error) {
+	in, err := inputParam(req, name)
+	if err != nil {
+		return "", err
+	}
+	if in == nil || in.IsURL() {
+		return in.StageURL(s)
+	}
+	h.progress(fmt.Sprintf("Staging %s to object storage...", in))
+	start := time.Now()
+	s.Progress = h.uploadProgress()
+	url, err := in.StageURL(s)
+	if err != nil {
+		return "", err
+	}
+	h.timer().Measure(timing.Stage, start, 0)
+	return url, nil
+}
+
+// cleanupStaged deletes the files staged for a task once it has ended. A
+// run cancelled after submitting taskID leaves them in place: the provider
+// may still be fetching them, and the run resuming the task deletes them
+// (see generateRequest.Staged).
+func cleanupStaged(ctx context.Context, s *storage.Stager, taskID string, h *hooks) {
+	if !s.Staged() || (taskID != "" && ctx.Err() != nil) {
+		return
+	}
+	if err := s.Cleanup(); err != nil {
+		h.progress(fmt.Sprintf("Warning: failed to delete staged files: %v", err))
+	}
+}
+
+// dropStaged deletes the files staged for a task that will not be resumed,
+// such as one whose run the client cancelled.
+func dropStaged(req *generateRequest, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	cfg, err := loadConfig(req)
+	if err != nil {
+		return err
+	}
+	s := storage.NewStager(cfg)
+	s.Adopt(keys...)
+	return s.Cleanup()
+}
+
+// downloadArtifact downloads a provider result URL through the provider's
+// client, naming the file after req and taskID.
+func downloadArtifact(ctx context.Context, client *httpclient.Client, url string, t *tool, req *generateRequest, taskID string, h *hooks) (artifact, error) {
+	if req.NoDownload {
+		return remoteArtifact(url, "video/mp4", h), nil
+	}
+	out, err := outputOptions(req)
+	if err != nil {
+		return artifact{}, err
+	}
+	h.progress("Downloading video...")
+	start := time.Now()
+	v := output.Vars{Model: req.Model, Prompt: req.Prompt, TaskID: taskID, Seed: output.Seed(t.intParam(req, "seed")), Ext: "mp4"}
+	path, size, err := output.Download(ctx, client, url, out, v, nil)
+	if err != nil {
+		return artifact{}, err
+	}
+	h.timer().Measure(timing.Download, start, size)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	h.progress(fmt.Sprintf("Video saved: %s (%d bytes)", path, size))
+	a := artifact{Path: path, MIMEType: "video/mp4", Size: size, SourceURL: url}
+	if output.Local(path) {
+		if a.Video, a.Warnings, err = probe.Verify(path, expectVideo(t, req)); err != nil {
+			return artifact{}, err
+		}
+		for _, w := range a.Warnings {
+			h.progress("Warning: " + w)
+		}
+	}
+	return a, nil
+}
+
+// expectVideo derives what a video should look like from the params of req.
+func expectVideo(t *tool, req *generateRequest) probe.Expect {
+	var want probe.Expect
+	// An image-to-video clip takes the shape of its first frame
+	if _, ok := t.Model.Params["ratio"]; ok && t.param(req, "image") == "" && t.param(req, "image-file") == "" {
+		want.Ratio = t.param(req, "ratio")
+	}
+	if _, ok := t.Model.Params["resolution"]; ok {
+		want.Resolution = t.param(req, "resolution")
+	}
+	if _, ok := t.Model.Params["duration"]; ok {
+		want.Duration, _ = strconv.ParseFloat(t.param(req, "duration"), 64)
+	}
+	if _, ok := t.Model.Params["frames"]; ok {
+		want.Frames = t.intParam(req, "frames")
+	}
+	// audio is a switch (Seedance) or an input the video speaks (avatars)
+	switch audio := t.param(req, "audio"); audio {
+	case "":
+	case "false":
+		want.Audio = probe.Bool(false)
+	default:
+		want.Audio = probe.Bool(true)
+	}
+	return want
+}
+
+// remoteArtifact describes a provider result URL left undownloaded.
+func remoteArtifact(url, mimeType string, h *hooks) artifact {
+	a := artifact{MIMEType: mimeType, SourceURL: url, Remote: true}
+	if t, ok := output.Expiry(url); ok {
+		a.ExpiresAt = &t
+	}
+	h.progress(fmt.Sprintf("Video left at the provider (%s)", output.ExpiryNote(url)))
+	return a
+}
+
+func (h *hooks) status(status string) {
+	h.timer().Status(status)
+	h.progress("Status: " + status)
+}
+
+func runGemini(ctx context.Context, t *tool, req *generateRequest, h *hooks) (*generateResult, error) {
+	cfg, err := loadConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	apiKey := config.ResolveAPIKey(cfg, config.ServiceGemini, "GEMINI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("Gemini API key not set: export GEMINI_API_KEY=<KEY> or run 'gemini-cli config set-key <KEY>'")
+	}
+	keys, err := keypool.New(cfg, config.ServiceGemini, apiKey, "")
+	if err != nil {
+		return nil, err
+	}
+	if req.NoDownload {
+		return nil, fmt.Errorf("no_download is not supported by %s: images are returned inline, not as URLs", req.Model)
+	}
+	out, err := outputOptions(req)
+	if err != nil {
+		return nil, err
+	}
+
+	imageReq := gemini.ImageRequest{
+		Model:       req.Model,
+		Prompt:      req.Prompt,
+		AspectRatio: t.param(req, "ratio"),
+		ImageSize:   t.param(req, "size"),
+	}
+	var assetRefs []*input.Input
+	switch ref := req.Params["reference"]; {
+	case ref == "":
+	case ref == "-":
+		return nil, fmt.Errorf("reference: stdin input is not supported by llm-api")
+	case assets.IsRef(ref):
+		in, err := geminiprovider.ReferenceAsset(ref)
+		if err != nil {
+			return nil, fmt.Errorf("reference: %w", err)
+		}
+		assetRefs = append(assetRefs, in)
+	default:
+		data, err := geminiprovider.Reference(ref)
+		if err != nil {
+			return nil, fmt.Errorf("reference: %w", err)
+		}
+		imageReq.References = append(imageReq.References, data)
+	}
+
+	h.progress(fmt.Sprintf("Generating with model %s...", req.Model))
+	start := time.Now()
+	var resp *geminiprovider.Response
+	key, err := keys.Try(func(k keypool.Key) error {
+		client := geminiprovider.Client(k.Value)
+		keyReq := imageReq
+		var err error
+		keyReq.ReferenceFiles, err = geminiprovider.ReferenceFiles(ctx, client, k.ID(), assetRefs)
+		if err != nil {
+			return err
+		}
+		resp, err = client.GenerateContent(ctx, keyReq)
+		return err
+	})
+	h.timer().Key(keys.Service, key.Masked())
+	if err != nil {
+		return nil, err
+	}
+	h.timer().Measure(timing.Generate, start, 0)
+	if len(resp.Candidates) == 0 {
+		return nil, fmt.Errorf("no candidates in response")
+	}
+
+	result := &generateResult{Tool: t.CLI, Model: req.Model}
+	var texts []string
+	var imageCount int
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+		if part.InlineData == nil || !strings.HasPrefix(part.InlineData.MIMEType, "image/") {
+			continue
+		}
+		imageCount++
+		imgData, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+		if err != nil {
+			return nil, fmt.Errorf("decode image: %w", err)
+		}
+
+		outPath, err := output.Save(out, output.Vars{Model: req.Model, Prompt: req.Prompt, Index: imageCount, Ext: "png"},
+			imgData, part.InlineData.MIMEType)
+		if err != nil {
+			return nil, fmt.Errorf("save image: %w", err)
+		}
+		if abs, err := filepath.Abs(outPath); err == nil {
+			outPath = abs
+		}
+		h.progress(fmt.Sprintf("Image saved: %s (%d bytes)", outPath, len(imgData)))
+		result.Artifacts = append(result.Artifacts, artifact{Path: outPath, MIMEType: part.InlineData.MIMEType, Size: int64(len(imgData))})
+	}
+	result.Text = strings.Join(texts, "\n")
+	return result, nil
+}
+
+func runArk(ctx context.Context, t *tool, req *generateRequest, h *hooks) (*generateResult, error) {
+	cfg, err := loadConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	apiKey := config.ResolveAPIKey(cfg, config.ServiceArk, "ARK_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("Ark API key not set: export ARK_API_KEY=<KEY> or run 'ark-cli config set-key <KEY>'")
+	}
+	keys, err := keypool.New(cfg, config.ServiceArk, apiKey, "")
+	if err != nil {
+		return nil, err
+	}
+
+	stager := storage.NewStager(cfg)
+	stager.Adopt(req.Staged...)
+
+	taskID, key := req.TaskID, keys.Get(req.KeyID)
+	defer func() { cleanupStaged(ctx, stager, taskID, h) }()
+	if taskID == "" {
+		image, err := stageParam(stager, req, "image", h)
+		if err != nil {
+			return nil, err
+		}
+		h.progress(fmt.Sprintf("Creating task with model %s...", req.Model))
+		start := time.Now()
+		key, err = keys.Try(func(k keypool.Key) error {
+			var err error
+			taskID, err = arkprovider.CreateTask(ctx, k.Value, req.Model, req.Prompt, image,
+				t.param(req, "resolution"), t.param(req, "duration"), t.param(req, "ratio"), t.param(req, "audio"))
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create task: %w", err)
+		}
+		h.timer().Measure(timing.Submit, start, 0)
+		h.submitted(taskID, key, stager.Keys())
+	}
+	h.timer().Key(keys.Service, key.Masked())
+
+	h.timer().Wait()
+	result, err := arkprovider.Seedance(key.Value).WaitForTask(ctx, taskID, h.status)
+	h.timer().EndWait()
+	if err != nil {
+		return nil, err
+	}
+	if result.VideoURL() == "" {
+		return nil, fmt.Errorf("task succeeded but no video URL in response")
+	}
+
+	a, err := downloadArtifact(ctx, arkprovider.SeedanceAPI, result.VideoURL(), t, req, taskID, h)
+	if err != nil {
+		return nil, err
+	}
+	return &generateResult{Tool: t.CLI, Model: req.Model, TaskID: taskID, Artifacts: []artifact{a}}, nil
+}
+
+func runArkJimeng(ctx context.Context, t *tool, req *generateRequest, h *hooks) (*generateResult, error) {
+	keys, err := jimengKeys(req)
+	if err != nil {
+		return nil, err
+	}
+
+	reqKey := arkprovider.JimengReqKey[req.Model]
+
+	taskID, key := req.TaskID, keys.Get(req.KeyID)
+	if taskID == "" {
+		image, imageBase64, err := imageParam(req, "image")
+		if err != nil {
+			return nil, err
+		}
+		endImage, endImageBase64, err := imageParam(req, "end-image")
+		if err != nil {
+			return nil, err
+		}
+
+		h.progress(fmt.Sprintf("Submitting video generation task (%s)...", req.Model))
+		start := time.Now()
+		opts := arkprovider.JimengSubmitOpts{
+			ReqKey:           reqKey,
+			Prompt:           req.Prompt,
+			FirstFrameImage:  image,
+			FirstFrameBase64: imageBase64,
+			EndFrameImage:    endImage,
+			EndFrameBase64:   endImageBase64,
+			AspectRatio:      t.param(req, "ratio"),
+			Frames:           t.intParam(req, "frames"),
+			Seed:             t.intParam(req, "seed"),
+			Progress:         h.uploadProgress(),
+		}
+		key, err = keys.Try(func(k keypool.Key) error {
+			var err error
+			taskID, err = arkprovider.Jimeng(k.Value, k.Secret).SubmitVideo(ctx, opts)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("submit task: %w", err)
+		}
+		h.timer().Measure(timing.Submit, start, 0)
+		h.submitted(taskID, key, nil)
+	}
+	h.timer().Key(keys.Service, key.Masked())
+
+	h.timer().Wait()
+	result, err := arkprovider.Jimeng(key.Value, key.Secret).WaitVideo(ctx, reqKey, taskID, h.status)
+	h.timer().EndWait()
+	if err != nil {
+		return nil, err
+	}
+	if result.VideoURL == "" {
+		return nil, fmt.Errorf("task succeeded but no video URL in response")
+	}
+
+	a, err := downloadArtifact(ctx, arkprovider.JimengAPI, result.VideoURL, t, req, taskID, h)
+	if err != nil {
+		return nil, err
+	}
+	return &generateResult{Tool: t.CLI, Model: req.Model, TaskID: taskID, Artifacts: []artifact{a}}, nil
+}
+
+func runActionImitationV2(ctx context.Context, t *tool, req *generateRequest, h *hooks) (*generateResult, error) {
+	keys, err := jimengKeys(req)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	stager := storage.NewStager(cfg)
+	stager.Adopt(req.Staged...)
+
+	taskID, key := req.TaskID, keys.Get(req.KeyID)
+	defer func() { cleanupStaged(ctx, stager, taskID, h) }()
+	if taskID == "" {
+		image, imageBase64, err := imageParam(req, "image")
+		if err != nil {
+			return nil, err
+		}
+		if image == "" && imageBase64 == nil {
+			return nil, fmt.Errorf("image is required for %s", req.Model)
+		}
+
+		video, err := stageParam(stager, req, "video", h)
+		if err != nil {
+			return nil, err
+		}
+		if video == "" {
+			return nil, fmt.Errorf("video is required for %s", req.Model)
+		}
+		pr := jimengprovider.ActionImitationV2Request{
+			ImageURL:    image,
+			ImageBase64: imageBase64,
+			VideoURL:    video,
+			Progress:    h.uploadProgress(),
+		}
+		if v, err := strconv.ParseBool(req.Params["cut-first-second"]); err == nil {
+			pr.CutFirstSecond = &v
+		}
+
+		h.progress("Submitting action imitation task...")
+		start := time.Now()
+		key, err = keys.Try(func(k keypool.Key) error {
+			var err error
+			taskID, err = jimengprovider.Client(k.Value, k.Secret).SubmitActionImitation(ctx, pr)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("submit task: %w", err)
+		}
+		h.timer().Measure(timing.Submit, start, 0)
+		h.submitted(taskID, key, stager.Keys())
+	}
+	h.timer().Key(keys.Service, key.Masked())
+
+	h.timer().Wait()
+	result, err := jimengprovider.Client(key.Value, key.Secret).WaitActionImitation(ctx, taskID, h.status)
+	h.timer().EndWait()
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := downloadArtifact(ctx, jimengprovider.API, result.VideoURL, t, req, taskID, h)
+	if err != nil {
+		return nil, err
+	}
+	return &generateResult{Tool: t.CLI, Model: req.Model, TaskID: taskID, Artifacts: []artifact{a}}, nil
+}
+
+func runOmniHuman(ctx context.Context, t *tool, req *generateRequest, h *hooks) (*generateResult, error) {
+	keys, err := jimengKeys(req)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	stager := storage.NewStager(cfg)
+	stager.Adopt(req.Staged...)
+
+	taskID, key := req.TaskID, keys.Get(req.KeyID)
+	defer func() { cleanupStaged(ctx, stager, taskID, h) }()
+	if taskID == "" {
+		image, imageBase64, err := imageParam(req, "image")
+		if err != nil {
+			return nil, err
+		}
+		if image == "" && imageBase64 == nil {
+			return nil, fmt.Errorf("image is required for %s", req.Model)
+		}
+		audio, err := stageParam(stager, req, "audio", h)
+		if err != nil {
+			return nil, err
+		}
+		if audio == "" {
+			return nil, fmt.Errorf("audio is required for %s", req.Model)
+		}
+		fastMode, _ := strconv.ParseBool(req.Params["fast-mode"])
+
+		h.progress("Submitting OmniHuman task...")
+		start := time.Now()
+		pr := jimengprovider.OmniHumanRequest{
+			ImageURL:         image,
+			ImageBase64:      imageBase64,
+			AudioURL:         audio,
+			Prompt:           req.Prompt,
+			Seed:             t.intParam(req, "seed"),
+			OutputResolution: t.intParam(req, "resolution"),
+			FastMode:         fastMode,
+			Progress:         h.uploadProgress(),
+		}
+		key, err = keys.Try(func(k keypool.Key) error {
+			var err error
+			taskID, err = jimengprovider.Client(k.Value, k.Secret).SubmitOmniHuman(ctx, pr)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("submit task: %w", err)
+		}
+		h.timer().Measure(timing.Submit, start, 0)
+		h.submitted(taskID, key, stager.Keys())
+	}
+	h.timer().Key(keys.Service, key.Masked())
+
+	h.timer().Wait()
+	result, err := jimengprovider.Client(key.Value, key.Secret).WaitOmniHuman(ctx, taskID, h.status)
+	h.timer().EndWait()
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := downloadArtifact(ctx, jimengprovider.API, result.VideoURL, t, req, taskID, h)
+	if err != nil {
+		return nil, err
+	}
+	return &generateResult{Tool: t.CLI, Model: req.Model, TaskID: taskID, Artifacts: []artifact{a}}, nil
+}
+
+func runTopView(ctx context.Context, t *tool, req *generateRequest, h *hooks) (*generateResult, error) {
+	cfg, err := loadConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	apiKey := config.ResolveAPIKey(cfg, config.ServiceTopView, "TOPVIEW_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("TopView API key not set: export TOPVIEW_API_KEY=<KEY> or run 'topview-cli config set-key <KEY>'")
+	}
+	uid, _ := config.Lookup(cfg, config.ServiceTopView, config.FieldUID, "TOPVIEW_UID")
+	keys, err := keypool.New(cfg, config.ServiceTopView, apiKey, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	taskID, key := req.TaskID, keys.Get(req.KeyID)
+	if taskID == "" {
+		imageIn, err := inputParam(req, "image")
+		if err != nil {
+			return nil, err
+		}
+		audioIn, err := inputParam(req, "audio")
+		if err != nil {
+			return nil, err
+		}
+		if imageIn == nil || audioIn == nil {
+			return nil, fmt.Errorf("image and audio are required for %s", req.Model)
+		}
+
+		// Uploaded files belong to the account of the key, so the uploads
+		// move to the next key together with the submission
+		var start time.Time
+		key, err = keys.Try(func(k keypool.Key) error {
+			client := topviewprovider.Client(k.Value, k.Secret)
+			h.progress("Uploading image to TopView...")
+			imageFileID, err := topviewprovider.UploadInput(ctx, client, k.ID(), imageIn, topview.ImageFormat, h.timer(), h.uploadProgress())
+			if err != nil {
+				return fmt.Errorf("upload image: %w", err)
+			}
+			h.progress("Uploading audio to TopView...")
+			audioFileID, err := topviewprovider.UploadInput(ctx, client, k.ID(), audioIn, topview.AudioFormat, h.timer(), h.uploadProgress())
+			if err != nil {
+				return fmt.Errorf("upload audio: %w", err)
+			}
+
+			h.progress("Submitting video avatar task...")
+			start = time.Now()
+			task, err := client.SubmitVideoAvatar(ctx, topview.VideoAvatarRequest{ImageFileID: imageFileID, AudioFileID: audioFileID})
+			if err != nil {
+				return fmt.Errorf("submit task: %w", err)
+			}
+			taskID = task.TaskID
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		h.timer().Measure(timing.Submit, start, 0)
+		h.submitted(taskID, key, nil)
+	}
+	h.timer().Key(keys.Service, key.Masked())
+
+	h.timer().Wait()
+	result, err := topviewprovider.Client(key.Value, key.Secret).WaitForTask(ctx, taskID, h.status,
+		func(attempt int, err error) {
+			h.progress(fmt.Sprintf("Warning: query failed (attempt %d): %v", attempt, err))
+		})
+	h.timer().EndWait()
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := downloadArtifact(ctx, topviewprovider.API, result.OutputVideoURL, t, req, taskID, h)
+	if err != nil {
+		return nil, err
+	}
+	return &generateResult{Tool: t.CLI, Model: req.Model, TaskID: taskID, Artifacts: []artifact{a}}, nil
+}
+
+// loadConfig loads the shared config with the request's profile selected.
+func loadConfig(req *generateRequest) (*config.Config, error) {
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		return nil, err
+	}
+	if req.Profile != "" {
+		if err := cfg.UseProfile(req.Profile); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// jimengKeys resolves the pool of Volcano Engine access keys shared by all
+// jimeng models.
+func jimengKeys(req *generateRequest) (*keypool.Pool, error) {
+	cfg, err := loadConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	ak, sk := config.ResolveAccessKeys(cfg, config.ServiceJimeng, "JIMENG_ACCESS_KEY_ID", "JIMENG_SECRET_ACCESS_KEY")
+	if ak == "" || sk == "" {
+		return nil, fmt.Errorf("Jimeng access keys not set: export JIMENG_ACCESS_KEY_ID/JIMENG_SECRET_ACCESS_KEY or run 'jimeng-cli config set-keys <AK> <SK>'")
+	}
+	return keypool.New(cfg, config.ServiceJimeng, ak, sk)
+}
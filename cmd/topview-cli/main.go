@@ -1,38 +1,121 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/llm-net/llm-api-plugin/cmd/topview-cli/provider"
+	"github.com/llm-net/llm-api-plugin/internal/assets"
+	"github.com/llm-net/llm-api-plugin/internal/buildinfo"
+	"github.com/llm-net/llm-api-plugin/internal/cassette"
 	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/doctor"
+	"github.com/llm-net/llm-api-plugin/internal/dryrun"
+	"github.com/llm-net/llm-api-plugin/internal/events"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/input"
+	"github.com/llm-net/llm-api-plugin/internal/keypool"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+	"github.com/llm-net/llm-api-plugin/internal/notify"
+	"github.com/llm-net/llm-api-plugin/internal/output"
+	"github.com/llm-net/llm-api-plugin/internal/probe"
+	"github.com/llm-net/llm-api-plugin/internal/redact"
+	"github.com/llm-net/llm-api-plugin/internal/selfupdate"
+	"github.com/llm-net/llm-api-plugin/internal/storage"
+	"github.com/llm-net/llm-api-plugin/internal/timing"
+	"github.com/llm-net/llm-api-plugin/pkg/topview"
 )
 
+// notifier fires completion notifications for the current generate command.
+var notifier *notify.Notifier
+
+// timings measures the phases of the current generate command; showTimings
+// (--timings) prints them and saves them next to the output.
+var (
+	timings     *timing.Timings
+	showTimings bool
+)
+
+// emitter writes the --events progress stream for agents.
+var emitter *events.Emitter
+
 func usage() {
 	fmt.Fprintf(os.Stderr, `topview-cli - CLI for TopView AI Video Avatar Generation
 
 Usage:
-  %[1]s generate --image <path> --audio <path> [flags]   Generate video avatar
+  %[1]s generate --image <input> --audio <input> [flags] Generate video avatar
   %[1]s models [<model-name>]                             List available models (JSON)
-  %[1]s config set-key <API_KEY>                          Set TopView API key
+  %[1]s doctor                                            Check credentials and connectivity of each provider
+  %[1]s assets <cmd>                                      Manage the asset library: reusable portraits, voices, videos (asset:<name>)
+  %[1]s probe <file.mp4>                                  Print duration, resolution, frame rate, codecs and audio of a video (JSON)
+  %[1]s self-update [flags]                               Install the latest release, checksum-verified (--help for flags)
+  %[1]s version                                           Print version and build info (also --version)
+  %[1]s config set-key [<API_KEY>]                        Set TopView API key
   %[1]s config set-uid <UID>                              Set TopView UID
-  %[1]s config show                                       Show current config
+  %[1]s config show [--effective]                         Show current config (--effective: merged project defaults)
+  %[1]s config notify <cmd>                               Manage completion notification targets
+  %[1]s config profile <cmd>                              Manage named credential profiles
+  %[1]s config migrate                                    Move plaintext secrets to the OS keyring / encrypted file
+  %[1]s config storage <cmd>                              Configure the object storage bucket for staging and publishing
+
+Global flags:
+  --profile <name>   Credential profile to use (or LLM_API_PROFILE)
+  --record <dir>     Save every HTTP request and response to a cassette directory
+  --replay <dir>     Serve HTTP responses from a cassette instead of the network
+  --har <file>       Export the HTTP traffic as a HAR file
+  --verbose          Log debug details: HTTP requests, raw provider responses (redacted)
+  --quiet            Only log warnings and errors
+  --log-format <f>   Log format: text (default) or json
+  --log-file <path>  Append logs to a file instead of stderr
 
 Flags for generate:
-  --image <path>         Path to portrait image file (required)
-  --audio <path>         Path to audio file (required)
-  --output <path>        Output file path                         [default: output_<timestamp>.mp4]
+  --image <input>        Portrait image: path, URL, data URI, - for stdin or asset:<name> (required)
+  --audio <input>        Audio: path, URL, data URI, - for stdin or asset:<name> (required)
+  --output <path>        Output file path, or - to write the video to stdout [default: output_<timestamp>.mp4]
+  --output-dir <dir>     Directory for generated files            [default: current directory]
+  --output-template <t>  File name template, e.g. "{model}_{task_id}.{ext}" (see README)
+  --no-download          Print the provider's video URL and its expiry instead of downloading it
+  --strict               Fail when the downloaded video has no audio track
+  --notify <target>      Notify on completion: configured target name, "desktop" or webhook URL (repeatable)
+  --publish              Upload the output to object storage and print a shareable URL
+  --dry-run              Print the request (secrets redacted) and a curl command without sending it
+  --timings              Print per-phase timings and save them to <output>.timings.json
+  --events <spec>        Emit NDJSON progress events: ndjson (stderr), ndjson:<file> or ndjson:fd:<n>
 
 Examples:
   %[1]s generate --image portrait.jpg --audio speech.mp3
   %[1]s generate --image photo.png --audio audio.wav --output avatar.mp4
+  %[1]s generate --image https://example.com/portrait.jpg --audio - < speech.mp3
+  %[1]s generate --image asset:alice-portrait --audio asset:alice-voice
   %[1]s models
 `, filepath.Base(os.Args[0]))
 }
 
 func main() {
+	args, err := config.SelectProfile(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+	args, err = cassette.Setup(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+	args, err = logging.Setup(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = args
+
 	if len(os.Args) < 2 {
 		usage()
 		os.Exit(1)
@@ -45,6 +128,25 @@ func main() {
 		handleGenerate()
 	case "models":
 		handleModels()
+	case "doctor":
+		handleDoctor()
+	case "probe":
+		if err := probe.Command(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "assets":
+		if err := assets.Command(os.Args[2:], input.Load); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "self-update":
+		if err := selfupdate.Command("topview-cli", os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "version", "--version":
+		fmt.Println(buildinfo.String("topview-cli"))
 	case "help", "--help", "-h":
 		usage()
 	default:
@@ -56,66 +158,97 @@ func main() {
 
 func handleConfig() {
 	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Usage: config set-key <KEY> | config set-uid <UID> | config show")
+		fmt.Fprintln(os.Stderr, "Usage: config set-key <KEY> | config set-uid <UID> | config show [--effective] | config notify <cmd> | config profile <cmd> | config storage <cmd> | config migrate")
 		os.Exit(1)
 	}
 	switch os.Args[2] {
 	case "set-key":
-		if len(os.Args) < 4 {
-			fmt.Fprintln(os.Stderr, "Usage: config set-key <API_KEY>")
+		value, err := config.SecretArg(os.Args, 3, "TopView API key: ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg, err := config.LoadOrCreate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		cfg, _ := config.LoadOrCreate()
-		if cfg.TopView == nil {
-			cfg.TopView = &config.ServiceConfig{}
+		where, err := cfg.SetSecret(config.ServiceTopView, config.FieldAPIKey, value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
-		cfg.TopView.APIKey = os.Args[3]
 		if err := config.Save(cfg); err != nil {
 			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("TopView API key saved to %s\n", config.Path())
+		fmt.Printf("TopView API key saved to %s\n", cfg.Location(where))
 	case "set-uid":
 		if len(os.Args) < 4 {
 			fmt.Fprintln(os.Stderr, "Usage: config set-uid <UID>")
 			os.Exit(1)
 		}
-		cfg, _ := config.LoadOrCreate()
-		if cfg.TopView == nil {
-			cfg.TopView = &config.ServiceConfig{}
+		value := os.Args[3]
+		cfg, err := config.LoadOrCreate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		where, err := cfg.SetSecret(config.ServiceTopView, config.FieldUID, value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
-		cfg.TopView.UID = os.Args[3]
 		if err := config.Save(cfg); err != nil {
 			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("TopView UID saved to %s\n", config.Path())
+		fmt.Printf("TopView UID saved to %s\n", cfg.Location(where))
 	case "show":
-		cfg, _ := config.LoadOrCreate()
-		apiKey := config.ResolveAPIKey("TOPVIEW_API_KEY", cfg.TopView)
+		cfg, err := config.LoadOrCreate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(os.Args) > 3 && os.Args[3] == "--effective" {
+			config.ShowEffective(cfg, provider.Registry)
+			fmt.Println()
+		}
+		profile, profileSource := cfg.ActiveProfile()
+		if n := cfg.PlaintextSecrets(); n > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %d plaintext secret(s) in %s; run '%s config migrate' to move them to secure storage\n", n, config.Path(), filepath.Base(os.Args[0]))
+		}
+		fmt.Printf("Profile: %s (source: %s)\n", profile, profileSource)
+		apiKey, source := config.Lookup(cfg, config.ServiceTopView, config.FieldAPIKey, "TOPVIEW_API_KEY")
 		if apiKey == "" {
 			fmt.Println("TopView: not configured")
 			return
 		}
-		source := "config file"
-		if os.Getenv("TOPVIEW_API_KEY") != "" {
-			source = "env TOPVIEW_API_KEY"
+		fmt.Printf("Config: %s\nTopView API Key: %s (source: %s)\n", config.Path(), keypool.Mask(apiKey), source)
+
+		uid, uidSource := config.Lookup(cfg, config.ServiceTopView, config.FieldUID, "TOPVIEW_UID")
+		if uid != "" {
+			fmt.Printf("TopView UID: %s (source: %s)\n", uid, uidSource)
 		}
-		masked := apiKey
-		if len(masked) > 8 {
-			masked = masked[:4] + "..." + masked[len(masked)-4:]
+	case "notify":
+		if err := notify.ConfigCommand(os.Args[3:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		fmt.Printf("Config: %s\nTopView API Key: %s (source: %s)\n", config.Path(), masked, source)
-
-		uid := ""
-		if cfg.TopView != nil {
-			uid = cfg.TopView.UID
+	case "profile":
+		if err := config.ProfileCommand(os.Args[3:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		if envUID := os.Getenv("TOPVIEW_UID"); envUID != "" {
-			uid = envUID
+	case "migrate":
+		if err := config.MigrateCommand(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
-		if uid != "" {
-			fmt.Printf("TopView UID: %s\n", uid)
+	case "storage":
+		if err := storage.ConfigCommand(os.Args[3:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown config command: %s\n", os.Args[2])
@@ -123,14 +256,27 @@ func handleConfig() {
 	}
 }
 
+func handleDoctor() {
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	profile, source := cfg.ActiveProfile()
+	fmt.Printf("Profile: %s (source: %s)\n\n", profile, source)
+	if doctor.Run(os.Stdout, provider.DoctorChecks(cfg)) > 0 {
+		os.Exit(1)
+	}
+}
+
 func handleModels() {
-	data, err := registry.JSON()
+	data, err := provider.Registry.JSON()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 	if len(os.Args) >= 3 {
-		m := registry.FindModel(os.Args[2])
+		m := provider.Registry.FindModel(os.Args[2])
 		if m == nil {
 			fmt.Fprintf(os.Stderr, "Unknown model: %s\n", os.Args[2])
 			os.Exit(1)
@@ -143,7 +289,10 @@ func handleModels() {
 }
 
 func handleGenerate() {
-	var imagePath, audioPath, output string
+	var imagePath, audioPath, eventsSpec string
+	var out output.Options
+	var notifyTargets []string
+	var publish, dryRun, noDownload, strict bool
 
 	args := os.Args[2:]
 	for i := 0; i < len(args); i++ {
@@ -161,7 +310,37 @@ func handleGenerate() {
 		case "--output":
 			i++
 			if i < len(args) {
-				output = args[i]
+				out.Path = args[i]
+			}
+		case "--output-dir":
+			i++
+			if i < len(args) {
+				out.Dir = args[i]
+			}
+		case "--output-template":
+			i++
+			if i < len(args) {
+				out.Template = args[i]
+			}
+		case "--notify":
+			i++
+			if i < len(args) {
+				notifyTargets = append(notifyTargets, args[i])
+			}
+		case "--publish":
+			publish = true
+		case "--no-download":
+			noDownload = true
+		case "--strict":
+			strict = true
+		case "--dry-run":
+			dryRun = true
+		case "--timings":
+			showTimings = true
+		case "--events":
+			i++
+			if i < len(args) {
+				eventsSpec = args[i]
 			}
 		default:
 			fmt.Fprintf(os.Stderr, "Unknown flag: %s\n", args[i])
@@ -179,87 +358,258 @@ func handleGenerate() {
 	}
 
 	// Resolve API key and UID
-	cfg, _ := config.LoadOrCreate()
-	apiKey := config.ResolveAPIKey("TOPVIEW_API_KEY", cfg.TopView)
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	apiKey := config.ResolveAPIKey(cfg, config.ServiceTopView, "TOPVIEW_API_KEY")
+	if apiKey == "" && dryRun {
+		apiKey = dryrun.MissingKey
+	}
 	if apiKey == "" {
 		fmt.Fprintf(os.Stderr, "Error: TopView API key not set.\n  Option 1: export TOPVIEW_API_KEY=<KEY>\n  Option 2: topview-cli config set-key <KEY>\n")
 		os.Exit(1)
 	}
 
-	uid := ""
-	if cfg.TopView != nil {
-		uid = cfg.TopView.UID
-	}
-	if envUID := os.Getenv("TOPVIEW_UID"); envUID != "" {
-		uid = envUID
+	uid, _ := config.Lookup(cfg, config.ServiceTopView, config.FieldUID, "TOPVIEW_UID")
+	keys, err := keypool.New(cfg, config.ServiceTopView, apiKey, uid)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	// Read image file
-	fmt.Fprintf(os.Stderr, "Reading image: %s\n", imagePath)
-	imageData, err := os.ReadFile(imagePath)
+	out, err = out.Defaults(cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading image: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Read audio file
-	fmt.Fprintf(os.Stderr, "Reading audio: %s\n", audioPath)
-	audioData, err := os.ReadFile(audioPath)
+	publish = publish || storage.PublishEnabled(cfg)
+	if publish && (noDownload || out.ToStdout()) {
+		logging.Warnf("--publish ignored: the video is not saved locally")
+		publish = false
+	}
+	notifier, err = notify.New(cfg, notifyTargets)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading audio: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Upload image
-	fmt.Fprintf(os.Stderr, "Uploading image to TopView...\n")
-	imageFormat := getImageFormat(imagePath)
-	imageContentType := detectContentType(imagePath)
-	imageFileID, err := uploadFile(apiKey, uid, imageData, imageFormat, imageContentType)
+	notifier.Tool, notifier.Model = "topview-cli", provider.Registry.Models[0].Name
+	timings = timing.New(notifier.Tool, notifier.Model)
+	notifier.Timings = timings
+	emitter, err = events.Open(eventsSpec, notifier.Tool, notifier.Model)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error uploading image: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Fprintf(os.Stderr, "Image uploaded: fileId=%s\n", imageFileID)
 
-	// Upload audio
-	fmt.Fprintf(os.Stderr, "Uploading audio to TopView...\n")
-	audioFormat := getAudioFormat(audioPath)
-	audioContentType := detectContentType(audioPath)
-	audioFileID, err := uploadFile(apiKey, uid, audioData, audioFormat, audioContentType)
+	// Resolve inputs: local path, URL, data URI or - for stdin
+	imageIn, err := input.Resolve(imagePath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error uploading audio: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: --image: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Fprintf(os.Stderr, "Audio uploaded: fileId=%s\n", audioFileID)
+	audioIn, err := input.Resolve(audioPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --audio: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The inputs are uploaded before the task is submitted, so a dry run
+	// shows placeholders for their file IDs
+	if dryRun {
+		k := keys.Get("")
+		req := provider.SubmitVideoAvatarTaskDryRun(k.Value, k.Secret,
+			fmt.Sprintf("<fileId of %s>", imageIn), fmt.Sprintf("<fileId of %s>", audioIn))
+		if err := dryrun.Print(os.Stdout, req); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Submit task
-	fmt.Fprintf(os.Stderr, "Submitting video avatar task...\n")
-	task, err := submitVideoAvatarTask(apiKey, uid, imageFileID, audioFileID)
+	// Uploaded files belong to the account of the key, so a key that hits
+	// its quota is replaced for the uploads and the submission together, and
+	// the task is polled with the key that submitted it
+	ctx := context.Background()
+	var task *provider.SubmitResult
+	start := time.Now()
+	key, err := keys.Try(func(k keypool.Key) error {
+		apiKey, uid = k.Value, k.Secret
+		client := provider.Client(apiKey, uid)
+
+		// Upload image
+		logging.Infof("Uploading image %s to TopView...", imageIn)
+		emitter.Emit(events.Event{Type: events.UploadStarted, Input: "image", Source: redact.URL(imageIn.String())})
+		imageFileID, err := provider.UploadInput(ctx, client, k.ID(), imageIn, topview.ImageFormat, timings,
+			uploadProgress("image", redact.URL(imageIn.String())))
+		if err != nil {
+			return fmt.Errorf("uploading image: %w", err)
+		}
+		logging.Infof("Image uploaded: fileId=%s", imageFileID)
+		emitter.Emit(events.Event{Type: events.UploadDone, Input: "image", Source: redact.URL(imageIn.String()), FileID: imageFileID})
+
+		// Upload audio
+		logging.Infof("Uploading audio %s to TopView...", audioIn)
+		emitter.Emit(events.Event{Type: events.UploadStarted, Input: "audio", Source: redact.URL(audioIn.String())})
+		audioFileID, err := provider.UploadInput(ctx, client, k.ID(), audioIn, topview.AudioFormat, timings,
+			uploadProgress("audio", redact.URL(audioIn.String())))
+		if err != nil {
+			return fmt.Errorf("uploading audio: %w", err)
+		}
+		logging.Infof("Audio uploaded: fileId=%s", audioFileID)
+		emitter.Emit(events.Event{Type: events.UploadDone, Input: "audio", Source: redact.URL(audioIn.String()), FileID: audioFileID})
+
+		// Submit task
+		logging.Info("Submitting video avatar task...")
+		start = time.Now()
+		task, err = client.SubmitVideoAvatar(ctx, topview.VideoAvatarRequest{ImageFileID: imageFileID, AudioFileID: audioFileID})
+		if err != nil {
+			return fmt.Errorf("submitting task: %w", err)
+		}
+		return nil
+	})
+	timings.Key(config.ServiceTopView, key.Masked())
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error submitting task: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		failed(err)
 		os.Exit(1)
 	}
-	fmt.Fprintf(os.Stderr, "Task created: %s\n", task.TaskID)
+	timings.Measure(timing.Submit, start, 0)
+	timings.Wait()
+	logging.Infof("Task created: %s", task.TaskID)
+	notifier.TaskID = task.TaskID
+	emitter.Submitted(task.TaskID)
 
 	// Poll for result
-	fmt.Fprintf(os.Stderr, "Polling for result (timeout %v)...\n", pollTimeout)
-	result, err := waitForTask(apiKey, uid, task.TaskID)
+	logging.Infof("Polling for result (timeout %v)...", provider.PollTimeout)
+	result, err := provider.Client(apiKey, uid).WaitForTask(ctx, task.TaskID,
+		func(status string) {
+			timings.Status(status)
+			emitter.Status(status)
+			logging.Infof("  Status: %s, waiting %v...", status, provider.PollInterval)
+		},
+		func(attempt int, err error) {
+			logging.Warnf("query failed (attempt %d): %v", attempt, err)
+		})
+	timings.EndWait()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		failed(err)
 		os.Exit(1)
 	}
 
-	// Download video
-	if output == "" {
-		output = fmt.Sprintf("output_%s.mp4", time.Now().Format("20060102_150405"))
+	if noDownload {
+		keepRemote(result.OutputVideoURL)
+		return
 	}
 
-	fmt.Fprintf(os.Stderr, "Downloading video...\n")
-	size, err := downloadVideo(result.OutputVideoURL, output)
+	// Download video
+	logging.Info("Downloading video...")
+	start = time.Now()
+	path, size, err := output.Download(ctx, provider.API, result.OutputVideoURL, out,
+		output.Vars{Model: notifier.Model, TaskID: task.TaskID, Ext: "mp4"}, emitter.Progress)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		failed(err)
 		os.Exit(1)
 	}
+	timings.Measure(timing.Download, start, size)
+
+	logging.Infof("Video saved: %s (%d bytes)", output.Describe(path), size)
+	if output.Local(path) {
+		// A talking avatar carries the input audio
+		_, problems, err := probe.Verify(path, probe.Expect{Audio: probe.Bool(true)})
+		if err == nil && strict && len(problems) > 0 {
+			err = fmt.Errorf("video does not match the request: %s", strings.Join(problems, "; "))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failed(err)
+			os.Exit(1)
+		}
+	}
+	emitter.Emit(events.Event{Type: events.ArtifactSaved, Path: path, Bytes: size})
+	if publish {
+		publishOutput(cfg, path)
+	}
+	done(path)
+}
+
+// keepRemote ends generate with --no-download: it prints the provider's
+// video URL on stdout, and its expiry on stderr, instead of downloading it.
+func keepRemote(url string) {
+	logging.Infof("Video left at the provider (%s):", output.ExpiryNote(url))
+	ev := events.Event{Type: events.ArtifactRemote, URL: url}
+	if t, ok := output.Expiry(url); ok {
+		ev.ExpiresAt = &t
+	}
+	emitter.Emit(ev)
+	fmt.Println(url)
+	done(url)
+}
 
-	fmt.Fprintf(os.Stderr, "Video saved: %s (%d bytes)\n", output, size)
+// publishOutput uploads an output file to object storage and prints its
+// shareable URL on stdout. Failures only warn: the local file is kept.
+func publishOutput(cfg *config.Config, path string) {
+	logging.Infof("Publishing %s...", path)
+	start := time.Now()
+	url, err := storage.Publish(cfg, path)
+	if err != nil {
+		logging.Warnf("publish failed: %v", err)
+		return
+	}
+	timings.Measure(timing.Publish, start, 0)
+	emitter.Emit(events.Event{Type: events.ArtifactPublished, Path: path, URL: url})
+	logging.Infof("Published: %s", url)
+	fmt.Println(url)
+}
+
+// uploadProgress reports the progress of an upload on stderr, at most every
+// few seconds, and as upload.progress events.
+func uploadProgress(input, source string) func(sent, total int64) {
+	print := httpclient.Throttle(2*time.Second, func(sent, total int64) {
+		logging.Infof("  Uploaded %s", httpclient.FormatProgress(sent, total))
+	})
+	return func(sent, total int64) {
+		emitter.UploadProgress(input, source, sent, total)
+		print(sent, total)
+	}
+}
+
+// failed and done end the generate command: they report its timings and
+// fire the completion notification.
+func failed(err error) {
+	emitter.Emit(events.Event{Type: events.Error, Message: err.Error()})
+	reportTimings(notify.Classify(err))
+	notifier.Failed(err)
+}
+
+func done(outputs ...string) {
+	reportTimings(notify.Success, outputs...)
+	notifier.Done(outputs...)
+}
+
+// reportTimings prints the --timings summary, saves it next to the first
+// output and exports it to the configured metrics backends.
+func reportTimings(outcome string, outputs ...string) {
+	if showTimings {
+		timings.Summary(os.Stderr)
+		if len(outputs) > 0 && output.Local(outputs[0]) {
+			path := timing.SidecarPath(outputs[0])
+			if err := timings.Save(path); err != nil {
+				logging.Warnf("failed to save timings: %v", err)
+			} else {
+				logging.Infof("Timings saved: %s", path)
+			}
+		}
+	}
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		logging.Warnf("failed to export metrics: %v", err)
+		return
+	}
+	if err := timings.Export(cfg, outcome); err != nil {
+		logging.Warnf("failed to export metrics: %v", err)
+	}
 }
@@ -0,0 +1,28 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/doctor"
+	"github.com/llm-net/llm-api-plugin/internal/keypool"
+)
+
+// DoctorChecks returns the 'doctor' checks for the TopView API.
+func DoctorChecks(cfg *config.Config) []doctor.Check {
+	key := doctor.Cred(cfg, "api_key", config.ServiceTopView, config.FieldAPIKey, "TOPVIEW_API_KEY")
+	uid := doctor.Cred(cfg, "uid", config.ServiceTopView, config.FieldUID, "TOPVIEW_UID")
+	uid.Optional = true
+	return []doctor.Check{{
+		Name:        "TopView",
+		URL:         BaseURL,
+		Credentials: []doctor.Credential{key, uid},
+		SetupHint:   "export TOPVIEW_API_KEY=<KEY> or run 'topview-cli config set-key'",
+		AccessHint:  "set the UID with 'topview-cli config set-uid', check that it belongs to the API key's account and that the plan includes API access",
+		Probe: func() error {
+			return keypool.Each(key.Value, uid.Value, func(k keypool.Key) error {
+				return Client(k.Value, k.Secret).CheckCredentials(context.Background())
+			})
+		},
+	}}
+}
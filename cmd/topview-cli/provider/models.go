@@ -1,8 +1,9 @@
-package main
+package provider
 
 import "github.com/llm-net/llm-api-plugin/internal/models"
 
-var registry = &models.Registry{
+// Registry lists the models served by topview-cli.
+var Registry = &models.Registry{
 	Tool: "topview-cli",
 	Models: []models.Model{
 		{
@@ -11,12 +12,12 @@ var registry = &models.Registry{
 			Capabilities: []string{"image-audio-to-video", "video-avatar"},
 			Params: map[string]models.Param{
 				"image": {
-					Description: "Path to portrait image file (jpg, png, webp)",
+					Description: "Portrait image (jpg, png, webp): local path, URL, data URI or - for stdin",
 					Type:        "string",
 					Required:    true,
 				},
 				"audio": {
-					Description: "Path to audio file (mp3, wav, m4a, aac)",
+					Description: "Audio (mp3, wav, m4a, aac): local path, URL, data URI or - for stdin",
 					Type:        "string",
 					Required:    true,
 				},
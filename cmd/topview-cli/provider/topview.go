@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/assets"
+	"github.com/llm-net/llm-api-plugin/internal/dryrun"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/input"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+	"github.com/llm-net/llm-api-plugin/internal/timing"
+	"github.com/llm-net/llm-api-plugin/pkg/topview"
+)
+
+const (
+	PollInterval = topview.DefaultPollInterval
+	PollTimeout  = topview.DefaultPollTimeout
+)
+
+// SubmitResult is the result of a video avatar task submission.
+type SubmitResult = topview.SubmitResult
+
+// API sends the TopView requests, the S3 uploads and the result
+// downloads, retried and rate limited.
+var API = httpclient.NewClient("topview", httpclient.Retry(3, time.Second), httpclient.RateLimit(200*time.Millisecond))
+
+// uploadTTL is how long a cached TopView fileId is trusted. TopView does not
+// document how long uploads are kept, so assets are uploaded again daily.
+const uploadTTL = 24 * time.Hour
+
+// Client returns the pkg/topview client of the CLIs: authenticated as
+// apiKey and uid, sending through API and logging to the shared logger.
+func Client(apiKey, uid string) *topview.Client {
+	return topview.New(apiKey, uid, topview.WithHTTPClient(API.HTTP()), topview.WithLogger(logging.Logger()))
+}
+
+// UploadInput uploads a media input given as a path, URL, data URI or stdin
+// and returns its TopView fileId. Local files are streamed from disk; URLs
+// are downloaded first. format maps the sniffed MIME type to a TopView
+// upload format (topview.ImageFormat or topview.AudioFormat). Each step is
+// recorded in tm, if set; progress, if set, receives the bytes sent so far
+// and the size. The fileId of an asset is cached in the asset library for
+// account, the ID of the key c authenticates with, and reused.
+func UploadInput(ctx context.Context, c *topview.Client, account string, in *input.Input, format func(mimeType string) string, tm *timing.Timings, progress func(sent, total int64)) (string, error) {
+	if in.Asset == nil {
+		return upload(ctx, c, in, format, tm, progress)
+	}
+	lib := assets.Open()
+	if h := lib.Lookup(in.Asset.Name, assets.ProviderTopView, account); h.Fresh() {
+		logging.Infof("Reusing the TopView upload of %s", in)
+		return h.ID, nil
+	}
+	fileID, err := upload(ctx, c, in, format, tm, progress)
+	if err != nil {
+		return "", err
+	}
+	expires := time.Now().Add(uploadTTL)
+	h := assets.Handle{Provider: assets.ProviderTopView, Account: account, ID: fileID, ExpiresAt: &expires}
+	if err := lib.SetHandle(in.Asset.Name, in.Asset.SHA256, h); err != nil {
+		logging.Warnf("cannot cache the upload of %s: %v", in, err)
+	}
+	return fileID, nil
+}
+
+func upload(ctx context.Context, c *topview.Client, in *input.Input, format func(mimeType string) string, tm *timing.Timings, progress func(sent, total int64)) (string, error) {
+	start := time.Now()
+	mimeType, err := in.MIMEType()
+	if err != nil {
+		return "", err
+	}
+	body, size, err := in.Open()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	media, _, _ := strings.Cut(mimeType, "/")
+	if in.IsURL() {
+		tm.Measure(timing.Upload(media, "fetch"), start, size)
+	}
+	return c.Upload(ctx, body, size, format(mimeType), mimeType, topview.UploadHooks{
+		Progress: progress,
+		Phase: func(name string, start time.Time, bytes int64) {
+			tm.Measure(timing.Upload(media, name), start, bytes)
+		},
+	})
+}
+
+// SubmitVideoAvatarTaskDryRun returns the request SubmitVideoAvatar would
+// send.
+func SubmitVideoAvatarTaskDryRun(apiKey, uid, imageFileID, audioFileID string) dryrun.Request {
+	c := Client(apiKey, uid)
+	headers := c.Headers()
+	headers["Content-Type"] = "application/json"
+	return dryrun.Request{
+		Method:  http.MethodPost,
+		URL:     c.SubmitURL(),
+		Headers: headers,
+		Body:    topview.VideoAvatarRequest{ImageFileID: imageFileID, AudioFileID: audioFileID}.Body(),
+	}
+}
+
+// BaseURL is the TopView API endpoint, probed by 'doctor'.
+const BaseURL = topview.DefaultBaseURL
@@ -2,11 +2,22 @@ module github.com/llm-net/llm-api-plugin
 
 go 1.24.0
 
-require github.com/volcengine/volc-sdk-golang v1.0.237
+require (
+	filippo.io/age v1.2.1
+	github.com/volcengine/volc-sdk-golang v1.0.237
+	github.com/zalando/go-keyring v0.2.6
+	golang.org/x/term v0.25.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
+	al.essio.dev/pkg/shellescape v1.5.1 // indirect
 	github.com/cenkalti/backoff/v4 v4.1.2 // indirect
+	github.com/danieljoos/wincred v1.2.2 // indirect
+	github.com/godbus/dbus/v5 v5.1.0 // indirect
 	github.com/google/uuid v1.3.0 // indirect
-	golang.org/x/net v0.12.0 // indirect
-	golang.org/x/text v0.11.0 // indirect
+	golang.org/x/crypto v0.24.0 // indirect
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/sys v0.26.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
 )
@@ -0,0 +1,329 @@
+// Package assets is the local library of reusable media: spokesperson
+// portraits, voice-overs, template videos and style references, stored
+// under a name together with their content hash. Every media flag accepts
+// "asset:<name>" (see package input).
+//
+// The library also remembers provider-side handles of an asset, such as a
+// TopView fileId, an object storage key with its presigned URL, or a Gemini
+// File API name, so that the same content is not uploaded again. Handles
+// belong to one account (a key ID or bucket, never a secret) and may
+// expire.
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+)
+
+// Prefix marks a media flag value naming an asset, e.g. "asset:alice".
+const Prefix = "asset:"
+
+// MinValidity is how long a cached handle must stay valid to be reused:
+// long enough for a provider to fetch it while a task runs.
+const MinValidity = time.Hour
+
+// Providers holding handles.
+const (
+	ProviderTopView = "topview"
+	ProviderGemini  = "gemini"
+	ProviderStorage = "storage"
+)
+
+// Asset is a named piece of media in the library.
+type Asset struct {
+	Name string `json:"name"`
+	// Kind is image, audio or video, from MIMEType.
+	Kind     string `json:"kind"`
+	MIMEType string `json:"mime_type"`
+	SHA256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+	// File is the stored copy, relative to the library directory.
+	File string `json:"file"`
+	// Source is the path or URL the asset was added from.
+	Source      string    `json:"source,omitempty"`
+	Description string    `json:"description,omitempty"`
+	AddedAt     time.Time `json:"added_at"`
+	Handles     []Handle  `json:"handles,omitempty"`
+}
+
+// Handle is a copy of an asset held by a provider.
+type Handle struct {
+	Provider string `json:"provider"`
+	// Account is the key ID or bucket the copy belongs to.
+	Account string `json:"account,omitempty"`
+	// ID is the provider's reference: a file ID, file name or object key.
+	ID        string     `json:"id,omitempty"`
+	URL       string     `json:"url,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Fresh reports whether h stays valid for at least MinValidity.
+func (h *Handle) Fresh() bool {
+	return h != nil && (h.ExpiresAt == nil || time.Until(*h.ExpiresAt) >= MinValidity)
+}
+
+// Library is the asset directory: index.json and the stored files.
+type Library struct {
+	dir string
+}
+
+// ErrNotFound is returned for an unknown asset name.
+var ErrNotFound = errors.New("asset not found")
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// mu serialises index updates within the process; the index is replaced
+// atomically, so other processes never read a partial one.
+var mu sync.Mutex
+
+// Open returns the library next to the configuration file.
+func Open() *Library {
+	return &Library{dir: filepath.Join(filepath.Dir(config.Path()), "assets")}
+}
+
+// Dir returns the library directory.
+func (l *Library) Dir() string {
+	return l.dir
+}
+
+// Path returns the stored copy of a.
+func (l *Library) Path(a *Asset) string {
+	return filepath.Join(l.dir, a.File)
+}
+
+// IsRef reports whether a media flag value names an asset.
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Resolve returns the asset a media flag value names.
+func (l *Library) Resolve(value string) (*Asset, error) {
+	return l.Get(strings.TrimPrefix(value, Prefix))
+}
+
+func (l *Library) load() (map[string]*Asset, error) {
+	index := map[string]*Asset{}
+	data, err := os.ReadFile(filepath.Join(l.dir, "index.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("parse asset index: %w", err)
+	}
+	return index, nil
+}
+
+func (l *Library) save(index map[string]*Asset) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(l.dir, 0700); err != nil {
+		return err
+	}
+	path := filepath.Join(l.dir, "index.json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// update applies fn to the index and saves it.
+func (l *Library) update(fn func(index map[string]*Asset) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	index, err := l.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(index); err != nil {
+		return err
+	}
+	return l.save(index)
+}
+
+// List returns all assets sorted by name.
+func (l *Library) List() ([]*Asset, error) {
+	index, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Asset, 0, len(index))
+	for _, a := range index {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Get returns the named asset.
+func (l *Library) Get(name string) (*Asset, error) {
+	index, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	a, ok := index[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q (see 'assets list')", ErrNotFound, name)
+	}
+	return a, nil
+}
+
+// Add stores data under name. ext, with a dot, names the stored copy.
+// Replacing an asset with different content drops its handles, which
+// refer to the old content.
+func (l *Library) Add(name string, data []byte, mimeType, ext, source, description string, replace bool) (*Asset, error) {
+	if !namePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid asset name %q: use letters, digits, '.', '-' and '_'", name)
+	}
+	kind, _, _ := strings.Cut(mimeType, "/")
+	switch kind {
+	case "image", "audio", "video":
+	default:
+		return nil, fmt.Errorf("unsupported media type %s: assets are images, audio or video", mimeType)
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	a := &Asset{
+		Name:        name,
+		Kind:        kind,
+		MIMEType:    mimeType,
+		SHA256:      hash,
+		Size:        int64(len(data)),
+		File:        filepath.Join("files", hash+ext),
+		Source:      source,
+		Description: description,
+		AddedAt:     time.Now().UTC(),
+	}
+
+	err := l.update(func(index map[string]*Asset) error {
+		if old, ok := index[name]; ok {
+			if !replace {
+				return fmt.Errorf("asset %q already exists; pass --replace to overwrite it", name)
+			}
+			if old.SHA256 == hash {
+				a.Handles = old.Handles
+			}
+		}
+		if err := l.store(a.File, data); err != nil {
+			return err
+		}
+		index[name] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.prune()
+	return a, nil
+}
+
+// store writes the content of a stored copy unless it exists already;
+// copies are named by their hash, so existing ones are identical.
+func (l *Library) store(file string, data []byte) error {
+	path := filepath.Join(l.dir, file)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Remove deletes the named asset and, unless another asset shares it, its
+// stored copy. Provider-side copies are left to expire.
+func (l *Library) Remove(name string) error {
+	err := l.update(func(index map[string]*Asset) error {
+		if _, ok := index[name]; !ok {
+			return fmt.Errorf("%w: %q", ErrNotFound, name)
+		}
+		delete(index, name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	l.prune()
+	return nil
+}
+
+// prune deletes stored copies no asset refers to.
+func (l *Library) prune() {
+	mu.Lock()
+	defer mu.Unlock()
+	index, err := l.load()
+	if err != nil {
+		return
+	}
+	used := map[string]bool{}
+	for _, a := range index {
+		used[filepath.Base(a.File)] = true
+	}
+	entries, _ := os.ReadDir(filepath.Join(l.dir, "files"))
+	for _, e := range entries {
+		if !used[e.Name()] {
+			os.Remove(filepath.Join(l.dir, "files", e.Name()))
+		}
+	}
+}
+
+// Lookup returns the handle of the named asset at provider for account, or
+// nil. It may have expired; see Handle.Fresh.
+func (l *Library) Lookup(name, provider, account string) *Handle {
+	a, err := l.Get(name)
+	if err != nil {
+		return nil
+	}
+	for i := range a.Handles {
+		if h := &a.Handles[i]; h.Provider == provider && h.Account == account {
+			return h
+		}
+	}
+	return nil
+}
+
+// SetHandle records h for the named asset, replacing the handle of the
+// same provider and account. hash guards against the asset having been
+// replaced since its content was uploaded.
+func (l *Library) SetHandle(name, hash string, h Handle) error {
+	if h.CreatedAt.IsZero() {
+		h.CreatedAt = time.Now().UTC()
+	}
+	return l.update(func(index map[string]*Asset) error {
+		a, ok := index[name]
+		if !ok || a.SHA256 != hash {
+			return nil
+		}
+		for i := range a.Handles {
+			if a.Handles[i].Provider == h.Provider && a.Handles[i].Account == h.Account {
+				a.Handles[i] = h
+				return nil
+			}
+		}
+		a.Handles = append(a.Handles, h)
+		return nil
+	})
+}
@@ -0,0 +1,107 @@
+package assets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Usage documents the 'assets' subcommands shared by all CLIs.
+const Usage = `Usage:
+  assets add <name> <input> [--description <text>] [--replace]   Store a named image, audio or video
+  assets list                                                      List assets (JSON)
+  assets show <name>                                               Show an asset and its cached uploads (JSON)
+  assets rm <name>                                                 Delete an asset
+
+<input> is a local path, http(s) URL, data URI or - for stdin. Media flags then
+accept asset:<name>; uploads of an asset (TopView fileId, object storage URL,
+Gemini File API name) are cached per account and reused while valid.`
+
+// Loader reads the content of an input for 'assets add': its bytes, media
+// type and a file extension with its dot (see input.Load).
+type Loader func(value string) (data []byte, mimeType, ext string, err error)
+
+// Command runs 'assets <args>' against the library next to the config file.
+func Command(args []string, load Loader) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", Usage)
+	}
+	lib := Open()
+
+	switch args[0] {
+	case "add":
+		var positional []string
+		var description string
+		var replace bool
+		for i := 1; i < len(args); i++ {
+			switch args[i] {
+			case "--description":
+				i++
+				if i < len(args) {
+					description = args[i]
+				}
+			case "--replace":
+				replace = true
+			default:
+				if len(args[i]) > 1 && args[i][0] == '-' {
+					return fmt.Errorf("unknown flag: %s\n%s", args[i], Usage)
+				}
+				positional = append(positional, args[i])
+			}
+		}
+		if len(positional) != 2 {
+			return fmt.Errorf("%s", Usage)
+		}
+		name, source := positional[0], positional[1]
+		data, mimeType, ext, err := load(source)
+		if err != nil {
+			return err
+		}
+		a, err := lib.Add(name, data, mimeType, ext, source, description, replace)
+		if err != nil {
+			return err
+		}
+		return printJSON(a)
+	case "list":
+		list, err := lib.List()
+		if err != nil {
+			return err
+		}
+		return printJSON(list)
+	case "show":
+		if len(args) != 2 {
+			return fmt.Errorf("%s", Usage)
+		}
+		a, err := lib.Get(args[1])
+		if err != nil {
+			return err
+		}
+		return printJSON(struct {
+			*Asset
+			Path string `json:"path"`
+		}{a, lib.Path(a)})
+	case "rm":
+		if len(args) != 2 {
+			return fmt.Errorf("%s", Usage)
+		}
+		if err := lib.Remove(args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Removed asset %s\n", args[1])
+		return nil
+	case "help", "--help", "-h":
+		fmt.Println(Usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown assets command: %s\n%s", args[0], Usage)
+	}
+}
+
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,85 @@
+// Package buildinfo reports the version a binary was built from. Release
+// builds set the variables at link time:
+//
+//	go build -ldflags "-X github.com/llm-net/llm-api-plugin/internal/buildinfo.Version=v0.2.0 \
+//	  -X github.com/llm-net/llm-api-plugin/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/llm-net/llm-api-plugin/internal/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Other builds fall back to the VCS information the go command embeds.
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X by release builds.
+var (
+	// Version is the release tag, e.g. v0.2.0, or "dev".
+	Version = "dev"
+	// Commit is the git commit the binary was built from.
+	Commit = ""
+	// Date is when the binary was built, in RFC 3339.
+	Date = ""
+)
+
+// Info is the build information of the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get returns the build information, completing Commit and Date from the
+// VCS stamp of the go command when they were not set at link time.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true" && Commit == ""
+			}
+		}
+	}
+	return info
+}
+
+// String describes the build for --version, e.g.
+// "ark-cli v0.2.0 (commit 1a2b3c4d5e6f, built 2026-01-02T03:04:05Z, go1.24.0 linux/amd64)".
+func String(tool string) string {
+	info := Get()
+	s := fmt.Sprintf("%s %s (", tool, info.Version)
+	if info.Commit != "" {
+		commit := info.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		if info.Modified {
+			commit += "-dirty"
+		}
+		s += "commit " + commit + ", "
+	}
+	if info.Date != "" {
+		s += "built " + info.Date + ", "
+	}
+	return s + info.GoVersion + " " + info.Platform + ")"
+}
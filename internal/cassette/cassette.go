@@ -0,0 +1,244 @@
+// Package cassette records HTTP traffic to disk and replays it, for bug
+// reports and offline regression fixtures. It plugs into the shared
+// transport of httpclient, which the Volc SDK clients use as well.
+//
+// A cassette is a directory holding one JSON file per interaction, named
+// after its sequence number, method and host. Credentials are redacted and
+// large bodies summarised, so cassettes are safe to share but only replay
+// what the CLIs need: JSON and text bodies, and binary bodies as zeros of
+// the recorded size.
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/redact"
+)
+
+// MaxText is the largest non-JSON text body stored in full. Larger and
+// binary bodies are omitted and only their size is kept.
+const MaxText = 64 << 10
+
+// Interaction is one request and its response, or the transport error.
+type Interaction struct {
+	Time     time.Time `json:"time"`
+	Duration float64   `json:"duration_ms"`
+	Request  Message   `json:"request"`
+	Response *Message  `json:"response,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Message is a request or response with its body encoded by encodeBody.
+type Message struct {
+	Method  string          `json:"method,omitempty"`
+	URL     string          `json:"url,omitempty"`
+	Status  int             `json:"status,omitempty"`
+	Headers http.Header     `json:"headers,omitempty"`
+	JSON    json.RawMessage `json:"json,omitempty"`
+	Text    string          `json:"text,omitempty"`
+	Size    int64           `json:"size"`
+	Omitted bool            `json:"omitted,omitempty"`
+}
+
+// setBody stores body in m: JSON redacted, small UTF-8 text as is, anything
+// else as its size only. complete is false when the body was not captured
+// in full.
+func (m *Message) setBody(body []byte, size int64, complete bool) {
+	m.Size = size
+	if size == 0 {
+		return
+	}
+	if complete {
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if dec.Decode(&v) == nil && !dec.More() {
+			if raw, err := marshal(redact.Value(v), ""); err == nil {
+				m.JSON = bytes.TrimSpace(raw)
+				return
+			}
+		}
+		if len(body) <= MaxText && utf8.Valid(body) {
+			m.Text = string(body)
+			return
+		}
+	}
+	m.Omitted = true
+}
+
+// body rebuilds the body stored by setBody. Base64 summaries expand to
+// zeros of the recorded size, as do omitted bodies.
+func (m *Message) body() ([]byte, error) {
+	switch {
+	case m.JSON != nil:
+		dec := json.NewDecoder(bytes.NewReader(m.JSON))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		return json.Marshal(expand(v))
+	case m.Omitted:
+		return make([]byte, m.Size), nil
+	}
+	return []byte(m.Text), nil
+}
+
+// expand replaces base64 summaries with base64 of as many zero bytes.
+func expand(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = expand(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = expand(e)
+		}
+	case string:
+		if n, ok := redact.ParseBlob(v); ok {
+			return base64.StdEncoding.EncodeToString(make([]byte, n))
+		}
+		if header, payload, ok := strings.Cut(v, ","); ok && strings.HasPrefix(header, "data:") {
+			if n, ok := redact.ParseBlob(payload); ok {
+				return header + "," + base64.StdEncoding.EncodeToString(make([]byte, n))
+			}
+		}
+	}
+	return v
+}
+
+// redactHeaders copies h with credentials replaced.
+func redactHeaders(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		for _, v := range values {
+			out[name] = append(out[name], redact.Header(name, v))
+		}
+	}
+	return out
+}
+
+// mediaType returns the media type of a Content-Type header.
+func mediaType(h http.Header) string {
+	t, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return t
+}
+
+// isText reports whether bodies of this media type are worth capturing in
+// full. Unknown types are captured up to MaxText.
+func isText(t string) bool {
+	return strings.HasPrefix(t, "text/") || strings.HasSuffix(t, "json") ||
+		strings.HasSuffix(t, "xml") || t == "application/x-www-form-urlencoded"
+}
+
+// fileName names the file of interaction seq.
+func fileName(seq int, method, host string) string {
+	host = strings.NewReplacer(":", "_", "/", "_").Replace(host)
+	return fmt.Sprintf("%04d-%s-%s.json", seq, method, host)
+}
+
+// marshal encodes v without HTML escaping, so placeholders stay readable.
+func marshal(v interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFile replaces path atomically, so a file is never left half written
+// when the process exits.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cassette-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// replaying is set by Setup for --replay.
+var replaying bool
+
+// Replaying reports whether HTTP responses come from a cassette. Binary
+// bodies, such as downloaded videos, are then zero-filled placeholders of
+// the recorded size.
+func Replaying() bool {
+	return replaying
+}
+
+// Setup strips --record <dir>, --replay <dir> and --har <file> from args and
+// installs the matching transport in httpclient. --record and --har may be
+// combined, and --har also works with --replay.
+func Setup(args []string) ([]string, error) {
+	var record, replay, har string
+	flags := map[string]*string{"--record": &record, "--replay": &replay, "--har": &har}
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		dst, ok := flags[name]
+		if !ok || i == 0 {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			i++
+			if i >= len(args) {
+				return nil, fmt.Errorf("%s requires a path", name)
+			}
+			value = args[i]
+		}
+		if value == "" {
+			return nil, fmt.Errorf("%s requires a path", name)
+		}
+		*dst = value
+	}
+	if record != "" && replay != "" {
+		return nil, fmt.Errorf("--record and --replay cannot be combined")
+	}
+
+	rt := httpclient.Transport()
+	if replay != "" {
+		r, err := NewReplayer(replay)
+		if err != nil {
+			return nil, err
+		}
+		rt = r
+		replaying = true
+	}
+	if record != "" || har != "" {
+		r, err := NewRecorder(rt, record, har)
+		if err != nil {
+			return nil, err
+		}
+		rt = r
+	}
+	httpclient.SetTransport(rt)
+	return rest, nil
+}
@@ -0,0 +1,195 @@
+package cassette
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HAR 1.2 types, limited to the fields browser devtools need to import a
+// log. See http://www.softwareishard.com/blog/har-12-spec/.
+type harLog struct {
+	Log struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+
+	seq int
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+const omittedComment = "body omitted from the recording"
+
+// harWriter collects entries and rewrites the HAR file after each one.
+type harWriter struct {
+	path string
+
+	mu      sync.Mutex
+	entries []harEntry
+}
+
+func (w *harWriter) add(seq int, it *Interaction) error {
+	e := harEntry{
+		StartedDateTime: it.Time.Format(time.RFC3339Nano),
+		Time:            it.Duration,
+		Timings:         harTimings{Wait: it.Duration},
+		seq:             seq,
+	}
+	e.Request = harRequest{
+		Method:      it.Request.Method,
+		URL:         it.Request.URL,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(it.Request.Headers),
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    it.Request.Size,
+	}
+	if u, err := url.Parse(it.Request.URL); err == nil {
+		e.Request.QueryString = harQuery(u.Query())
+	}
+	if it.Request.Size > 0 {
+		text, comment := harText(&it.Request)
+		e.Request.PostData = &harPostData{
+			MimeType: it.Request.Headers.Get("Content-Type"),
+			Text:     text,
+			Comment:  comment,
+		}
+	}
+	e.Response = harResponse{
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harNameValue{},
+		Headers:     []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	if it.Response != nil {
+		text, comment := harText(it.Response)
+		e.Response.Status = it.Response.Status
+		e.Response.StatusText = http.StatusText(it.Response.Status)
+		e.Response.Headers = harHeaders(it.Response.Headers)
+		e.Response.BodySize = it.Response.Size
+		e.Response.Content = harContent{
+			Size:     it.Response.Size,
+			MimeType: it.Response.Headers.Get("Content-Type"),
+			Text:     text,
+			Comment:  comment,
+		}
+	}
+	if it.Error != "" {
+		e.Comment = it.Error
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.entries = append(w.entries, e)
+	sort.SliceStable(w.entries, func(i, j int) bool { return w.entries[i].seq < w.entries[j].seq })
+
+	var log harLog
+	log.Log.Version = "1.2"
+	log.Log.Creator = harCreator{Name: filepath.Base(os.Args[0]), Version: "dev"}
+	log.Log.Entries = w.entries
+	data, err := marshal(log, "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(w.path, data)
+}
+
+// harText returns the recorded body of m as HAR text, or a comment when it
+// was omitted.
+func harText(m *Message) (string, string) {
+	switch {
+	case m.JSON != nil:
+		return string(m.JSON), ""
+	case m.Omitted:
+		return "", omittedComment
+	}
+	return m.Text, ""
+}
+
+func harHeaders(h http.Header) []harNameValue {
+	out := []harNameValue{}
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range h[name] {
+			out = append(out, harNameValue{Name: name, Value: v})
+		}
+	}
+	return out
+}
+
+func harQuery(q url.Values) []harNameValue {
+	return harHeaders(http.Header(q))
+}
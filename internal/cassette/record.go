@@ -0,0 +1,180 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/redact"
+)
+
+// Recorder is a transport that saves every interaction passing through it
+// to a cassette directory, a HAR file, or both. Interactions are written as
+// soon as their response body is closed, because the CLIs exit without
+// running deferred cleanup.
+type Recorder struct {
+	next http.RoundTripper
+	dir  string
+	har  *harWriter
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewRecorder returns a Recorder sending requests through next. dir, if set,
+// is created and must not already hold a cassette; harPath, if set, is
+// rewritten after every interaction.
+func NewRecorder(next http.RoundTripper, dir, harPath string) (*Recorder, error) {
+	r := &Recorder{next: next, dir: dir}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("create cassette: %w", err)
+		}
+		if existing, _ := filepath.Glob(filepath.Join(dir, "[0-9][0-9][0-9][0-9]-*.json")); len(existing) > 0 {
+			return nil, fmt.Errorf("%s already contains a cassette; record into an empty directory", dir)
+		}
+	}
+	if harPath != "" {
+		r.har = &harWriter{path: harPath}
+	}
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	r.seq++
+	seq := r.seq
+	r.mu.Unlock()
+
+	it := &Interaction{
+		Time: time.Now(),
+		Request: Message{
+			Method:  req.Method,
+			URL:     redact.URL(req.URL.String()),
+			Headers: redactHeaders(req.Header),
+		},
+	}
+	it.Request.setBody(requestBody(req))
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		it.Duration = since(it.Time)
+		it.Error = err.Error()
+		r.save(seq, req.URL.Host, it)
+		return nil, err
+	}
+	it.Response = &Message{
+		Status:  resp.StatusCode,
+		Headers: redactHeaders(resp.Header),
+	}
+	limit := int64(MaxText)
+	if isText(mediaType(resp.Header)) {
+		limit = -1
+	}
+	resp.Body = &recordingBody{
+		ReadCloser: resp.Body,
+		capture:    capture{limit: limit},
+		done: func(c *capture, complete bool) {
+			it.Duration = since(it.Time)
+			it.Response.setBody(c.buf.Bytes(), c.n, complete && !c.overflow)
+			r.save(seq, req.URL.Host, it)
+		},
+	}
+	return resp, nil
+}
+
+// requestBody captures the request body without consuming it. Bodies the
+// transport can only read once, such as file uploads, are not captured.
+func requestBody(req *http.Request) ([]byte, int64, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, 0, true
+	}
+	if req.GetBody == nil {
+		return nil, req.ContentLength, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, req.ContentLength, false
+	}
+	defer body.Close()
+	c := capture{limit: -1}
+	if !isText(mediaType(req.Header)) {
+		c.limit = MaxText
+	}
+	io.Copy(&c, body)
+	return c.buf.Bytes(), c.n, !c.overflow
+}
+
+func (r *Recorder) save(seq int, host string, it *Interaction) {
+	if r.dir != "" {
+		data, err := marshal(it, "  ")
+		if err == nil {
+			err = writeFile(filepath.Join(r.dir, fileName(seq, it.Request.Method, host)), data)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot record interaction %d: %v\n", seq, err)
+		}
+	}
+	if r.har != nil {
+		if err := r.har.add(seq, it); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot write HAR: %v\n", err)
+		}
+	}
+}
+
+func since(t time.Time) float64 {
+	return float64(time.Since(t).Microseconds()) / 1000
+}
+
+// capture counts the bytes written to it and keeps up to limit of them; a
+// negative limit keeps everything.
+type capture struct {
+	buf      bytes.Buffer
+	n        int64
+	limit    int64
+	overflow bool
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	if !c.overflow {
+		if c.limit >= 0 && int64(c.buf.Len()+len(p)) > c.limit {
+			c.overflow = true
+			c.buf = bytes.Buffer{}
+		} else {
+			c.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// recordingBody captures a response body as it is read and reports it once,
+// at EOF or Close, whichever comes first. A body closed before EOF is
+// reported as incomplete.
+type recordingBody struct {
+	io.ReadCloser
+	capture capture
+	done    func(c *capture, complete bool)
+	once    sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.capture.Write(p[:n])
+	if err == io.EOF {
+		b.once.Do(func() { b.done(&b.capture, true) })
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(&b.capture, false) })
+	return err
+}
@@ -0,0 +1,109 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Replayer is a transport that serves the interactions of a cassette in the
+// order they were recorded, without network access. Each request must have
+// the method and host of the next recorded one; paths and queries are not
+// compared, since staged file names and signatures change between runs.
+type Replayer struct {
+	dir string
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	names        []string
+	next         int
+}
+
+// NewReplayer loads the cassette in dir.
+func NewReplayer(dir string) (*Replayer, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "[0-9][0-9][0-9][0-9]-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no cassette found in %s", dir)
+	}
+	sort.Strings(names)
+	r := &Replayer{dir: dir, names: names}
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var it Interaction
+		if err := json.Unmarshal(data, &it); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", name, err)
+		}
+		r.interactions = append(r.interactions, &it)
+	}
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+
+	r.mu.Lock()
+	if r.next >= len(r.interactions) {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("replay %s %s: cassette %s has no more interactions (%d recorded)",
+			req.Method, req.URL.Host, r.dir, len(r.interactions))
+	}
+	it, name := r.interactions[r.next], filepath.Base(r.names[r.next])
+	r.next++
+	r.mu.Unlock()
+
+	recorded, err := url.Parse(it.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("replay %s: %w", name, err)
+	}
+	if it.Request.Method != req.Method || recorded.Host != req.URL.Host {
+		return nil, fmt.Errorf("replay %s %s: expected %s %s as recorded in %s",
+			req.Method, req.URL.Host, it.Request.Method, recorded.Host, name)
+	}
+	if it.Error != "" {
+		return nil, fmt.Errorf("%s (replayed from %s)", it.Error, name)
+	}
+	if it.Response == nil {
+		return nil, fmt.Errorf("replay %s: no response recorded", name)
+	}
+
+	body, err := it.Response.body()
+	if err != nil {
+		return nil, fmt.Errorf("replay %s: %w", name, err)
+	}
+	header := it.Response.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	// The body is rebuilt, so its length may differ from the recorded one.
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	header.Del("Content-Encoding")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", it.Response.Status, http.StatusText(it.Response.Status)),
+		StatusCode:    it.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
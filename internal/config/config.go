@@ -5,21 +5,118 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/llm-net/llm-api-plugin/internal/redact"
 )
 
 type ServiceConfig struct {
-	APIKey         string `json:"api_key,omitempty"`
-	UID            string `json:"uid,omitempty"`
-	AccessKeyID    string `json:"access_key_id,omitempty"`
+	APIKey          string `json:"api_key,omitempty"`
+	UID             string `json:"uid,omitempty"`
+	AccessKeyID     string `json:"access_key_id,omitempty"`
 	SecretAccessKey string `json:"secret_access_key,omitempty"`
+
+	// Stored maps secret fields kept out of this file to their secret
+	// store (keyring or file).
+	Stored map[string]string `json:"stored,omitempty"`
 }
 
-type Config struct {
+// NotifyTarget is a named destination for completion notifications.
+type NotifyTarget struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`              // webhook, command or desktop
+	URL     string   `json:"url,omitempty"`     // webhook endpoint
+	Secret  string   `json:"secret,omitempty"`  // webhook HMAC-SHA256 signing key
+	Command string   `json:"command,omitempty"` // shell command
+	On      []string `json:"on,omitempty"`      // success, failure, timeout; empty means all
+
+	// Stored names the secret store holding Secret when it is kept out of
+	// this file.
+	Stored string `json:"stored,omitempty"`
+}
+
+// StorageConfig describes an S3-compatible bucket (Volc TOS, AWS S3, MinIO).
+// Its access keys live in the "s3" service so that profiles and secret
+// storage apply to them.
+type StorageConfig struct {
+	// Endpoint is the S3 API endpoint, e.g. https://tos-s3-cn-beijing.volces.com.
+	Endpoint string `json:"endpoint"`
+	// Region is the signing region, e.g. cn-beijing.
+	Region string `json:"region"`
+	Bucket string `json:"bucket"`
+	// Prefix is prepended to every object key.
+	Prefix string `json:"prefix,omitempty"`
+	// PathStyle addresses objects as <endpoint>/<bucket>/<key> (MinIO).
+	PathStyle bool `json:"path_style,omitempty"`
+	// TTL is the presigned URL lifetime of staged inputs (default 1h).
+	TTL string `json:"ttl,omitempty"`
+	// Publish uploads every output by default, as if --publish were given.
+	Publish bool `json:"publish,omitempty"`
+	// PublishTTL is the presigned URL lifetime of published outputs
+	// (default and maximum 168h).
+	PublishTTL string `json:"publish_ttl,omitempty"`
+	// PublicURL is the base URL of a public bucket or CDN. Published outputs
+	// link to it instead of a presigned URL.
+	PublicURL string `json:"public_url,omitempty"`
+}
+
+// MetricsConfig enables exporting the timings of every generation.
+type MetricsConfig struct {
+	// Textfile is a Prometheus textfile (node_exporter textfile collector)
+	// accumulating counters across runs.
+	Textfile string `json:"textfile,omitempty"`
+	// OTLP is an OTLP/HTTP metrics endpoint accepting JSON, e.g.
+	// http://localhost:4318/v1/metrics.
+	OTLP string `json:"otlp,omitempty"`
+}
+
+// Services holds one ServiceConfig per provider. The top level of the config
+// file is the default profile; named profiles carry their own Services.
+type Services struct {
 	Gemini  *ServiceConfig `json:"gemini,omitempty"`
 	Veo3    *ServiceConfig `json:"veo3,omitempty"`
 	Ark     *ServiceConfig `json:"ark,omitempty"`
 	TopView *ServiceConfig `json:"topview,omitempty"`
 	Jimeng  *ServiceConfig `json:"jimeng,omitempty"`
+	// S3 holds the access keys of the object storage bucket (see Storage).
+	S3 *ServiceConfig `json:"s3,omitempty"`
+}
+
+type Config struct {
+	Services
+
+	// CurrentProfile is the profile selected by 'config profile use'.
+	CurrentProfile string              `json:"current_profile,omitempty"`
+	Profiles       map[string]*Profile `json:"profiles,omitempty"`
+
+	Notify []NotifyTarget `json:"notify,omitempty"`
+
+	// Defaults apply to every project; a .llm-api.json overrides them.
+	Defaults *Defaults `json:"defaults,omitempty"`
+
+	// Storage is the bucket used to stage local files and publish outputs.
+	Storage *StorageConfig `json:"storage,omitempty"`
+
+	// Metrics exports generation timings (see MetricsConfig).
+	Metrics *MetricsConfig `json:"metrics,omitempty"`
+
+	project *Project
+
+	// profile overrides CurrentProfile for this process (--profile,
+	// LLM_API_PROFILE or UseProfile).
+	profile       string
+	profileSource string
+}
+
+// NotifyTarget returns the notification target with the given name, or nil.
+func (c *Config) NotifyTarget(name string) *NotifyTarget {
+	for i := range c.Notify {
+		if c.Notify[i].Name == name {
+			return &c.Notify[i]
+		}
+	}
+	return nil
 }
 
 func Path() string {
@@ -40,6 +137,8 @@ func Load() (*Config, error) {
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
 	}
+	cfg.loadProject()
+	cfg.selectProfile()
 	return &cfg, nil
 }
 
@@ -55,36 +154,43 @@ func Save(cfg *Config) error {
 	return os.WriteFile(path, data, 0600)
 }
 
+// LoadOrCreate loads the config file, or returns an empty config when there
+// is none yet. A config that exists but cannot be read or parsed is an error,
+// so that callers do not save over it.
 func LoadOrCreate() (*Config, error) {
-	cfg, err := Load()
-	if err != nil {
-		return &Config{}, nil
+	if _, err := os.Stat(Path()); os.IsNotExist(err) {
+		cfg := &Config{}
+		cfg.loadProject()
+		cfg.selectProfile()
+		return cfg, nil
 	}
-	return cfg, nil
+	return Load()
 }
 
-// ResolveAPIKey returns the API key for a service.
-// Priority: environment variable > config file.
-func ResolveAPIKey(envVar string, fromConfig *ServiceConfig) string {
-	if v := os.Getenv(envVar); v != "" {
-		return v
-	}
-	if fromConfig != nil && fromConfig.APIKey != "" {
-		return fromConfig.APIKey
-	}
-	return ""
+// SplitKeys splits a credential field holding several keys, separated by
+// commas or white space, as pooled by the keypool package.
+func SplitKeys(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
 }
 
-// ResolveAccessKeys returns the AccessKeyID and SecretAccessKey for a service.
-// Priority: environment variables > config file.
-func ResolveAccessKeys(akEnvVar, skEnvVar string, fromConfig *ServiceConfig) (accessKeyID, secretAccessKey string) {
-	accessKeyID = os.Getenv(akEnvVar)
-	if accessKeyID == "" && fromConfig != nil {
-		accessKeyID = fromConfig.AccessKeyID
-	}
-	secretAccessKey = os.Getenv(skEnvVar)
-	if secretAccessKey == "" && fromConfig != nil {
-		secretAccessKey = fromConfig.SecretAccessKey
-	}
+// ResolveAPIKey returns the API key for a service in the active profile and
+// registers it with redact so that logs mask it. The value may list several
+// keys (see SplitKeys).
+// Priority: environment variable > active profile > inherited profiles > default profile.
+func ResolveAPIKey(cfg *Config, service, envVar string) string {
+	v, _ := Lookup(cfg, service, FieldAPIKey, envVar)
+	redact.AddSecret(SplitKeys(v)...)
+	return v
+}
+
+// ResolveAccessKeys returns the AccessKeyID and SecretAccessKey for a service
+// in the active profile, with the same priority as ResolveAPIKey.
+func ResolveAccessKeys(cfg *Config, service, akEnvVar, skEnvVar string) (accessKeyID, secretAccessKey string) {
+	accessKeyID, _ = Lookup(cfg, service, FieldAccessKeyID, akEnvVar)
+	secretAccessKey, _ = Lookup(cfg, service, FieldSecretAccessKey, skEnvVar)
+	redact.AddSecret(SplitKeys(accessKeyID)...)
+	redact.AddSecret(SplitKeys(secretAccessKey)...)
 	return
 }
@@ -0,0 +1,404 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// BaseProfile names the top-level services of the config file.
+const BaseProfile = "default"
+
+// ProfileEnv selects a profile when --profile is not given.
+const ProfileEnv = "LLM_API_PROFILE"
+
+// Service names accepted by Lookup and Edit.
+const (
+	ServiceGemini  = "gemini"
+	ServiceVeo3    = "veo3"
+	ServiceArk     = "ark"
+	ServiceTopView = "topview"
+	ServiceJimeng  = "jimeng"
+	ServiceS3      = "s3"
+)
+
+var allServices = []string{ServiceGemini, ServiceVeo3, ServiceArk, ServiceTopView, ServiceJimeng, ServiceS3}
+
+// ServiceConfig fields accepted by Lookup.
+const (
+	FieldAPIKey          = "api_key"
+	FieldUID             = "uid"
+	FieldAccessKeyID     = "access_key_id"
+	FieldSecretAccessKey = "secret_access_key"
+)
+
+// Profile is a named set of credentials. Services it leaves empty are taken
+// from the profile it inherits from, and finally from the default profile.
+type Profile struct {
+	Inherits string `json:"inherits,omitempty"`
+	Services
+}
+
+// profileFlag is the value of --profile, set by SelectProfile.
+var profileFlag string
+
+// SelectProfile removes a global --profile <name> (or --profile=<name>) flag
+// from args and verifies that the profile selected by it, LLM_API_PROFILE,
+// the project's .llm-api.json or 'config profile use' exists. It returns the remaining arguments.
+func SelectProfile(args []string) ([]string, error) {
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--profile":
+			i++
+			if i >= len(args) {
+				return nil, fmt.Errorf("--profile requires a profile name")
+			}
+			profileFlag = args[i]
+		case strings.HasPrefix(args[i], "--profile="):
+			profileFlag = strings.TrimPrefix(args[i], "--profile=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	// 'config profile' must keep working to create a missing profile.
+	if len(rest) >= 3 && rest[1] == "config" && rest[2] == "profile" {
+		return rest, nil
+	}
+	cfg, err := LoadOrCreate()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cfg.chain(cfg.profile); err != nil {
+		return nil, err
+	}
+	return rest, nil
+}
+
+func (c *Config) selectProfile() {
+	switch {
+	case profileFlag != "":
+		c.profile, c.profileSource = profileFlag, "--profile"
+	case os.Getenv(ProfileEnv) != "":
+		c.profile, c.profileSource = os.Getenv(ProfileEnv), "env "+ProfileEnv
+	case c.project != nil && c.project.Profile != "":
+		c.profile, c.profileSource = c.project.Profile, c.project.path
+	case c.CurrentProfile != "":
+		c.profile, c.profileSource = c.CurrentProfile, "config file"
+	default:
+		c.profile, c.profileSource = BaseProfile, "default"
+	}
+}
+
+// ActiveProfile returns the selected profile and what selected it.
+func (c *Config) ActiveProfile() (name, source string) {
+	if c.profile == "" {
+		c.selectProfile()
+	}
+	return c.profile, c.profileSource
+}
+
+// UseProfile switches this Config to the named profile.
+func (c *Config) UseProfile(name string) error {
+	if name == "" {
+		name = BaseProfile
+	}
+	if _, err := c.chain(name); err != nil {
+		return err
+	}
+	c.profile, c.profileSource = name, "request"
+	return nil
+}
+
+type profileLayer struct {
+	name     string
+	services *Services
+}
+
+// chain returns the named profile followed by the profiles it inherits from,
+// ending with the default profile.
+func (c *Config) chain(name string) ([]profileLayer, error) {
+	var layers []profileLayer
+	seen := map[string]bool{}
+	for name != "" && name != BaseProfile {
+		if seen[name] {
+			return nil, fmt.Errorf("profile %q inherits from itself", name)
+		}
+		seen[name] = true
+		p := c.Profiles[name]
+		if p == nil {
+			return nil, fmt.Errorf("unknown profile %q (create it with 'config profile add %s')", name, name)
+		}
+		layers = append(layers, profileLayer{name, &p.Services})
+		name = p.Inherits
+	}
+	return append(layers, profileLayer{BaseProfile, &c.Services}), nil
+}
+
+// Lookup resolves one field of a service for the active profile and reports
+// where the value came from ("env <VAR>" or "profile <name>", followed by the
+// secret store for secrets kept out of config.json).
+// Priority: environment variable > active profile > inherited profiles > default profile.
+func Lookup(cfg *Config, service, field, envVar string) (value, source string) {
+	if envVar != "" {
+		if v := os.Getenv(envVar); v != "" {
+			return v, "env " + envVar
+		}
+	}
+	if cfg == nil {
+		return "", ""
+	}
+	name, _ := cfg.ActiveProfile()
+	layers, err := cfg.chain(name)
+	if err != nil {
+		return "", ""
+	}
+	for i, l := range layers {
+		sc := l.services.Get(service)
+		v, store := sc.Field(field), ""
+		if v == "" {
+			var err error
+			if v, store, err = lookupStored(sc, l.name, service, field); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+		if v != "" {
+			source = "profile " + l.name
+			if i > 0 {
+				source += " (inherited)"
+			}
+			if store != "" {
+				source += ", " + store
+			}
+			return v, source
+		}
+	}
+	return "", ""
+}
+
+// Edit returns the active profile's own ServiceConfig for service, creating
+// it if needed, so that 'config set-key' writes to the selected profile.
+func (c *Config) Edit(service string) (*ServiceConfig, error) {
+	name, _ := c.ActiveProfile()
+	layers, err := c.chain(name)
+	if err != nil {
+		return nil, err
+	}
+	slot := layers[0].services.slot(service)
+	if slot == nil {
+		return nil, fmt.Errorf("unknown service %q", service)
+	}
+	if *slot == nil {
+		*slot = &ServiceConfig{}
+	}
+	return *slot, nil
+}
+
+// Location describes where a value was written: where (the config path or
+// secret store) and, for a named profile, the profile name.
+func (c *Config) Location(where string) string {
+	if name, _ := c.ActiveProfile(); name != BaseProfile {
+		return fmt.Sprintf("%s (profile %s)", where, name)
+	}
+	return where
+}
+
+// Get returns the ServiceConfig for a service name, or nil.
+func (s *Services) Get(service string) *ServiceConfig {
+	if slot := s.slot(service); slot != nil {
+		return *slot
+	}
+	return nil
+}
+
+func (s *Services) slot(service string) **ServiceConfig {
+	switch service {
+	case ServiceGemini:
+		return &s.Gemini
+	case ServiceVeo3:
+		return &s.Veo3
+	case ServiceArk:
+		return &s.Ark
+	case ServiceTopView:
+		return &s.TopView
+	case ServiceJimeng:
+		return &s.Jimeng
+	case ServiceS3:
+		return &s.S3
+	}
+	return nil
+}
+
+// configured lists the services with at least one value set.
+func (s *Services) configured() []string {
+	var names []string
+	for _, name := range allServices {
+		if sc := s.Get(name); sc != nil && !sc.empty() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Field returns a field by its JSON name. It is nil-safe.
+func (s *ServiceConfig) Field(field string) string {
+	if s == nil {
+		return ""
+	}
+	switch field {
+	case FieldAPIKey:
+		return s.APIKey
+	case FieldUID:
+		return s.UID
+	case FieldAccessKeyID:
+		return s.AccessKeyID
+	case FieldSecretAccessKey:
+		return s.SecretAccessKey
+	}
+	return ""
+}
+
+func (s *ServiceConfig) set(field, value string) {
+	switch field {
+	case FieldAPIKey:
+		s.APIKey = value
+	case FieldUID:
+		s.UID = value
+	case FieldAccessKeyID:
+		s.AccessKeyID = value
+	case FieldSecretAccessKey:
+		s.SecretAccessKey = value
+	}
+}
+
+func (s *ServiceConfig) empty() bool {
+	return s.APIKey == "" && s.UID == "" && s.AccessKeyID == "" && s.SecretAccessKey == "" && len(s.Stored) == 0
+}
+
+// ProfileUsage documents the 'config profile' subcommands shared by all CLIs.
+const ProfileUsage = `Usage:
+  config profile add <name> [--inherits <profile>]
+  config profile use <name>
+  config profile list
+  config profile delete <name>
+
+Select a profile per command with --profile <name> or LLM_API_PROFILE=<name>.
+'config set-key' and friends write to the selected profile.`
+
+// ProfileCommand runs 'config profile <args>' against the shared config file.
+func ProfileCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", ProfileUsage)
+	}
+	cfg, err := LoadOrCreate()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 2 {
+			return fmt.Errorf("%s", ProfileUsage)
+		}
+		name := args[1]
+		if name == "" || name == BaseProfile {
+			return fmt.Errorf("invalid profile name %q", name)
+		}
+		p := cfg.Profiles[name]
+		if p == nil {
+			p = &Profile{}
+		}
+		rest := args[2:]
+		for i := 0; i < len(rest); i++ {
+			switch rest[i] {
+			case "--inherits":
+				i++
+				if i < len(rest) {
+					p.Inherits = rest[i]
+				}
+			default:
+				return fmt.Errorf("unknown flag: %s\n%s", rest[i], ProfileUsage)
+			}
+		}
+		if p.Inherits == BaseProfile {
+			p.Inherits = ""
+		}
+		if cfg.Profiles == nil {
+			cfg.Profiles = map[string]*Profile{}
+		}
+		cfg.Profiles[name] = p
+		if _, err := cfg.chain(name); err != nil {
+			return err
+		}
+		if err := Save(cfg); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Printf("Profile %q saved to %s\n", name, Path())
+	case "use":
+		if len(args) < 2 {
+			return fmt.Errorf("%s", ProfileUsage)
+		}
+		if _, err := cfg.chain(args[1]); err != nil {
+			return err
+		}
+		cfg.CurrentProfile = args[1]
+		if cfg.CurrentProfile == BaseProfile {
+			cfg.CurrentProfile = ""
+		}
+		if err := Save(cfg); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Printf("Now using profile %q\n", args[1])
+	case "list":
+		active, source := cfg.ActiveProfile()
+		names := []string{BaseProfile}
+		for name := range cfg.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names[1:])
+		for _, name := range names {
+			mark := " "
+			if name == active {
+				mark = "*"
+			}
+			services := cfg.Services.configured()
+			inherits := ""
+			if p := cfg.Profiles[name]; p != nil {
+				services = p.configured()
+				inherits = BaseProfile
+				if p.Inherits != "" {
+					inherits = p.Inherits
+				}
+				inherits = "\tinherits=" + inherits
+			}
+			fmt.Printf("%s %s%s\t%s\n", mark, name, inherits, strings.Join(services, ","))
+		}
+		fmt.Printf("\nActive: %s (source: %s)\n", active, source)
+	case "delete":
+		if len(args) < 2 {
+			return fmt.Errorf("%s", ProfileUsage)
+		}
+		name := args[1]
+		if cfg.Profiles[name] == nil {
+			return fmt.Errorf("unknown profile %q", name)
+		}
+		for other, p := range cfg.Profiles {
+			if p.Inherits == name {
+				return fmt.Errorf("profile %q inherits from %q; delete or change it first", other, name)
+			}
+		}
+		cfg.deleteSecrets(name, &cfg.Profiles[name].Services)
+		delete(cfg.Profiles, name)
+		if cfg.CurrentProfile == name {
+			cfg.CurrentProfile = ""
+		}
+		if err := Save(cfg); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Printf("Profile %q deleted\n", name)
+	default:
+		return fmt.Errorf("unknown profile command: %s\n%s", args[0], ProfileUsage)
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/llm-net/llm-api-plugin/internal/models"
+)
+
+// ProjectFile is the per-project config, found by walking up from the
+// current directory and layered over the global config.
+const ProjectFile = ".llm-api.json"
+
+// AllModels is the Params key whose values apply to every model.
+const AllModels = "*"
+
+// Defaults are generation defaults, set globally under "defaults" in
+// config.json or per project in .llm-api.json.
+type Defaults struct {
+	// OutputDir holds generated files when no --output is given.
+	OutputDir string `json:"output_dir,omitempty"`
+	// OutputTemplate names generated files in OutputDir, e.g.
+	// "{model}/{date}_{index}.{ext}".
+	OutputTemplate string `json:"output_template,omitempty"`
+	// KeyStrategy picks among several API keys of a provider:
+	// "round-robin" (default) or "least-limited".
+	KeyStrategy string `json:"key_strategy,omitempty"`
+	// Models maps a capability (see '<cli> models') to its default model.
+	Models map[string]string `json:"models,omitempty"`
+	// Params maps a model name (or "*") to parameter defaults, keyed by the
+	// parameter names shown by '<cli> models'.
+	Params map[string]map[string]string `json:"params,omitempty"`
+}
+
+// Project is the content of a .llm-api.json file.
+type Project struct {
+	// Profile selects a credential profile for this project.
+	Profile string `json:"profile,omitempty"`
+	Defaults
+
+	path string
+}
+
+// Path returns the file the project was loaded from.
+func (p *Project) Path() string {
+	return p.path
+}
+
+// FindProject returns the nearest .llm-api.json at or above dir, or nil.
+func FindProject(dir string) (*Project, error) {
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			p := &Project{path: path}
+			if err := json.Unmarshal(data, p); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			return p, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// loadProject attaches the project config of the working directory.
+func (c *Config) loadProject() {
+	dir, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	p, err := FindProject(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring project config: %v\n", err)
+		return
+	}
+	c.project = p
+}
+
+// Project returns the project config in effect, or nil.
+func (c *Config) Project() *Project {
+	return c.project
+}
+
+type defaultsLayer struct {
+	source string
+	d      *Defaults
+}
+
+// layers returns the defaults in priority order with a description of
+// where each came from.
+func (c *Config) layers() []defaultsLayer {
+	var ls []defaultsLayer
+	if c.project != nil {
+		ls = append(ls, defaultsLayer{c.project.path, &c.project.Defaults})
+	}
+	if c.Defaults != nil {
+		ls = append(ls, defaultsLayer{Path(), c.Defaults})
+	}
+	return ls
+}
+
+// ModelFor returns the configured default model for a capability, provided
+// it belongs to reg, and where it was configured. It returns "" when unset.
+func (c *Config) ModelFor(capability string, reg *models.Registry) (model, source string) {
+	for _, l := range c.layers() {
+		if m := l.d.Models[capability]; m != "" && reg.FindModel(m) != nil {
+			return m, l.source
+		}
+	}
+	return "", ""
+}
+
+// ParamDefault returns the configured default of a model parameter and where
+// it was configured. Model-specific values win over "*" within each layer.
+func (c *Config) ParamDefault(model, name string) (value, source string) {
+	for _, l := range c.layers() {
+		for _, key := range []string{model, AllModels} {
+			if v, ok := l.d.Params[key][name]; ok {
+				return v, l.source
+			}
+		}
+	}
+	return "", ""
+}
+
+// Param returns the configured default of a model parameter, or fallback.
+func (c *Config) Param(model, name, fallback string) string {
+	if v, source := c.ParamDefault(model, name); source != "" {
+		return v
+	}
+	return fallback
+}
+
+// OutputDir returns the configured output directory and where it was
+// configured. A relative project output_dir is relative to the project file.
+func (c *Config) OutputDir() (dir, source string) {
+	for _, l := range c.layers() {
+		if l.d.OutputDir == "" {
+			continue
+		}
+		dir = l.d.OutputDir
+		if c.project != nil && l.d == &c.project.Defaults && !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(c.project.path), dir)
+		}
+		return dir, l.source
+	}
+	return "", ""
+}
+
+// OutputTemplate returns the configured output file name template and where
+// it was configured.
+func (c *Config) OutputTemplate() (tmpl, source string) {
+	for _, l := range c.layers() {
+		if l.d.OutputTemplate != "" {
+			return l.d.OutputTemplate, l.source
+		}
+	}
+	return "", ""
+}
+
+// KeyStrategy returns the configured key selection strategy and where it
+// was configured.
+func (c *Config) KeyStrategy() (strategy, source string) {
+	for _, l := range c.layers() {
+		if l.d.KeyStrategy != "" {
+			return l.d.KeyStrategy, l.source
+		}
+	}
+	return "", ""
+}
+
+// ArgValue returns the value following flag in args, or "".
+func ArgValue(args []string, flag string) string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == flag {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// ShowEffective prints the merged project and global defaults for the models
+// of reg, explaining where each value comes from.
+func ShowEffective(c *Config, reg *models.Registry) {
+	fmt.Printf("Global config: %s\n", Path())
+	if c.project != nil {
+		fmt.Printf("Project config: %s\n", c.project.path)
+	} else {
+		fmt.Printf("Project config: none (no %s found)\n", ProjectFile)
+	}
+	profile, source := c.ActiveProfile()
+	fmt.Printf("Profile: %s (source: %s)\n", profile, source)
+	if dir, source := c.OutputDir(); dir != "" {
+		fmt.Printf("Output dir: %s (source: %s)\n", dir, source)
+	} else {
+		fmt.Println("Output dir: current directory (built-in)")
+	}
+	if tmpl, source := c.OutputTemplate(); tmpl != "" {
+		fmt.Printf("Output template: %s (source: %s)\n", tmpl, source)
+	} else {
+		fmt.Println("Output template: output_{date}.{ext} (built-in)")
+	}
+	if strategy, source := c.KeyStrategy(); strategy != "" {
+		fmt.Printf("Key strategy: %s (source: %s)\n", strategy, source)
+	}
+
+	var capabilities []string
+	seen := map[string]bool{}
+	for _, m := range reg.Models {
+		for _, capability := range m.Capabilities {
+			if !seen[capability] {
+				seen[capability] = true
+				capabilities = append(capabilities, capability)
+			}
+		}
+	}
+	fmt.Println("\nDefault models:")
+	for _, capability := range capabilities {
+		if m, source := c.ModelFor(capability, reg); m != "" {
+			fmt.Printf("  %s: %s (source: %s)\n", capability, m, source)
+		} else {
+			fmt.Printf("  %s: not set\n", capability)
+		}
+	}
+
+	fmt.Println("\nModel params:")
+	for _, m := range reg.Models {
+		fmt.Printf("  %s\n", m.Name)
+		names := make([]string, 0, len(m.Params))
+		for name := range m.Params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			builtin := m.Params[name].Default
+			if v, source := c.ParamDefault(m.Name, name); source != "" {
+				fmt.Printf("    %s = %s (source: %s; built-in: %q)\n", name, v, source, builtin)
+			} else if builtin != "" {
+				fmt.Printf("    %s = %s (built-in)\n", name, builtin)
+			}
+		}
+	}
+	for _, l := range c.layers() {
+		for model, params := range l.d.Params {
+			m := reg.FindModel(model)
+			if m == nil {
+				continue
+			}
+			for name := range params {
+				if _, ok := m.Params[name]; !ok {
+					fmt.Printf("\nWarning: %s sets unknown param %q for %s\n", l.source, name, model)
+				}
+			}
+		}
+	}
+}
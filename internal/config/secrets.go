@@ -0,0 +1,575 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+
+	"github.com/llm-net/llm-api-plugin/internal/redact"
+)
+
+// Secret stores.
+const (
+	StoreKeyring   = "keyring"
+	StoreFile      = "file"
+	StorePlaintext = "plaintext"
+)
+
+// SecretStoreEnv forces a secret store (keyring, file or plaintext).
+const SecretStoreEnv = "LLM_API_SECRET_STORE"
+
+// PassphraseEnv supplies the passphrase of the encrypted secrets file.
+const PassphraseEnv = "LLM_API_PASSPHRASE"
+
+// keyringService is the service name secrets are filed under in the OS keyring.
+const keyringService = "llm-api-plugin"
+
+// secretFields are the ServiceConfig fields kept out of config.json.
+var secretFields = []string{FieldAPIKey, FieldSecretAccessKey}
+
+func isSecretField(field string) bool {
+	for _, f := range secretFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// secretStore holds secrets by key outside of config.json.
+type secretStore interface {
+	get(key string) (string, error)
+	set(key, value string) error
+	delete(key string) error
+	// String describes where secrets are kept.
+	String() string
+}
+
+// secretKey names a secret in a store: <profile>/<service>/<field>.
+func secretKey(profile, service, field string) string {
+	return profile + "/" + service + "/" + field
+}
+
+// SecretsPath is the age-encrypted fallback secrets file.
+func SecretsPath() string {
+	return filepath.Join(filepath.Dir(Path()), "secrets.age")
+}
+
+// writeStore picks the store for new secrets: LLM_API_SECRET_STORE if set,
+// otherwise the OS keyring when available, otherwise the encrypted file.
+// It returns "" for plaintext.
+func writeStore() (string, error) {
+	switch v := os.Getenv(SecretStoreEnv); v {
+	case "":
+	case StoreKeyring, StoreFile:
+		return v, nil
+	case StorePlaintext:
+		return "", nil
+	default:
+		return "", fmt.Errorf("invalid %s %q, want keyring, file or plaintext", SecretStoreEnv, v)
+	}
+	if keyringAvailable() {
+		return StoreKeyring, nil
+	}
+	return StoreFile, nil
+}
+
+func keyringAvailable() bool {
+	_, err := keyring.Get(keyringService, "probe")
+	return err == nil || errors.Is(err, keyring.ErrNotFound)
+}
+
+func openStore(name string) (secretStore, error) {
+	switch name {
+	case StoreKeyring:
+		return keyringStore{}, nil
+	case StoreFile:
+		return theFileStore, nil
+	}
+	return nil, fmt.Errorf("unknown secret store %q", name)
+}
+
+type keyringStore struct{}
+
+func (keyringStore) get(key string) (string, error) { return keyring.Get(keyringService, key) }
+func (keyringStore) set(key, value string) error    { return keyring.Set(keyringService, key, value) }
+func (keyringStore) String() string                 { return "OS keyring" }
+
+func (keyringStore) delete(key string) error {
+	if err := keyring.Delete(keyringService, key); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// fileStore keeps secrets as a JSON object encrypted with an age scrypt
+// passphrase. The decrypted contents are cached for the life of the process.
+type fileStore struct {
+	mu         sync.Mutex
+	loaded     bool
+	err        error // a failed load is not retried, to avoid repeated prompts
+	passphrase string
+	secrets    map[string]string
+}
+
+var theFileStore = &fileStore{}
+
+func (f *fileStore) String() string { return SecretsPath() }
+
+func (f *fileStore) load() error {
+	if !f.loaded && f.err == nil {
+		f.err = f.read()
+	}
+	return f.err
+}
+
+func (f *fileStore) read() error {
+	data, err := os.ReadFile(SecretsPath())
+	if os.IsNotExist(err) {
+		pass, err := readPassphrase(true)
+		if err != nil {
+			return err
+		}
+		f.passphrase, f.secrets, f.loaded = pass, map[string]string{}, true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	pass, err := readPassphrase(false)
+	if err != nil {
+		return err
+	}
+	id, err := age.NewScryptIdentity(pass)
+	if err != nil {
+		return err
+	}
+	r, err := age.Decrypt(bytes.NewReader(data), id)
+	if err != nil {
+		return fmt.Errorf("decrypt %s: %w", SecretsPath(), err)
+	}
+	var secrets map[string]string
+	if err := json.NewDecoder(r).Decode(&secrets); err != nil {
+		return fmt.Errorf("decode %s: %w", SecretsPath(), err)
+	}
+	if secrets == nil {
+		secrets = map[string]string{}
+	}
+	f.passphrase, f.secrets, f.loaded = pass, secrets, true
+	return nil
+}
+
+func (f *fileStore) save() error {
+	recipient, err := age.NewScryptRecipient(f.passphrase)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(f.secrets)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipient)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	path := SecretsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (f *fileStore) get(key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return "", err
+	}
+	v, ok := f.secrets[key]
+	if !ok {
+		return "", fmt.Errorf("%s not found in %s", key, SecretsPath())
+	}
+	return v, nil
+}
+
+func (f *fileStore) set(key, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(); err != nil {
+		return err
+	}
+	f.secrets[key] = value
+	return f.save()
+}
+
+func (f *fileStore) delete(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := os.Stat(SecretsPath()); os.IsNotExist(err) {
+		return nil
+	}
+	if err := f.load(); err != nil {
+		return err
+	}
+	delete(f.secrets, key)
+	return f.save()
+}
+
+// readPassphrase returns LLM_API_PASSPHRASE or prompts for it on the
+// terminal. A new passphrase is asked for twice.
+func readPassphrase(confirm bool) (string, error) {
+	if v := os.Getenv(PassphraseEnv); v != "" {
+		return v, nil
+	}
+	tty, closeTTY, err := openTerminal()
+	if err != nil {
+		return "", fmt.Errorf("no OS keyring available and no terminal to ask for the %s passphrase; set %s", SecretsPath(), PassphraseEnv)
+	}
+	defer closeTTY()
+	prompt := "Passphrase for " + SecretsPath() + ": "
+	if confirm {
+		prompt = "New passphrase for " + SecretsPath() + ": "
+	}
+	pass, err := readHidden(tty, prompt)
+	if err != nil {
+		return "", err
+	}
+	if pass == "" {
+		return "", fmt.Errorf("empty passphrase")
+	}
+	if confirm {
+		again, err := readHidden(tty, "Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != pass {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return pass, nil
+}
+
+// openTerminal returns stdin when it is a terminal, otherwise the
+// controlling terminal so that prompts still work while stdin is piped.
+func openTerminal() (*os.File, func(), error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return os.Stdin, func() {}, nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !term.IsTerminal(int(tty.Fd())) {
+		tty.Close()
+		return nil, nil, fmt.Errorf("no terminal")
+	}
+	return tty, func() { tty.Close() }, nil
+}
+
+func readHidden(tty *os.File, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// ReadSecret reads a secret for 'config set-key': from a no-echo prompt when
+// stdin is a terminal, otherwise from the first line of stdin.
+func ReadSecret(prompt string) (string, error) {
+	var v string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		s, err := readHidden(os.Stdin, prompt)
+		if err != nil {
+			return "", err
+		}
+		v = s
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		v = strings.TrimSpace(line)
+	}
+	if v == "" {
+		return "", fmt.Errorf("empty value")
+	}
+	return v, nil
+}
+
+// SecretArg returns args[i] when present and not "-", warning that it
+// ends up in shell history, or else reads the secret with ReadSecret.
+func SecretArg(args []string, i int, prompt string) (string, error) {
+	if i < len(args) && args[i] != "-" {
+		fmt.Fprintln(os.Stderr, "Warning: secrets passed as arguments end up in shell history; omit the value to be prompted instead")
+		return args[i], nil
+	}
+	return ReadSecret(prompt)
+}
+
+// lookupStored reads a field recorded in sc.Stored from its store.
+func lookupStored(sc *ServiceConfig, profile, service, field string) (string, string, error) {
+	if sc == nil || sc.Stored[field] == "" {
+		return "", "", nil
+	}
+	store, err := openStore(sc.Stored[field])
+	if err != nil {
+		return "", "", err
+	}
+	v, err := store.get(secretKey(profile, service, field))
+	if err != nil {
+		return "", "", fmt.Errorf("read %s from %s: %w", secretKey(profile, service, field), store, err)
+	}
+	return v, storeLabels[sc.Stored[field]], nil
+}
+
+// storeLabels describe secret stores in 'config show' sources.
+var storeLabels = map[string]string{StoreKeyring: "OS keyring", StoreFile: "encrypted file"}
+
+// SetSecret writes a field of the active profile's service. Secret fields go
+// to the secret store, and any plaintext secrets left in config.json are
+// migrated along with them. The returned string says where the value went.
+// The caller saves cfg afterwards.
+func (c *Config) SetSecret(service, field, value string) (string, error) {
+	sc, err := c.Edit(service)
+	if err != nil {
+		return "", err
+	}
+	if !isSecretField(field) {
+		sc.set(field, value)
+		return Path(), nil
+	}
+	storeName, err := writeStore()
+	if err != nil {
+		return "", err
+	}
+	if storeName == "" {
+		sc.set(field, value)
+		delete(sc.Stored, field)
+		return Path(), nil
+	}
+	if _, err := c.MigrateSecrets(); err != nil {
+		return "", err
+	}
+	store, err := openStore(storeName)
+	if err != nil {
+		return "", err
+	}
+	profile, _ := c.ActiveProfile()
+	if err := store.set(secretKey(profile, service, field), value); err != nil {
+		return "", fmt.Errorf("write %s: %w", store, err)
+	}
+	sc.set(field, "")
+	if sc.Stored == nil {
+		sc.Stored = map[string]string{}
+	}
+	sc.Stored[field] = storeName
+	return store.String(), nil
+}
+
+// notifySecretKey names the signing key of a notify target in a store.
+func notifySecretKey(name string) string {
+	return "notify/" + name + "/secret"
+}
+
+// SetNotifySecret sets the webhook signing key of t, keeping it in the secret
+// store like API keys. The returned string says where the value went. The
+// caller saves cfg afterwards.
+func (c *Config) SetNotifySecret(t *NotifyTarget, value string) (string, error) {
+	storeName, err := writeStore()
+	if err != nil {
+		return "", err
+	}
+	if storeName == "" {
+		t.Secret, t.Stored = value, ""
+		return Path(), nil
+	}
+	store, err := openStore(storeName)
+	if err != nil {
+		return "", err
+	}
+	if err := store.set(notifySecretKey(t.Name), value); err != nil {
+		return "", fmt.Errorf("write %s: %w", store, err)
+	}
+	t.Secret, t.Stored = "", storeName
+	return store.String(), nil
+}
+
+// ResolveSecret returns the webhook signing key of t, reading it from the
+// secret store if needed, and registers it with redact.
+func (t *NotifyTarget) ResolveSecret() (string, error) {
+	v := t.Secret
+	if v == "" && t.Stored != "" {
+		store, err := openStore(t.Stored)
+		if err != nil {
+			return "", err
+		}
+		if v, err = store.get(notifySecretKey(t.Name)); err != nil {
+			return "", fmt.Errorf("read %s from %s: %w", notifySecretKey(t.Name), store, err)
+		}
+	}
+	redact.AddSecret(v)
+	return v, nil
+}
+
+// DeleteNotifySecret removes the stored signing key of t, if any.
+func DeleteNotifySecret(t *NotifyTarget) {
+	if t.Stored == "" {
+		return
+	}
+	store, err := openStore(t.Stored)
+	if err == nil {
+		err = store.delete(notifySecretKey(t.Name))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not delete %s: %v\n", notifySecretKey(t.Name), err)
+	}
+}
+
+// PlaintextSecrets counts secret fields and notify signing keys still stored
+// in config.json.
+func (c *Config) PlaintextSecrets() int {
+	n := 0
+	c.eachService(func(profile, service string, sc *ServiceConfig) {
+		for _, field := range secretFields {
+			if sc.Field(field) != "" {
+				n++
+			}
+		}
+	})
+	for _, t := range c.Notify {
+		if t.Secret != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// MigrateSecrets moves plaintext secrets of every profile into the secret
+// store and returns how many were moved. The caller saves cfg afterwards.
+func (c *Config) MigrateSecrets() (int, error) {
+	if c.PlaintextSecrets() == 0 {
+		return 0, nil
+	}
+	storeName, err := writeStore()
+	if err != nil || storeName == "" {
+		return 0, err
+	}
+	store, err := openStore(storeName)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	c.eachService(func(profile, service string, sc *ServiceConfig) {
+		for _, field := range secretFields {
+			v := sc.Field(field)
+			if v == "" || err != nil {
+				continue
+			}
+			if err = store.set(secretKey(profile, service, field), v); err != nil {
+				err = fmt.Errorf("write %s: %w", store, err)
+				return
+			}
+			sc.set(field, "")
+			if sc.Stored == nil {
+				sc.Stored = map[string]string{}
+			}
+			sc.Stored[field] = storeName
+			n++
+		}
+	})
+	for i := range c.Notify {
+		t := &c.Notify[i]
+		if t.Secret == "" || err != nil {
+			continue
+		}
+		if err = store.set(notifySecretKey(t.Name), t.Secret); err != nil {
+			err = fmt.Errorf("write %s: %w", store, err)
+			break
+		}
+		t.Secret, t.Stored = "", storeName
+		n++
+	}
+	return n, err
+}
+
+// deleteSecrets removes a profile's stored secrets.
+func (c *Config) deleteSecrets(profile string, s *Services) {
+	for _, service := range allServices {
+		sc := s.Get(service)
+		if sc == nil {
+			continue
+		}
+		for field, storeName := range sc.Stored {
+			store, err := openStore(storeName)
+			if err == nil {
+				err = store.delete(secretKey(profile, service, field))
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not delete %s: %v\n", secretKey(profile, service, field), err)
+			}
+		}
+	}
+}
+
+func (c *Config) eachService(fn func(profile, service string, sc *ServiceConfig)) {
+	visit := func(profile string, s *Services) {
+		for _, service := range allServices {
+			if sc := s.Get(service); sc != nil {
+				fn(profile, service, sc)
+			}
+		}
+	}
+	visit(BaseProfile, &c.Services)
+	for name, p := range c.Profiles {
+		visit(name, &p.Services)
+	}
+}
+
+// MigrateCommand runs 'config migrate', moving plaintext secrets out of
+// config.json.
+func MigrateCommand() error {
+	cfg, err := LoadOrCreate()
+	if err != nil {
+		return err
+	}
+	n, err := cfg.MigrateSecrets()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		fmt.Println("No plaintext secrets to migrate")
+		return nil
+	}
+	if err := Save(cfg); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	store, _ := writeStore()
+	s, _ := openStore(store)
+	fmt.Printf("Moved %d secret(s) from %s to %s\n", n, Path(), s)
+	return nil
+}
@@ -0,0 +1,246 @@
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+)
+
+// probeTimeout bounds each network step of a check.
+const probeTimeout = 15 * time.Second
+
+// MaxSkew is the clock difference beyond which signed requests are reported
+// as at risk. Volcengine rejects signatures more than a few minutes off.
+const MaxSkew = 5 * time.Minute
+
+// Credential is a resolved credential field shown in the report.
+type Credential struct {
+	Name   string
+	Value  string
+	Source string
+	// Optional credentials may be left unset.
+	Optional bool
+}
+
+// Check describes how to diagnose one provider.
+type Check struct {
+	// Name identifies the provider, e.g. "Gemini".
+	Name string
+	// URL is the API endpoint probed for DNS, TLS and clock skew.
+	URL string
+	// Credentials are the resolved credentials the probe uses.
+	Credentials []Credential
+	// Signed marks providers whose request signatures depend on the clock.
+	Signed bool
+	// SetupHint tells how to configure the credentials, e.g. a command.
+	SetupHint string
+	// AccessHint tells how to grant the account access to the service.
+	AccessHint string
+	// Probe makes a cheap authenticated call.
+	Probe func() error
+}
+
+// Cred resolves a credential field the same way the CLIs do.
+func Cred(cfg *config.Config, name, service, field, envVar string) Credential {
+	value, source := config.Lookup(cfg, service, field, envVar)
+	return Credential{Name: name, Value: value, Source: source}
+}
+
+// Run diagnoses every check, printing a report to w, and returns the number
+// of providers that failed.
+func Run(w io.Writer, checks []Check) int {
+	failed := 0
+	for i, c := range checks {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if !run(w, c) {
+			failed++
+		}
+	}
+	fmt.Fprintf(w, "\n%d of %d provider(s) OK\n", len(checks)-failed, len(checks))
+	return failed
+}
+
+func run(w io.Writer, c Check) bool {
+	fmt.Fprintf(w, "%s (%s)\n", c.Name, c.URL)
+	line := func(step, format string, args ...interface{}) {
+		fmt.Fprintf(w, "  %-14s %s\n", step, fmt.Sprintf(format, args...))
+	}
+	missing := false
+	fail := func(step string, err error, fix string) bool {
+		line(step, "FAILED: %s", truncate(err.Error(), 300))
+		line("Fix", "%s", fix)
+		if missing {
+			line("Fix", "%s", c.SetupHint)
+		}
+		return false
+	}
+
+	for _, cred := range c.Credentials {
+		if cred.Value == "" && cred.Optional {
+			line(cred.Name, "not set (optional)")
+			continue
+		}
+		if cred.Value == "" {
+			line(cred.Name, "not set")
+			missing = true
+			continue
+		}
+		line(cred.Name, "%s (source: %s)", mask(cred.Value), cred.Source)
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fail("URL", err, "report this as a bug")
+	}
+	host := u.Hostname()
+
+	proxy, _ := http.ProxyFromEnvironment(&http.Request{URL: u})
+	if proxy != nil {
+		// DNS and TLS happen at the proxy; only the HTTP steps are meaningful.
+		line("Proxy", "%s", proxy.Redacted())
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		start := time.Now()
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		cancel()
+		if err != nil {
+			return fail("DNS", err, fmt.Sprintf("cannot resolve %s: check your network, DNS server or VPN, or set HTTPS_PROXY", host))
+		}
+		line("DNS", "ok, %s (%s)", addrs[0], since(start))
+
+		start = time.Now()
+		dialer := &net.Dialer{Timeout: probeTimeout}
+		conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, "443"), &tls.Config{ServerName: host})
+		if err != nil {
+			return fail("TLS", err, diagnose(err, 0, c))
+		}
+		version := tls.VersionName(conn.ConnectionState().Version)
+		conn.Close()
+		line("TLS", "ok, %s (%s)", version, since(start))
+	}
+
+	skew, err := clockSkew(c.URL)
+	switch {
+	case err != nil:
+		return fail("HTTP", err, diagnose(err, 0, c))
+	case c.Signed && (skew > MaxSkew || skew < -MaxSkew):
+		line("Clock", "WARNING: local clock is %s off the server; request signing will fail", signed(skew))
+	default:
+		line("Clock", "ok, skew %s", signed(skew))
+	}
+
+	if missing {
+		line("Auth", "skipped: credentials not configured")
+		line("Fix", "%s", c.SetupHint)
+		return false
+	}
+
+	start := time.Now()
+	if err := c.Probe(); err != nil {
+		return fail("Auth", err, diagnose(err, skew, c))
+	}
+	line("Auth", "ok (%s)", since(start))
+	return true
+}
+
+// clockSkew compares the local clock with the Date header of url. A positive
+// skew means the local clock is ahead.
+func clockSkew(rawURL string) (time.Duration, error) {
+	client := &http.Client{Timeout: probeTimeout}
+	start := time.Now()
+	resp, err := client.Head(rawURL)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	rtt := time.Since(start)
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, nil
+	}
+	// Date has one-second resolution and was stamped mid-flight.
+	local := start.Add(rtt / 2)
+	return local.Sub(date).Round(time.Second), nil
+}
+
+// diagnose suggests a fix for a failed step.
+func diagnose(err error, skew time.Duration, c Check) string {
+	msg := strings.ToLower(err.Error())
+	has := func(subs ...string) bool {
+		for _, s := range subs {
+			if strings.Contains(msg, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case has("x509", "certificate", "tls: "):
+		return "TLS verification failed: a proxy or firewall may be intercepting HTTPS; trust its CA (SSL_CERT_FILE) or bypass it"
+	case has("proxyconnect"):
+		return "the proxy is unreachable: check HTTPS_PROXY / HTTP_PROXY"
+	case has("timeout", "deadline exceeded", "connection refused", "connection reset", "no route to host", "network is unreachable"):
+		return "network blocked or unreachable: check firewall and VPN rules, or set HTTPS_PROXY"
+	case has("no such host", "server misbehaving"):
+		return "DNS lookup failed: check your DNS server or VPN, or set HTTPS_PROXY"
+	case c.Signed && (skew > MaxSkew || skew < -MaxSkew):
+		return fmt.Sprintf("local clock is %s off: enable NTP time sync (e.g. 'sudo timedatectl set-ntp true')", signed(skew))
+	case has("signaturedoesnotmatch", "invalidsignature"):
+		return "signature rejected: the secret access key does not match the access key ID; " + c.SetupHint
+	case has("requestexpired", "invalidtimestamp", "expired"):
+		return "request timestamp rejected: sync your clock with NTP"
+	case has("http 403", "code 403", "code=50400", `"code":50400`, "accessdenied", "permission_denied", "forbidden"):
+		return "credentials accepted but access denied: " + c.AccessHint
+	case has("http 401", "code 401", "invalidaccesskey", "invalid api key", "api key not valid", "api_key_invalid", "unauthenticated", "authenticationerror"):
+		return "credentials rejected: the key is wrong, revoked or expired; " + c.SetupHint
+	case has("http 429", "code=50429", `"code":50429`, "ratelimit", "resource_exhausted"):
+		return "rate limited or out of quota: wait and retry, or raise the account's quota"
+	case has("http 5", "code=505"):
+		return "the provider returned a server error: retry later"
+	}
+	return "unexpected error: retry, and check the provider's status page"
+}
+
+// mask hides a credential, each key of a comma-separated list on its own.
+func mask(s string) string {
+	keys := config.SplitKeys(s)
+	for i, k := range keys {
+		if len(k) <= 8 {
+			keys[i] = strings.Repeat("*", len(k))
+		} else {
+			keys[i] = k[:4] + "..." + k[len(k)-4:]
+		}
+	}
+	return strings.Join(keys, ", ")
+}
+
+func since(t time.Time) string {
+	return time.Since(t).Round(time.Millisecond).String()
+}
+
+func signed(d time.Duration) string {
+	if d >= 0 {
+		return "+" + d.String()
+	}
+	return d.String()
+}
+
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > n {
+		return s[:n] + "..."
+	}
+	return s
+}
@@ -0,0 +1,106 @@
+// Package dryrun prints the outbound request a generate command would send,
+// for --dry-run.
+package dryrun
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/llm-net/llm-api-plugin/internal/redact"
+)
+
+// MissingKey stands in for credentials that are not configured, so that a
+// dry run works without them. Print redacts credentials either way.
+const MissingKey = "<missing>"
+
+// Request is an outbound API request captured instead of being sent.
+type Request struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+}
+
+// Print writes req as indented JSON followed by an equivalent curl command.
+// Credentials are replaced with placeholders and large base64 blobs (raw or
+// inside data URIs) with their decoded size.
+func Print(w io.Writer, req Request) error {
+	r, err := sanitize(req)
+	if err != nil {
+		return err
+	}
+	out, err := marshal(r, "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s\n", out)
+
+	var curl strings.Builder
+	fmt.Fprintf(&curl, "curl -X %s %s", r.Method, shellQuote(r.URL))
+	names := make([]string, 0, len(r.Headers))
+	for name := range r.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&curl, " \\\n  -H %s", shellQuote(name+": "+r.Headers[name]))
+	}
+	if r.Body != nil {
+		body, err := marshal(r.Body, "")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&curl, " \\\n  -d %s", shellQuote(strings.TrimSpace(body)))
+	}
+	fmt.Fprintln(w, curl.String())
+	return nil
+}
+
+// marshal encodes v without HTML escaping, so placeholders stay readable.
+func marshal(v interface{}, indent string) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// sanitize returns a copy of req that is safe to print.
+func sanitize(req Request) (Request, error) {
+	r := Request{Method: req.Method, URL: redact.URL(req.URL)}
+	if len(req.Headers) > 0 {
+		r.Headers = make(map[string]string, len(req.Headers))
+		for name, value := range req.Headers {
+			r.Headers[name] = redact.Header(name, value)
+		}
+	}
+	if req.Body == nil {
+		return r, nil
+	}
+	// Round-trip through JSON so typed request structs and maps are walked
+	// the same way and numbers keep their exact form.
+	raw, err := json.Marshal(req.Body)
+	if err != nil {
+		return r, fmt.Errorf("marshal request: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var body interface{}
+	if err := dec.Decode(&body); err != nil {
+		return r, err
+	}
+	r.Body = redact.Value(body)
+	return r, nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
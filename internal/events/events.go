@@ -0,0 +1,191 @@
+// Package events writes machine-readable progress events, one JSON object
+// per line (NDJSON), for agents driving the CLIs with --events.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	UploadStarted     = "upload.started"
+	UploadProgress    = "upload.progress"
+	UploadDone        = "upload.done"
+	TaskSubmitted     = "task.submitted"
+	TaskStatus        = "task.status"
+	DownloadProgress  = "download.progress"
+	ArtifactSaved     = "artifact.saved"
+	ArtifactRemote    = "artifact.remote"
+	ArtifactPublished = "artifact.published"
+	Error             = "error"
+)
+
+// progressInterval is the minimum time between two upload.progress or
+// download.progress events.
+const progressInterval = 500 * time.Millisecond
+
+// Event is one line of the stream. Tool, model and task ID are filled in by
+// the Emitter.
+type Event struct {
+	Type   string    `json:"type"`
+	Time   time.Time `json:"time"`
+	Tool   string    `json:"tool,omitempty"`
+	Model  string    `json:"model,omitempty"`
+	TaskID string    `json:"task_id,omitempty"`
+
+	// Input is the flag an upload belongs to (image, audio, ...) and Source
+	// the path or URL it was given as.
+	Input  string `json:"input,omitempty"`
+	Source string `json:"source,omitempty"`
+	// FileID is the provider file ID of an upload.
+	FileID string `json:"file_id,omitempty"`
+	// Status is the provider status of a task.status event and Poll the
+	// number of polls so far.
+	Status string `json:"status,omitempty"`
+	Poll   int    `json:"poll,omitempty"`
+	// Bytes transferred so far and Total size, when known.
+	Bytes int64 `json:"bytes,omitempty"`
+	Total int64 `json:"total,omitempty"`
+	// Path is a local output file ("-" for stdout) and URL where an input
+	// was staged, an output published or, with --no-download, left by the
+	// provider.
+	Path string `json:"path,omitempty"`
+	URL  string `json:"url,omitempty"`
+	// ExpiresAt is when the URL of an artifact.remote event stops working,
+	// when its signature tells.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Message describes an error.
+	Message string `json:"message,omitempty"`
+}
+
+// Emitter writes events to one destination. A nil *Emitter is valid and
+// writes nothing, so callers can emit unconditionally.
+type Emitter struct {
+	Tool   string
+	Model  string
+	TaskID string
+
+	mu           sync.Mutex
+	w            io.Writer
+	polls        int
+	lastProgress time.Time
+}
+
+// Open returns an Emitter for the value of --events: "ndjson" writes to
+// stderr, "ndjson:<path>" appends to a file and "ndjson:fd:<n>" writes to an
+// inherited file descriptor. An empty spec returns nil.
+func Open(spec, tool, model string) (*Emitter, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	format, dest, _ := strings.Cut(spec, ":")
+	if format != "ndjson" {
+		return nil, fmt.Errorf("unsupported --events format %q (supported: ndjson)", format)
+	}
+	switch {
+	case dest == "":
+		return &Emitter{Tool: tool, Model: model, w: os.Stderr}, nil
+	case strings.HasPrefix(dest, "fd:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(dest, "fd:"))
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid --events file descriptor %q", dest)
+		}
+		return &Emitter{Tool: tool, Model: model, w: os.NewFile(uintptr(fd), dest)}, nil
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open events file: %w", err)
+	}
+	return &Emitter{Tool: tool, Model: model, w: f}, nil
+}
+
+// Emit stamps ev with the time, tool, model and task ID, and writes it.
+// Write errors are ignored: events never change the outcome of a command.
+func (e *Emitter) Emit(ev Event) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.emit(ev)
+}
+
+func (e *Emitter) emit(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	if ev.Tool == "" {
+		ev.Tool = e.Tool
+	}
+	if ev.Model == "" {
+		ev.Model = e.Model
+	}
+	if ev.TaskID == "" {
+		ev.TaskID = e.TaskID
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	e.w.Write(append(data, '\n'))
+}
+
+// Submitted records the task ID, which is then attached to every event, and
+// emits task.submitted.
+func (e *Emitter) Submitted(taskID string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.TaskID = taskID
+	e.emit(Event{Type: TaskSubmitted})
+}
+
+// Status emits task.status for an unfinished poll.
+func (e *Emitter) Status(status string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.polls++
+	e.emit(Event{Type: TaskStatus, Status: status, Poll: e.polls})
+}
+
+// Progress emits download.progress, at most every progressInterval and once
+// the download is complete. total is -1 when unknown.
+func (e *Emitter) Progress(written, total int64) {
+	e.progress(Event{Type: DownloadProgress}, written, total)
+}
+
+// UploadProgress emits upload.progress for the upload of input (image,
+// audio, ...) from source, throttled like Progress.
+func (e *Emitter) UploadProgress(input, source string, sent, total int64) {
+	e.progress(Event{Type: UploadProgress, Input: input, Source: source}, sent, total)
+}
+
+func (e *Emitter) progress(ev Event, n, total int64) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	complete := total >= 0 && n >= total
+	if !complete && time.Since(e.lastProgress) < progressInterval {
+		return
+	}
+	e.lastProgress = time.Now()
+	ev.Bytes = n
+	if total >= 0 {
+		ev.Total = total
+	}
+	e.emit(ev)
+}
@@ -2,26 +2,123 @@ package httpclient
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"time"
 )
 
 var DefaultTimeout = 120 * time.Second
 
-func PostJSON(url string, headers map[string]string, body []byte) ([]byte, int, error) {
+// shared is the keep-alive transport behind every Client, so polling loops
+// and the requests of concurrent jobs reuse connections.
+var shared = func() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ForceAttemptHTTP2 = true
+	t.MaxIdleConns = 100
+	t.MaxIdleConnsPerHost = 16
+	t.IdleConnTimeout = 90 * time.Second
+	return t
+}()
+
+// transport carries every request made through a Client; --record and
+// --replay swap it for a recording or replaying one.
+var transport http.RoundTripper = shared
+
+// SetTransport replaces the transport under every Client, including those
+// created before the call.
+func SetTransport(rt http.RoundTripper) {
+	transport = rt
+}
+
+// Transport returns the shared transport, for wrapping with SetTransport.
+func Transport() http.RoundTripper {
+	return transport
+}
+
+// Client sends requests through its middleware to the shared transport.
+// The chain is assembled per request, so a Client created at package
+// initialisation still picks up the transport installed later by
+// SetTransport.
+type Client struct {
+	// Name identifies the client in logs and Stats.
+	Name string
+	// Timeout bounds PostJSON and GetJSON calls. Do, uploads and downloads
+	// are bounded only by their context.
+	Timeout    time.Duration
+	middleware []Middleware
+}
+
+// Default is the client of the package-level functions, for requests that
+// belong to no provider such as webhooks and metrics export.
+var Default = NewClient("default")
+
+// NewClient returns a client applying middleware to every request, the
+// first one outermost.
+func NewClient(name string, middleware ...Middleware) *Client {
+	return &Client{Name: name, Timeout: DefaultTimeout, middleware: middleware}
+}
+
+// With returns a copy of c that also applies middleware, inside c's own.
+// Stateful middleware such as RateLimit stays shared with c.
+func (c *Client) With(middleware ...Middleware) *Client {
+	out := *c
+	out.middleware = append(append([]Middleware(nil), c.middleware...), middleware...)
+	return &out
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *Client) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt := Metrics(c.Name)(Log(c.Name)(transport))
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		rt = c.middleware[i](rt)
+	}
+	return rt.RoundTrip(req)
+}
+
+// HTTP returns an *http.Client sending through c without an overall
+// timeout, for SDKs that take their own client.
+func (c *Client) HTTP() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+// Do sends req with ctx.
+func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return c.HTTP().Do(req.WithContext(ctx))
+}
+
+// PostJSON posts body as JSON and returns the response body and status.
+func (c *Client) PostJSON(ctx context.Context, url string, headers map[string]string, body []byte) ([]byte, int, error) {
 	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
 	if err != nil {
 		return nil, 0, fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	return c.send(ctx, req, headers)
+}
+
+// GetJSON fetches url and returns the response body and status.
+func (c *Client) GetJSON(ctx context.Context, url string, headers map[string]string) ([]byte, int, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("create request: %w", err)
+	}
+	return c.send(ctx, req, headers)
+}
+
+func (c *Client) send(ctx context.Context, req *http.Request, headers map[string]string) ([]byte, int, error) {
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
 
-	client := &http.Client{Timeout: DefaultTimeout}
-	resp, err := client.Do(req)
+	resp, err := c.Do(ctx, req)
 	if err != nil {
 		return nil, 0, fmt.Errorf("http request: %w", err)
 	}
@@ -35,26 +132,47 @@ func PostJSON(url string, headers map[string]string, body []byte) ([]byte, int,
 	return respBody, resp.StatusCode, nil
 }
 
-func GetJSON(url string, headers map[string]string) ([]byte, int, error) {
+// DownloadTo streams the body at url into w and returns the number of bytes
+// written and the Content-Type of the response. progress, if set, receives
+// the bytes written so far and the total size (-1 when unknown) as the body
+// is copied.
+func (c *Client) DownloadTo(ctx context.Context, url string, w io.Writer, progress func(written, total int64)) (int64, string, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, 0, fmt.Errorf("create request: %w", err)
+		return 0, "", fmt.Errorf("download: %w", err)
 	}
-	for k, v := range headers {
-		req.Header.Set(k, v)
-	}
-
-	client := &http.Client{Timeout: DefaultTimeout}
-	resp, err := client.Do(req)
+	resp, err := c.Do(ctx, req)
 	if err != nil {
-		return nil, 0, fmt.Errorf("http request: %w", err)
+		return 0, "", fmt.Errorf("download: %w", err)
 	}
 	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
+	}
+
+	if progress != nil {
+		w = &progressWriter{w: w, total: resp.ContentLength, progress: progress}
+	}
+	n, err := io.Copy(w, resp.Body)
 	if err != nil {
-		return nil, resp.StatusCode, fmt.Errorf("read response: %w", err)
+		return 0, "", fmt.Errorf("write file: %w", err)
+	}
+	// Without a Content-Length the final size is only known now
+	if progress != nil && resp.ContentLength < 0 {
+		progress(n, n)
 	}
 
-	return respBody, resp.StatusCode, nil
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return n, contentType, nil
+}
+
+// PostJSON posts body through Default.
+func PostJSON(url string, headers map[string]string, body []byte) ([]byte, int, error) {
+	return Default.PostJSON(context.Background(), url, headers, body)
+}
+
+// GetJSON fetches url through Default.
+func GetJSON(url string, headers map[string]string) ([]byte, int, error) {
+	return Default.GetJSON(context.Background(), url, headers)
 }
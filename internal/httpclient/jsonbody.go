@@ -0,0 +1,206 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/llm-net/llm-api-plugin/internal/redact"
+)
+
+// Base64 is media content sent as a base64 JSON string. Inside a JSONBody
+// it is encoded while the body is read, so the content is never held in
+// memory; marshalled on its own (dry runs, logs) it becomes a summary of
+// its size.
+type Base64 struct {
+	size int64
+	open func() (io.ReadCloser, error)
+}
+
+// NewBase64 returns content of size bytes that open reads from the start,
+// once for each pass over the body.
+func NewBase64(size int64, open func() (io.ReadCloser, error)) *Base64 {
+	return &Base64{size: size, open: open}
+}
+
+// Size returns the size of the content before encoding.
+func (b *Base64) Size() int64 {
+	return b.size
+}
+
+// MarshalJSON implements json.Marshaler.
+func (b *Base64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redact.Blob(int(b.size)))
+}
+
+// reader returns the content base64-encoded, encoding in a goroutine as the
+// reader is drained.
+func (b *Base64) reader() io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		src, err := b.open()
+		if err == nil {
+			enc := base64.NewEncoder(base64.StdEncoding, pw)
+			var n int64
+			n, err = io.Copy(enc, src)
+			src.Close()
+			if err == nil && n != b.size {
+				err = fmt.Errorf("read %d bytes of content, expected %d", n, b.size)
+			}
+			if err == nil {
+				err = enc.Close()
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// JSONBody is a JSON request body whose Base64 values are streamed. Its
+// length is known up front, so it can be sent with a Content-Length, and
+// it can be read any number of times.
+type JSONBody struct {
+	parts []bodyPart
+	size  int64
+}
+
+// bodyPart is either marshalled JSON or a Base64 value.
+type bodyPart struct {
+	json []byte
+	blob *Base64
+}
+
+// NewJSONBody marshals v like json.Marshal. *Base64 values must be held in
+// map[string]interface{} or []interface{} values to be streamed; anywhere
+// else they marshal to their summary.
+func NewJSONBody(v interface{}) (*JSONBody, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	prefix := "base64-stream-" + hex.EncodeToString(nonce) + "-"
+	var blobs []*Base64
+	data, err := json.Marshal(substitute(v, func(b *Base64) string {
+		blobs = append(blobs, b)
+		return fmt.Sprintf("%s%d", prefix, len(blobs)-1)
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	body := &JSONBody{}
+	for i, blob := range blobs {
+		// The quotes around the token stay: the blob only replaces its content
+		token := []byte(fmt.Sprintf("%s%d", prefix, i))
+		at := bytes.Index(data, token)
+		if at < 0 {
+			return nil, fmt.Errorf("marshal body: base64 value %d not found", i)
+		}
+		body.add(bodyPart{json: data[:at]})
+		body.add(bodyPart{blob: blob})
+		data = data[at+len(token):]
+	}
+	body.add(bodyPart{json: data})
+	return body, nil
+}
+
+func (b *JSONBody) add(p bodyPart) {
+	if p.blob != nil {
+		b.size += int64(base64.StdEncoding.EncodedLen(int(p.blob.size)))
+	} else if len(p.json) == 0 {
+		return
+	} else {
+		b.size += int64(len(p.json))
+	}
+	b.parts = append(b.parts, p)
+}
+
+// substitute returns a copy of v with the *Base64 values of its maps and
+// slices replaced by the tokens returned by token.
+func substitute(v interface{}, token func(*Base64) string) interface{} {
+	switch v := v.(type) {
+	case *Base64:
+		return token(v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = substitute(e, token)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = substitute(e, token)
+		}
+		return out
+	}
+	return v
+}
+
+// Len returns the length of the body in bytes.
+func (b *JSONBody) Len() int64 {
+	return b.size
+}
+
+// Open returns a new reader of the whole body.
+func (b *JSONBody) Open() io.ReadCloser {
+	return &bodyReader{parts: b.parts}
+}
+
+// SHA256 returns the hex-encoded SHA-256 of the body, reading it once.
+func (b *JSONBody) SHA256() (string, error) {
+	h := sha256.New()
+	r := b.Open()
+	defer r.Close()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// bodyReader reads the parts of a JSONBody in turn.
+type bodyReader struct {
+	parts []bodyPart
+	cur   io.ReadCloser
+}
+
+func (r *bodyReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			part := r.parts[0]
+			r.parts = r.parts[1:]
+			if part.blob != nil {
+				r.cur = part.blob.reader()
+			} else {
+				r.cur = io.NopCloser(bytes.NewReader(part.json))
+			}
+		}
+		n, err := r.cur.Read(p)
+		if err == io.EOF {
+			r.cur.Close()
+			r.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *bodyReader) Close() error {
+	if r.cur != nil {
+		r.cur.Close()
+		r.cur = nil
+	}
+	r.parts = nil
+	return nil
+}
@@ -0,0 +1,225 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+)
+
+// Middleware wraps a transport with behaviour of its own.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Headers sets headers, such as credentials, on every request that does not
+// already carry them.
+func Headers(headers map[string]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			// A RoundTripper must not modify the caller's request
+			req = req.Clone(req.Context())
+			for k, v := range headers {
+				if req.Header.Get(k) == "" {
+					req.Header.Set(k, v)
+				}
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// maxRetryWait caps the wait before a retry, including a server's
+// Retry-After.
+const maxRetryWait = 30 * time.Second
+
+// Retry sends a request up to attempts times, waiting backoff and doubling
+// it between attempts, or as long as the server's Retry-After asks.
+// Idempotent requests are retried on network errors and 429, 502, 503 and
+// 504 responses; other requests only on 429, which the server rejected
+// before acting on them. Requests whose body cannot be re-read, such as
+// streamed uploads, are never retried.
+func Retry(attempts int, backoff time.Duration) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			for attempt := 1; ; attempt++ {
+				resp, err := next.RoundTrip(req)
+				if attempt >= attempts || !retryable(req, resp, err) {
+					return resp, err
+				}
+				wait := backoff << (attempt - 1)
+				if resp != nil {
+					if d, ok := retryAfter(resp); ok {
+						wait = d
+					}
+					io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+					resp.Body.Close()
+				}
+				wait = min(wait, maxRetryWait)
+
+				if req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, err
+					}
+					req = req.Clone(req.Context())
+					req.Body = body
+				}
+				logging.Debug("http retry", "method", req.Method, "url", req.URL.String(), "wait", wait, "attempt", attempt+1, "of", attempts)
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-time.After(wait):
+				}
+			}
+		})
+	}
+}
+
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if req.Context().Err() != nil {
+		return false
+	}
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead ||
+		req.Method == http.MethodPut || req.Method == http.MethodDelete || req.Method == http.MethodOptions
+	if err != nil {
+		return idempotent
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header in seconds or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// RateLimit spaces the requests sent through it at least interval apart.
+// The limit is shared by every Client the middleware is installed in, and
+// by concurrent jobs of 'llm-api serve'.
+func RateLimit(interval time.Duration) Middleware {
+	var mu sync.Mutex
+	var next time.Time
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			at := next
+			if now := time.Now(); at.Before(now) {
+				at = now
+			}
+			next = at.Add(interval)
+			mu.Unlock()
+
+			if wait := time.Until(at); wait > 0 {
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-time.After(wait):
+				}
+			}
+			return rt.RoundTrip(req)
+		})
+	}
+}
+
+// Log writes one debug record per request (shown with --verbose) with the
+// method, the URL with its signatures redacted, the outcome and the
+// duration. Every Client logs its requests.
+func Log(name string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			attrs := []any{"client", name, "method", req.Method, "url", req.URL.String()}
+			if err != nil {
+				attrs = append(attrs, "error", err)
+			} else {
+				attrs = append(attrs, "status", resp.StatusCode)
+			}
+			attrs = append(attrs, "duration", time.Since(start).Round(time.Millisecond))
+			logging.Debug("http request", attrs...)
+			return resp, err
+		})
+	}
+}
+
+// Stat counts the requests a Client sent.
+type Stat struct {
+	Client   string        `json:"client"`
+	Requests int           `json:"requests"`
+	Failed   int           `json:"failed,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+var stats = struct {
+	sync.Mutex
+	m map[string]*Stat
+}{m: map[string]*Stat{}}
+
+// Metrics counts requests, failures (network errors and 5xx responses) and
+// time to response headers in Stats under name. Every Client records its
+// requests; retries count as separate requests.
+func Metrics(name string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			d := time.Since(start)
+
+			stats.Lock()
+			s := stats.m[name]
+			if s == nil {
+				s = &Stat{Client: name}
+				stats.m[name] = s
+			}
+			s.Requests++
+			if err != nil || resp.StatusCode >= 500 {
+				s.Failed++
+			}
+			s.Duration += d
+			stats.Unlock()
+			return resp, err
+		})
+	}
+}
+
+// Stats returns the requests counted so far in this process, by client
+// name.
+func Stats() []Stat {
+	stats.Lock()
+	defer stats.Unlock()
+	out := make([]Stat, 0, len(stats.m))
+	for _, s := range stats.m {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Client < out[j].Client })
+	return out
+}
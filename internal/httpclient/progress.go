@@ -0,0 +1,79 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ProgressReader returns a reader reporting the running byte count of r and
+// the total size (-1 when unknown) to progress as it is read.
+func ProgressReader(r io.Reader, total int64, progress func(n, total int64)) io.Reader {
+	return &progressReader{r: r, total: total, progress: progress}
+}
+
+type progressReader struct {
+	r        io.Reader
+	n        int64
+	total    int64
+	progress func(n, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.n += int64(n)
+		p.progress(p.n, p.total)
+	}
+	return n, err
+}
+
+// progressWriter reports the running byte count of the writes it forwards.
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	total    int64
+	progress func(written, total int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.progress(p.written, p.total)
+	return n, err
+}
+
+// Throttle returns a progress func passing at most one update per interval
+// on to fn, plus the final one of a transfer of known size.
+func Throttle(interval time.Duration, fn func(n, total int64)) func(n, total int64) {
+	var mu sync.Mutex
+	last := time.Now()
+	return func(n, total int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		if (total < 0 || n < total) && time.Since(last) < interval {
+			return
+		}
+		last = time.Now()
+		fn(n, total)
+	}
+}
+
+// FormatProgress describes a transfer, e.g. "12.0 MB of 40.0 MB (30%)".
+func FormatProgress(n, total int64) string {
+	if total <= 0 {
+		return byteSize(n)
+	}
+	return fmt.Sprintf("%s of %s (%d%%)", byteSize(n), byteSize(total), n*100/total)
+}
+
+func byteSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,435 @@
+// Package input resolves the value of a media flag (--image, --video,
+// --audio, ...). A value may be a local path, an http(s) URL, a data URI,
+// "-" for stdin or "asset:<name>" from the asset library; callers then
+// convert it to whatever form their provider accepts: the URL itself, inline
+// base64, raw bytes for an upload, or a URL staged through object storage.
+package input
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/assets"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/storage"
+)
+
+// Kind is the form a media flag value was given in.
+type Kind int
+
+// Input kinds.
+const (
+	Path Kind = iota
+	URL
+	DataURI
+	Stdin
+)
+
+// MaxSize bounds the inputs read into memory from stdin or a URL. Provider
+// upload limits are far lower; a larger input is refused rather than read.
+const MaxSize = 512 << 20
+
+func (k Kind) String() string {
+	switch k {
+	case URL:
+		return "url"
+	case DataURI:
+		return "data URI"
+	case Stdin:
+		return "stdin"
+	default:
+		return "path"
+	}
+}
+
+// Input is a resolved media flag value. Content is loaded on demand: data
+// URIs and stdin when resolved, paths and URLs on first use. A nil *Input
+// stands for an unset flag and converts to empty values.
+type Input struct {
+	Kind  Kind
+	Value string
+	// Asset is set for an asset reference; Value is then its stored copy.
+	Asset *assets.Asset
+
+	data     []byte
+	loaded   bool
+	declared string // MIME type declared by a data URI or HTTP response
+	mimeType string
+}
+
+// ErrStdinUsed is returned when a second flag asks for stdin.
+var ErrStdinUsed = errors.New("only one input can be read from stdin (-)")
+
+var stdinUsed bool
+
+// Resolve classifies value. An empty value yields a nil *Input. Local paths
+// must exist; stdin is read in full and can back only one flag per process.
+func Resolve(value string) (*Input, error) {
+	in := &Input{Value: value}
+	lower := strings.ToLower(value)
+	switch {
+	case value == "":
+		return nil, nil
+	case assets.IsRef(value):
+		lib := assets.Open()
+		a, err := lib.Resolve(value)
+		if err != nil {
+			return nil, err
+		}
+		in.Kind, in.Value, in.Asset, in.declared = Path, lib.Path(a), a, a.MIMEType
+		if _, err := os.Stat(in.Value); err != nil {
+			return nil, fmt.Errorf("asset %q: stored copy missing, add it again: %w", a.Name, err)
+		}
+	case value == "-":
+		if stdinUsed {
+			return nil, ErrStdinUsed
+		}
+		stdinUsed = true
+		data, err := readLimited(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("read stdin: %w", err)
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("stdin is empty")
+		}
+		in.Kind, in.data, in.loaded = Stdin, data, true
+	case strings.HasPrefix(lower, "data:"):
+		mediaType, data, err := parseDataURI(value)
+		if err != nil {
+			return nil, err
+		}
+		in.Kind, in.data, in.loaded, in.declared = DataURI, data, true, mediaType
+	case strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://"):
+		if _, err := url.Parse(value); err != nil {
+			return nil, fmt.Errorf("invalid URL %q: %w", value, err)
+		}
+		in.Kind = URL
+	default:
+		info, err := os.Stat(value)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("%s is a directory", value)
+		}
+		in.Kind = Path
+	}
+	return in, nil
+}
+
+// parseDataURI decodes data:[<mediatype>][;base64],<data>.
+func parseDataURI(value string) (string, []byte, error) {
+	header, payload, ok := strings.Cut(value[len("data:"):], ",")
+	if !ok {
+		return "", nil, fmt.Errorf("invalid data URI: missing ','")
+	}
+	isBase64 := strings.HasSuffix(strings.ToLower(header), ";base64")
+	if isBase64 {
+		header = header[:len(header)-len(";base64")]
+	}
+	mediaType, _, _ := mime.ParseMediaType(header)
+
+	var data []byte
+	var err error
+	if isBase64 {
+		data, err = base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "="))
+		}
+	} else {
+		var s string
+		s, err = url.PathUnescape(payload)
+		data = []byte(s)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid data URI: %w", err)
+	}
+	if len(data) == 0 {
+		return "", nil, fmt.Errorf("invalid data URI: no data")
+	}
+	return mediaType, data, nil
+}
+
+// IsURL reports whether the value is an http(s) URL that providers can fetch
+// themselves.
+func (in *Input) IsURL() bool {
+	return in != nil && in.Kind == URL
+}
+
+// String describes the input for progress messages.
+func (in *Input) String() string {
+	if in.Asset != nil {
+		return assets.Prefix + in.Asset.Name
+	}
+	switch in.Kind {
+	case Path, URL:
+		return in.Value
+	default:
+		return in.Kind.String()
+	}
+}
+
+// Bytes returns the content, reading the file or downloading the URL on
+// first use.
+func (in *Input) Bytes() ([]byte, error) {
+	if in.loaded {
+		return in.data, nil
+	}
+	var err error
+	switch in.Kind {
+	case Path:
+		in.data, err = os.ReadFile(in.Value)
+		if err != nil {
+			return nil, err
+		}
+	case URL:
+		in.data, in.declared, err = fetch(in.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	in.loaded = true
+	return in.data, nil
+}
+
+// fetcher downloads URL inputs; the servers are third parties, so failed
+// fetches are retried.
+var fetcher = httpclient.NewClient("input", httpclient.Retry(3, time.Second))
+
+func fetch(rawURL string) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httpclient.DefaultTimeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("download %s: %w", rawURL, err)
+	}
+	resp, err := fetcher.Do(ctx, req)
+	if err != nil {
+		return nil, "", fmt.Errorf("download %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("download %s: HTTP %d", rawURL, resp.StatusCode)
+	}
+	if resp.ContentLength > MaxSize {
+		return nil, "", fmt.Errorf("download %s: %s exceeds the %d MB input limit", rawURL,
+			httpclient.FormatProgress(resp.ContentLength, 0), MaxSize>>20)
+	}
+	data, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("download %s: %w", rawURL, err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return data, mediaType, nil
+}
+
+// readLimited reads r to the end, failing once it exceeds MaxSize.
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxSize {
+		return nil, fmt.Errorf("input exceeds the %d MB limit", MaxSize>>20)
+	}
+	return data, nil
+}
+
+// MIMEType sniffs the media type from the content. The type declared by a
+// data URI or HTTP response, then the file extension, are only used when
+// the content is not recognised.
+func (in *Input) MIMEType() (string, error) {
+	if in.mimeType != "" {
+		return in.mimeType, nil
+	}
+	var head []byte
+	if in.Kind == Path && !in.loaded {
+		f, err := os.Open(in.Value)
+		if err != nil {
+			return "", err
+		}
+		head = make([]byte, sniffLen)
+		n, _ := io.ReadFull(f, head)
+		f.Close()
+		head = head[:n]
+	} else {
+		data, err := in.Bytes()
+		if err != nil {
+			return "", err
+		}
+		head = data
+	}
+
+	t := Sniff(head)
+	if t == unknownType || strings.HasPrefix(t, "text/plain") {
+		if in.declared != "" && in.declared != unknownType {
+			t = in.declared
+		} else if byExt := mime.TypeByExtension(strings.ToLower(in.ext())); byExt != "" {
+			t, _, _ = mime.ParseMediaType(byExt)
+		}
+	}
+	in.mimeType = t
+	return t, nil
+}
+
+// ext returns the extension of the path or URL path, if any.
+func (in *Input) ext() string {
+	switch in.Kind {
+	case Path:
+		return filepath.Ext(in.Value)
+	case URL:
+		if u, err := url.Parse(in.Value); err == nil {
+			return path.Ext(u.Path)
+		}
+	}
+	return ""
+}
+
+// Name returns a file name for the content: the base name of the path or
+// URL, or a name with an extension derived from the sniffed type.
+func (in *Input) Name() string {
+	if in.Asset != nil {
+		return in.Asset.Name + filepath.Ext(in.Value)
+	}
+	switch in.Kind {
+	case Path:
+		return filepath.Base(in.Value)
+	case URL:
+		if u, err := url.Parse(in.Value); err == nil && path.Ext(u.Path) != "" {
+			return path.Base(u.Path)
+		}
+	}
+	t, _ := in.MIMEType()
+	return "input" + Extension(t)
+}
+
+// Load reads the content of a media flag value, its sniffed media type and
+// a matching file extension; it is the assets.Loader of 'assets add'.
+func Load(value string) ([]byte, string, string, error) {
+	in, err := Resolve(value)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if in == nil {
+		return nil, "", "", fmt.Errorf("no input given")
+	}
+	data, err := in.Bytes()
+	if err != nil {
+		return nil, "", "", err
+	}
+	mimeType, err := in.MIMEType()
+	if err != nil {
+		return nil, "", "", err
+	}
+	return data, mimeType, Extension(mimeType), nil
+}
+
+// Open returns a reader of the content and its size. Local files are
+// streamed from disk; other kinds are loaded first.
+func (in *Input) Open() (io.ReadCloser, int64, error) {
+	if in.Kind == Path && !in.loaded {
+		f, err := os.Open(in.Value)
+		if err != nil {
+			return nil, 0, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, info.Size(), nil
+	}
+	data, err := in.Bytes()
+	if err != nil {
+		return nil, 0, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+// Base64 returns the content for inline base64 in a JSON body. Local files
+// are encoded from disk as the body is sent.
+func (in *Input) Base64() (*httpclient.Base64, error) {
+	r, size, err := in.Open()
+	if err != nil {
+		return nil, err
+	}
+	r.Close()
+	return httpclient.NewBase64(size, func() (io.ReadCloser, error) {
+		r, _, err := in.Open()
+		return r, err
+	}), nil
+}
+
+// URLOrBase64 suits providers that take either an image URL or inline
+// base64: URLs are passed through, anything else is returned as base64.
+func (in *Input) URLOrBase64() (u string, b64 *httpclient.Base64, err error) {
+	if in == nil {
+		return "", nil, nil
+	}
+	if in.IsURL() {
+		return in.Value, nil, nil
+	}
+	b64, err = in.Base64()
+	return "", b64, err
+}
+
+// StageURL suits providers that only accept URLs: URLs are passed through,
+// anything else is uploaded through s and its presigned URL returned.
+func (in *Input) StageURL(s *storage.Stager) (string, error) {
+	switch {
+	case in == nil:
+		return "", nil
+	case in.IsURL():
+		return in.Value, nil
+	case in.Asset != nil:
+		return in.stageAsset(s)
+	case in.Kind == Path:
+		return s.Stage(in.Value)
+	}
+	data, err := in.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return s.StageData(in.Name(), data)
+}
+
+// stageAsset stages an asset once per bucket: the object is kept, and later
+// runs reuse its presigned URL while it is fresh or presign it again.
+func (in *Input) stageAsset(s *storage.Stager) (string, error) {
+	bucket, err := s.Bucket()
+	if err != nil {
+		return "", fmt.Errorf("cannot stage %s: %w", in, err)
+	}
+	lib := assets.Open()
+	h := lib.Lookup(in.Asset.Name, assets.ProviderStorage, bucket)
+	if h.Fresh() {
+		return h.URL, nil
+	}
+	key := ""
+	if h != nil {
+		key = h.ID
+	} else if key, err = s.Keep(in.Value, "assets", in.Asset.SHA256[:16], in.Name()); err != nil {
+		return "", err
+	}
+	u, expires := s.Presign(key)
+	err = lib.SetHandle(in.Asset.Name, in.Asset.SHA256, assets.Handle{
+		Provider:  assets.ProviderStorage,
+		Account:   bucket,
+		ID:        key,
+		URL:       u,
+		ExpiresAt: &expires,
+	})
+	return u, err
+}
@@ -0,0 +1,77 @@
+package input
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+)
+
+const (
+	sniffLen    = 512
+	unknownType = "application/octet-stream"
+)
+
+// Sniff returns the media type of content from its leading bytes. It extends
+// http.DetectContentType with the audio and video containers that providers
+// care about but the standard sniffer does not tell apart.
+func Sniff(head []byte) string {
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	switch {
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		switch string(head[8:12]) {
+		case "M4A ", "M4B ":
+			return "audio/mp4"
+		case "qt  ":
+			return "video/quicktime"
+		}
+		return "video/mp4"
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return "audio/flac"
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0 && head[1] != 0xFE:
+		// MPEG audio frame sync; layer bits 00 mark an ADTS AAC stream.
+		if head[1]&0x06 == 0 {
+			return "audio/aac"
+		}
+		return "audio/mpeg"
+	}
+	t, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if t == "" {
+		return unknownType
+	}
+	return t
+}
+
+// extensions lists the preferred file extension of common media types.
+var extensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"image/gif":       ".gif",
+	"image/bmp":       ".bmp",
+	"audio/mpeg":      ".mp3",
+	"audio/wave":      ".wav",
+	"audio/wav":       ".wav",
+	"audio/x-wav":     ".wav",
+	"audio/aac":       ".aac",
+	"audio/mp4":       ".m4a",
+	"audio/flac":      ".flac",
+	"application/ogg": ".ogg",
+	"video/mp4":       ".mp4",
+	"video/webm":      ".webm",
+	"video/quicktime": ".mov",
+	"video/avi":       ".avi",
+	"text/plain":      ".txt",
+}
+
+// Extension returns the file extension, with its dot, for a media type.
+func Extension(mediaType string) string {
+	if ext, ok := extensions[mediaType]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
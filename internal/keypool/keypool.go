@@ -0,0 +1,258 @@
+// Package keypool spreads requests over several credentials of one
+// provider, to get past per-key quotas. A credential field may list keys
+// separated by commas (see config.SplitKeys); AK/SK pairs are matched by
+// position. Task submissions go through Pool.Try, which fails over to the
+// next key when a key hits its quota and cools the exhausted key down. The
+// state is kept next to the config file, so selection carries over between
+// CLI runs.
+package keypool
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+	"github.com/llm-net/llm-api-plugin/internal/redact"
+)
+
+// Strategies choosing the next key.
+const (
+	// RoundRobin uses the key that was used longest ago.
+	RoundRobin = "round-robin"
+	// LeastLimited uses the key that hit its quota longest ago, sticking to
+	// one key until it is limited.
+	LeastLimited = "least-limited"
+)
+
+// StrategyEnv overrides the key_strategy of the configuration.
+const StrategyEnv = "LLM_API_KEY_STRATEGY"
+
+// DefaultCooldown is how long a key rests after hitting its quota when the
+// provider does not say.
+var DefaultCooldown = time.Minute
+
+// dailyCooldown is the rest after a daily quota was exhausted.
+const dailyCooldown = time.Hour
+
+// Key is one credential of a pool: an API key, or an AccessKeyID with its
+// SecretAccessKey.
+type Key struct {
+	Value  string
+	Secret string
+
+	service string
+}
+
+// ID identifies the key in the state file without revealing it.
+func (k Key) ID() string {
+	sum := sha256.Sum256([]byte(k.service + "\x00" + k.Value))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Masked shows the key in logs and reports.
+func (k Key) Masked() string {
+	return redact.Mask(k.Value)
+}
+
+// Pool is the keys configured for one service.
+type Pool struct {
+	Service  string
+	Strategy string
+	keys     []Key
+}
+
+// New returns the pool of the keys listed in values, paired by position
+// with those in secrets. secrets may also hold a single value shared by
+// every key, such as a TopView UID, or be empty.
+func New(cfg *config.Config, service, values, secrets string) (*Pool, error) {
+	vs, ss := config.SplitKeys(values), config.SplitKeys(secrets)
+	if len(vs) == 0 {
+		return nil, fmt.Errorf("no %s key configured", service)
+	}
+	if len(ss) > 1 && len(ss) != len(vs) {
+		return nil, fmt.Errorf("%s: %d keys but %d secrets; list them in the same order", service, len(vs), len(ss))
+	}
+	p := &Pool{Service: service, Strategy: RoundRobin}
+	if cfg != nil {
+		if s, _ := cfg.KeyStrategy(); s != "" {
+			p.Strategy = s
+		}
+	}
+	if s := os.Getenv(StrategyEnv); s != "" {
+		p.Strategy = s
+	}
+	if p.Strategy != RoundRobin && p.Strategy != LeastLimited {
+		return nil, fmt.Errorf("unknown key strategy %q (want %s or %s)", p.Strategy, RoundRobin, LeastLimited)
+	}
+	for i, v := range vs {
+		k := Key{Value: v, service: service}
+		switch len(ss) {
+		case 1:
+			k.Secret = ss[0]
+		case len(vs):
+			k.Secret = ss[i]
+		}
+		p.keys = append(p.keys, k)
+	}
+	return p, nil
+}
+
+// Len returns the number of keys.
+func (p *Pool) Len() int {
+	return len(p.keys)
+}
+
+// Get returns the key with the given ID, such as the one that submitted a
+// task being resumed, or the first key in the order of the strategy.
+func (p *Pool) Get(id string) Key {
+	for _, k := range p.keys {
+		if k.ID() == id {
+			return k
+		}
+	}
+	return p.order()[0]
+}
+
+// Try calls fn with the next key. When the provider refuses the call for
+// quota, the key cools down and fn is called again with the next one, until
+// every key was tried. It returns the last key tried, which the caller
+// keeps using for the task it submitted.
+func (p *Pool) Try(fn func(Key) error) (Key, error) {
+	if len(p.keys) == 1 {
+		return p.keys[0], fn(p.keys[0])
+	}
+	keys := p.order()
+	var err error
+	for i, k := range keys {
+		if i == 0 && p.cooling(k) {
+			logging.Warnf("every %s key is cooling down after hitting its quota; trying %s anyway", p.Service, k.Masked())
+		}
+		logging.Infof("Using %s key %s (%d of %d)", p.Service, k.Masked(), p.index(k)+1, len(p.keys))
+		err = fn(k)
+		if !IsQuota(err) {
+			p.update(k, func(s *keyState) { s.LastUsed = time.Now() })
+			return k, err
+		}
+		cooldown := Cooldown(err)
+		p.update(k, func(s *keyState) {
+			now := time.Now()
+			s.LastUsed, s.LimitedAt, s.CoolUntil = now, now, now.Add(cooldown)
+		})
+		if i < len(keys)-1 {
+			logging.Warnf("%s key %s hit its quota, cooling down for %s; switching to the next key", p.Service, k.Masked(), cooldown)
+		}
+	}
+	return keys[len(keys)-1], err
+}
+
+// order sorts the keys by the strategy, keys cooling down last (soonest
+// available first).
+func (p *Pool) order() []Key {
+	states := loadState()
+	now := time.Now()
+	keys := append([]Key(nil), p.keys...)
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := states[keys[i].ID()], states[keys[j].ID()]
+		coolA, coolB := a.CoolUntil.After(now), b.CoolUntil.After(now)
+		if coolA != coolB {
+			return !coolA
+		}
+		if coolA {
+			return a.CoolUntil.Before(b.CoolUntil)
+		}
+		if p.Strategy == LeastLimited {
+			return a.LimitedAt.Before(b.LimitedAt)
+		}
+		return a.LastUsed.Before(b.LastUsed)
+	})
+	return keys
+}
+
+func (p *Pool) cooling(k Key) bool {
+	return loadState()[k.ID()].CoolUntil.After(time.Now())
+}
+
+func (p *Pool) index(k Key) int {
+	for i, key := range p.keys {
+		if key.ID() == k.ID() {
+			return i
+		}
+	}
+	return 0
+}
+
+// Each calls fn with every key of values and secrets, as New pairs them,
+// and joins the errors, naming the key that failed when there are several.
+// 'doctor' uses it to check each key.
+func Each(values, secrets string, fn func(Key) error) error {
+	p, err := New(nil, "", values, secrets)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, k := range p.keys {
+		if err := fn(k); err != nil {
+			if p.Len() > 1 {
+				err = fmt.Errorf("key %s: %w", k.Masked(), err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Mask shows a list of keys for display, masking each one.
+func Mask(values string) string {
+	keys := config.SplitKeys(values)
+	for i, k := range keys {
+		keys[i] = redact.Mask(k)
+	}
+	return strings.Join(keys, ", ")
+}
+
+// quotaMarkers, lower case, identify quota and rate limit errors in the
+// messages of the providers: HTTP 429, Gemini's RESOURCE_EXHAUSTED and the
+// Volcengine codes 50429 (QPS) and 50430 (concurrency).
+var quotaMarkers = []string{"http 429", "code 429", "status 429", "too many requests", "resource_exhausted", "50429", "50430", "quota", "rate limit", "ratelimit"}
+
+// IsQuota reports whether err says the key ran out of quota or was rate
+// limited.
+func IsQuota(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range quotaMarkers {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+var retryDelay = regexp.MustCompile(`"retryDelay":\s*"(\d+(?:\.\d+)?)s"`)
+
+// Cooldown returns how long a key should rest after err: the delay Gemini
+// asks for, an hour for a daily quota, or DefaultCooldown.
+func Cooldown(err error) time.Duration {
+	msg := err.Error()
+	if m := retryDelay.FindStringSubmatch(msg); m != nil {
+		if secs, err := strconv.ParseFloat(m[1], 64); err == nil {
+			return max(time.Duration(secs*float64(time.Second)), time.Second).Round(time.Second)
+		}
+	}
+	lower := strings.ToLower(msg)
+	if strings.Contains(lower, "per day") || strings.Contains(lower, "perday") || strings.Contains(lower, "daily") {
+		return dailyCooldown
+	}
+	return DefaultCooldown
+}
@@ -0,0 +1,217 @@
+package keypool
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIsQuota(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("HTTP 429: Too Many Requests"), true},
+		{errors.New(`API error (code 429): {"error":{"status":"RESOURCE_EXHAUSTED"}}`), true},
+		{errors.New("submit task: code 50429, Request Has Reached API Limit"), true},
+		{errors.New("submit task: code 50430, API Concurrent Limit"), true},
+		{errors.New("You exceeded your current quota"), true},
+		{errors.New("Rate limit exceeded"), true},
+		{fmt.Errorf("submit: %w", errors.New("status 429")), true},
+		{errors.New("HTTP 401: invalid api key"), false},
+		{errors.New("HTTP 500: internal error"), false},
+		{errors.New("code 50400: access denied"), false},
+		{errors.New("context deadline exceeded"), false},
+	}
+	for _, tt := range tests {
+		if got := IsQuota(tt.err); got != tt.want {
+			t.Errorf("IsQuota(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want time.Duration
+	}{
+		{"HTTP 429: Too Many Requests", DefaultCooldown},
+		{`RESOURCE_EXHAUSTED {"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay": "37s"}`, 37 * time.Second},
+		{`"retryDelay":"12.6s"`, 13 * time.Second},
+		{`"retryDelay":"0.2s"`, time.Second},
+		{"Quota exceeded: 50 requests per day", time.Hour},
+		{"GenerateRequestsPerDayPerProjectPerModel", time.Hour},
+		{"daily limit reached", time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Cooldown(errors.New(tt.msg)); got != tt.want {
+			t.Errorf("Cooldown(%q) = %s, want %s", tt.msg, got, tt.want)
+		}
+	}
+}
+
+// testPool returns a pool of keys a, b and c with its state in a temporary
+// home.
+func testPool(t *testing.T, strategy string) *Pool {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(StrategyEnv, strategy)
+	p, err := New(nil, "test", "key-a,key-b,key-c", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func values(keys []Key) string {
+	s := ""
+	for _, k := range keys {
+		s += k.Value[len("key-"):]
+	}
+	return s
+}
+
+func TestOrder(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		strategy string
+		states   map[string]keyState
+		want     string
+	}{
+		{"unused", RoundRobin, nil, "abc"},
+		{
+			"round robin", RoundRobin,
+			map[string]keyState{
+				"key-a": {LastUsed: now.Add(-time.Minute)},
+				"key-b": {LastUsed: now.Add(-time.Hour)},
+			},
+			"cba",
+		},
+		{
+			"least limited", LeastLimited,
+			map[string]keyState{
+				"key-a": {LastUsed: now, LimitedAt: now.Add(-time.Hour)},
+				"key-b": {LastUsed: now.Add(-time.Hour), LimitedAt: now.Add(-time.Minute)},
+				"key-c": {LastUsed: now.Add(-time.Hour), LimitedAt: now.Add(-10 * time.Minute)},
+			},
+			"acb",
+		},
+		{
+			"cooling keys last", RoundRobin,
+			map[string]keyState{
+				"key-a": {LastUsed: now.Add(-time.Hour), CoolUntil: now.Add(time.Minute)},
+				"key-c": {LastUsed: now},
+			},
+			"bca",
+		},
+		{
+			"soonest available first", LeastLimited,
+			map[string]keyState{
+				"key-a": {LimitedAt: now, CoolUntil: now.Add(time.Hour)},
+				"key-b": {LimitedAt: now, CoolUntil: now.Add(time.Minute)},
+				"key-c": {LimitedAt: now, CoolUntil: now.Add(10 * time.Minute)},
+			},
+			"bca",
+		},
+		{
+			"expired cooldown", RoundRobin,
+			map[string]keyState{
+				"key-a": {LastUsed: now.Add(-time.Minute), CoolUntil: now.Add(-time.Second)},
+				"key-b": {LastUsed: now},
+			},
+			"cab",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testPool(t, tt.strategy)
+			for _, k := range p.keys {
+				if s, ok := tt.states[k.Value]; ok {
+					p.update(k, func(state *keyState) { *state = s })
+				}
+			}
+			if got := values(p.order()); got != tt.want {
+				t.Errorf("order = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTryFailsOver(t *testing.T) {
+	p := testPool(t, RoundRobin)
+	quota := errors.New("HTTP 429: Too Many Requests")
+
+	// a is out of quota: b serves the call, and a rests
+	var tried string
+	k, err := p.Try(func(k Key) error {
+		tried += k.Value[len("key-"):]
+		if k.Value == "key-a" {
+			return quota
+		}
+		return nil
+	})
+	if err != nil || k.Value != "key-b" || tried != "ab" {
+		t.Fatalf("Try = %s, %v after trying %s; want key-b after ab", k.Value, err, tried)
+	}
+	if !p.cooling(p.keys[0]) {
+		t.Error("key-a is not cooling down after hitting its quota")
+	}
+	if got := values(p.order()); got != "cba" {
+		t.Errorf("order after the failover = %s, want cba", got)
+	}
+
+	// Every key is out of quota: all are tried and the last error returned
+	tried = ""
+	k, err = p.Try(func(k Key) error {
+		tried += k.Value[len("key-"):]
+		return quota
+	})
+	if !errors.Is(err, quota) || tried != "cba" || k.Value != "key-a" {
+		t.Errorf("Try = %s, %v after trying %s; want the quota error from key-a after cba", k.Value, err, tried)
+	}
+
+	// A non-quota error does not fail over
+	tried = ""
+	_, err = p.Try(func(k Key) error {
+		tried += k.Value[len("key-"):]
+		return errors.New("HTTP 400: bad request")
+	})
+	if err == nil || len(tried) != 1 {
+		t.Errorf("Try tried %s on a non-quota error, want a single key", tried)
+	}
+}
+
+func TestNewPairsSecrets(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tests := []struct {
+		values, secrets string
+		want            []string
+		ok              bool
+	}{
+		{"ak1,ak2", "sk1,sk2", []string{"sk1", "sk2"}, true},
+		{"k1 k2", "uid", []string{"uid", "uid"}, true},
+		{"k1", "", []string{""}, true},
+		{"ak1,ak2,ak3", "sk1,sk2", nil, false},
+		{"", "sk", nil, false},
+	}
+	for _, tt := range tests {
+		p, err := New(nil, "test", tt.values, tt.secrets)
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("New(%q, %q) succeeded", tt.values, tt.secrets)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("New(%q, %q): %v", tt.values, tt.secrets, err)
+		}
+		for i, k := range p.keys {
+			if k.Secret != tt.want[i] {
+				t.Errorf("New(%q, %q): key %d secret = %q, want %q", tt.values, tt.secrets, i, k.Secret, tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package keypool
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+)
+
+// keyState is what the pool remembers about a key across runs.
+type keyState struct {
+	Service   string    `json:"service"`
+	Key       string    `json:"key"`
+	LastUsed  time.Time `json:"last_used,omitzero"`
+	LimitedAt time.Time `json:"limited_at,omitzero"`
+	CoolUntil time.Time `json:"cool_until,omitzero"`
+}
+
+// stateMu serialises the state file within the process, e.g. between the
+// jobs of 'llm-api serve'. Concurrent CLI runs may lose an update, which
+// only makes the next choice less even.
+var stateMu sync.Mutex
+
+// statePath is the state file, next to the config file.
+func statePath() string {
+	return filepath.Join(filepath.Dir(config.Path()), "key-state.json")
+}
+
+// loadState returns the state by key ID; missing or unreadable state is
+// empty.
+func loadState() map[string]keyState {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return readState()
+}
+
+func readState() map[string]keyState {
+	states := map[string]keyState{}
+	data, err := os.ReadFile(statePath())
+	if err != nil {
+		return states
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		logging.Debug("ignoring key state", "error", err)
+	}
+	return states
+}
+
+// update changes the state of k and saves it. Failures are only logged:
+// the state is a hint, not a requirement.
+func (p *Pool) update(k Key, change func(*keyState)) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	states := readState()
+	s := states[k.ID()]
+	s.Service, s.Key = p.Service, k.Masked()
+	change(&s)
+	states[k.ID()] = s
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return
+	}
+	path := statePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		logging.Debug("saving key state", "error", err)
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".key-state-*")
+	if err != nil {
+		logging.Debug("saving key state", "error", err)
+		return
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		logging.Debug("saving key state", "error", err)
+	}
+}
@@ -0,0 +1,153 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/llm-net/llm-api-plugin/internal/redact"
+)
+
+// redactingHandler redacts the message of every record; attributes are
+// redacted by replaceAttr.
+type redactingHandler struct {
+	slog.Handler
+}
+
+func (h redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, redact.Text(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(a)
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return redactingHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h redactingHandler) WithGroup(name string) slog.Handler {
+	return redactingHandler{h.Handler.WithGroup(name)}
+}
+
+// replaceAttr redacts an attribute: credentials by key, URLs and base64 in
+// strings, and both inside structured values such as decoded responses.
+func replaceAttr(_ []string, a slog.Attr) slog.Attr {
+	if redact.SecretKey(a.Key) {
+		return slog.String(a.Key, redact.Mask(a.Value.String()))
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redact.Text(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, redact.Text(err.Error()))
+		}
+		return slog.Any(a.Key, redactAny(a.Value.Any()))
+	}
+	return a
+}
+
+// redactAny returns a redacted copy of v in its JSON form, so the caller's
+// value is not modified. json.RawMessage and []byte holding JSON are
+// decoded first.
+func redactAny(v any) any {
+	var data []byte
+	switch v := v.(type) {
+	case json.RawMessage:
+		data = v
+	case []byte:
+		if !json.Valid(v) {
+			return redact.Text(string(v))
+		}
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return redact.Text(fmt.Sprint(v))
+		}
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return redact.Text(string(data))
+	}
+	return redact.Value(decoded)
+}
+
+// textHandler writes records the way the CLIs have always printed
+// progress: info records as the bare message, warnings and errors with a
+// "Warning:" or "Error:" prefix, debug records with "debug:". Attributes
+// follow as key=value, structured values as compact JSON.
+type textHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Leveler
+	attrs []slog.Attr
+}
+
+func newTextHandler(w io.Writer, level slog.Leveler) slog.Handler {
+	return redactingHandler{&textHandler{mu: &sync.Mutex{}, w: w, level: level}}
+}
+
+func (h *textHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("Error: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("Warning: ")
+	case r.Level < slog.LevelInfo:
+		b.WriteString("debug: ")
+	}
+	b.WriteString(r.Message)
+	write := func(a slog.Attr) {
+		a = replaceAttr(nil, a)
+		b.WriteString(" " + a.Key + "=")
+		switch a.Value.Kind() {
+		case slog.KindAny:
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(a.Value.Any()); err != nil {
+				buf.WriteString(fmt.Sprint(a.Value.Any()))
+			}
+			b.WriteString(strings.TrimSuffix(buf.String(), "\n"))
+		default:
+			b.WriteString(a.Value.String())
+		}
+	}
+	for _, a := range h.attrs {
+		write(a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		write(a)
+		return true
+	})
+	b.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := *h
+	out.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &out
+}
+
+// WithGroup is not used by the CLIs; groups are flattened.
+func (h *textHandler) WithGroup(string) slog.Handler {
+	return h
+}
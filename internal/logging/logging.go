@@ -0,0 +1,155 @@
+// Package logging is the leveled logger shared by the CLIs. Progress is
+// logged at info level, diagnostics such as raw provider responses and
+// HTTP requests at debug level (--verbose), and every record is redacted
+// before it is written: registered credentials, URL signatures and large
+// base64 payloads never reach the terminal or the log file.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Environment variables setting the defaults of the logging flags, for
+// callers that cannot pass flags such as MCP clients.
+const (
+	LevelEnv  = "LLM_API_LOG_LEVEL"
+	FormatEnv = "LLM_API_LOG_FORMAT"
+	FileEnv   = "LLM_API_LOG_FILE"
+)
+
+var (
+	mu     sync.RWMutex
+	logger = slog.New(newTextHandler(os.Stderr, slog.LevelInfo))
+	level  = slog.LevelInfo
+)
+
+// Setup strips --verbose, --quiet, --log-format <text|json> and
+// --log-file <path> from args and configures the logger. The environment
+// variables apply when the flags are absent.
+func Setup(args []string) ([]string, error) {
+	lvl, format, file := os.Getenv(LevelEnv), os.Getenv(FormatEnv), os.Getenv(FileEnv)
+	values := map[string]*string{"--log-format": &format, "--log-file": &file}
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if i > 0 && (args[i] == "--verbose" || args[i] == "--quiet") {
+			lvl = strings.TrimPrefix(args[i], "--")
+			continue
+		}
+		dst, ok := values[name]
+		if !ok || i == 0 {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			i++
+			if i >= len(args) {
+				return nil, fmt.Errorf("%s requires a value", name)
+			}
+			value = args[i]
+		}
+		*dst = value
+	}
+
+	l, err := ParseLevel(lvl)
+	if err != nil {
+		return nil, err
+	}
+	var w io.Writer = os.Stderr
+	if file != "" {
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("open log file: %w", err)
+		}
+		w = f
+	}
+	var h slog.Handler
+	switch format {
+	case "", "text":
+		h = newTextHandler(w, l)
+	case "json":
+		h = redactingHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: l, ReplaceAttr: replaceAttr})}
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want text or json)", format)
+	}
+
+	mu.Lock()
+	logger, level = slog.New(h), l
+	mu.Unlock()
+	return rest, nil
+}
+
+// ParseLevel parses debug (or verbose), info, warn (or quiet) and error.
+// The empty string is info.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug", "verbose":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning", "quiet":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+}
+
+// Enabled reports whether records at l are written.
+func Enabled(l slog.Level) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return l >= level
+}
+
+// Logger returns the configured logger, for libraries that take an
+// *slog.Logger. Its records are redacted like those of the functions below.
+func Logger() *slog.Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return logger
+}
+
+func log(l slog.Level, msg string, args ...any) {
+	mu.RLock()
+	lg := logger
+	mu.RUnlock()
+	lg.Log(context.Background(), l, msg, args...)
+}
+
+// Debug logs a diagnostic record, shown with --verbose.
+func Debug(msg string, args ...any) { log(slog.LevelDebug, msg, args...) }
+
+// Info logs progress, hidden by --quiet.
+func Info(msg string, args ...any) { log(slog.LevelInfo, msg, args...) }
+
+// Warn logs a problem the command recovered from.
+func Warn(msg string, args ...any) { log(slog.LevelWarn, msg, args...) }
+
+// Error logs a failure.
+func Error(msg string, args ...any) { log(slog.LevelError, msg, args...) }
+
+// Debugf logs a formatted diagnostic record.
+func Debugf(format string, args ...any) {
+	if Enabled(slog.LevelDebug) {
+		Debug(fmt.Sprintf(format, args...))
+	}
+}
+
+// Infof logs formatted progress.
+func Infof(format string, args ...any) {
+	if Enabled(slog.LevelInfo) {
+		Info(fmt.Sprintf(format, args...))
+	}
+}
+
+// Warnf logs a formatted warning.
+func Warnf(format string, args ...any) {
+	Warn(fmt.Sprintf(format, args...))
+}
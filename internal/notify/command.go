@@ -0,0 +1,171 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+)
+
+// ConfigUsage documents the 'config notify' subcommands shared by all CLIs.
+const ConfigUsage = `Usage:
+  config notify add <name> --webhook <url> [--secret [<key>|-]] [--on <events>]
+  config notify add <name> --command <shell command> [--on <events>]
+  config notify add <name> --desktop [--on <events>]
+  config notify list
+  config notify rm <name>
+  config notify test <name>
+
+<events> is a comma-separated subset of success,failure,timeout (default: all).
+--secret without a value (or with -) reads the signing key from a prompt or
+stdin; it is kept in the secret store like API keys.`
+
+// ConfigCommand runs 'config notify <args>' against the shared config file.
+func ConfigCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", ConfigUsage)
+	}
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 2 {
+			return fmt.Errorf("%s", ConfigUsage)
+		}
+		t := config.NotifyTarget{Name: args[1]}
+		var secret string
+		rest := args[2:]
+		for i := 0; i < len(rest); i++ {
+			switch rest[i] {
+			case "--webhook":
+				i++
+				if i < len(rest) {
+					t.Type, t.URL = TypeWebhook, rest[i]
+				}
+			case "--secret":
+				// The key may be omitted (or "-") to be prompted for.
+				j := len(rest)
+				if i+1 < len(rest) && !strings.HasPrefix(rest[i+1], "--") {
+					i++
+					j = i
+				}
+				v, err := config.SecretArg(rest, j, "Webhook signing key: ")
+				if err != nil {
+					return fmt.Errorf("--secret: %w", err)
+				}
+				secret = v
+			case "--command":
+				i++
+				if i < len(rest) {
+					t.Type, t.Command = TypeCommand, rest[i]
+				}
+			case "--desktop":
+				t.Type = TypeDesktop
+			case "--on":
+				i++
+				if i < len(rest) {
+					for _, on := range strings.Split(rest[i], ",") {
+						if on != Success && on != Failure && on != Timeout {
+							return fmt.Errorf("invalid event %q, want success, failure or timeout", on)
+						}
+						t.On = append(t.On, on)
+					}
+				}
+			default:
+				return fmt.Errorf("unknown flag: %s\n%s", rest[i], ConfigUsage)
+			}
+		}
+		if t.Type == "" {
+			return fmt.Errorf("one of --webhook, --command or --desktop is required\n%s", ConfigUsage)
+		}
+		where := config.Path()
+		if secret != "" {
+			w, err := cfg.SetNotifySecret(&t, secret)
+			if err != nil {
+				return err
+			}
+			where = w
+		}
+		// The replaced signing key is deleted only once the config no
+		// longer refers to it
+		var replaced *config.NotifyTarget
+		if existing := cfg.NotifyTarget(t.Name); existing != nil {
+			if existing.Stored != t.Stored {
+				old := *existing
+				replaced = &old
+			}
+			*existing = t
+		} else {
+			cfg.Notify = append(cfg.Notify, t)
+		}
+		if err := config.Save(cfg); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		if replaced != nil {
+			config.DeleteNotifySecret(replaced)
+		}
+		if secret != "" {
+			fmt.Printf("Notify target %q saved to %s (signing key: %s)\n", t.Name, config.Path(), where)
+		} else {
+			fmt.Printf("Notify target %q saved to %s\n", t.Name, config.Path())
+		}
+	case "list":
+		if len(cfg.Notify) == 0 {
+			fmt.Println("No notify targets configured")
+			return nil
+		}
+		for _, t := range cfg.Notify {
+			on := "all"
+			if len(t.On) > 0 {
+				on = strings.Join(t.On, ",")
+			}
+			detail := ""
+			switch t.Type {
+			case TypeWebhook:
+				detail = t.URL
+				if t.Secret != "" || t.Stored != "" {
+					detail += " (signed)"
+				}
+			case TypeCommand:
+				detail = t.Command
+			}
+			fmt.Printf("%s\t%s\ton=%s\t%s\n", t.Name, t.Type, on, detail)
+		}
+	case "rm":
+		if len(args) < 2 {
+			return fmt.Errorf("%s", ConfigUsage)
+		}
+		for i, t := range cfg.Notify {
+			if t.Name == args[1] {
+				cfg.Notify = append(cfg.Notify[:i], cfg.Notify[i+1:]...)
+				if err := config.Save(cfg); err != nil {
+					return fmt.Errorf("saving config: %w", err)
+				}
+				config.DeleteNotifySecret(&t)
+				fmt.Printf("Notify target %q removed\n", args[1])
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown notify target %q", args[1])
+	case "test":
+		if len(args) < 2 {
+			return fmt.Errorf("%s", ConfigUsage)
+		}
+		t, err := resolve(cfg, args[1])
+		if err != nil {
+			return err
+		}
+		ev := Event{Event: Success, Tool: "test", Model: "test", Outputs: []string{"test.mp4"}, Time: time.Now()}
+		if err := Send(*t, ev); err != nil {
+			return err
+		}
+		fmt.Printf("Test notification sent to %q\n", t.Name)
+	default:
+		return fmt.Errorf("unknown notify command: %s\n%s", args[0], ConfigUsage)
+	}
+	return nil
+}
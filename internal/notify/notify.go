@@ -0,0 +1,302 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/timing"
+)
+
+// Event outcomes.
+const (
+	Success = "success"
+	Failure = "failure"
+	Timeout = "timeout"
+)
+
+// Target types.
+const (
+	TypeWebhook = "webhook"
+	TypeCommand = "command"
+	TypeDesktop = "desktop"
+)
+
+// commandTimeout bounds how long a notification command may run.
+const commandTimeout = 30 * time.Second
+
+// Event describes a finished generation.
+type Event struct {
+	Event      string    `json:"event"` // success, failure or timeout
+	Tool       string    `json:"tool"`
+	Model      string    `json:"model"`
+	TaskID     string    `json:"task_id,omitempty"`
+	JobID      string    `json:"job_id,omitempty"`
+	Outputs    []string  `json:"outputs,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	Time       time.Time `json:"time"`
+	// Timings breaks DurationMS down into phases, when measured.
+	Timings *timing.Report `json:"timings,omitempty"`
+}
+
+// Notifier fires events at a fixed set of targets.
+type Notifier struct {
+	Tool   string
+	Model  string
+	TaskID string
+	Start  time.Time
+	// Timings, if set, is attached to every event.
+	Timings *timing.Timings
+	targets []config.NotifyTarget
+}
+
+// New resolves target specs into a Notifier. A spec is the name of a target
+// in cfg.Notify, "desktop", or an http(s) URL used as an unsigned webhook.
+// A Notifier without targets is valid and does nothing.
+func New(cfg *config.Config, specs []string) (*Notifier, error) {
+	n := &Notifier{Start: time.Now()}
+	for _, spec := range specs {
+		for _, name := range strings.Split(spec, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			t, err := resolve(cfg, name)
+			if err != nil {
+				return nil, err
+			}
+			n.targets = append(n.targets, *t)
+		}
+	}
+	return n, nil
+}
+
+func resolve(cfg *config.Config, spec string) (*config.NotifyTarget, error) {
+	if cfg != nil {
+		if t := cfg.NotifyTarget(spec); t != nil {
+			resolved := *t
+			secret, err := t.ResolveSecret()
+			if err != nil {
+				return nil, fmt.Errorf("notify target %q: %w", spec, err)
+			}
+			resolved.Secret = secret
+			return &resolved, nil
+		}
+	}
+	switch {
+	case spec == TypeDesktop:
+		return &config.NotifyTarget{Name: spec, Type: TypeDesktop}, nil
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return &config.NotifyTarget{Name: spec, Type: TypeWebhook, URL: spec}, nil
+	}
+	return nil, fmt.Errorf("unknown notify target %q (configure it with 'config notify add')", spec)
+}
+
+// Enabled reports whether any target is configured.
+func (n *Notifier) Enabled() bool {
+	return n != nil && len(n.targets) > 0
+}
+
+// Done fires a success event for the given output files.
+func (n *Notifier) Done(outputs ...string) {
+	n.Fire(Event{Event: Success, Outputs: outputs})
+}
+
+// Failed fires a failure or timeout event for err.
+func (n *Notifier) Failed(err error) {
+	n.Fire(Event{Event: Classify(err), Error: err.Error()})
+}
+
+// Fire fills in the Notifier's defaults and sends ev to every target that
+// subscribes to its outcome. Delivery errors are reported on stderr and never
+// change the outcome of the generation itself.
+func (n *Notifier) Fire(ev Event) {
+	if !n.Enabled() {
+		return
+	}
+	if ev.Tool == "" {
+		ev.Tool = n.Tool
+	}
+	if ev.Model == "" {
+		ev.Model = n.Model
+	}
+	if ev.TaskID == "" {
+		ev.TaskID = n.TaskID
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	if ev.DurationMS == 0 && !n.Start.IsZero() {
+		ev.DurationMS = ev.Time.Sub(n.Start).Milliseconds()
+	}
+	if ev.Timings == nil {
+		ev.Timings = n.Timings.Report()
+	}
+	for _, t := range n.targets {
+		if !subscribes(t, ev.Event) {
+			continue
+		}
+		if err := Send(t, ev); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: notify %s failed: %v\n", t.Name, err)
+		}
+	}
+}
+
+// Classify maps a generation error onto failure or timeout.
+func Classify(err error) string {
+	if err == nil {
+		return Success
+	}
+	msg := err.Error()
+	if errors.Is(err, context.DeadlineExceeded) || strings.Contains(msg, "timeout after") || strings.Contains(msg, "timed out") {
+		return Timeout
+	}
+	return Failure
+}
+
+func subscribes(t config.NotifyTarget, event string) bool {
+	if len(t.On) == 0 {
+		return true
+	}
+	for _, on := range t.On {
+		if on == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Send delivers ev to a single target.
+func Send(t config.NotifyTarget, ev Event) error {
+	switch t.Type {
+	case TypeWebhook:
+		return sendWebhook(t, ev)
+	case TypeCommand:
+		return runCommand(t, ev)
+	case TypeDesktop:
+		return showDesktop(ev)
+	default:
+		return fmt.Errorf("unknown target type %q", t.Type)
+	}
+}
+
+// sendWebhook POSTs ev as JSON. With a secret, the request carries
+// X-LLM-API-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">, where
+// timestamp is the X-LLM-API-Timestamp header (Unix seconds).
+func sendWebhook(t config.NotifyTarget, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	headers := map[string]string{
+		"User-Agent":          "llm-api-plugin",
+		"X-LLM-API-Event":     ev.Event,
+		"X-LLM-API-Timestamp": ts,
+	}
+	if t.Secret != "" {
+		headers["X-LLM-API-Signature"] = "sha256=" + Sign(t.Secret, ts, body)
+	}
+	respBody, status, err := httpclient.PostJSON(t.URL, headers, body)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("HTTP %d: %s", status, string(respBody))
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by secret.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// runCommand runs the target's shell command with the event in LLM_API_*
+// environment variables.
+func runCommand(t config.NotifyTarget, ev Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", t.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", t.Command)
+	}
+	output := ""
+	if len(ev.Outputs) > 0 {
+		output = ev.Outputs[0]
+	}
+	cmd.Env = append(os.Environ(),
+		"LLM_API_EVENT="+ev.Event,
+		"LLM_API_TOOL="+ev.Tool,
+		"LLM_API_MODEL="+ev.Model,
+		"LLM_API_TASK_ID="+ev.TaskID,
+		"LLM_API_JOB_ID="+ev.JobID,
+		"LLM_API_OUTPUT="+output,
+		"LLM_API_OUTPUTS="+strings.Join(ev.Outputs, string(os.PathListSeparator)),
+		"LLM_API_ERROR="+ev.Error,
+		"LLM_API_DURATION_MS="+strconv.FormatInt(ev.DurationMS, 10),
+	)
+	// stdout is reserved for the CLI's own result.
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// showDesktop raises a native desktop notification.
+func showDesktop(ev Event) error {
+	title := fmt.Sprintf("%s %s", ev.Model, ev.Event)
+	msg := ev.Error
+	if ev.Event == Success {
+		msg = "Done"
+		if len(ev.Outputs) > 0 {
+			msg = "Saved " + strings.Join(ev.Outputs, ", ")
+		}
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(msg), appleScriptString(title))
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		script := `Add-Type -AssemblyName System.Windows.Forms;` +
+			`$n = New-Object System.Windows.Forms.NotifyIcon;` +
+			`$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true;` +
+			`$n.ShowBalloonTip(10000, $env:LLM_API_TITLE, $env:LLM_API_MESSAGE, 'Info'); Start-Sleep -Seconds 5; $n.Dispose()`
+		cmd = exec.Command("powershell", "-NoProfile", "-Command", script)
+		cmd.Env = append(os.Environ(), "LLM_API_TITLE="+title, "LLM_API_MESSAGE="+msg)
+	default:
+		cmd = exec.Command("notify-send", "--app-name=llm-api-plugin", title, msg)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w %s", cmd.Path, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,350 @@
+// Package output names generated files and writes them safely. Names come
+// from --output, or from a template such as "{model}/{date}_{index}.{ext}"
+// expanded in --output-dir; the extension follows the sniffed content, not
+// what the provider claims. Content is written to a temporary file in the
+// target directory and linked into place under a name that does not exist
+// yet, so concurrent jobs and multi-image responses never overwrite each
+// other or leave half-written files behind.
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/input"
+)
+
+// DefaultTemplate names generated files when neither --output nor a
+// template is given.
+const DefaultTemplate = "output_{date}.{ext}"
+
+// DateFormat is the layout of {date}.
+const DateFormat = "20060102_150405"
+
+// Options choose where generated files go.
+type Options struct {
+	// Path is an explicit file path (--output), or Stdout; Dir and
+	// Template are ignored when it is set.
+	Path string
+	// Dir holds the files named by Template (--output-dir).
+	Dir string
+	// Template names the files (--output-template). It may contain
+	// subdirectories.
+	Template string
+}
+
+// Defaults fills Dir and Template from the output_dir and output_template
+// defaults of cfg and checks the template, so that a typo fails before
+// anything is generated.
+func (o Options) Defaults(cfg *config.Config) (Options, error) {
+	if o.Dir == "" && cfg != nil {
+		o.Dir, _ = cfg.OutputDir()
+	}
+	if o.Template == "" && cfg != nil {
+		o.Template, _ = cfg.OutputTemplate()
+	}
+	if o.Template == "" {
+		o.Template = DefaultTemplate
+	}
+	if _, err := expand(o.Template, Vars{}, "x"); err != nil {
+		return o, err
+	}
+	return o, nil
+}
+
+// Vars are the values of the template placeholders.
+type Vars struct {
+	Model  string
+	Prompt string
+	TaskID string
+	Seed   string
+	// Index numbers the files of one response from 1.
+	Index int
+	// Time is {date}; zero means when the file is created.
+	Time time.Time
+	// Ext, without a dot, is used when the content type cannot be told.
+	Ext string
+}
+
+// Seed formats a seed for Vars: empty for a random seed (zero or
+// negative), so that the {seed} placeholder drops out.
+func Seed(seed int) string {
+	if seed <= 0 {
+		return ""
+	}
+	return strconv.Itoa(seed)
+}
+
+var placeholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// expand substitutes v into tmpl. Unknown placeholders are an error.
+func expand(tmpl string, v Vars, ext string) (string, error) {
+	index := v.Index
+	if index == 0 {
+		index = 1
+	}
+	values := map[string]string{
+		"model":       clean(v.Model),
+		"seed":        clean(v.Seed),
+		"task_id":     clean(v.TaskID),
+		"index":       strconv.Itoa(index),
+		"date":        v.Time.Format(DateFormat),
+		"prompt_slug": Slug(v.Prompt),
+		"ext":         ext,
+	}
+	var unknown []string
+	out := placeholder.ReplaceAllStringFunc(tmpl, func(m string) string {
+		name := m[1 : len(m)-1]
+		value, ok := values[name]
+		if !ok {
+			unknown = append(unknown, m)
+		}
+		return value
+	})
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("unknown placeholder %s in output template %q", strings.Join(unknown, ", "), tmpl)
+	}
+	if !strings.Contains(tmpl, "{ext}") {
+		out += "." + ext
+	}
+	return tidy(out), nil
+}
+
+var repeatedSeparators = regexp.MustCompile(`([_-])[_-]+`)
+
+// tidy removes the separators left around empty placeholders from each
+// element of a path, e.g. "output__1" or "_seed.mp4".
+func tidy(path string) string {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i, p := range parts {
+		p = repeatedSeparators.ReplaceAllString(p, "$1")
+		stem, ext := p, ""
+		if dot := strings.LastIndex(p, "."); dot > 0 {
+			stem, ext = p[:dot], p[dot:]
+		}
+		parts[i] = strings.Trim(stem, "_-") + ext
+	}
+	return filepath.FromSlash(strings.Join(parts, "/"))
+}
+
+// clean makes a value safe as part of a file name.
+func clean(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '-'
+		}
+		return r
+	}, s)
+}
+
+// maxSlug is the length in characters of {prompt_slug}.
+const maxSlug = 40
+
+// Slug shortens a prompt to lower-case words joined by dashes, keeping
+// letters of any script so that Chinese prompts stay readable.
+func Slug(s string) string {
+	var b strings.Builder
+	n, dash := 0, false
+	for _, r := range strings.ToLower(s) {
+		if n >= maxSlug {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+				n++
+			}
+			b.WriteRune(r)
+			n++
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// File is a generated file being written. Write to it, then Commit to move
+// it into place or Abort to discard it.
+type File struct {
+	f    *os.File
+	opts Options
+	vars Vars
+	head []byte
+}
+
+// Create starts a file for vars in the directory chosen by o.
+func Create(o Options, v Vars) (*File, error) {
+	if v.Time.IsZero() {
+		v.Time = time.Now()
+	}
+	dir := o.Dir
+	if o.Path != "" {
+		dir = filepath.Dir(o.Path)
+	}
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create output dir: %w", err)
+	}
+	f, err := os.CreateTemp(dir, ".llm-api-*.part")
+	if err != nil {
+		return nil, fmt.Errorf("create file: %w", err)
+	}
+	return &File{f: f, opts: o, vars: v}, nil
+}
+
+// Write implements io.Writer, keeping the leading bytes for sniffing.
+func (f *File) Write(p []byte) (int, error) {
+	if len(f.head) < 512 {
+		f.head = append(f.head, p[:min(len(p), 512-len(f.head))]...)
+	}
+	return f.f.Write(p)
+}
+
+// Abort discards the file.
+func (f *File) Abort() {
+	f.f.Close()
+	os.Remove(f.f.Name())
+}
+
+// Commit names the file and moves it into place, returning its path. The
+// extension comes from the sniffed content, then from declared (such as a
+// response's Content-Type), then from Vars.Ext. An existing file is never
+// replaced: a -2, -3, ... suffix is added instead.
+func (f *File) Commit(declared string) (string, error) {
+	tmp := f.f.Name()
+	if err := f.f.Close(); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("write file: %w", err)
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	target := f.opts.Path
+	if target == "" {
+		name, err := expand(f.opts.Template, f.vars, extension(f.head, declared, f.vars.Ext))
+		if err != nil {
+			os.Remove(tmp)
+			return "", err
+		}
+		target = filepath.Join(f.opts.Dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			os.Remove(tmp)
+			return "", fmt.Errorf("create output dir: %w", err)
+		}
+	}
+	path, err := place(tmp, target)
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("save file: %w", err)
+	}
+	return path, nil
+}
+
+// extension picks the file extension, without its dot, for content
+// starting with head.
+func extension(head []byte, declared, fallback string) string {
+	t := input.Sniff(head)
+	if t == "application/octet-stream" || t == "text/plain" {
+		t = declared
+	}
+	if t != "" && t != "application/octet-stream" && t != "binary/octet-stream" {
+		if ext := input.Extension(t); ext != ".bin" {
+			return strings.TrimPrefix(ext, ".")
+		}
+	}
+	if fallback == "" {
+		return "bin"
+	}
+	return fallback
+}
+
+// maxSuffix bounds the search for a free name.
+const maxSuffix = 1000
+
+// place moves tmp to target, or to the first free name with a numeric
+// suffix. A hard link fails if the name exists, so the check and the move
+// are one step; file systems without links fall back to a rename after a
+// check.
+func place(tmp, target string) (string, error) {
+	ext := filepath.Ext(target)
+	stem := strings.TrimSuffix(target, ext)
+	for n := 1; n <= maxSuffix; n++ {
+		name := target
+		if n > 1 {
+			name = fmt.Sprintf("%s-%d%s", stem, n, ext)
+		}
+		err := os.Link(tmp, name)
+		if err == nil {
+			os.Remove(tmp)
+			return name, nil
+		}
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if _, statErr := os.Lstat(name); statErr == nil {
+			continue
+		}
+		if err := os.Rename(tmp, name); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+	return "", fmt.Errorf("%s and %d numbered variants already exist", target, maxSuffix)
+}
+
+// Save writes data as a generated file and returns its path, or Stdout
+// when o streams to standard output. mimeType is the declared type of data,
+// used when sniffing cannot tell.
+func Save(o Options, v Vars, data []byte, mimeType string) (string, error) {
+	if o.ToStdout() {
+		return writeStdout(data)
+	}
+	f, err := Create(o, v)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return "", fmt.Errorf("write file: %w", err)
+	}
+	return f.Commit(mimeType)
+}
+
+// Download saves the body at url through client as a generated file, or
+// streams it to standard output, and returns its path (Stdout) and size.
+// progress is passed to the client.
+func Download(ctx context.Context, client *httpclient.Client, url string, o Options, v Vars, progress func(written, total int64)) (string, int64, error) {
+	if o.ToStdout() {
+		return downloadStdout(ctx, client, url, progress)
+	}
+	f, err := Create(o, v)
+	if err != nil {
+		return "", 0, err
+	}
+	n, contentType, err := client.DownloadTo(ctx, url, f, progress)
+	if err != nil {
+		f.Abort()
+		return "", 0, err
+	}
+	path, err := f.Commit(contentType)
+	if err != nil {
+		return "", 0, err
+	}
+	return path, n, nil
+}
@@ -0,0 +1,113 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPlaceConcurrent(t *testing.T) {
+	for _, n := range []int{2, 8, 32} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			dir := t.TempDir()
+			target := filepath.Join(dir, "video.mp4")
+			o := Options{Path: target}
+
+			paths := make([]string, n)
+			errs := make([]error, n)
+			var wg sync.WaitGroup
+			for i := range n {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					paths[i], errs[i] = Save(o, Vars{}, []byte(fmt.Sprintf("job %d", i)), "video/mp4")
+				}()
+			}
+			wg.Wait()
+
+			for i, err := range errs {
+				if err != nil {
+					t.Fatalf("job %d: %v", i, err)
+				}
+				data, err := os.ReadFile(paths[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := fmt.Sprintf("job %d", i); string(data) != want {
+					t.Errorf("%s holds %q, want %q", paths[i], data, want)
+				}
+			}
+			got := append([]string(nil), paths...)
+			sort.Strings(got)
+			want := []string{target}
+			for i := 2; i <= n; i++ {
+				want = append(want, filepath.Join(dir, fmt.Sprintf("video-%d.mp4", i)))
+			}
+			sort.Strings(want)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("paths = %v, want %v", got, want)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != n {
+				t.Errorf("%d files in the output dir, want %d (temporary files left behind?)", len(entries), n)
+			}
+		})
+	}
+}
+
+func TestPlaceKeepsExisting(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "image.png")
+	for _, name := range []string{"image.png", "image-2.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("old "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path, err := Save(Options{Path: target}, Vars{}, []byte("new"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "image-3.png"); path != want {
+		t.Errorf("Save = %s, want %s", path, want)
+	}
+	for _, name := range []string{"image.png", "image-2.png"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != "old "+name {
+			t.Errorf("%s was overwritten with %q", name, data)
+		}
+	}
+}
+
+func TestSaveTemplate(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	when := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	tests := []struct {
+		name     string
+		template string
+		vars     Vars
+		data     []byte
+		declared string
+		want     string
+	}{
+		{"default", DefaultTemplate, Vars{Time: when}, png, "", "output_20240506_070809.png"},
+		{"sniffed over declared", "{model}_{index}.{ext}", Vars{Model: "seedream", Index: 2}, png, "image/jpeg", "seedream_2.png"},
+		{"declared", "{model}.{ext}", Vars{Model: "veo"}, []byte("not sniffable"), "video/mp4", "veo.mp4"},
+		{"fallback", "{task_id}", Vars{TaskID: "t-1", Ext: "mp3"}, []byte("not sniffable"), "", "t-1.mp3"},
+		{"empty placeholder", "{model}/{seed}_{index}.{ext}", Vars{Model: "m"}, png, "", "m/1.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path, err := Save(Options{Dir: dir, Template: tt.template}, tt.vars, tt.data, tt.declared)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(dir, filepath.FromSlash(tt.want)); path != want {
+				t.Errorf("Save = %s, want %s", path, want)
+			}
+		})
+	}
+}
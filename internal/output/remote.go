@@ -0,0 +1,104 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+)
+
+// Stdout as --output streams the artifact to standard output instead of
+// saving it, for piping into another command.
+const Stdout = "-"
+
+// stdout receives artifacts written with --output -.
+var stdout io.Writer = os.Stdout
+
+// ToStdout reports whether o streams to standard output.
+func (o Options) ToStdout() bool {
+	return o.Path == Stdout
+}
+
+// Local reports whether path, as passed to done and notifications, is a
+// saved file rather than a remote URL or standard output.
+func Local(path string) bool {
+	return path != "" && path != Stdout && !strings.Contains(path, "://")
+}
+
+// Describe names path in messages: "stdout" for Stdout.
+func Describe(path string) string {
+	if path == Stdout {
+		return "stdout"
+	}
+	return path
+}
+
+// writeStdout writes an artifact to standard output.
+func writeStdout(data []byte) (string, error) {
+	if _, err := stdout.Write(data); err != nil {
+		return "", fmt.Errorf("write stdout: %w", err)
+	}
+	return Stdout, nil
+}
+
+// downloadStdout streams the body at url to standard output.
+func downloadStdout(ctx context.Context, client *httpclient.Client, url string, progress func(written, total int64)) (string, int64, error) {
+	n, _, err := client.DownloadTo(ctx, url, stdout, progress)
+	if err != nil {
+		return "", 0, err
+	}
+	return Stdout, n, nil
+}
+
+// signedDate is the layout of the X-Amz-Date style query parameters.
+const signedDate = "20060102T150405Z"
+
+// Expiry returns when a provider result URL stops working, read from its
+// signature: X-Amz-, X-Tos- or X-Goog-Date plus -Expires seconds, or an
+// Expires / x-expires / x-signature-expires Unix time. ok is false when the
+// URL does not say.
+func Expiry(rawURL string) (t time.Time, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}, false
+	}
+	q := map[string]string{}
+	for k, v := range u.Query() {
+		if len(v) > 0 {
+			q[strings.ToLower(k)] = v[0]
+		}
+	}
+	for _, prefix := range []string{"x-amz-", "x-tos-", "x-goog-"} {
+		date, err := time.Parse(signedDate, q[prefix+"date"])
+		if err != nil {
+			continue
+		}
+		if secs, err := strconv.ParseInt(q[prefix+"expires"], 10, 64); err == nil {
+			return date.Add(time.Duration(secs) * time.Second), true
+		}
+	}
+	for _, key := range []string{"expires", "x-expires", "x-signature-expires"} {
+		if unix, err := strconv.ParseInt(q[key], 10, 64); err == nil && unix > 0 {
+			return time.Unix(unix, 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ExpiryNote describes when url expires, for progress messages.
+func ExpiryNote(url string) string {
+	t, ok := Expiry(url)
+	if !ok {
+		return "expiry unknown"
+	}
+	if d := time.Until(t); d > 0 {
+		return fmt.Sprintf("expires %s, in %s", t.Local().Format(time.DateTime), d.Round(time.Minute))
+	}
+	return fmt.Sprintf("expired %s", t.Local().Format(time.DateTime))
+}
@@ -0,0 +1,127 @@
+package probe
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/llm-net/llm-api-plugin/internal/cassette"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+)
+
+// Expect is what the request asked for; zero fields are not checked.
+type Expect struct {
+	// Ratio is an aspect ratio such as "16:9".
+	Ratio string
+	// Resolution is a nominal height such as "720p" or "1080".
+	Resolution string
+	// Duration in seconds.
+	Duration float64
+	// Frames is the total number of video frames.
+	Frames int
+	// Audio, if set, tells whether the video should have an audio track.
+	Audio *bool
+}
+
+// Tolerances: providers round sizes to codec-friendly multiples and add or
+// drop a few frames.
+const (
+	ratioTolerance    = 0.03
+	durationTolerance = 0.5
+)
+
+// Check returns how info differs from e, one message per mismatch.
+func (info *Info) Check(e Expect) []string {
+	var problems []string
+	if w, h, ok := parseRatio(e.Ratio); ok && info.Width > 0 && info.Height > 0 {
+		want := w / h
+		got := float64(info.Width) / float64(info.Height)
+		if math.Abs(got-want)/want > ratioTolerance {
+			problems = append(problems, fmt.Sprintf("aspect ratio is %dx%d, not %s", info.Width, info.Height, e.Ratio))
+		}
+	}
+	if p, ok := parseResolution(e.Resolution); ok && info.Width > 0 && info.Height > 0 {
+		// Compare pixel counts rather than heights: a 720p square video is
+		// 960x960. Adjacent tiers (480p, 720p, 1080p) are twice apart
+		want := float64(p) * float64(p) * 16 / 9
+		got := float64(info.Width * info.Height)
+		if got < want*0.6 || got > want*1.6 {
+			problems = append(problems, fmt.Sprintf("resolution is %dx%d, not %s", info.Width, info.Height, e.Resolution))
+		}
+	}
+	if e.Duration > 0 && info.Duration > 0 && math.Abs(info.Duration-e.Duration) > durationTolerance {
+		problems = append(problems, fmt.Sprintf("duration is %.2fs, not %gs", info.Duration, e.Duration))
+	}
+	if e.Frames > 0 && info.Frames > 0 {
+		slack := int(math.Max(2, info.FrameRate*durationTolerance))
+		if info.Frames < e.Frames-slack || info.Frames > e.Frames+slack {
+			problems = append(problems, fmt.Sprintf("%d frames, not %d", info.Frames, e.Frames))
+		}
+	}
+	if e.Audio != nil && *e.Audio != info.HasAudio {
+		if *e.Audio {
+			problems = append(problems, "no audio track")
+		} else {
+			problems = append(problems, "has an audio track, but a silent video was requested")
+		}
+	}
+	return problems
+}
+
+// Verify probes the video saved at path and compares it with e. A file that
+// is not a readable MP4 with a video track, such as a truncated download,
+// is an error; mismatches with e are logged as warnings and returned.
+func Verify(path string, e Expect) (*Info, []string, error) {
+	info, err := File(path)
+	if err != nil && cassette.Replaying() {
+		logging.Warnf("%s not checked: %v (replayed downloads are placeholders)", path, err)
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("downloaded video %s is unusable: %w", path, err)
+	}
+	if info.VideoCodec == "" {
+		return nil, nil, fmt.Errorf("downloaded video %s has no video track", path)
+	}
+	logging.Infof("Video: %dx%d, %.2fs, %g fps, %s%s", info.Width, info.Height, info.Duration, info.FrameRate,
+		info.VideoCodec, audioNote(info))
+	problems := info.Check(e)
+	for _, p := range problems {
+		logging.Warnf("%s: %s", path, p)
+	}
+	return info, problems, nil
+}
+
+// Bool returns a pointer to b, for Expect.Audio.
+func Bool(b bool) *bool {
+	return &b
+}
+
+func audioNote(info *Info) string {
+	if !info.HasAudio {
+		return ", no audio"
+	}
+	return " + " + info.AudioCodec
+}
+
+// parseRatio parses "16:9"; other values, such as "adaptive", are not
+// checked.
+func parseRatio(s string) (w, h float64, ok bool) {
+	a, b, found := strings.Cut(s, ":")
+	if !found {
+		return 0, 0, false
+	}
+	w, err1 := strconv.ParseFloat(a, 64)
+	h, err2 := strconv.ParseFloat(b, 64)
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 {
+		return 0, 0, false
+	}
+	return w, h, true
+}
+
+// parseResolution parses "720p" or "720".
+func parseResolution(s string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(s), "p"))
+	return n, err == nil && n > 0
+}
@@ -0,0 +1,43 @@
+package probe
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	landscape := Info{Width: 1280, Height: 720, Duration: 5.04, FrameRate: 24, Frames: 121, HasAudio: true}
+	square := Info{Width: 960, Height: 960, Duration: 10, FrameRate: 24, Frames: 241}
+
+	tests := []struct {
+		name   string
+		info   Info
+		expect Expect
+		want   []string
+	}{
+		{"nothing expected", landscape, Expect{}, nil},
+		{"all match", landscape, Expect{Ratio: "16:9", Resolution: "720p", Duration: 5, Frames: 121, Audio: Bool(true)}, nil},
+		{"rounded size", Info{Width: 1248, Height: 704}, Expect{Ratio: "16:9", Resolution: "720p"}, nil},
+		{"square 720p", square, Expect{Ratio: "1:1", Resolution: "720"}, nil},
+		{"adaptive ratio", square, Expect{Ratio: "adaptive"}, nil},
+		{"frames within slack", square, Expect{Frames: 250}, nil},
+		{"wrong ratio", landscape, Expect{Ratio: "9:16"}, []string{"aspect ratio is 1280x720, not 9:16"}},
+		{"wrong resolution", landscape, Expect{Resolution: "1080p"}, []string{"resolution is 1280x720, not 1080p"}},
+		{"wrong duration", square, Expect{Duration: 5}, []string{"duration is 10.00s, not 5s"}},
+		{"wrong frames", square, Expect{Frames: 121}, []string{"241 frames, not 121"}},
+		{"missing audio", square, Expect{Audio: Bool(true)}, []string{"no audio track"}},
+		{"unwanted audio", landscape, Expect{Audio: Bool(false)}, []string{"has an audio track, but a silent video was requested"}},
+		{"unknown size", Info{Duration: 5}, Expect{Ratio: "9:16", Resolution: "480p"}, nil},
+		{
+			"several", landscape, Expect{Ratio: "1:1", Duration: 10},
+			[]string{"aspect ratio is 1280x720, not 1:1", "duration is 5.04s, not 10s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.Check(tt.expect); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Usage documents the 'probe' command shared by all CLIs.
+const Usage = `Usage:
+  probe <file.mp4> [--ratio <w:h>] [--resolution <720p>] [--duration <s>] [--frames <n>] [--audio|--no-audio]
+
+Prints the duration, resolution, frame rate, codecs and audio of an MP4 as
+JSON. With expectations, mismatches are listed under "warnings" and the
+command exits with status 1, as does a truncated or unreadable file.`
+
+// Command runs 'probe <args>'.
+func Command(args []string) error {
+	var path string
+	var want Expect
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--ratio":
+			i++
+			if i < len(args) {
+				want.Ratio = args[i]
+			}
+		case "--resolution":
+			i++
+			if i < len(args) {
+				want.Resolution = args[i]
+			}
+		case "--duration":
+			i++
+			if i < len(args) {
+				d, err := strconv.ParseFloat(args[i], 64)
+				if err != nil {
+					return fmt.Errorf("invalid --duration %q", args[i])
+				}
+				want.Duration = d
+			}
+		case "--frames":
+			i++
+			if i < len(args) {
+				n, err := strconv.Atoi(args[i])
+				if err != nil {
+					return fmt.Errorf("invalid --frames %q", args[i])
+				}
+				want.Frames = n
+			}
+		case "--audio":
+			want.Audio = Bool(true)
+		case "--no-audio":
+			want.Audio = Bool(false)
+		case "help", "--help", "-h":
+			fmt.Println(Usage)
+			return nil
+		default:
+			if strings.HasPrefix(args[i], "--") || path != "" {
+				return fmt.Errorf("unknown flag: %s\n%s", args[i], Usage)
+			}
+			path = args[i]
+		}
+	}
+	if path == "" {
+		return fmt.Errorf("%s", Usage)
+	}
+
+	info, err := File(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	problems := info.Check(want)
+	data, err := json.MarshalIndent(struct {
+		*Info
+		Warnings []string `json:"warnings,omitempty"`
+	}{info, problems}, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	if len(problems) > 0 {
+		return fmt.Errorf("%s does not match: %s", path, strings.Join(problems, "; "))
+	}
+	return nil
+}
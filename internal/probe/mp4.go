@@ -0,0 +1,339 @@
+// Package probe reads the properties of downloaded videos from their MP4
+// boxes, without ffmpeg: duration, resolution, frame rate, codecs and
+// audio. Check compares them with what the request asked for, so that a
+// truncated download or a video of the wrong shape or length is noticed.
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Info describes an MP4 file.
+type Info struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Brand is the major brand of the ftyp box, e.g. "isom".
+	Brand string `json:"brand,omitempty"`
+	// Duration in seconds.
+	Duration float64 `json:"duration"`
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	// FrameRate and Frames are those of the video track.
+	FrameRate  float64 `json:"frame_rate,omitempty"`
+	Frames     int     `json:"frames,omitempty"`
+	VideoCodec string  `json:"video_codec,omitempty"`
+	HasAudio   bool    `json:"has_audio"`
+	AudioCodec string  `json:"audio_codec,omitempty"`
+	SampleRate int     `json:"sample_rate,omitempty"`
+	Channels   int     `json:"channels,omitempty"`
+	// Fragmented files carry their samples in moof boxes; Duration and
+	// Frames then come from the movie header only and may be zero.
+	Fragmented bool `json:"fragmented,omitempty"`
+}
+
+// ErrTruncated is returned for a file that ends inside a box, such as a
+// download cut short.
+var ErrTruncated = errors.New("truncated")
+
+// maxMoov bounds the metadata read into memory; real moov boxes are a few
+// hundred kilobytes at most.
+const maxMoov = 64 << 20
+
+// codecs names the sample entry types of common codecs.
+var codecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"av01": "av1",
+	"vp08": "vp8",
+	"vp09": "vp9",
+	"mp4v": "mpeg4",
+	"mp4a": "aac",
+	"Opus": "opus",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	".mp3": "mp3",
+}
+
+// topLevel lists the boxes an MP4 file may start with.
+var topLevel = map[string]bool{"ftyp": true, "styp": true, "moov": true, "mdat": true, "free": true, "skip": true, "wide": true, "pdin": true}
+
+// box is a parsed box header: its type and where its payload lies.
+type box struct {
+	typ    string
+	offset int64 // of the payload
+	size   int64 // of the payload
+}
+
+// File probes the MP4 file at path.
+func File(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	info, err := Read(f, st.Size())
+	if info != nil {
+		info.Path = path
+	}
+	return info, err
+}
+
+// Read probes an MP4 of size bytes. It reads the box headers and the
+// metadata only, not the media data.
+func Read(r io.ReaderAt, size int64) (*Info, error) {
+	info := &Info{Size: size}
+	var moov []byte
+	for offset := int64(0); offset < size; {
+		b, err := readHeader(r, offset, size)
+		if offset == 0 && !topLevel[b.typ] {
+			return info, fmt.Errorf("not an MP4 file")
+		}
+		if err != nil {
+			return info, err
+		}
+		switch b.typ {
+		case "ftyp":
+			head := make([]byte, 4)
+			if b.size >= 4 {
+				if _, err := r.ReadAt(head, b.offset); err == nil {
+					info.Brand = string(head)
+				}
+			}
+		case "moov":
+			if b.size > maxMoov {
+				return info, fmt.Errorf("moov box of %d bytes is too large", b.size)
+			}
+			moov = make([]byte, b.size)
+			if _, err := r.ReadAt(moov, b.offset); err != nil {
+				return info, fmt.Errorf("read moov: %w", err)
+			}
+		case "moof":
+			info.Fragmented = true
+		}
+		offset = b.offset + b.size
+	}
+	if moov == nil {
+		return info, fmt.Errorf("no moov box: not an MP4 file, or its metadata is missing")
+	}
+	if err := info.parseMoov(moov); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+// readHeader reads the box header at offset of a file of size bytes.
+func readHeader(r io.ReaderAt, offset, size int64) (box, error) {
+	var head [16]byte
+	if size-offset < 8 {
+		return box{}, fmt.Errorf("%w: %d stray bytes at offset %d", ErrTruncated, size-offset, offset)
+	}
+	if _, err := r.ReadAt(head[:8], offset); err != nil {
+		return box{}, err
+	}
+	b := box{typ: string(head[4:8]), offset: offset + 8}
+	fail := func(format string, args ...interface{}) (box, error) {
+		return box{typ: b.typ}, fmt.Errorf(format, args...)
+	}
+	switch n := int64(binary.BigEndian.Uint32(head[:4])); n {
+	case 0: // to the end of the file
+		b.size = size - b.offset
+	case 1: // 64-bit size follows
+		if _, err := r.ReadAt(head[8:16], offset+8); err != nil {
+			return fail("%w: %s box at offset %d", ErrTruncated, b.typ, offset)
+		}
+		b.offset += 8
+		b.size = int64(binary.BigEndian.Uint64(head[8:16])) - 16
+	default:
+		b.size = n - 8
+	}
+	if b.size < 0 {
+		return fail("invalid %s box size at offset %d", b.typ, offset)
+	}
+	if b.offset+b.size > size {
+		return fail("%w: %s box at offset %d needs %d bytes, the file ends after %d",
+			ErrTruncated, b.typ, offset, b.offset+b.size-offset, size-offset)
+	}
+	return b, nil
+}
+
+// children splits the payload of a container box into its boxes.
+func children(data []byte) (map[string][][]byte, error) {
+	m := map[string][][]byte{}
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, fmt.Errorf("%w: box header", ErrTruncated)
+		}
+		n := int64(binary.BigEndian.Uint32(data[:4]))
+		typ := string(data[4:8])
+		head := int64(8)
+		switch n {
+		case 0:
+			n = int64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, fmt.Errorf("%w: %s box header", ErrTruncated, typ)
+			}
+			n = int64(binary.BigEndian.Uint64(data[8:16]))
+			head = 16
+		}
+		if n < head || n > int64(len(data)) {
+			return nil, fmt.Errorf("%w: %s box", ErrTruncated, typ)
+		}
+		m[typ] = append(m[typ], data[head:n])
+		data = data[n:]
+	}
+	return m, nil
+}
+
+// child returns the first box of type typ along path inside data.
+func child(data []byte, path ...string) []byte {
+	for _, typ := range path {
+		m, err := children(data)
+		if err != nil || len(m[typ]) == 0 {
+			return nil
+		}
+		data = m[typ][0]
+	}
+	return data
+}
+
+// track is what parseMoov needs from a trak box.
+type track struct {
+	handler       string
+	codec         string
+	width, height int
+	timescale     uint32
+	duration      uint64
+	samples       int
+	sampleRate    int
+	channels      int
+}
+
+func (info *Info) parseMoov(moov []byte) error {
+	boxes, err := children(moov)
+	if err != nil {
+		return err
+	}
+	if mvhd := first(boxes["mvhd"]); len(mvhd) >= 20 {
+		var timescale uint32
+		var duration uint64
+		if mvhd[0] == 1 && len(mvhd) >= 32 {
+			timescale = binary.BigEndian.Uint32(mvhd[20:24])
+			duration = binary.BigEndian.Uint64(mvhd[24:32])
+		} else {
+			timescale = binary.BigEndian.Uint32(mvhd[12:16])
+			duration = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+		}
+		if timescale > 0 && duration != math.MaxUint32 && duration != math.MaxUint64 {
+			info.Duration = float64(duration) / float64(timescale)
+		}
+	}
+	if len(boxes["mvex"]) > 0 {
+		info.Fragmented = true
+	}
+
+	for _, trak := range boxes["trak"] {
+		t := parseTrak(trak)
+		switch t.handler {
+		case "vide":
+			if info.VideoCodec != "" {
+				continue
+			}
+			info.VideoCodec = t.codec
+			info.Width, info.Height = t.width, t.height
+			info.Frames = t.samples
+			if t.timescale > 0 && t.duration > 0 && t.samples > 0 {
+				seconds := float64(t.duration) / float64(t.timescale)
+				info.FrameRate = math.Round(float64(t.samples)/seconds*100) / 100
+				if info.Duration == 0 {
+					info.Duration = seconds
+				}
+			}
+		case "soun":
+			if info.HasAudio {
+				continue
+			}
+			info.HasAudio = true
+			info.AudioCodec = t.codec
+			info.SampleRate, info.Channels = t.sampleRate, t.channels
+		}
+	}
+	info.Duration = math.Round(info.Duration*1000) / 1000
+	return nil
+}
+
+func parseTrak(trak []byte) track {
+	var t track
+	if tkhd := child(trak, "tkhd"); len(tkhd) > 0 {
+		// Width and height are 16.16 fixed point at the end, after the
+		// transformation matrix; a 90° rotation swaps them for display
+		if n := len(tkhd); n >= 84 {
+			t.width = int(binary.BigEndian.Uint32(tkhd[n-8:n-4]) >> 16)
+			t.height = int(binary.BigEndian.Uint32(tkhd[n-4:]) >> 16)
+			matrix := tkhd[n-44 : n-8]
+			if binary.BigEndian.Uint32(matrix[0:4]) == 0 && binary.BigEndian.Uint32(matrix[4:8]) != 0 {
+				t.width, t.height = t.height, t.width
+			}
+		}
+	}
+	mdia := child(trak, "mdia")
+	if mdhd := child(mdia, "mdhd"); len(mdhd) >= 20 {
+		if mdhd[0] == 1 && len(mdhd) >= 32 {
+			t.timescale = binary.BigEndian.Uint32(mdhd[20:24])
+			t.duration = binary.BigEndian.Uint64(mdhd[24:32])
+		} else {
+			t.timescale = binary.BigEndian.Uint32(mdhd[12:16])
+			t.duration = uint64(binary.BigEndian.Uint32(mdhd[16:20]))
+		}
+	}
+	if hdlr := child(mdia, "hdlr"); len(hdlr) >= 12 {
+		t.handler = string(hdlr[8:12])
+	}
+	stbl := child(mdia, "minf", "stbl")
+	if stsd := child(stbl, "stsd"); len(stsd) >= 16 {
+		// The first sample entry: size, codec type, then the visual or
+		// audio sample entry fields
+		entry := stsd[8:]
+		t.codec = string(entry[4:8])
+		if name, ok := codecs[t.codec]; ok {
+			t.codec = name
+		}
+		fields := entry[8:]
+		switch t.handler {
+		case "vide":
+			if len(fields) >= 28 && (t.width == 0 || t.height == 0) {
+				t.width = int(binary.BigEndian.Uint16(fields[24:26]))
+				t.height = int(binary.BigEndian.Uint16(fields[26:28]))
+			}
+		case "soun":
+			if len(fields) >= 28 {
+				t.channels = int(binary.BigEndian.Uint16(fields[16:18]))
+				t.sampleRate = int(binary.BigEndian.Uint32(fields[24:28]) >> 16)
+			}
+		}
+	}
+	if stsz := child(stbl, "stsz"); len(stsz) >= 12 {
+		t.samples = int(binary.BigEndian.Uint32(stsz[8:12]))
+	} else if stz2 := child(stbl, "stz2"); len(stz2) >= 12 {
+		t.samples = int(binary.BigEndian.Uint32(stz2[8:12]))
+	}
+	return t
+}
+
+func first(list [][]byte) []byte {
+	if len(list) == 0 {
+		return nil
+	}
+	return list[0]
+}
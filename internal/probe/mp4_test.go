@@ -0,0 +1,211 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// mkbox builds a box of typ around the concatenated payloads.
+func mkbox(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	b := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(b, uint32(8+len(body)))
+	copy(b[4:], typ)
+	return append(b, body...)
+}
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+// header builds the version 0 payload of an mvhd or mdhd box.
+func header(timescale, duration uint32, pad int) []byte {
+	return bytes.Join([][]byte{make([]byte, 12), u32(timescale), u32(duration), make([]byte, pad)}, nil)
+}
+
+// fixture describes a track of a generated MP4.
+type fixture struct {
+	handler          string
+	codec            string
+	width, height    uint32
+	rotate           bool
+	timescale        uint32
+	duration         uint32
+	samples          uint32
+	channels, hertz  uint16
+	sampleEntryExtra int
+}
+
+func (f fixture) trak() []byte {
+	matrix := [][]byte{u32(0x10000), u32(0), u32(0), u32(0), u32(0x10000), u32(0), u32(0), u32(0), u32(0x40000000)}
+	if f.rotate {
+		matrix[0], matrix[1], matrix[3], matrix[4] = u32(0), u32(0x10000), u32(0xffff0000), u32(0)
+	}
+	tkhd := bytes.Join([][]byte{make([]byte, 40), bytes.Join(matrix, nil), u32(f.width << 16), u32(f.height << 16)}, nil)
+	hdlr := bytes.Join([][]byte{make([]byte, 8), []byte(f.handler), make([]byte, 13)}, nil)
+
+	var fields []byte
+	if f.handler == "soun" {
+		fields = bytes.Join([][]byte{make([]byte, 16), u16(f.channels), u16(16), make([]byte, 4), u32(uint32(f.hertz) << 16)}, nil)
+	} else {
+		fields = bytes.Join([][]byte{make([]byte, 24), u16(uint16(f.width)), u16(uint16(f.height)), make([]byte, 50)}, nil)
+	}
+	entry := mkbox(f.codec, fields)
+	stsd := bytes.Join([][]byte{make([]byte, 4), u32(1), entry}, nil)
+	stsz := bytes.Join([][]byte{make([]byte, 8), u32(f.samples)}, nil)
+
+	return mkbox("trak",
+		mkbox("tkhd", tkhd),
+		mkbox("mdia",
+			mkbox("mdhd", header(f.timescale, f.duration, 4)),
+			mkbox("hdlr", hdlr),
+			mkbox("minf", mkbox("stbl", mkbox("stsd", stsd), mkbox("stsz", stsz)))))
+}
+
+var (
+	video = fixture{handler: "vide", codec: "avc1", width: 1280, height: 720, timescale: 15360, duration: 76800, samples: 150}
+	audio = fixture{handler: "soun", codec: "mp4a", timescale: 44100, duration: 220500, samples: 216, channels: 2, hertz: 44100}
+)
+
+// mp4 builds a file with an ftyp, a moov of tracks and an mdat.
+func mp4(tracks ...fixture) []byte {
+	moov := [][]byte{mkbox("mvhd", header(1000, 5000, 80))}
+	for _, t := range tracks {
+		moov = append(moov, t.trak())
+	}
+	return bytes.Join([][]byte{
+		mkbox("ftyp", []byte("isom"), u32(512), []byte("isomavc1")),
+		mkbox("moov", moov...),
+		mkbox("mdat", make([]byte, 1024)),
+	}, nil)
+}
+
+func TestRead(t *testing.T) {
+	// A 9:16 video stored as 1280x720 and rotated for display
+	rotated := video
+	rotated.rotate = true
+	noHeaderSize := video
+	noHeaderSize.width, noHeaderSize.height = 0, 0
+
+	tests := []struct {
+		name string
+		file []byte
+		want Info
+	}{
+		{
+			name: "video and audio",
+			file: mp4(video, audio),
+			want: Info{Brand: "isom", Duration: 5, Width: 1280, Height: 720, FrameRate: 30, Frames: 150,
+				VideoCodec: "h264", HasAudio: true, AudioCodec: "aac", SampleRate: 44100, Channels: 2},
+		},
+		{
+			name: "silent",
+			file: mp4(video),
+			want: Info{Brand: "isom", Duration: 5, Width: 1280, Height: 720, FrameRate: 30, Frames: 150, VideoCodec: "h264"},
+		},
+		{
+			name: "rotated",
+			file: mp4(rotated),
+			want: Info{Brand: "isom", Duration: 5, Width: 720, Height: 1280, FrameRate: 30, Frames: 150, VideoCodec: "h264"},
+		},
+		{
+			name: "size from the sample entry",
+			file: mp4(noHeaderSize),
+			want: Info{Brand: "isom", Duration: 5, FrameRate: 30, Frames: 150, VideoCodec: "h264"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Read(bytes.NewReader(tt.file), int64(len(tt.file)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.Size = int64(len(tt.file))
+			if *info != tt.want {
+				t.Errorf("Read =\n%+v\nwant\n%+v", *info, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLargeSize(t *testing.T) {
+	file := mp4(video)
+	// Replace the mdat with one of a 64-bit size
+	mdat := bytes.Join([][]byte{u32(1), []byte("mdat"), binary.BigEndian.AppendUint64(nil, 16+64), make([]byte, 64)}, nil)
+	file = append(file[:len(file)-8-1024], mdat...)
+	info, err := Read(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Width != 1280 || info.Frames != 150 {
+		t.Errorf("Read = %+v", *info)
+	}
+}
+
+// sparse is a ReaderAt of size bytes that holds head followed by zeros.
+type sparse struct {
+	head []byte
+	size int64
+}
+
+func (s sparse) ReadAt(p []byte, off int64) (int, error) {
+	if off >= s.size {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if rest := s.size - off; int64(n) > rest {
+		n = int(rest)
+	}
+	for i := range p[:n] {
+		p[i] = 0
+	}
+	if off < int64(len(s.head)) {
+		copy(p[:n], s.head[off:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestReadErrors(t *testing.T) {
+	file := mp4(video, audio)
+	moovEnd := len(file) - 8 - 1024
+
+	ftyp := mkbox("ftyp", []byte("isom"))
+	huge := append(append([]byte{}, ftyp...), u32(8+maxMoov+1)...)
+	huge = append(huge, "moov"...)
+
+	tests := []struct {
+		name      string
+		r         sparse
+		truncated bool
+		message   string
+	}{
+		{"cut in mdat", sparse{file[:len(file)-100], int64(len(file) - 100)}, true, "mdat box"},
+		{"cut in moov", sparse{file[:moovEnd-10], int64(moovEnd - 10)}, true, "moov box"},
+		{"cut in a box header", sparse{append(append([]byte{}, ftyp...), 0, 0, 0, 0, 0), int64(len(ftyp) + 5)}, true, "stray bytes"},
+		{"cut in a 64-bit size", sparse{append(append([]byte{}, ftyp...), 0, 0, 0, 1, 'm', 'd', 'a', 't', 0, 0), int64(len(ftyp) + 10)}, true, "mdat box"},
+		{"oversized moov", sparse{huge, int64(len(huge)) + maxMoov + 1}, false, "too large"},
+		{"not an MP4", sparse{[]byte("<html><body>Not found</body></html>"), 35}, false, "not an MP4"},
+		{"no moov", sparse{ftyp, int64(len(ftyp))}, false, "no moov"},
+		{"invalid size", sparse{append(append([]byte{}, ftyp...), 0, 0, 0, 4, 'f', 'r', 'e', 'e'), int64(len(ftyp) + 8)}, false, "invalid free box size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(tt.r, tt.r.size)
+			if err == nil {
+				t.Fatal("Read succeeded")
+			}
+			if errors.Is(err, ErrTruncated) != tt.truncated {
+				t.Errorf("Read error %q: truncated = %v, want %v", err, !tt.truncated, tt.truncated)
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Read error %q does not mention %q", err, tt.message)
+			}
+		})
+	}
+}
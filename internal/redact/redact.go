@@ -0,0 +1,203 @@
+// Package redact hides credentials and summarises large base64 blobs in
+// requests and responses before they are printed or saved to disk.
+package redact
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Placeholder replaces a secret value.
+const Placeholder = "<redacted>"
+
+// MaxInline is the length above which base64 strings are summarised.
+const MaxInline = 256
+
+// secretHeaders carry credentials. Keys are lower case.
+var secretHeaders = map[string]bool{
+	"authorization":        true,
+	"x-goog-api-key":       true,
+	"topview-uid":          true,
+	"cookie":               true,
+	"set-cookie":           true,
+	"x-amz-security-token": true,
+	"x-security-token":     true,
+}
+
+// secretParams are query parameters of presigned URLs. Keys are lower case.
+var secretParams = map[string]bool{
+	"x-amz-signature":      true,
+	"x-amz-credential":     true,
+	"x-amz-security-token": true,
+	"x-tos-signature":      true,
+	"x-tos-credential":     true,
+	"x-tos-security-token": true,
+	"signature":            true,
+	"key":                  true,
+}
+
+// secretKeyWords mark JSON fields and log attributes holding credentials,
+// as a whole key or one of its underscore-separated ends.
+var secretKeyWords = []string{"api_key", "apikey", "secret", "token", "password", "access_key", "authorization"}
+
+// SecretKey reports whether a header, JSON field or log attribute named
+// name carries credentials, e.g. Authorization, api_key or
+// secret_access_key.
+func SecretKey(name string) bool {
+	if secretHeaders[strings.ToLower(name)] {
+		return true
+	}
+	n := strings.ToLower(strings.ReplaceAll(name, "-", "_"))
+	for _, w := range secretKeyWords {
+		if n == w || strings.HasPrefix(n, w+"_") || strings.HasSuffix(n, "_"+w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Mask hides a secret for display, keeping only its first and last 4
+// characters so that keys can still be told apart.
+func Mask(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", len(s)-8) + s[len(s)-4:]
+}
+
+// secrets are the credentials registered with AddSecret.
+var secrets struct {
+	sync.RWMutex
+	values []string
+}
+
+// AddSecret registers credential values, such as resolved API keys, that
+// Text masks wherever they appear. Values shorter than 8 characters are
+// ignored, as masking them would garble unrelated text.
+func AddSecret(values ...string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, v := range values {
+		if len(v) >= 8 {
+			secrets.values = append(secrets.values, v)
+		}
+	}
+}
+
+var (
+	urlPattern    = regexp.MustCompile(`https?://[^\s"'<>]+`)
+	base64Pattern = regexp.MustCompile(`[A-Za-z0-9+/]{` + fmt.Sprint(MaxInline) + `,}={0,2}`)
+)
+
+// Text redacts free text such as a log message: registered secrets are
+// masked, URLs lose their signatures and long base64 runs are summarised.
+func Text(s string) string {
+	secrets.RLock()
+	for _, v := range secrets.values {
+		s = strings.ReplaceAll(s, v, Mask(v))
+	}
+	secrets.RUnlock()
+	s = urlPattern.ReplaceAllStringFunc(s, URL)
+	return base64Pattern.ReplaceAllStringFunc(s, func(b64 string) string {
+		return Blob(decodedLen(b64))
+	})
+}
+
+// Header returns value, or Placeholder when the header carries credentials.
+func Header(name, value string) string {
+	if secretHeaders[strings.ToLower(name)] {
+		return Placeholder
+	}
+	return value
+}
+
+// URL replaces the signature and credential query parameters of a URL.
+func URL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.RawQuery == "" {
+		return raw
+	}
+	q := u.Query()
+	changed := false
+	for name := range q {
+		if secretParams[strings.ToLower(name)] {
+			q.Set(name, Placeholder)
+			changed = true
+		}
+	}
+	if !changed {
+		return raw
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// String summarises a large base64 string or base64 data URI and redacts
+// the credentials of a URL. Other strings are returned unchanged.
+func String(s string) string {
+	if strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+		return URL(s)
+	}
+	if len(s) <= MaxInline {
+		return s
+	}
+	if strings.HasPrefix(s, "data:") {
+		if header, payload, ok := strings.Cut(s, ","); ok && strings.HasSuffix(header, ";base64") {
+			return header + "," + Blob(decodedLen(payload))
+		}
+		return s
+	}
+	if _, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return Blob(decodedLen(s))
+	}
+	return s
+}
+
+// Value applies String and Text to every string in a decoded JSON value, in
+// place, and masks the string fields whose key is a SecretKey.
+func Value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if str, ok := e.(string); ok && SecretKey(k) {
+				v[k] = Mask(str)
+				continue
+			}
+			v[k] = Value(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = Value(e)
+		}
+		return v
+	case string:
+		return Text(String(v))
+	}
+	return v
+}
+
+// Blob is the summary of a base64 blob of n decoded bytes.
+func Blob(n int) string {
+	return fmt.Sprintf("<base64, %d bytes>", n)
+}
+
+// ParseBlob reports the decoded size of a summary made by Blob.
+func ParseBlob(s string) (int, bool) {
+	var n int
+	if !strings.HasPrefix(s, "<base64, ") {
+		return 0, false
+	}
+	if _, err := fmt.Sscanf(s, "<base64, %d bytes>", &n); err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+func decodedLen(b64 string) int {
+	return base64.StdEncoding.DecodedLen(len(b64)) - strings.Count(b64[max(0, len(b64)-2):], "=")
+}
@@ -0,0 +1,155 @@
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const redacted = "%3Credacted%3E"
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://example.com/video.mp4", "https://example.com/video.mp4"},
+		{"https://example.com/v.mp4?width=720", "https://example.com/v.mp4?width=720"},
+		{
+			"https://b.s3.amazonaws.com/v.mp4?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=AKIA%2F20240101&X-Amz-Signature=abcdef",
+			"https://b.s3.amazonaws.com/v.mp4?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=" + redacted + "&X-Amz-Signature=" + redacted,
+		},
+		{
+			"https://b.tos-cn-beijing.volces.com/v.mp4?X-Tos-Signature=abc&X-Tos-Security-Token=tok&X-Tos-Expires=3600",
+			"https://b.tos-cn-beijing.volces.com/v.mp4?X-Tos-Expires=3600&X-Tos-Security-Token=" + redacted + "&X-Tos-Signature=" + redacted,
+		},
+		{
+			"https://generativelanguage.googleapis.com/v1beta/files/abc?key=AIzaSecret&alt=media",
+			"https://generativelanguage.googleapis.com/v1beta/files/abc?alt=media&key=" + redacted,
+		},
+		{"https://cdn.example.com/a.png?Signature=xyz", "https://cdn.example.com/a.png?Signature=" + redacted},
+		{"://not a url?key=1", "://not a url?key=1"},
+	}
+	for _, tt := range tests {
+		if got := URL(tt.in); got != tt.want {
+			t.Errorf("URL(%s) =\n%s\nwant\n%s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+	tests := []struct {
+		name, value, want string
+	}{
+		{"Authorization", "Bearer sk-123", Placeholder},
+		{"authorization", "HMAC-SHA256 Credential=AK", Placeholder},
+		{"X-Goog-Api-Key", "AIza", Placeholder},
+		{"Topview-Uid", "uid", Placeholder},
+		{"Cookie", "session=1", Placeholder},
+		{"Set-Cookie", "session=1", Placeholder},
+		{"X-Security-Token", "tok", Placeholder},
+		{"X-Amz-Security-Token", "tok", Placeholder},
+		{"Content-Type", "application/json", "application/json"},
+		{"X-Date", "20240101T000000Z", "20240101T000000Z"},
+	}
+	for _, tt := range tests {
+		if got := Header(tt.name, tt.value); got != tt.want {
+			t.Errorf("Header(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSecretKey(t *testing.T) {
+	secret := []string{"Authorization", "x-goog-api-key", "api_key", "apiKey", "API-KEY", "secret", "secret_access_key",
+		"client_secret", "token", "session_token", "token_type", "password", "access_key", "access_key_id"}
+	plain := []string{"prompt", "model", "tokens", "total_tokens", "secretary", "keyframe", "key", "task_id"}
+	for _, name := range secret {
+		if !SecretKey(name) {
+			t.Errorf("SecretKey(%q) = false, want true", name)
+		}
+	}
+	for _, name := range plain {
+		if SecretKey(name) {
+			t.Errorf("SecretKey(%q) = true, want false", name)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	AddSecret("sk-registered-secret")
+	blob := strings.Repeat("QUJD", 100)
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			"secret fields",
+			`{"api_key":"AIzaSyExampleKey123","model":"veo","auth":{"secret_access_key":"abcd1234efgh5678"}}`,
+			`{"api_key":"AIza***********y123","auth":{"secret_access_key":"abcd********5678"},"model":"veo"}`,
+		},
+		{
+			"non-string secret field",
+			`{"token":{"value":"x"},"max_tokens":100}`,
+			`{"max_tokens":100,"token":{"value":"x"}}`,
+		},
+		{
+			"signed URL",
+			`{"video_url":"https://b.s3.amazonaws.com/v.mp4?X-Amz-Signature=abc"}`,
+			`{"video_url":"https://b.s3.amazonaws.com/v.mp4?X-Amz-Signature=` + redacted + `"}`,
+		},
+		{
+			"base64 in arrays",
+			`{"images":["` + blob + `","data:image/png;base64,` + blob + `","short"]}`,
+			`{"images":["<base64, 300 bytes>","data:image/png;base64,<base64, 300 bytes>","short"]}`,
+		},
+		{
+			"registered secret in text",
+			`{"error":"invalid key sk-registered-secret for https://x.test/?key=1"}`,
+			`{"error":"invalid key sk-r************cret for https://x.test/?key=` + redacted + `"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			if err := json.Unmarshal([]byte(tt.in), &v); err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			enc := json.NewEncoder(&out)
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(Value(v)); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(out.String()); got != tt.want {
+				t.Errorf("Value =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	AddSecret("AKLTregisteredaccesskey", "short")
+	tests := []struct {
+		in, want string
+	}{
+		{"request failed: HTTP 500", "request failed: HTTP 500"},
+		{"using AKLTregisteredaccesskey", "using AKLT***************skey"},
+		{"short stays", "short stays"},
+		{`GET "https://b.s3.amazonaws.com/v.mp4?X-Amz-Signature=abc" failed`, `GET "https://b.s3.amazonaws.com/v.mp4?X-Amz-Signature=` + redacted + `" failed`},
+		{"body " + strings.Repeat("A", 298) + "==", "body <base64, 223 bytes>"},
+	}
+	for _, tt := range tests {
+		if got := Text(tt.in); got != tt.want {
+			t.Errorf("Text(%.40q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseBlob(t *testing.T) {
+	if n, ok := ParseBlob(Blob(1234)); !ok || n != 1234 {
+		t.Errorf("ParseBlob(Blob(1234)) = %d, %v", n, ok)
+	}
+	for _, s := range []string{"", "<base64, many bytes>", "base64, 12 bytes"} {
+		if _, ok := ParseBlob(s); ok {
+			t.Errorf("ParseBlob(%q) succeeded", s)
+		}
+	}
+}
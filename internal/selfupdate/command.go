@@ -0,0 +1,106 @@
+package selfupdate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/llm-net/llm-api-plugin/internal/buildinfo"
+)
+
+// Usage documents the 'self-update' command shared by all CLIs.
+const Usage = `Usage:
+  self-update [--to <tag>] [--force] [--dir <dir>] [--insecure]
+                                                     Install the latest (or given) release
+  self-update --check                                Show whether a newer release exists
+  self-update --rollback [--dir <dir>]               Restore the binaries replaced by the last update
+
+Every binary found next to the running one (gemini-cli, ark-cli, topview-cli,
+jimeng-cli, llm-api) is replaced, after checking it against the release's
+SHA256SUMS manifest and the manifest's ed25519 signature against the update
+public key (built in, or LLM_API_UPDATE_PUBKEY). Without a public key, as in
+builds from source, the update is refused unless --insecure is given.`
+
+// Command runs 'self-update <args>' for the binary named tool.
+func Command(tool string, args []string) error {
+	var to, dir string
+	var check, force, rollback, insecure bool
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--to", "--dir":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value\n%s", args[i], Usage)
+			}
+			if args[i] == "--to" {
+				to = args[i+1]
+			} else {
+				dir = args[i+1]
+			}
+			i++
+		case "--check":
+			check = true
+		case "--force":
+			force = true
+		case "--rollback":
+			rollback = true
+		case "--insecure":
+			insecure = true
+		case "help", "--help", "-h":
+			fmt.Println(Usage)
+			return nil
+		default:
+			return fmt.Errorf("unknown flag: %s\n%s", args[i], Usage)
+		}
+	}
+
+	if dir == "" {
+		var err error
+		if dir, err = Dir(); err != nil {
+			return err
+		}
+	}
+
+	if rollback {
+		version, err := Rollback(dir)
+		if err != nil {
+			return err
+		}
+		if version == "" {
+			version = "the previous version"
+		}
+		fmt.Printf("Rolled back %s to %s\n", dir, version)
+		return nil
+	}
+
+	ctx := context.Background()
+	current := buildinfo.Version
+	tag := to
+	if tag == "" {
+		var err error
+		if tag, err = Latest(ctx); err != nil {
+			return err
+		}
+	} else if !strings.HasPrefix(tag, "v") {
+		tag = "v" + tag
+	}
+
+	if check {
+		fmt.Printf("Current: %s\nLatest:  %s\n", current, tag)
+		if tag != current {
+			fmt.Printf("Run '%s self-update' to install %s\n", tool, tag)
+		}
+		return nil
+	}
+	if tag == current && !force {
+		fmt.Printf("%s is already at %s (use --force to reinstall)\n", tool, tag)
+		return nil
+	}
+
+	tools, err := Update(ctx, dir, tool, tag, insecure)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s in %s from %s to %s\n", strings.Join(tools, ", "), dir, current, tag)
+	fmt.Printf("The previous binaries are kept; '%s self-update --rollback' restores them\n", tool)
+	return nil
+}
@@ -0,0 +1,202 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/llm-net/llm-api-plugin/internal/buildinfo"
+)
+
+// Files kept in the install directory. versionFile is shared with
+// scripts/setup.sh, which also checks the binaries against sumsFile.
+const (
+	versionFile = ".version"
+	sumsFile    = ".sha256sums"
+	previousDir = ".previous"
+)
+
+// Dir returns the directory of the running binary, where the tools are
+// installed.
+func Dir() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("locate the running binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return filepath.Dir(exe), nil
+}
+
+// Installed returns the version recorded in dir, or "" when none is.
+func Installed(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, versionFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// installed lists the tools present in dir, always including self so that
+// a binary installed on its own updates too.
+func installed(dir, self string) []string {
+	var tools []string
+	for _, tool := range Tools {
+		if _, err := os.Stat(filepath.Join(dir, tool+exeSuffix())); err == nil || tool == self {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+// Update installs the binaries of release tag over those in dir, returning
+// the tools updated. Nothing is replaced unless every download verified;
+// insecure allows a release whose manifest cannot be verified (see Checksums).
+func Update(ctx context.Context, dir, self, tag string, insecure bool) ([]string, error) {
+	sums, err := Checksums(ctx, tag, insecure)
+	if err != nil {
+		return nil, err
+	}
+	tools := installed(dir, self)
+	staged := map[string]string{}
+	defer func() {
+		for _, tmp := range staged {
+			os.Remove(tmp)
+		}
+	}()
+	for _, tool := range tools {
+		tmp, err := download(ctx, dir, tag, tool, sums)
+		if err != nil {
+			return nil, err
+		}
+		staged[tool] = tmp
+	}
+	old := Installed(dir)
+	if old == "" && buildinfo.Version != "dev" {
+		old = buildinfo.Version
+	}
+	return tools, install(dir, staged, old, tag)
+}
+
+// Rollback restores the binaries kept by the last update or rollback,
+// keeping the replaced ones in turn, and returns their version.
+func Rollback(dir string) (string, error) {
+	prev := filepath.Join(dir, previousDir)
+	version := Installed(prev)
+	staged := map[string]string{}
+	for _, tool := range Tools {
+		kept := filepath.Join(prev, tool+exeSuffix())
+		if _, err := os.Stat(kept); err != nil {
+			continue
+		}
+		tmp := filepath.Join(dir, fmt.Sprintf(".%s-rollback-%d", tool, os.Getpid()))
+		if err := os.Rename(kept, tmp); err != nil {
+			return "", err
+		}
+		staged[tool] = tmp
+	}
+	if len(staged) == 0 {
+		return "", fmt.Errorf("no previous version kept in %s", prev)
+	}
+	if err := install(dir, staged, Installed(dir), version); err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+// install moves the staged binaries of version into dir, each with a
+// single rename, after keeping the ones of version old they replace in the
+// previous directory. Staged files installed are removed from staged.
+func install(dir string, staged map[string]string, old, version string) error {
+	prev := filepath.Join(dir, previousDir)
+	if err := os.RemoveAll(prev); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(prev, 0755); err != nil {
+		return err
+	}
+	for tool, tmp := range staged {
+		cur := filepath.Join(dir, tool+exeSuffix())
+		if _, err := os.Stat(cur); err == nil {
+			if err := keep(cur, filepath.Join(prev, tool+exeSuffix())); err != nil {
+				return fmt.Errorf("keep previous %s: %w", tool, err)
+			}
+		}
+		if err := os.Rename(tmp, cur); err != nil {
+			return fmt.Errorf("replace %s: %w", tool, err)
+		}
+		delete(staged, tool)
+	}
+	if old != "" {
+		if err := os.WriteFile(filepath.Join(prev, versionFile), []byte(old+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+	// An unknown version must not leave the replaced one recorded
+	if version == "" {
+		if err := os.Remove(filepath.Join(dir, versionFile)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := os.WriteFile(filepath.Join(dir, versionFile), []byte(version+"\n"), 0644); err != nil {
+		return err
+	}
+	return writeSums(dir)
+}
+
+// keep preserves the binary at cur as dst. It is hard-linked, so cur stays
+// in place until the new binary replaces it; Windows cannot replace a
+// running executable, so there it is moved away instead.
+func keep(cur, dst string) error {
+	if runtime.GOOS == "windows" {
+		return os.Rename(cur, dst)
+	}
+	if err := os.Link(cur, dst); err == nil {
+		return nil
+	}
+	return copyFile(cur, dst)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// writeSums records the checksums of the installed binaries in sha256sum
+// format, for 'sha256sum -c' in scripts/setup.sh.
+func writeSums(dir string) error {
+	var b strings.Builder
+	for _, tool := range Tools {
+		name := tool + exeSuffix()
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s  %s\n", hex.EncodeToString(h.Sum(nil)), name)
+	}
+	return os.WriteFile(filepath.Join(dir, sumsFile), []byte(b.String()), 0644)
+}
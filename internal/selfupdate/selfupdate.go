@@ -0,0 +1,235 @@
+// Package selfupdate replaces the installed binaries with those of a GitHub
+// release. Every asset is checked against the release's SHA256SUMS manifest,
+// which must also carry a valid ed25519 signature (SHA256SUMS.sig) made
+// with the configured public key. The binaries next to the running one are
+// replaced together, atomically each, and the replaced ones are kept for
+// 'self-update --rollback'.
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/logging"
+)
+
+// Tools are the binaries of a release, installed side by side.
+var Tools = []string{"gemini-cli", "ark-cli", "topview-cli", "jimeng-cli", "llm-api"}
+
+// ReleaseURL is the releases page of the repository: the latest release
+// redirects from <ReleaseURL>/latest to .../tag/<tag>, and assets download
+// from <ReleaseURL>/download/<tag>/<asset>. ReleaseURLEnv points it at a
+// mirror with the same layout.
+var ReleaseURL = "https://github.com/llm-net/llm-api-plugin/releases"
+
+// ReleaseURLEnv overrides ReleaseURL.
+const ReleaseURLEnv = "LLM_API_RELEASE_URL"
+
+// PublicKey is the base64 ed25519 key the checksum manifest must be signed
+// with. Release builds set it with -ldflags -X; PublicKeyEnv overrides it.
+// When both are empty updates are refused unless insecure is requested.
+var PublicKey = ""
+
+// PublicKeyEnv overrides PublicKey.
+const PublicKeyEnv = "LLM_API_UPDATE_PUBKEY"
+
+// Manifest and Signature are the checksum assets of a release.
+const (
+	Manifest  = "SHA256SUMS"
+	Signature = "SHA256SUMS.sig"
+)
+
+var api = httpclient.NewClient("selfupdate", httpclient.Retry(3, time.Second))
+
+func releaseURL() string {
+	if u := os.Getenv(ReleaseURLEnv); u != "" {
+		return strings.TrimSuffix(u, "/")
+	}
+	return ReleaseURL
+}
+
+// Asset is the release asset of tool for the running platform, as named by
+// the release workflow: <tool>-<goos>-<goarch>[.exe].
+func Asset(tool string) string {
+	return tool + "-" + runtime.GOOS + "-" + runtime.GOARCH + exeSuffix()
+}
+
+func exeSuffix() string {
+	if runtime.GOOS == "windows" {
+		return ".exe"
+	}
+	return ""
+}
+
+// Latest resolves the tag of the latest release from the redirect of
+// <ReleaseURL>/latest, which unlike the GitHub API is not rate limited.
+func Latest(ctx context.Context) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, releaseURL()+"/latest", nil)
+	if err != nil {
+		return "", err
+	}
+	client := api.HTTP()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("resolve latest release: %w", err)
+	}
+	resp.Body.Close()
+	loc := resp.Header.Get("Location")
+	if resp.StatusCode < 300 || resp.StatusCode > 399 || loc == "" {
+		return "", fmt.Errorf("resolve latest release: HTTP %d without a redirect to the release", resp.StatusCode)
+	}
+	tag := path.Base(loc)
+	if !strings.Contains(loc, "/tag/") || tag == "" {
+		return "", fmt.Errorf("resolve latest release: unexpected redirect to %s", loc)
+	}
+	return tag, nil
+}
+
+// fetch downloads a release asset into memory.
+func fetch(ctx context.Context, tag, asset string) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, _, err := api.DownloadTo(ctx, assetURL(tag, asset), &buf, nil); err != nil {
+		return nil, fmt.Errorf("%s: %w", asset, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func assetURL(tag, asset string) string {
+	return releaseURL() + "/download/" + tag + "/" + asset
+}
+
+// Checksums returns the SHA-256 of every asset of the release by name,
+// after verifying the manifest's signature. Without a public key it fails,
+// unless insecure is set, in which case the manifest is trusted as is.
+func Checksums(ctx context.Context, tag string, insecure bool) (map[string]string, error) {
+	key, err := publicKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil && !insecure {
+		return nil, fmt.Errorf("no update public key is built in or set in %s, so %s cannot be verified; refusing to update (--insecure trusts it unsigned)", PublicKeyEnv, Manifest)
+	}
+	manifest, err := fetch(ctx, tag, Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("release %s: %w", tag, err)
+	}
+	if key != nil {
+		sig, err := fetch(ctx, tag, Signature)
+		if err != nil {
+			return nil, fmt.Errorf("manifest signature: %w", err)
+		}
+		if err := verify(key, manifest, sig); err != nil {
+			return nil, err
+		}
+		logging.Infof("Checksum manifest signature verified")
+	} else {
+		logging.Warnf("--insecure: no update public key configured; trusting %s without a signature check", Manifest)
+	}
+	return parseManifest(manifest)
+}
+
+func publicKey() (ed25519.PublicKey, error) {
+	s := PublicKey
+	if v := os.Getenv(PublicKeyEnv); v != "" {
+		s = v
+	}
+	if s == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid update public key: want %d base64 bytes", ed25519.PublicKeySize)
+	}
+	return key, nil
+}
+
+// verify checks sig, raw or base64, over manifest.
+func verify(key ed25519.PublicKey, manifest, sig []byte) error {
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil {
+			return fmt.Errorf("manifest signature: not a raw or base64 ed25519 signature")
+		}
+		sig = decoded
+	}
+	if !ed25519.Verify(key, manifest, sig) {
+		return errors.New("manifest signature does not match the update public key; refusing to update")
+	}
+	return nil
+}
+
+// parseManifest reads sha256sum output: "<hex>  <name>" per line, the name
+// optionally prefixed with '*' for binary mode.
+func parseManifest(data []byte) (map[string]string, error) {
+	sums := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		sum, name := strings.ToLower(fields[0]), path.Base(strings.TrimPrefix(fields[1], "*"))
+		if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+			return nil, fmt.Errorf("checksum manifest: malformed line %q", sc.Text())
+		}
+		sums[name] = sum
+	}
+	if len(sums) == 0 {
+		return nil, errors.New("checksum manifest is empty")
+	}
+	return sums, nil
+}
+
+// download saves the asset of tool to a temporary file in dir, checking its
+// checksum, and returns the file's path.
+func download(ctx context.Context, dir, tag, tool string, sums map[string]string) (string, error) {
+	asset := Asset(tool)
+	want, ok := sums[asset]
+	if !ok {
+		return "", fmt.Errorf("%s is not in the checksum manifest of %s", asset, tag)
+	}
+	f, err := os.CreateTemp(dir, "."+tool+"-update-*")
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	logging.Infof("Downloading %s...", asset)
+	progress := httpclient.Throttle(2*time.Second, func(n, total int64) {
+		logging.Infof("  %s: %s", asset, httpclient.FormatProgress(n, total))
+	})
+	_, _, err = api.DownloadTo(ctx, assetURL(tag, asset), io.MultiWriter(f, h), progress)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			err = fmt.Errorf("checksum mismatch for %s: got %s, want %s", asset, got, want)
+		}
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0755)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,151 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testManifest = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08  llm-api-linux-amd64\n"
+
+func testKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, priv
+}
+
+func TestVerify(t *testing.T) {
+	pub, priv := testKey(t)
+	other, _ := testKey(t)
+	manifest := []byte(testManifest)
+	sig := ed25519.Sign(priv, manifest)
+	b64 := base64.StdEncoding.EncodeToString(sig)
+
+	tests := []struct {
+		name     string
+		key      ed25519.PublicKey
+		manifest []byte
+		sig      []byte
+		ok       bool
+	}{
+		{"raw signature", pub, manifest, sig, true},
+		{"base64 signature", pub, manifest, []byte(b64), true},
+		{"base64 with newline", pub, manifest, []byte(b64 + "\n"), true},
+		{"other key", other, manifest, sig, false},
+		{"tampered manifest", pub, []byte(strings.Replace(testManifest, "9f86", "0f86", 1)), sig, false},
+		{"flipped signature", pub, manifest, append([]byte{sig[0] ^ 1}, sig[1:]...), false},
+		{"missing signature", pub, manifest, nil, false},
+		{"garbage signature", pub, manifest, []byte("not a signature"), false},
+		{"short base64 signature", pub, manifest, []byte(b64[:20]), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verify(tt.key, tt.manifest, tt.sig)
+			if tt.ok && err != nil {
+				t.Errorf("verify: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("verify accepted an invalid signature")
+			}
+		})
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	pub, priv := testKey(t)
+	_, otherPriv := testKey(t)
+	good := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(testManifest)))
+	bad := base64.StdEncoding.EncodeToString(ed25519.Sign(otherPriv, []byte(testManifest)))
+
+	tests := []struct {
+		name     string
+		key      string
+		sig      string // "" serves no signature asset
+		insecure bool
+		ok       bool
+	}{
+		{"good signature", base64.StdEncoding.EncodeToString(pub), good, false, true},
+		{"bad signature", base64.StdEncoding.EncodeToString(pub), bad, false, false},
+		{"missing signature", base64.StdEncoding.EncodeToString(pub), "", false, false},
+		{"bad signature with insecure", base64.StdEncoding.EncodeToString(pub), bad, true, false},
+		{"no key", "", good, false, false},
+		{"no key with insecure", "", "", true, true},
+		{"invalid key", "bm90IGEga2V5", good, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/"+Manifest):
+					w.Write([]byte(testManifest))
+				case strings.HasSuffix(r.URL.Path, "/"+Signature) && tt.sig != "":
+					w.Write([]byte(tt.sig))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+			t.Setenv(ReleaseURLEnv, srv.URL)
+			t.Setenv(PublicKeyEnv, tt.key)
+
+			sums, err := Checksums(context.Background(), "v1.0.0", tt.insecure)
+			if !tt.ok {
+				if err == nil {
+					t.Error("Checksums accepted the manifest")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Checksums: %v", err)
+			}
+			if got := sums["llm-api-linux-amd64"]; got != "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08" {
+				t.Errorf("checksum = %q", got)
+			}
+		})
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	sum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	tests := []struct {
+		name     string
+		manifest string
+		want     map[string]string
+	}{
+		{"text mode", sum + "  ark-cli-linux-amd64\n", map[string]string{"ark-cli-linux-amd64": sum}},
+		{"binary mode", sum + " *dist/ark-cli-linux-amd64\n", map[string]string{"ark-cli-linux-amd64": sum}},
+		{"upper case", strings.ToUpper(sum) + "  a\n\nignored line here\n", map[string]string{"a": sum}},
+		{"malformed sum", "abc  a\n", nil},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseManifest([]byte(tt.manifest))
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("parseManifest = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, sum := range tt.want {
+				if got[name] != sum {
+					t.Errorf("%s = %q, want %q", name, got[name], sum)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("parseManifest = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+)
+
+// ConfigUsage documents the 'config storage' subcommands shared by all CLIs.
+const ConfigUsage = `Usage:
+  config storage set [--endpoint <url>] [--region <region>] [--bucket <name>] [--prefix <prefix>]
+                     [--path-style] [--ttl <duration>] [--publish] [--publish-ttl <duration>] [--public-url <url>]
+  config storage set-keys <AK> [<SECRET_KEY>]
+  config storage show
+  config storage test
+  config storage clear
+
+Endpoints: Volc TOS https://tos-s3-<region>.volces.com, AWS https://s3.<region>.amazonaws.com,
+MinIO http://localhost:9000 (with --path-style). Durations look like 30m, 1h, 168h.`
+
+// ConfigCommand runs 'config storage <args>' against the shared config file.
+func ConfigCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", ConfigUsage)
+	}
+	cfg, err := config.LoadOrCreate()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "set":
+		sc := cfg.Storage
+		if sc == nil {
+			sc = &config.StorageConfig{}
+		}
+		rest := args[1:]
+		value := func(i *int) string {
+			*i++
+			if *i < len(rest) {
+				return rest[*i]
+			}
+			return ""
+		}
+		for i := 0; i < len(rest); i++ {
+			switch rest[i] {
+			case "--endpoint":
+				sc.Endpoint = value(&i)
+			case "--region":
+				sc.Region = value(&i)
+			case "--bucket":
+				sc.Bucket = value(&i)
+			case "--prefix":
+				sc.Prefix = value(&i)
+			case "--path-style":
+				sc.PathStyle = true
+			case "--ttl":
+				sc.TTL = value(&i)
+			case "--publish":
+				sc.Publish = true
+			case "--publish-ttl":
+				sc.PublishTTL = value(&i)
+			case "--public-url":
+				sc.PublicURL = value(&i)
+			default:
+				return fmt.Errorf("unknown flag: %s\n%s", rest[i], ConfigUsage)
+			}
+		}
+		for _, d := range []string{sc.TTL, sc.PublishTTL} {
+			if _, err := time.ParseDuration(d); d != "" && err != nil {
+				return fmt.Errorf("invalid duration %q", d)
+			}
+		}
+		if sc.Endpoint != "" && !strings.HasPrefix(sc.Endpoint, "http://") && !strings.HasPrefix(sc.Endpoint, "https://") {
+			sc.Endpoint = "https://" + sc.Endpoint
+		}
+		cfg.Storage = sc
+		if err := config.Save(cfg); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Printf("Object storage saved to %s\n", config.Path())
+	case "set-keys":
+		if len(args) < 2 {
+			return fmt.Errorf("%s", ConfigUsage)
+		}
+		sk, err := config.SecretArg(args, 2, "Object storage SecretAccessKey: ")
+		if err != nil {
+			return err
+		}
+		if _, err := cfg.SetSecret(config.ServiceS3, config.FieldAccessKeyID, args[1]); err != nil {
+			return err
+		}
+		where, err := cfg.SetSecret(config.ServiceS3, config.FieldSecretAccessKey, sk)
+		if err != nil {
+			return err
+		}
+		if err := config.Save(cfg); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Printf("Object storage access keys saved to %s\n", cfg.Location(where))
+	case "show":
+		sc := cfg.Storage
+		if sc == nil {
+			fmt.Println("Object storage: not configured")
+			return nil
+		}
+		style := "virtual-hosted"
+		if sc.PathStyle {
+			style = "path"
+		}
+		fmt.Printf("Endpoint: %s\nRegion: %s\nBucket: %s\nPrefix: %s\nAddressing: %s\n", sc.Endpoint, sc.Region, sc.Bucket, sc.Prefix, style)
+		fmt.Printf("Staged input TTL: %s\n", ttl(sc.TTL, DefaultTTL))
+		fmt.Printf("Publish outputs by default: %t\n", sc.Publish)
+		if sc.PublicURL != "" {
+			fmt.Printf("Public URL: %s\n", sc.PublicURL)
+		} else {
+			fmt.Printf("Published URL TTL: %s\n", ttl(sc.PublishTTL, DefaultPublishTTL))
+		}
+		ak, akSource := config.Lookup(cfg, config.ServiceS3, config.FieldAccessKeyID, AccessKeyEnv)
+		if ak == "" {
+			fmt.Println("Access keys: not set")
+		} else {
+			_, skSource := config.Lookup(cfg, config.ServiceS3, config.FieldSecretAccessKey, SecretKeyEnv)
+			if len(ak) > 8 {
+				ak = ak[:4] + "..." + ak[len(ak)-4:]
+			}
+			fmt.Printf("AccessKeyID: %s (source: %s)\nSecretAccessKey: (source: %s)\n", ak, akSource, skSource)
+		}
+	case "test":
+		return test(cfg)
+	case "clear":
+		cfg.Storage = nil
+		if err := config.Save(cfg); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Println("Object storage settings removed (access keys are kept)")
+	default:
+		return fmt.Errorf("unknown storage command: %s\n%s", args[0], ConfigUsage)
+	}
+	return nil
+}
+
+// test stages a small file, reads it back through its presigned URL and
+// deletes it.
+func test(cfg *config.Config) error {
+	f, err := os.CreateTemp("", "llm-api-storage-test-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	content := "llm-api-plugin storage test " + time.Now().Format(time.RFC3339)
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+
+	s := NewStager(cfg)
+	url, err := s.Stage(f.Name())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Uploaded %s\n", filepath.Base(f.Name()))
+	defer func() {
+		if err := s.Cleanup(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			fmt.Println("Deleted test object")
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), httpclient.DefaultTimeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("fetch presigned URL: %w", err)
+	}
+	resp, err := api.Do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("fetch presigned URL: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != content {
+		return fmt.Errorf("fetch presigned URL: HTTP %d: %s", resp.StatusCode, string(body))
+	}
+	fmt.Println("Presigned URL works")
+	return nil
+}
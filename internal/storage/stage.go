@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+)
+
+// Stager uploads local input files for providers that only accept URLs and
+// deletes them again once the task has ended. A nil *Stager is valid and
+// stages nothing.
+type Stager struct {
+	// Progress, if set, receives the bytes sent so far and the size of the
+	// file being staged.
+	Progress func(sent, total int64)
+
+	cfg    *config.Config
+	client *Client
+	keys   []string
+}
+
+// NewStager returns a Stager for the bucket configured in cfg. Storage is
+// only required once a file is staged.
+func NewStager(cfg *config.Config) *Stager {
+	return &Stager{cfg: cfg}
+}
+
+// Stage uploads the file at path and returns a presigned URL valid for the
+// configured TTL.
+func (s *Stager) Stage(path string) (string, error) {
+	return s.stage(path, func(key string) error {
+		return s.client.PutFile(key, path, s.Progress)
+	})
+}
+
+// StageData uploads in-memory content, such as stdin or a data URI, under
+// name and returns a presigned URL valid for the configured TTL.
+func (s *Stager) StageData(name string, data []byte) (string, error) {
+	return s.stage(name, func(key string) error {
+		var body io.Reader = bytes.NewReader(data)
+		if s.Progress != nil {
+			body = httpclient.ProgressReader(body, int64(len(data)), s.Progress)
+		}
+		return s.client.Put(key, body, int64(len(data)), ContentType(name))
+	})
+}
+
+func (s *Stager) stage(name string, put func(key string) error) (string, error) {
+	if err := s.connect(); err != nil {
+		return "", fmt.Errorf("cannot stage %s: %w", name, err)
+	}
+	key := s.client.Key("staging", time.Now().Format("20060102"), randomName(name))
+	if err := put(key); err != nil {
+		return "", fmt.Errorf("upload %s: %w", name, err)
+	}
+	s.keys = append(s.keys, key)
+	return s.client.Presign(key, ttl(s.client.cfg.TTL, DefaultTTL)), nil
+}
+
+func (s *Stager) connect() error {
+	if s.client != nil {
+		return nil
+	}
+	c, err := New(s.cfg)
+	if err != nil {
+		return err
+	}
+	s.client = c
+	return nil
+}
+
+// Keep uploads the file at path under the object key joined from parts and
+// returns the key. Unlike Stage, Cleanup leaves the object in place, so that
+// it can be presigned again later (see assets).
+func (s *Stager) Keep(path string, parts ...string) (string, error) {
+	if err := s.connect(); err != nil {
+		return "", fmt.Errorf("cannot stage %s: %w", path, err)
+	}
+	key := s.client.Key(parts...)
+	if err := s.client.PutFile(key, path, s.Progress); err != nil {
+		return "", fmt.Errorf("upload %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// Presign returns a presigned URL of a kept object, valid for the
+// configured TTL, and when it expires. Keep or Bucket must have succeeded.
+func (s *Stager) Presign(key string) (string, time.Time) {
+	d := ttl(s.client.cfg.TTL, DefaultTTL)
+	return s.client.Presign(key, d), time.Now().Add(d)
+}
+
+// Bucket identifies the configured bucket, e.g. to cache uploads per
+// bucket.
+func (s *Stager) Bucket() (string, error) {
+	if err := s.connect(); err != nil {
+		return "", err
+	}
+	return s.client.endpoint.Host + "/" + s.client.cfg.Bucket, nil
+}
+
+// Check reports whether staging is configured, without network access.
+func (s *Stager) Check() error {
+	_, err := New(s.cfg)
+	return err
+}
+
+// Staged reports whether any file has been staged.
+func (s *Stager) Staged() bool {
+	return s != nil && len(s.keys) > 0
+}
+
+// Keys returns the object keys of the staged files, for recording with a
+// task that another run may resume.
+func (s *Stager) Keys() []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s.keys...)
+}
+
+// Adopt takes over files staged by an earlier run, such as an interrupted
+// one whose task is being resumed, so that Cleanup deletes them too.
+func (s *Stager) Adopt(keys ...string) {
+	s.keys = append(s.keys, keys...)
+}
+
+// Cleanup deletes every staged file. It is safe to call more than once.
+func (s *Stager) Cleanup() error {
+	if s == nil || len(s.keys) == 0 {
+		return nil
+	}
+	if err := s.connect(); err != nil {
+		return fmt.Errorf("delete staged files: %w", err)
+	}
+	var errs []error
+	for _, key := range s.keys {
+		if err := s.client.Delete(key); err != nil {
+			errs = append(errs, fmt.Errorf("delete %s: %w", key, err))
+		}
+	}
+	s.keys = nil
+	return errors.Join(errs...)
+}
+
+// Publish uploads an output file and returns a shareable URL: the public URL
+// when public_url is configured, otherwise a presigned URL valid for
+// publish_ttl.
+func Publish(cfg *config.Config, path string) (string, error) {
+	c, err := New(cfg)
+	if err != nil {
+		return "", err
+	}
+	key := c.Key("outputs", time.Now().Format("20060102"), randomName(path))
+	if err := c.PutFile(key, path, nil); err != nil {
+		return "", fmt.Errorf("upload %s: %w", path, err)
+	}
+	if u := c.PublicURL(key); u != "" {
+		return u, nil
+	}
+	return c.Presign(key, ttl(c.cfg.PublishTTL, DefaultPublishTTL)), nil
+}
+
+// PublishEnabled reports whether outputs are published without --publish.
+func PublishEnabled(cfg *config.Config) bool {
+	return cfg.Storage != nil && cfg.Storage.Publish
+}
+
+// randomName prefixes a file's base name with random hex so that keys are
+// unique and hard to guess.
+func randomName(path string) string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b) + "-" + filepath.Base(path)
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+)
+
+// fakeBucket is a path-style S3 endpoint keeping objects in memory.
+type fakeBucket struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		b.objects[key] = string(data)
+	case http.MethodDelete:
+		delete(b.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unexpected "+r.Method, http.StatusMethodNotAllowed)
+	}
+}
+
+func testStorage(t *testing.T) (*config.Config, *fakeBucket) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(AccessKeyEnv, "AKIDEXAMPLE")
+	t.Setenv(SecretKeyEnv, "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	b := &fakeBucket{objects: map[string]string{}}
+	srv := httptest.NewServer(b)
+	t.Cleanup(srv.Close)
+	return &config.Config{Storage: &config.StorageConfig{Endpoint: srv.URL, Bucket: "bucket", PathStyle: true}}, b
+}
+
+func TestStagerAdopt(t *testing.T) {
+	cfg, b := testStorage(t)
+
+	// An interrupted run stages an input and records its key with the task
+	first := NewStager(cfg)
+	if _, err := first.StageData("clip.mp4", []byte("video")); err != nil {
+		t.Fatal(err)
+	}
+	keys := first.Keys()
+	if len(keys) != 1 || b.objects[keys[0]] != "video" {
+		t.Fatalf("staged %v, bucket holds %v", keys, b.objects)
+	}
+
+	// The run resuming the task deletes it without staging anything itself
+	resumed := NewStager(cfg)
+	resumed.Adopt(keys...)
+	if !resumed.Staged() {
+		t.Fatal("Staged = false after Adopt")
+	}
+	if err := resumed.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.objects) != 0 {
+		t.Errorf("bucket still holds %v after Cleanup", b.objects)
+	}
+	if resumed.Staged() {
+		t.Error("Staged = true after Cleanup")
+	}
+}
+
+func TestStagerCleanupNothing(t *testing.T) {
+	// Without staged files Cleanup needs no storage configuration
+	if err := NewStager(&config.Config{}).Cleanup(); err != nil {
+		t.Errorf("Cleanup: %v", err)
+	}
+	var s *Stager
+	if err := s.Cleanup(); err != nil || s.Keys() != nil {
+		t.Errorf("nil Stager: Cleanup = %v, Keys = %v", err, s.Keys())
+	}
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+)
+
+// Default presigned URL lifetimes.
+const (
+	DefaultTTL        = time.Hour
+	DefaultPublishTTL = maxPresignExpiry
+)
+
+// Env vars overriding the bucket access keys.
+const (
+	AccessKeyEnv = "LLM_API_S3_ACCESS_KEY_ID"
+	SecretKeyEnv = "LLM_API_S3_SECRET_ACCESS_KEY"
+)
+
+// api sends the object store requests. Deletes are retried; uploads stream
+// their body and are not.
+var api = httpclient.NewClient("storage", httpclient.Retry(3, time.Second))
+
+// Client talks to one bucket of an S3-compatible object store.
+type Client struct {
+	cfg      config.StorageConfig
+	endpoint *url.URL
+	signer   signer
+}
+
+// ErrNotConfigured explains how to set up object storage.
+var ErrNotConfigured = errors.New("object storage not configured; run 'config storage set --endpoint <url> --region <region> --bucket <name>' and 'config storage set-keys <AK>'")
+
+// New returns a Client for the bucket configured in cfg, using the access
+// keys of the active profile.
+func New(cfg *config.Config) (*Client, error) {
+	sc := cfg.Storage
+	if sc == nil || sc.Endpoint == "" || sc.Bucket == "" {
+		return nil, ErrNotConfigured
+	}
+	endpoint, err := url.Parse(sc.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid storage endpoint %q", sc.Endpoint)
+	}
+	ak, sk := config.ResolveAccessKeys(cfg, config.ServiceS3, AccessKeyEnv, SecretKeyEnv)
+	if ak == "" || sk == "" {
+		return nil, fmt.Errorf("object storage access keys not set; export %s/%s or run 'config storage set-keys <AK>'", AccessKeyEnv, SecretKeyEnv)
+	}
+	region := sc.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &Client{
+		cfg:      *sc,
+		endpoint: endpoint,
+		signer:   signer{accessKey: ak, secretKey: sk, region: region},
+	}, nil
+}
+
+// Key joins the configured prefix and parts into an object key.
+func (c *Client) Key(parts ...string) string {
+	key := strings.Join(parts, "/")
+	if prefix := strings.Trim(c.cfg.Prefix, "/"); prefix != "" {
+		key = prefix + "/" + key
+	}
+	return key
+}
+
+// objectURL returns the unsigned URL of key.
+func (c *Client) objectURL(key string) *url.URL {
+	u := *c.endpoint
+	u.RawQuery = ""
+	base := strings.TrimSuffix(u.Path, "/")
+	if c.cfg.PathStyle {
+		base += "/" + c.cfg.Bucket
+	} else {
+		u.Host = c.cfg.Bucket + "." + u.Host
+	}
+	u.Path = base + "/" + key
+	u.RawPath = base + "/" + escapeKey(key)
+	return &u
+}
+
+// escapeKey percent-encodes each segment of an object key.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = uriEncode(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// PutFile uploads a local file to key, streaming its content. progress, if
+// set, receives the bytes sent so far and the file size.
+func (c *Client) PutFile(key, path string, progress func(sent, total int64)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var body io.Reader = f
+	if progress != nil {
+		body = httpclient.ProgressReader(f, info.Size(), progress)
+	}
+	return c.Put(key, body, info.Size(), ContentType(path))
+}
+
+// Put uploads size bytes from body to key.
+func (c *Client) Put(key string, body io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequest(http.MethodPut, c.objectURL(key).String(), body)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	// Uploads may be large, so no overall timeout applies.
+	return c.do(context.Background(), req)
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (c *Client) Delete(key string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.objectURL(key).String(), nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), httpclient.DefaultTimeout)
+	defer cancel()
+	return c.do(ctx, req)
+}
+
+// Presign returns a URL granting GET access to key for ttl.
+func (c *Client) Presign(key string, ttl time.Duration) string {
+	return c.signer.presign(http.MethodGet, c.objectURL(key), ttl, time.Now())
+}
+
+// PublicURL returns the URL of key under the configured public base URL, or
+// "" when there is none.
+func (c *Client) PublicURL(key string) string {
+	if c.cfg.PublicURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(c.cfg.PublicURL, "/") + "/" + escapeKey(key)
+}
+
+func (c *Client) do(ctx context.Context, req *http.Request) error {
+	c.signer.sign(req, time.Now())
+	resp, err := api.Do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 == 2 || req.Method == http.MethodDelete && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	var s3err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(body, &s3err) == nil && s3err.Code != "" {
+		return fmt.Errorf("HTTP %d: %s: %s", resp.StatusCode, s3err.Code, s3err.Message)
+	}
+	return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+}
+
+// ContentType guesses a file's MIME type from its extension.
+func ContentType(path string) string {
+	if t := mime.TypeByExtension(strings.ToLower(filepath.Ext(path))); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// ttl parses a configured duration, falling back to def.
+func ttl(value string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
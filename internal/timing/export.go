@@ -0,0 +1,247 @@
+package timing
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/config"
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+)
+
+// Environment variables overriding the metrics config.
+const (
+	TextfileEnv = "LLM_API_METRICS_TEXTFILE"
+	OTLPEnv     = "LLM_API_METRICS_OTLP"
+)
+
+// Export sends the timings of a finished generation to the exporters
+// configured in cfg or the environment. outcome is success, failure or
+// timeout. Without exporters it does nothing.
+func (t *Timings) Export(cfg *config.Config, outcome string) error {
+	if t == nil {
+		return nil
+	}
+	textfile, otlp := os.Getenv(TextfileEnv), os.Getenv(OTLPEnv)
+	if cfg != nil && cfg.Metrics != nil {
+		if textfile == "" {
+			textfile = cfg.Metrics.Textfile
+		}
+		if otlp == "" {
+			otlp = cfg.Metrics.OTLP
+		}
+	}
+	r := t.Report()
+	var errs []error
+	if textfile != "" {
+		if err := t.writeTextfile(textfile, r, outcome); err != nil {
+			errs = append(errs, fmt.Errorf("prometheus textfile: %w", err))
+		}
+	}
+	if otlp != "" {
+		if err := t.sendOTLP(otlp, r, outcome); err != nil {
+			errs = append(errs, fmt.Errorf("OTLP: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Prometheus metrics written to the textfile. Counters accumulate across
+// runs; the file is read back and rewritten on every export.
+var promMetrics = []struct{ name, kind, help string }{
+	{"llm_api_generations_total", "counter", "Finished generations by outcome."},
+	{"llm_api_generation_seconds_total", "counter", "Total wall time of finished generations."},
+	{"llm_api_phase_seconds_total", "counter", "Total time spent in each phase."},
+	{"llm_api_phase_runs_total", "counter", "Number of times each phase ran."},
+	{"llm_api_phase_bytes_total", "counter", "Bytes transferred by each phase."},
+	{"llm_api_last_phase_seconds", "gauge", "Duration of each phase in the last generation."},
+	{"llm_api_last_generation_timestamp_seconds", "gauge", "Unix time of the last finished generation."},
+}
+
+// textfileMu serialises exports within a process, such as concurrent jobs of
+// 'llm-api serve'.
+var textfileMu sync.Mutex
+
+func (t *Timings) writeTextfile(path string, r *Report, outcome string) error {
+	textfileMu.Lock()
+	defer textfileMu.Unlock()
+
+	series, err := readTextfile(path)
+	if err != nil {
+		return err
+	}
+	run := labels("tool", t.Tool, "model", t.Model, "outcome", outcome)
+	series["llm_api_generations_total"+run]++
+	series["llm_api_generation_seconds_total"+run] += float64(r.TotalMS) / 1000
+	series["llm_api_last_generation_timestamp_seconds"+run] = float64(time.Now().Unix())
+	for _, p := range r.Phases {
+		l := labels("tool", t.Tool, "model", t.Model, "phase", p.Name)
+		series["llm_api_phase_seconds_total"+l] += float64(p.DurationMS) / 1000
+		series["llm_api_phase_runs_total"+l]++
+		if p.Bytes > 0 {
+			series["llm_api_phase_bytes_total"+l] += float64(p.Bytes)
+		}
+		series["llm_api_last_phase_seconds"+l] = float64(p.DurationMS) / 1000
+	}
+
+	keys := make([]string, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, m := range promMetrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, k := range keys {
+			if strings.HasPrefix(k, m.name+"{") {
+				fmt.Fprintf(&b, "%s %s\n", k, strconv.FormatFloat(series[k], 'f', -1, 64))
+			}
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// node_exporter may read the file at any time, so replace it atomically.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readTextfile parses the samples of a textfile written by writeTextfile.
+// A missing file yields no samples.
+func readTextfile(path string) (map[string]float64, error) {
+	series := map[string]float64{}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return series, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			continue
+		}
+		if v, err := strconv.ParseFloat(line[i+1:], 64); err == nil {
+			series[line[:i]] = v
+		}
+	}
+	return series, sc.Err()
+}
+
+// labels formats Prometheus labels from name/value pairs.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(pairs[i+1])
+		fmt.Fprintf(&b, `%s="%s"`, pairs[i], v)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// OTLP/JSON types, limited to gauges.
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpDataPoint struct {
+	Attributes   []otlpAttribute `json:"attributes"`
+	TimeUnixNano string          `json:"timeUnixNano"`
+	AsDouble     float64         `json:"asDouble"`
+}
+
+type otlpMetric struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Unit        string `json:"unit"`
+	Gauge       struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	} `json:"gauge"`
+}
+
+func attributes(pairs ...string) []otlpAttribute {
+	var attrs []otlpAttribute
+	for i := 0; i+1 < len(pairs); i += 2 {
+		a := otlpAttribute{Key: pairs[i]}
+		a.Value.StringValue = pairs[i+1]
+		attrs = append(attrs, a)
+	}
+	return attrs
+}
+
+func (t *Timings) sendOTLP(endpoint string, r *Report, outcome string) error {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	gauge := func(name, description, unit string) *otlpMetric {
+		return &otlpMetric{Name: name, Description: description, Unit: unit}
+	}
+	total := gauge("llm_api.generation.duration", "Wall time of a finished generation.", "s")
+	total.Gauge.DataPoints = []otlpDataPoint{{
+		Attributes:   attributes("tool", t.Tool, "model", t.Model, "outcome", outcome),
+		TimeUnixNano: now,
+		AsDouble:     float64(r.TotalMS) / 1000,
+	}}
+	phases := gauge("llm_api.phase.duration", "Time spent in a phase of a generation.", "s")
+	transfers := gauge("llm_api.phase.bytes", "Bytes transferred by a phase of a generation.", "By")
+	for _, p := range r.Phases {
+		attrs := attributes("tool", t.Tool, "model", t.Model, "outcome", outcome, "phase", p.Name)
+		phases.Gauge.DataPoints = append(phases.Gauge.DataPoints,
+			otlpDataPoint{Attributes: attrs, TimeUnixNano: now, AsDouble: float64(p.DurationMS) / 1000})
+		if p.Bytes > 0 {
+			transfers.Gauge.DataPoints = append(transfers.Gauge.DataPoints,
+				otlpDataPoint{Attributes: attrs, TimeUnixNano: now, AsDouble: float64(p.Bytes)})
+		}
+	}
+	metrics := []*otlpMetric{total, phases}
+	if len(transfers.Gauge.DataPoints) > 0 {
+		metrics = append(metrics, transfers)
+	}
+
+	payload := map[string]interface{}{
+		"resourceMetrics": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": attributes("service.name", "llm-api-plugin"),
+			},
+			"scopeMetrics": []interface{}{map[string]interface{}{
+				"scope":   map[string]string{"name": "llm-api-plugin"},
+				"metrics": metrics,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	respBody, status, err := httpclient.PostJSON(endpoint, nil, body)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("HTTP %d: %s", status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
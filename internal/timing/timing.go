@@ -0,0 +1,253 @@
+// Package timing measures the phases of a generation (uploads, task submit,
+// time spent in each remote status, download, ...) so slow runs can be
+// attributed, and reports them as a summary, as JSON and to metrics
+// exporters.
+package timing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+)
+
+// Phase names shared by the CLIs. Upload and status phases are built with
+// Upload and status names.
+const (
+	Stage    = "stage"
+	Submit   = "submit"
+	Generate = "generate"
+	Download = "download"
+	Publish  = "publish"
+	// Wait is time spent polling before any status was reported.
+	Wait = "wait"
+)
+
+// Upload names one step of uploading a media input, e.g. upload/image/put.
+func Upload(media, step string) string {
+	return "upload/" + media + "/" + step
+}
+
+func statusPhase(status string) string {
+	return "status/" + status
+}
+
+// Timings collects the phases of one generation. A nil *Timings is valid and
+// records nothing, so provider code can be instrumented unconditionally.
+type Timings struct {
+	Tool  string
+	Model string
+	Start time.Time
+
+	mu          sync.Mutex
+	phases      []phase
+	keys        map[string]string
+	waiting     bool
+	status      string
+	statusSince time.Time
+}
+
+type phase struct {
+	name     string
+	duration time.Duration
+	bytes    int64
+}
+
+// New starts timing a generation.
+func New(tool, model string) *Timings {
+	return &Timings{Tool: tool, Model: model, Start: time.Now()}
+}
+
+// Measure records a phase that began at start and ends now. bytes is the
+// amount of data transferred, or 0.
+func (t *Timings) Measure(name string, start time.Time, bytes int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phases = append(t.phases, phase{name: name, duration: time.Since(start), bytes: bytes})
+}
+
+// Wait starts attributing time to the remote status of a submitted task.
+func (t *Timings) Wait() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.waiting, t.status, t.statusSince = true, "", time.Now()
+}
+
+// Status records a poll that found the task in status. The time since the
+// previous change is attributed to the previous status, or to this one for
+// the first poll. Time spent in the same status is summed.
+func (t *Timings) Status(status string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.waiting {
+		t.waiting, t.statusSince = true, time.Now()
+	}
+	switch {
+	case t.status == "":
+		t.status = status
+	case status != t.status:
+		t.addStatus(time.Now())
+		t.status = status
+	}
+}
+
+// EndWait closes the current status once the task has finished.
+func (t *Timings) EndWait() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.waiting {
+		t.addStatus(time.Now())
+		t.waiting, t.status = false, ""
+	}
+}
+
+// addStatus adds the time since statusSince to the current status phase.
+func (t *Timings) addStatus(now time.Time) {
+	name := Wait
+	if t.status != "" {
+		name = statusPhase(t.status)
+	}
+	d := now.Sub(t.statusSince)
+	t.statusSince = now
+	for i := range t.phases {
+		if t.phases[i].name == name {
+			t.phases[i].duration += d
+			return
+		}
+	}
+	t.phases = append(t.phases, phase{name: name, duration: d})
+}
+
+// Report is the JSON form of Timings.
+type Report struct {
+	TotalMS int64         `json:"total_ms"`
+	Phases  []PhaseReport `json:"phases"`
+	// Keys maps each service to the masked API key or AccessKeyID used.
+	Keys map[string]string `json:"keys,omitempty"`
+}
+
+// PhaseReport is one phase of a Report.
+type PhaseReport struct {
+	Name        string `json:"name"`
+	DurationMS  int64  `json:"duration_ms"`
+	Bytes       int64  `json:"bytes,omitempty"`
+	BytesPerSec int64  `json:"bytes_per_sec,omitempty"`
+}
+
+// Key records the masked credential used for service.
+func (t *Timings) Key(service, masked string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.keys == nil {
+		t.keys = map[string]string{}
+	}
+	t.keys[service] = masked
+}
+
+// Report returns the phases recorded so far, or nil for a nil *Timings.
+func (t *Timings) Report() *Report {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := &Report{TotalMS: time.Since(t.Start).Milliseconds(), Phases: []PhaseReport{}}
+	if len(t.keys) > 0 {
+		r.Keys = maps.Clone(t.keys)
+	}
+	for _, p := range t.phases {
+		pr := PhaseReport{Name: p.name, DurationMS: p.duration.Milliseconds(), Bytes: p.bytes}
+		if p.bytes > 0 && p.duration > 0 {
+			pr.BytesPerSec = int64(float64(p.bytes) / p.duration.Seconds())
+		}
+		r.Phases = append(r.Phases, pr)
+	}
+	return r
+}
+
+// Summary writes a table of the phases, with throughput for transfers, and
+// the HTTP requests each client sent.
+func (t *Timings) Summary(w io.Writer) {
+	r := t.Report()
+	if r == nil {
+		return
+	}
+	width := 0
+	for _, p := range r.Phases {
+		width = max(width, len(p.Name))
+	}
+	fmt.Fprintf(w, "Timings (total %s):\n", ms(r.TotalMS))
+	for _, p := range r.Phases {
+		fmt.Fprintf(w, "  %-*s  %8s", width, p.Name, ms(p.DurationMS))
+		if p.Bytes > 0 {
+			fmt.Fprintf(w, "  %s", byteSize(p.Bytes))
+			if p.BytesPerSec > 0 {
+				fmt.Fprintf(w, ", %s/s", byteSize(p.BytesPerSec))
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	for _, service := range slices.Sorted(maps.Keys(r.Keys)) {
+		fmt.Fprintf(w, "  key %s: %s\n", service, r.Keys[service])
+	}
+	for _, s := range httpclient.Stats() {
+		fmt.Fprintf(w, "  http %s: %d requests", s.Client, s.Requests)
+		if s.Failed > 0 {
+			fmt.Fprintf(w, " (%d failed)", s.Failed)
+		}
+		fmt.Fprintf(w, ", %s waiting for responses\n", ms(s.Duration.Milliseconds()))
+	}
+}
+
+func ms(n int64) string {
+	d := time.Duration(n) * time.Millisecond
+	if d < time.Second {
+		return d.String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+func byteSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// SidecarPath returns the path of the timings file saved next to output.
+func SidecarPath(output string) string {
+	return output + ".timings.json"
+}
+
+// Save writes the report as indented JSON to path.
+func (t *Timings) Save(path string) error {
+	data, err := json.MarshalIndent(t.Report(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,159 @@
+// Package volc sends Volcengine OpenAPI requests whose JSON body is too
+// large to hold in memory, such as inline base64 media. The SDK client
+// marshals and signs the whole body as one string; here the body is hashed
+// in one pass over its content and streamed in a second.
+package volc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/volcengine/volc-sdk-golang/base"
+)
+
+const (
+	algorithm  = "HMAC-SHA256"
+	dateFormat = "20060102T150405Z"
+)
+
+// PostJSON sends body to the api action of c and decodes the JSON response,
+// like the SDK's CVSubmitTask and friends: business errors are returned in
+// the response, not as an error. progress, if set, receives the bytes sent
+// so far and the body length.
+func PostJSON(ctx context.Context, c *base.Client, api string, body *httpclient.JSONBody, progress func(sent, total int64)) (map[string]interface{}, int, error) {
+	info := c.ApiInfoList[api]
+	if info == nil {
+		return nil, 500, fmt.Errorf("unknown API %s", api)
+	}
+	u := url.URL{
+		Scheme:   c.ServiceInfo.Scheme,
+		Host:     c.ServiceInfo.Host,
+		Path:     info.Path,
+		RawQuery: info.Query.Encode(),
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(info.Method), u.String(), nil)
+	if err != nil {
+		return nil, 500, fmt.Errorf("create request: %w", err)
+	}
+	for _, h := range []http.Header{c.ServiceInfo.Header, info.Header} {
+		for name, values := range h {
+			req.Header[name] = values
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", base.SDKName+"/"+base.SDKVersion)
+
+	hash, err := body.SHA256()
+	if err != nil {
+		return nil, 500, fmt.Errorf("read request body: %w", err)
+	}
+	sign(req, c.ServiceInfo.Credentials, hash, time.Now())
+
+	req.ContentLength = body.Len()
+	req.GetBody = func() (io.ReadCloser, error) { return body.Open(), nil }
+	req.Body = body.Open()
+	if progress != nil {
+		req.Body = readCloser{httpclient.ProgressReader(req.Body, body.Len(), progress), req.Body}
+	}
+
+	client := c.Client
+	if client == nil {
+		client = httpclient.Default.HTTP()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 500, fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("read response: %w", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		if resp.StatusCode/100 != 2 {
+			return nil, resp.StatusCode, fmt.Errorf("api %s http code %d body %s", api, resp.StatusCode, respBody)
+		}
+		return nil, resp.StatusCode, fmt.Errorf("parse response: %w", err)
+	}
+	return out, resp.StatusCode, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// sign adds the Volcengine V4 signature of req, whose body hashes to
+// bodyHash, as the SDK's Credentials.Sign does.
+func sign(req *http.Request, cred base.Credentials, bodyHash string, t time.Time) {
+	date := t.UTC().Format(dateFormat)
+	req.Header.Set("X-Date", date)
+	req.Header.Set("X-Content-Sha256", bodyHash)
+	if cred.SessionToken != "" {
+		req.Header.Set("X-Security-Token", cred.SessionToken)
+	}
+
+	names := []string{"host"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || lower == "content-md5" || strings.HasPrefix(lower, "x-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		value := req.URL.Host
+		if name != "host" {
+			value = strings.TrimSpace(req.Header.Get(name))
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		headers.String(),
+		signed,
+		bodyHash,
+	}, "\n")
+	scope := strings.Join([]string{date[:8], cred.Region, cred.Service, "request"}, "/")
+	stringToSign := strings.Join([]string{algorithm, date, scope, sha256Hex(canonical)}, "\n")
+
+	key := []byte(cred.SecretAccessKey)
+	for _, part := range []string{date[:8], cred.Region, cred.Service, "request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, cred.AccessKeyID, scope, signed, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,113 @@
+package volc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/volcengine/volc-sdk-golang/base"
+)
+
+// TestSignMatchesSDK checks sign against the SDK's signer for the same
+// request, time and credentials.
+func TestSignMatchesSDK(t *testing.T) {
+	cred := base.Credentials{
+		AccessKeyID:     "AKLTexampleaccesskey",
+		SecretAccessKey: "c2VjcmV0LWFjY2Vzcy1rZXktZXhhbXBsZQ==",
+		Region:          "cn-north-1",
+		Service:         "cv",
+	}
+	withToken := cred
+	withToken.SessionToken = "session-token"
+	when := time.Date(2024, 3, 9, 23, 59, 30, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		cred    base.Credentials
+		method  string
+		url     string
+		headers map[string]string
+		body    string
+	}{
+		{
+			name:   "submit task",
+			cred:   cred,
+			method: "POST",
+			url:    "https://visual.volcengineapi.com/?Action=CVSync2AsyncSubmitTask&Version=2022-08-31",
+			body:   `{"req_key":"jimeng_vgfm_t2v_l20","prompt":"a red fox in the snow"}`,
+		},
+		{
+			name:   "session token",
+			cred:   withToken,
+			method: "POST",
+			url:    "https://visual.volcengineapi.com/?Action=CVSubmitTask&Version=2022-08-31",
+			body:   `{"req_key":"jimeng_realman_avatar_picture_omni_v15"}`,
+		},
+		{
+			name:    "extra headers",
+			cred:    cred,
+			method:  "POST",
+			url:     "https://visual.volcengineapi.com/?Action=CVGetResult&Version=2022-08-31",
+			headers: map[string]string{"X-Custom": " padded value ", "Accept": "application/json"},
+			body:    `{"task_id":"123"}`,
+		},
+		{
+			name:   "query escaping",
+			cred:   cred,
+			method: "GET",
+			url:    "https://visual.volcengineapi.com/api?Action=List&Version=2022-08-31&Filter=a+b%2Fc&Name=%E4%B8%AD",
+		},
+		{
+			name:   "empty path",
+			cred:   cred,
+			method: "POST",
+			url:    "https://visual.volcengineapi.com?Action=CVSubmitTask&Version=2022-08-31",
+			body:   `{}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			sdkHeaders := req.Header.Clone()
+			path := req.URL.Path
+			if path == "" {
+				path = "/" // as Credentials.Sign does
+			}
+
+			sum := sha256.Sum256([]byte(tt.body))
+			sign(req, tt.cred, hex.EncodeToString(sum[:]), when)
+
+			want := base.GetSignRequest(base.RequestParam{
+				Body:      []byte(tt.body),
+				Host:      req.URL.Host,
+				Path:      path,
+				Method:    tt.method,
+				Date:      when,
+				QueryList: req.URL.Query(),
+				Headers:   sdkHeaders,
+			}, tt.cred)
+			if got := req.Header.Get("Authorization"); got != want.Authorization {
+				t.Errorf("Authorization =\n%s\nSDK:\n%s", got, want.Authorization)
+			}
+			if got := req.Header.Get("X-Date"); got != want.XDate {
+				t.Errorf("X-Date = %s, SDK %s", got, want.XDate)
+			}
+			if got := req.Header.Get("X-Content-Sha256"); got != want.XContentSha256 {
+				t.Errorf("X-Content-Sha256 = %s, SDK %s", got, want.XContentSha256)
+			}
+			if got := req.Header.Get("X-Security-Token"); got != tt.cred.SessionToken {
+				t.Errorf("X-Security-Token = %q, want %q", got, tt.cred.SessionToken)
+			}
+		})
+	}
+}
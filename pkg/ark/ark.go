@@ -0,0 +1,298 @@
+// Package ark is a client for Seedance video generation on Volcengine Ark.
+//
+//	c := ark.New(os.Getenv("ARK_API_KEY"))
+//	id, err := c.CreateTask(ctx, ark.VideoRequest{Prompt: "waves at dusk", Duration: "5"})
+//	result, err := c.WaitForTask(ctx, id, nil)
+//	fmt.Println(result.VideoURL())
+package ark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/pkg/internal/rest"
+)
+
+const (
+	// DefaultModel is used when a request names no model.
+	DefaultModel = "doubao-seedance-1-5-pro-251215"
+	// DefaultBaseURL is the Ark API endpoint.
+	DefaultBaseURL = "https://ark.cn-beijing.volces.com/api/v3"
+	// DefaultPollInterval and DefaultPollTimeout pace WaitForTask.
+	DefaultPollInterval = 5 * time.Second
+	DefaultPollTimeout  = 300 * time.Second
+)
+
+// Task statuses reported by the API.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Request types
+
+type CreateTaskRequest struct {
+	Model      string         `json:"model"`
+	Content    []TaskContent  `json:"content"`
+	Parameters TaskParameters `json:"parameters,omitempty"`
+}
+
+type TaskContent struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+type ImageURL struct {
+	URL string `json:"url"`
+}
+
+type TaskParameters struct {
+	Duration   string `json:"duration,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+	Ratio      string `json:"ratio,omitempty"`
+	Audio      bool   `json:"with_audio"`
+}
+
+// Response types
+
+type CreateTaskResponse struct {
+	ID    string    `json:"id"`
+	Error *APIError `json:"error,omitempty"`
+}
+
+type TaskResult struct {
+	ID      string             `json:"id"`
+	Status  string             `json:"status"`
+	Content *TaskResultContent `json:"content,omitempty"`
+	Error   *APIError          `json:"error,omitempty"`
+}
+
+type TaskResultContent struct {
+	VideoURL string `json:"video_url,omitempty"`
+}
+
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// VideoURL returns the URL of the generated video, or "" until the task
+// succeeded.
+func (r *TaskResult) VideoURL() string {
+	if r.Content == nil {
+		return ""
+	}
+	return r.Content.VideoURL
+}
+
+// VideoRequest is a text-to-video or, with ImageURL, image-to-video
+// generation.
+type VideoRequest struct {
+	// Model defaults to DefaultModel.
+	Model  string
+	Prompt string
+	// ImageURL, a URL or data URI, is used as the first frame.
+	ImageURL string
+	// Resolution (e.g. "1080p"), Duration in seconds (e.g. "5") and Ratio
+	// (e.g. "16:9") are optional.
+	Resolution string
+	Duration   string
+	Ratio      string
+	// NoAudio generates a silent video.
+	NoAudio bool
+}
+
+// Body returns the task request body of r.
+func (r VideoRequest) Body() CreateTaskRequest {
+	model := r.Model
+	if model == "" {
+		model = DefaultModel
+	}
+
+	req := CreateTaskRequest{
+		Model: model,
+		Content: []TaskContent{
+			{Type: "text", Text: r.Prompt},
+		},
+		Parameters: TaskParameters{
+			Duration:   r.Duration,
+			Resolution: r.Resolution,
+			Ratio:      r.Ratio,
+			Audio:      !r.NoAudio,
+		},
+	}
+	if r.ImageURL != "" {
+		req.Content = append(req.Content, TaskContent{Type: "image_url", ImageURL: &ImageURL{URL: r.ImageURL}})
+	}
+	return req
+}
+
+// Client calls the Ark API with one API key. It is safe for concurrent use.
+type Client struct {
+	apiKey       string
+	baseURL      string
+	pollInterval time.Duration
+	pollTimeout  time.Duration
+	rest         rest.Client
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.rest.SetHTTP(hc) }
+}
+
+// WithBaseURL replaces DefaultBaseURL, e.g. for another region.
+func WithBaseURL(u string) Option {
+	return func(c *Client) { c.baseURL = strings.TrimSuffix(u, "/") }
+}
+
+// WithLogger logs requests and raw responses at debug level to l. Logs
+// are discarded by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) { c.rest.SetLogger(l) }
+}
+
+// WithPolling replaces DefaultPollInterval and DefaultPollTimeout.
+func WithPolling(interval, timeout time.Duration) Option {
+	return func(c *Client) { c.pollInterval, c.pollTimeout = interval, timeout }
+}
+
+// New returns a client authenticated with apiKey.
+func New(apiKey string, opts ...Option) *Client {
+	c := &Client{
+		apiKey:       apiKey,
+		baseURL:      DefaultBaseURL,
+		pollInterval: DefaultPollInterval,
+		pollTimeout:  DefaultPollTimeout,
+		rest:         rest.New(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// TasksURL returns the endpoint tasks are created at.
+func (c *Client) TasksURL() string {
+	return c.baseURL + "/contents/generations/tasks"
+}
+
+func (c *Client) headers() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + c.apiKey}
+}
+
+// CreateTask submits a video generation task and returns its ID.
+func (c *Client) CreateTask(ctx context.Context, req VideoRequest) (string, error) {
+	body, err := json.Marshal(req.Body())
+	if err != nil {
+		return "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	respBody, statusCode, err := c.rest.Do(ctx, http.MethodPost, c.TasksURL(), c.headers(), body)
+	if err != nil {
+		return "", err
+	}
+	c.rest.Debug(ctx, "seedance create task", "status_code", statusCode, "response", json.RawMessage(respBody))
+
+	if statusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d: %s", statusCode, string(respBody))
+	}
+
+	var resp CreateTaskResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return "", fmt.Errorf("unmarshal response: %w\nraw: %s", err, string(respBody))
+	}
+
+	if resp.Error != nil {
+		return "", fmt.Errorf("API error [%s]: %s", resp.Error.Code, resp.Error.Message)
+	}
+
+	if resp.ID == "" {
+		return "", fmt.Errorf("no task ID in response: %s", string(respBody))
+	}
+
+	return resp.ID, nil
+}
+
+// QueryTask fetches the current state of a task.
+func (c *Client) QueryTask(ctx context.Context, taskID string) (*TaskResult, error) {
+	respBody, statusCode, err := c.rest.Do(ctx, http.MethodGet, c.TasksURL()+"/"+taskID, c.headers(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.rest.Debug(ctx, "seedance query", "status_code", statusCode, "task_id", taskID, "response", json.RawMessage(respBody))
+
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", statusCode, string(respBody))
+	}
+
+	var result TaskResult
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w\nraw: %s", err, string(respBody))
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("API error [%s]: %s", result.Error.Code, result.Error.Message)
+	}
+
+	return &result, nil
+}
+
+// WaitForTask polls until the task succeeds, fails, times out or ctx is
+// done. onStatus, if non-nil, is called with the status after every
+// unfinished poll.
+func (c *Client) WaitForTask(ctx context.Context, taskID string, onStatus func(status string)) (*TaskResult, error) {
+	deadline := time.Now().Add(c.pollTimeout)
+
+	for {
+		result, err := c.QueryTask(ctx, taskID)
+		if err != nil {
+			return nil, err
+		}
+
+		switch result.Status {
+		case StatusSucceeded:
+			return result, nil
+		case StatusFailed:
+			if result.Error != nil {
+				return nil, fmt.Errorf("task failed [%s]: %s", result.Error.Code, result.Error.Message)
+			}
+			return nil, fmt.Errorf("task failed")
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout after %v, task still in status: %s", c.pollTimeout, result.Status)
+		}
+
+		if onStatus != nil {
+			onStatus(result.Status)
+		}
+		if err := rest.Sleep(ctx, c.pollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ListTasks lists at most one task. It is the cheapest authenticated call,
+// suitable for verifying the API key.
+func (c *Client) ListTasks(ctx context.Context) error {
+	respBody, statusCode, err := c.rest.Do(ctx, http.MethodGet, c.TasksURL()+"?page_num=1&page_size=1", c.headers(), nil)
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", statusCode, string(respBody))
+	}
+	return nil
+}
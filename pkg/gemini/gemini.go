@@ -0,0 +1,384 @@
+// Package gemini is a client for Gemini image and text generation through
+// the generateContent API.
+//
+//	c := gemini.New(os.Getenv("GEMINI_API_KEY"))
+//	resp, err := c.GenerateContent(ctx, gemini.ImageRequest{Prompt: "a red fox", AspectRatio: "16:9"})
+//	for _, img := range resp.Images() { ... }
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/pkg/internal/rest"
+)
+
+const (
+	// DefaultModel is used when an image request names no model.
+	DefaultModel = "gemini-3-pro-image-preview"
+	// DefaultTextModel is used when a text request names no model.
+	DefaultTextModel = "gemini-2.5-flash"
+	// DefaultBaseURL is the models endpoint of the Gemini API.
+	DefaultBaseURL = "https://generativelanguage.googleapis.com/v1beta/models/"
+)
+
+// Request types
+type Part struct {
+	Text       string      `json:"text,omitempty"`
+	InlineData *InlineData `json:"inlineData,omitempty"`
+	FileData   *FileData   `json:"fileData,omitempty"`
+}
+
+type InlineData struct {
+	MIMEType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// FileData refers to a file uploaded with UploadFile.
+type FileData struct {
+	MIMEType string `json:"mimeType"`
+	FileURI  string `json:"fileUri"`
+}
+
+type Content struct {
+	Parts []Part `json:"parts"`
+}
+
+type ImageConfig struct {
+	AspectRatio string `json:"aspectRatio,omitempty"`
+	ImageSize   string `json:"imageSize,omitempty"`
+}
+
+type GenerationConfig struct {
+	ResponseModalities []string     `json:"responseModalities"`
+	ImageConfig        *ImageConfig `json:"imageConfig,omitempty"`
+	ResponseMIMEType   string       `json:"responseMimeType,omitempty"`
+	ResponseSchema     interface{}  `json:"responseSchema,omitempty"`
+}
+
+type Request struct {
+	SystemInstruction *Content          `json:"systemInstruction,omitempty"`
+	Contents          []Content         `json:"contents"`
+	GenerationConfig  *GenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// Response types
+type ResponsePart struct {
+	Text       string      `json:"text,omitempty"`
+	InlineData *InlineData `json:"inlineData,omitempty"`
+}
+
+type ResponseContent struct {
+	Parts []ResponsePart `json:"parts"`
+}
+
+type Candidate struct {
+	Content ResponseContent `json:"content"`
+}
+
+type Response struct {
+	Candidates []Candidate `json:"candidates"`
+	Error      *APIError   `json:"error,omitempty"`
+}
+
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// Images returns the inline images of every candidate, base64-encoded.
+func (r *Response) Images() []InlineData {
+	var images []InlineData
+	for _, c := range r.Candidates {
+		for _, p := range c.Content.Parts {
+			if p.InlineData != nil {
+				images = append(images, *p.InlineData)
+			}
+		}
+	}
+	return images
+}
+
+// Text returns the text parts of every candidate, one per line.
+func (r *Response) Text() string {
+	var lines []string
+	for _, c := range r.Candidates {
+		for _, p := range c.Content.Parts {
+			if p.Text != "" {
+				lines = append(lines, p.Text)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ImageRequest is a text-to-image generation.
+type ImageRequest struct {
+	// Model defaults to DefaultModel.
+	Model  string
+	Prompt string
+	// AspectRatio, e.g. "16:9", and ImageSize, e.g. "2K", are optional.
+	AspectRatio string
+	ImageSize   string
+	// References are images whose character or style the generated image
+	// keeps, sent along with the prompt. ReferenceFiles are such images
+	// uploaded with UploadFile.
+	References     []InlineData
+	ReferenceFiles []FileData
+}
+
+// Body returns the generateContent request body of r.
+func (r ImageRequest) Body() Request {
+	parts := []Part{{Text: r.Prompt}}
+	for i := range r.References {
+		parts = append(parts, Part{InlineData: &r.References[i]})
+	}
+	for i := range r.ReferenceFiles {
+		parts = append(parts, Part{FileData: &r.ReferenceFiles[i]})
+	}
+	req := Request{
+		Contents: []Content{
+			{Parts: parts},
+		},
+		GenerationConfig: &GenerationConfig{
+			ResponseModalities: []string{"TEXT", "IMAGE"},
+		},
+	}
+
+	if r.AspectRatio != "" || r.ImageSize != "" {
+		req.GenerationConfig.ImageConfig = &ImageConfig{
+			AspectRatio: r.AspectRatio,
+			ImageSize:   r.ImageSize,
+		}
+	}
+	return req
+}
+
+// TextRequest is a text generation.
+type TextRequest struct {
+	// Model defaults to DefaultTextModel.
+	Model  string
+	Prompt string
+	// System, if set, is the system instruction.
+	System string
+	// Schema, if set, makes the model answer with JSON of this OpenAPI
+	// schema, e.g. {"type": "OBJECT", "properties": {...}}.
+	Schema interface{}
+}
+
+// Body returns the generateContent request body of r.
+func (r TextRequest) Body() Request {
+	req := Request{
+		Contents: []Content{
+			{Parts: []Part{{Text: r.Prompt}}},
+		},
+		GenerationConfig: &GenerationConfig{
+			ResponseModalities: []string{"TEXT"},
+		},
+	}
+	if r.System != "" {
+		req.SystemInstruction = &Content{Parts: []Part{{Text: r.System}}}
+	}
+	if r.Schema != nil {
+		req.GenerationConfig.ResponseMIMEType = "application/json"
+		req.GenerationConfig.ResponseSchema = r.Schema
+	}
+	return req
+}
+
+// Client calls the Gemini API with one API key. It is safe for concurrent
+// use.
+type Client struct {
+	apiKey  string
+	baseURL string
+	rest    rest.Client
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.rest.SetHTTP(hc) }
+}
+
+// WithBaseURL replaces DefaultBaseURL, e.g. for a proxy.
+func WithBaseURL(u string) Option {
+	return func(c *Client) { c.baseURL = strings.TrimSuffix(u, "/") + "/" }
+}
+
+// WithLogger logs requests and raw responses at debug level to l. Logs
+// are discarded by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) { c.rest.SetLogger(l) }
+}
+
+// New returns a client authenticated with apiKey.
+func New(apiKey string, opts ...Option) *Client {
+	c := &Client{apiKey: apiKey, baseURL: DefaultBaseURL, rest: rest.New()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// GenerateURL returns the generateContent endpoint of model.
+func (c *Client) GenerateURL(model string) string {
+	if model == "" {
+		model = DefaultModel
+	}
+	return c.baseURL + model + ":generateContent"
+}
+
+func (c *Client) headers() map[string]string {
+	return map[string]string{"x-goog-api-key": c.apiKey}
+}
+
+// GenerateContent calls generateContent and returns the parsed response.
+func (c *Client) GenerateContent(ctx context.Context, req ImageRequest) (*Response, error) {
+	model := req.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	return c.generate(ctx, model, req.Body())
+}
+
+// GenerateText calls generateContent for text only; Response.Text holds
+// the answer.
+func (c *Client) GenerateText(ctx context.Context, req TextRequest) (*Response, error) {
+	model := req.Model
+	if model == "" {
+		model = DefaultTextModel
+	}
+	return c.generate(ctx, model, req.Body())
+}
+
+func (c *Client) generate(ctx context.Context, model string, req Request) (*Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	respBody, statusCode, err := c.rest.Do(ctx, http.MethodPost, c.GenerateURL(model), c.headers(), body)
+	if err != nil {
+		return nil, err
+	}
+	c.rest.Debug(ctx, "gemini generate", "model", model, "status_code", statusCode, "response", json.RawMessage(respBody))
+
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", statusCode, string(respBody))
+	}
+
+	var resp Response
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w\nraw: %s", err, string(respBody))
+	}
+
+	if resp.Error != nil {
+		return nil, fmt.Errorf("API error [%d] %s: %s", resp.Error.Code, resp.Error.Status, resp.Error.Message)
+	}
+
+	return &resp, nil
+}
+
+// GetModel fetches a model's metadata. It is the cheapest authenticated
+// call, suitable for verifying the API key.
+func (c *Client) GetModel(ctx context.Context, model string) error {
+	if model == "" {
+		model = DefaultModel
+	}
+	respBody, statusCode, err := c.rest.Do(ctx, http.MethodGet, c.baseURL+model, c.headers(), nil)
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", statusCode, string(respBody))
+	}
+	return nil
+}
+
+// File is a file stored by the File API. Files expire, by default after 48
+// hours.
+type File struct {
+	Name           string    `json:"name"`
+	DisplayName    string    `json:"displayName,omitempty"`
+	MIMEType       string    `json:"mimeType"`
+	SizeBytes      string    `json:"sizeBytes,omitempty"`
+	URI            string    `json:"uri"`
+	State          string    `json:"state,omitempty"`
+	ExpirationTime time.Time `json:"expirationTime"`
+}
+
+// FileData returns the part referring to f.
+func (f *File) FileData() FileData {
+	return FileData{MIMEType: f.MIMEType, FileURI: f.URI}
+}
+
+// UploadURL returns the File API upload endpoint, next to the models
+// endpoint.
+func (c *Client) UploadURL() string {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return ""
+	}
+	version := strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/models")
+	return u.Scheme + "://" + u.Host + "/upload" + version + "/files"
+}
+
+// UploadFile stores data with the File API, so that requests can refer to
+// it by URI instead of sending it inline every time.
+func (c *Client) UploadFile(ctx context.Context, data []byte, mimeType, displayName string) (*File, error) {
+	meta, err := json.Marshal(map[string]interface{}{"file": map[string]string{"displayName": displayName}})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	// Resumable protocol: start a session, then send the bytes to it.
+	headers := c.headers()
+	headers["X-Goog-Upload-Protocol"] = "resumable"
+	headers["X-Goog-Upload-Command"] = "start"
+	headers["X-Goog-Upload-Header-Content-Length"] = strconv.Itoa(len(data))
+	headers["X-Goog-Upload-Header-Content-Type"] = mimeType
+	respBody, respHeader, statusCode, err := c.rest.Send(ctx, http.MethodPost, c.UploadURL(), headers, meta)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", statusCode, string(respBody))
+	}
+	session := respHeader.Get("X-Goog-Upload-URL")
+	if session == "" {
+		return nil, fmt.Errorf("no upload URL in response")
+	}
+
+	headers = c.headers()
+	headers["Content-Type"] = mimeType
+	headers["X-Goog-Upload-Offset"] = "0"
+	headers["X-Goog-Upload-Command"] = "upload, finalize"
+	respBody, statusCode, err = c.rest.Do(ctx, http.MethodPost, session, headers, data)
+	if err != nil {
+		return nil, err
+	}
+	c.rest.Debug(ctx, "gemini upload file", "status_code", statusCode, "response", json.RawMessage(respBody))
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", statusCode, string(respBody))
+	}
+
+	var resp struct {
+		File File `json:"file"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w\nraw: %s", err, string(respBody))
+	}
+	if resp.File.URI == "" {
+		return nil, fmt.Errorf("no file URI in response: %s", string(respBody))
+	}
+	return &resp.File, nil
+}
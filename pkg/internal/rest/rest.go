@@ -0,0 +1,107 @@
+// Package rest holds the plumbing shared by the public clients: a JSON
+// request helper bounded by a per-call timeout, the default HTTP client
+// and logger, and a context-aware sleep for polling.
+package rest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// DefaultTimeout bounds a single JSON call. Uploads and polling loops are
+// bounded only by their context.
+const DefaultTimeout = 120 * time.Second
+
+// Client sends JSON requests through HTTP, logging to Logger.
+type Client struct {
+	HTTP    *http.Client
+	Logger  *slog.Logger
+	Timeout time.Duration
+}
+
+// New returns a Client using http.DefaultClient and discarding logs.
+func New() Client {
+	return Client{HTTP: http.DefaultClient, Logger: slog.New(slog.DiscardHandler), Timeout: DefaultTimeout}
+}
+
+// SetHTTP replaces the HTTP client; nil restores http.DefaultClient.
+func (c *Client) SetHTTP(hc *http.Client) {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	c.HTTP = hc
+}
+
+// SetLogger replaces the logger; nil discards logs.
+func (c *Client) SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(slog.DiscardHandler)
+	}
+	c.Logger = l
+}
+
+// Debug logs a diagnostic record.
+func (c *Client) Debug(ctx context.Context, msg string, args ...any) {
+	c.Logger.DebugContext(ctx, msg, args...)
+}
+
+// Do sends a request with headers and, if body is non-nil, a JSON body,
+// and returns the response body and status.
+func (c *Client) Do(ctx context.Context, method, url string, headers map[string]string, body []byte) ([]byte, int, error) {
+	respBody, _, status, err := c.Send(ctx, method, url, headers, body)
+	return respBody, status, err
+}
+
+// Send is Do that also returns the response headers. headers may replace
+// the JSON Content-Type.
+func (c *Client) Send(ctx context.Context, method, url string, headers map[string]string, body []byte) ([]byte, http.Header, int, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.Header, resp.StatusCode, fmt.Errorf("read response: %w", err)
+	}
+	return respBody, resp.Header, resp.StatusCode, nil
+}
+
+// Sleep waits for d or until ctx is done, whichever comes first.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
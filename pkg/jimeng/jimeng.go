@@ -0,0 +1,512 @@
+// Package jimeng is a client for the Jimeng models of the Volcengine visual
+// API: video generation (text/image-to-video), OmniHuman 1.5 digital humans
+// and action imitation 2.0.
+//
+//	c := jimeng.New(os.Getenv("JIMENG_ACCESS_KEY_ID"), os.Getenv("JIMENG_SECRET_ACCESS_KEY"))
+//	id, err := c.SubmitOmniHuman(ctx, jimeng.OmniHumanRequest{ImageURL: img, AudioURL: audio})
+//	result, err := c.WaitOmniHuman(ctx, id, nil)
+//	fmt.Println(result.VideoURL)
+//
+// Requests are signed with the access keys; local images can be sent
+// inline as Media, which is base64-encoded while the request streams.
+package jimeng
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/internal/volc"
+	"github.com/llm-net/llm-api-plugin/pkg/internal/rest"
+	"github.com/volcengine/volc-sdk-golang/service/visual"
+)
+
+const (
+	// DefaultEndpoint is the Volcengine visual API.
+	DefaultEndpoint = "https://visual.volcengineapi.com"
+	// DefaultPollInterval and DefaultPollTimeout pace the Wait methods.
+	DefaultPollInterval = 5 * time.Second
+	DefaultPollTimeout  = 300 * time.Second
+)
+
+// req_keys of the fixed-model services.
+const (
+	ReqKeyOmniHuman         = "jimeng_realman_avatar_picture_omni_v15"
+	ReqKeyActionImitationV2 = "jimeng_dreamactor_m20_gen_video"
+)
+
+// Normalized task statuses of Result.
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Visual API actions.
+const (
+	apiSubmit      = "CVSubmitTask"
+	apiGetResult   = "CVGetResult"
+	apiAsyncSubmit = "CVSync2AsyncSubmitTask"
+	apiAsyncResult = "CVSync2AsyncGetResult"
+)
+
+// codeOK is the business code of a successful call; codeNoAccess means
+// the account may not use the req_key's service.
+const (
+	codeOK       = 10000
+	codeNoAccess = 50400
+)
+
+// Media is image content sent inline as base64. It is encoded while the
+// request body is sent, so the content is never held in memory.
+type Media = httpclient.Base64
+
+// NewMedia returns content of size bytes that open reads from the start,
+// once for each pass over the request body (signing, then sending).
+func NewMedia(size int64, open func() (io.ReadCloser, error)) *Media {
+	return httpclient.NewBase64(size, open)
+}
+
+// Bytes returns data as Media.
+func Bytes(data []byte) *Media {
+	return NewMedia(int64(len(data)), func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+// Result is the normalized state of a task.
+type Result struct {
+	TaskID string
+	// Status is StatusPending, StatusRunning, StatusDone or StatusFailed.
+	Status string
+	// VideoURL is set once the task is done; it expires after an hour.
+	VideoURL string
+	Message  string
+	// Code is the business code of the query, 10000 on success.
+	Code int
+}
+
+// VideoRequest is a Jimeng video generation. The image fields select
+// text-to-video (none), image-to-video (first frame) or first-and-last-
+// frame generation; each frame is a URL or inline Media, Media first.
+type VideoRequest struct {
+	// ReqKey selects the model, e.g. "jimeng_t2v_v30_1080p".
+	ReqKey           string
+	Prompt           string
+	FirstFrameImage  string
+	FirstFrameBase64 *Media
+	EndFrameImage    string
+	EndFrameBase64   *Media
+	AspectRatio      string
+	// Frames defaults to 121 (5 seconds).
+	Frames int
+	Seed   int
+	// Progress, if set, receives the bytes of the request body sent so far
+	// and its length.
+	Progress func(sent, total int64)
+}
+
+// Body returns the request body of r.
+func (r VideoRequest) Body() map[string]interface{} {
+	reqBody := map[string]interface{}{
+		"req_key": r.ReqKey,
+		"prompt":  r.Prompt,
+	}
+
+	// Collect image URLs and base64 data (first frame + optional end frame)
+	var imageURLs []string
+	var base64Data []interface{}
+
+	if r.FirstFrameBase64 != nil {
+		base64Data = append(base64Data, r.FirstFrameBase64)
+	} else if r.FirstFrameImage != "" {
+		imageURLs = append(imageURLs, r.FirstFrameImage)
+	}
+
+	if r.EndFrameBase64 != nil {
+		base64Data = append(base64Data, r.EndFrameBase64)
+	} else if r.EndFrameImage != "" {
+		imageURLs = append(imageURLs, r.EndFrameImage)
+	}
+
+	if len(base64Data) > 0 {
+		reqBody["binary_data_base64"] = base64Data
+	}
+	if len(imageURLs) > 0 {
+		reqBody["image_urls"] = imageURLs
+	}
+
+	if r.Seed != 0 {
+		reqBody["seed"] = r.Seed
+	}
+
+	if r.AspectRatio != "" {
+		reqBody["aspect_ratio"] = r.AspectRatio
+	}
+
+	if r.Frames > 0 {
+		reqBody["frames"] = r.Frames
+	} else {
+		reqBody["frames"] = 121 // default 5 seconds
+	}
+	return reqBody
+}
+
+// OmniHumanRequest is an OmniHuman 1.5 generation: a portrait speaking or
+// singing the audio.
+type OmniHumanRequest struct {
+	// ImageURL or ImageBase64, which wins, is the portrait.
+	ImageURL    string
+	ImageBase64 *Media
+	// AudioURL must be shorter than 60 seconds.
+	AudioURL string
+	// Prompt is optional; Chinese, English, Japanese, Korean, Spanish or
+	// Indonesian, at most about 300 characters.
+	Prompt string
+	// Seed is random when zero.
+	Seed int
+	// OutputResolution is 720 or 1080 (the default).
+	OutputResolution int
+	// FastMode trades some quality for speed.
+	FastMode bool
+	// Progress, if set, receives the bytes of the request body sent so far
+	// and its length.
+	Progress func(sent, total int64)
+}
+
+// Body returns the request body of r.
+func (r OmniHumanRequest) Body() map[string]interface{} {
+	reqBody := map[string]interface{}{
+		"req_key":   ReqKeyOmniHuman,
+		"audio_url": r.AudioURL,
+	}
+	if r.ImageBase64 != nil {
+		reqBody["binary_data_base64"] = []interface{}{r.ImageBase64}
+	} else if r.ImageURL != "" {
+		reqBody["image_url"] = r.ImageURL
+	}
+	if r.Prompt != "" {
+		reqBody["prompt"] = r.Prompt
+	}
+	if r.Seed != 0 {
+		reqBody["seed"] = r.Seed
+	}
+	if r.OutputResolution == 720 || r.OutputResolution == 1080 {
+		reqBody["output_resolution"] = r.OutputResolution
+	}
+	if r.FastMode {
+		reqBody["pe_fast_mode"] = true
+	}
+	return reqBody
+}
+
+// ActionImitationRequest is an action imitation 2.0 generation: the
+// person in the image performing the motion of the template video.
+type ActionImitationRequest struct {
+	// ImageURL or ImageBase64, which wins, is the person.
+	ImageURL    string
+	ImageBase64 *Media
+	// VideoURL is the template video.
+	VideoURL string
+	// CutFirstSecond trims the first second of the result; the API
+	// defaults to true when nil.
+	CutFirstSecond *bool
+	// Progress, if set, receives the bytes of the request body sent so far
+	// and its length.
+	Progress func(sent, total int64)
+}
+
+// Body returns the request body of r.
+func (r ActionImitationRequest) Body() map[string]interface{} {
+	reqBody := map[string]interface{}{
+		"req_key":   ReqKeyActionImitationV2,
+		"video_url": r.VideoURL,
+	}
+	if r.ImageBase64 != nil {
+		reqBody["binary_data_base64"] = []interface{}{r.ImageBase64}
+	} else if r.ImageURL != "" {
+		reqBody["image_urls"] = []string{r.ImageURL}
+	}
+	// cut_result_first_second_switch defaults to true, so it is only sent
+	// when set
+	if r.CutFirstSecond != nil {
+		reqBody["cut_result_first_second_switch"] = *r.CutFirstSecond
+	}
+	return reqBody
+}
+
+// Client calls the visual API with one pair of access keys. It is safe for
+// concurrent use.
+type Client struct {
+	visual       *visual.Visual
+	pollInterval time.Duration
+	pollTimeout  time.Duration
+	rest         rest.Client
+	err          error
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.rest.SetHTTP(hc)
+		c.visual.Client.Client = c.rest.HTTP
+	}
+}
+
+// WithEndpoint replaces DefaultEndpoint, e.g. for a proxy.
+func WithEndpoint(endpoint string) Option {
+	return func(c *Client) {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			c.err = fmt.Errorf("invalid endpoint %q", endpoint)
+			return
+		}
+		c.visual.SetHost(u.Host)
+		if u.Scheme != "" {
+			c.visual.SetSchema(u.Scheme)
+		}
+	}
+}
+
+// WithLogger logs raw responses at debug level to l. Logs are discarded
+// by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) { c.rest.SetLogger(l) }
+}
+
+// WithPolling replaces DefaultPollInterval and DefaultPollTimeout.
+func WithPolling(interval, timeout time.Duration) Option {
+	return func(c *Client) { c.pollInterval, c.pollTimeout = interval, timeout }
+}
+
+// New returns a client signing requests with the given access keys.
+func New(accessKeyID, secretAccessKey string, opts ...Option) *Client {
+	c := &Client{
+		visual:       visual.NewInstance(),
+		pollInterval: DefaultPollInterval,
+		pollTimeout:  DefaultPollTimeout,
+		rest:         rest.New(),
+	}
+	c.visual.Client.Client = c.rest.HTTP
+	c.visual.Client.SetAccessKey(accessKeyID)
+	c.visual.Client.SetSecretKey(secretAccessKey)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// response is the envelope of every visual API response.
+type response struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		TaskID   string `json:"task_id"`
+		Status   string `json:"status"`
+		VideoURL string `json:"video_url"`
+	} `json:"data"`
+}
+
+// call sends body to api. Inline Media is encoded from its source as the
+// body streams.
+func (c *Client) call(ctx context.Context, api, msg string, body map[string]interface{}, progress func(sent, total int64)) (*response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	jsonBody, err := httpclient.NewJSONBody(body)
+	if err != nil {
+		return nil, err
+	}
+	resp, statusCode, err := volc.PostJSON(ctx, c.visual.Client, api, jsonBody, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+	c.rest.Debug(ctx, msg, "status_code", statusCode, "task_id", body["task_id"], "response", json.RawMessage(respBytes))
+
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", statusCode, string(respBytes))
+	}
+
+	var result response
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w\nraw: %s", err, string(respBytes))
+	}
+	return &result, nil
+}
+
+// submit sends a task and returns its ID.
+func (c *Client) submit(ctx context.Context, api, reqKey string, body map[string]interface{}, progress func(sent, total int64)) (string, error) {
+	result, err := c.call(ctx, api, "jimeng submit "+reqKey, body, progress)
+	if err != nil {
+		return "", fmt.Errorf("submit task: %w", err)
+	}
+	if result.Code != codeOK {
+		return "", fmt.Errorf("API error: code=%d, message=%s", result.Code, result.Message)
+	}
+	if result.Data.TaskID == "" {
+		return "", fmt.Errorf("no task ID in response")
+	}
+	return result.Data.TaskID, nil
+}
+
+// query fetches a task's state. A business error is reported as a failed
+// task rather than an error, so that Wait stops with its message.
+func (c *Client) query(ctx context.Context, api string, body map[string]interface{}) (*Result, error) {
+	taskID, _ := body["task_id"].(string)
+	result, err := c.call(ctx, api, "jimeng query "+body["req_key"].(string), body, nil)
+	if err != nil {
+		return nil, fmt.Errorf("query task: %w", err)
+	}
+	if result.Code != codeOK {
+		return &Result{TaskID: taskID, Status: StatusFailed, Message: result.Message, Code: result.Code}, nil
+	}
+	r := &Result{TaskID: taskID, Status: mapStatus(result.Data.Status), Message: result.Message, Code: result.Code}
+	if r.Status == StatusDone {
+		r.VideoURL = result.Data.VideoURL
+	}
+	return r, nil
+}
+
+// mapStatus maps the Volcengine status (in_queue, processing, generating,
+// done, not_found, expired) to a normalized one.
+func mapStatus(volcStatus string) string {
+	switch volcStatus {
+	case "processing", "in_queue":
+		return StatusPending
+	case "generating":
+		return StatusRunning
+	case "done":
+		return StatusDone
+	case "not_found", "expired":
+		return StatusFailed
+	default:
+		return StatusPending
+	}
+}
+
+// wait polls query until the task is done, fails, times out or ctx is
+// done. onStatus, if non-nil, is called with the status after every
+// unfinished poll.
+func (c *Client) wait(ctx context.Context, query func(context.Context) (*Result, error), onStatus func(status string)) (*Result, error) {
+	deadline := time.Now().Add(c.pollTimeout)
+	for {
+		result, err := query(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		switch result.Status {
+		case StatusDone:
+			if result.VideoURL == "" {
+				return nil, fmt.Errorf("task succeeded but no video URL in response")
+			}
+			return result, nil
+		case StatusFailed:
+			return nil, fmt.Errorf("task failed: %s", result.Message)
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout after %v, task still in status: %s", c.pollTimeout, result.Status)
+		}
+
+		if onStatus != nil {
+			onStatus(result.Status)
+		}
+		if err := rest.Sleep(ctx, c.pollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// SubmitVideo submits a video generation task and returns its ID.
+func (c *Client) SubmitVideo(ctx context.Context, req VideoRequest) (string, error) {
+	return c.submit(ctx, apiAsyncSubmit, req.ReqKey, req.Body(), req.Progress)
+}
+
+// QueryVideo fetches the state of a video generation task of reqKey.
+func (c *Client) QueryVideo(ctx context.Context, reqKey, taskID string) (*Result, error) {
+	return c.query(ctx, apiAsyncResult, map[string]interface{}{"req_key": reqKey, "task_id": taskID})
+}
+
+// WaitVideo polls a video generation task of reqKey until it is done.
+func (c *Client) WaitVideo(ctx context.Context, reqKey, taskID string, onStatus func(status string)) (*Result, error) {
+	return c.wait(ctx, func(ctx context.Context) (*Result, error) {
+		return c.QueryVideo(ctx, reqKey, taskID)
+	}, onStatus)
+}
+
+// SubmitOmniHuman submits an OmniHuman 1.5 task and returns its ID.
+func (c *Client) SubmitOmniHuman(ctx context.Context, req OmniHumanRequest) (string, error) {
+	return c.submit(ctx, apiSubmit, ReqKeyOmniHuman, req.Body(), req.Progress)
+}
+
+// QueryOmniHuman fetches the state of an OmniHuman 1.5 task.
+func (c *Client) QueryOmniHuman(ctx context.Context, taskID string) (*Result, error) {
+	return c.query(ctx, apiGetResult, map[string]interface{}{"req_key": ReqKeyOmniHuman, "task_id": taskID})
+}
+
+// WaitOmniHuman polls an OmniHuman 1.5 task until it is done.
+func (c *Client) WaitOmniHuman(ctx context.Context, taskID string, onStatus func(status string)) (*Result, error) {
+	return c.wait(ctx, func(ctx context.Context) (*Result, error) {
+		return c.QueryOmniHuman(ctx, taskID)
+	}, onStatus)
+}
+
+// actionImitationMeta is the AIGC labelling requested with results.
+const actionImitationMeta = `{"aigc_meta": {"content_producer": "001191440300192203821610000", "producer_id": "producer_id_test123", "content_propagator": "001191440300192203821610000", "propagate_id": "propagate_id_test123"}}`
+
+// SubmitActionImitation submits an action imitation 2.0 task and returns
+// its ID.
+func (c *Client) SubmitActionImitation(ctx context.Context, req ActionImitationRequest) (string, error) {
+	return c.submit(ctx, apiAsyncSubmit, ReqKeyActionImitationV2, req.Body(), req.Progress)
+}
+
+// QueryActionImitation fetches the state of an action imitation 2.0 task.
+func (c *Client) QueryActionImitation(ctx context.Context, taskID string) (*Result, error) {
+	return c.query(ctx, apiAsyncResult, map[string]interface{}{
+		"req_key":  ReqKeyActionImitationV2,
+		"task_id":  taskID,
+		"req_json": actionImitationMeta,
+	})
+}
+
+// WaitActionImitation polls an action imitation 2.0 task until it is done.
+func (c *Client) WaitActionImitation(ctx context.Context, taskID string, onStatus func(status string)) (*Result, error) {
+	return c.wait(ctx, func(ctx context.Context) (*Result, error) {
+		return c.QueryActionImitation(ctx, taskID)
+	}, onStatus)
+}
+
+// CheckSignature queries a nonexistent task of reqKey. Any business
+// response proves the access keys and request signature were accepted;
+// an error with code 50400 means the account may not use the service.
+func (c *Client) CheckSignature(ctx context.Context, reqKey string) error {
+	api := apiAsyncResult
+	if reqKey == ReqKeyOmniHuman {
+		api = apiGetResult
+	}
+	result, err := c.call(ctx, api, "jimeng check "+reqKey, map[string]interface{}{"req_key": reqKey, "task_id": "0"}, nil)
+	if err != nil {
+		return err
+	}
+	if result.Code == codeNoAccess {
+		return fmt.Errorf("API error: code=%d, message=%s", result.Code, result.Message)
+	}
+	return nil
+}
@@ -0,0 +1,429 @@
+// Package topview is a client for TopView video avatars (avatar4): a
+// photo and an audio track are uploaded, then turned into a talking video.
+//
+//	c := topview.New(os.Getenv("TOPVIEW_API_KEY"), os.Getenv("TOPVIEW_UID"))
+//	image, err := c.Upload(ctx, imgFile, imgSize, "png", "image/png", topview.UploadHooks{})
+//	audio, err := c.Upload(ctx, mp3File, mp3Size, "mp3", "audio/mpeg", topview.UploadHooks{})
+//	task, err := c.SubmitVideoAvatar(ctx, topview.VideoAvatarRequest{ImageFileID: image, AudioFileID: audio})
+//	result, err := c.WaitForTask(ctx, task.TaskID, nil, nil)
+//	fmt.Println(result.OutputVideoURL)
+package topview
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/llm-net/llm-api-plugin/internal/httpclient"
+	"github.com/llm-net/llm-api-plugin/pkg/internal/rest"
+)
+
+const (
+	// DefaultBaseURL is the TopView API endpoint.
+	DefaultBaseURL = "https://api.topview.ai/v1"
+	// DefaultPollInterval and DefaultPollTimeout pace WaitForTask.
+	DefaultPollInterval = 5 * time.Second
+	DefaultPollTimeout  = 600 * time.Second // 10 minutes
+	maxPollAttempts     = 120
+)
+
+// Upload phases reported to UploadHooks.Phase.
+const (
+	PhaseCredential = "credential"
+	PhasePut        = "put"
+	PhaseCheck      = "check"
+)
+
+// TopView API response wrapper
+
+type apiResponse struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// Upload types
+
+type uploadCredential struct {
+	FileID    string `json:"fileId"`
+	UploadURL string `json:"uploadUrl"`
+	FileName  string `json:"fileName"`
+}
+
+// Task types
+
+// SubmitRequest is the body of a video avatar task submission.
+type SubmitRequest struct {
+	AvatarSourceFrom string `json:"avatarSourceFrom"`
+	ImageFileID      string `json:"imageFileId,omitempty"`
+	AudioSourceFrom  string `json:"audioSourceFrom"`
+	AudioFileID      string `json:"audioFileId,omitempty"`
+	ModeType         string `json:"modeType"`
+}
+
+// SubmitResult is the result of a video avatar task submission.
+type SubmitResult struct {
+	TaskID    string `json:"taskId"`
+	Status    string `json:"status"`
+	ErrorMsg  string `json:"errorMsg"`
+	SubTaskID string `json:"subTaskId"`
+}
+
+// QueryResult is the state of a video avatar task.
+type QueryResult struct {
+	TaskID         string `json:"taskId"`
+	Status         string `json:"status"`
+	ErrorMsg       string `json:"errorMsg"`
+	OutputVideoURL string `json:"outputVideoUrl"`
+}
+
+// VideoAvatarRequest is an avatar4 generation from uploaded files.
+type VideoAvatarRequest struct {
+	// ImageFileID and AudioFileID are returned by Upload.
+	ImageFileID string
+	AudioFileID string
+}
+
+// Body returns the submission body of r.
+func (r VideoAvatarRequest) Body() SubmitRequest {
+	return SubmitRequest{
+		AvatarSourceFrom: "3", // user local photo
+		ImageFileID:      r.ImageFileID,
+		AudioSourceFrom:  "0", // uploaded audio
+		AudioFileID:      r.AudioFileID,
+		ModeType:         "2", // avatar4
+	}
+}
+
+// UploadHooks observe an upload. Both are optional.
+type UploadHooks struct {
+	// Progress receives the bytes sent so far and the size.
+	Progress func(sent, total int64)
+	// Phase is called after each step (PhaseCredential, PhasePut,
+	// PhaseCheck) with its start time and the bytes it transferred.
+	Phase func(name string, start time.Time, bytes int64)
+}
+
+func (h UploadHooks) phase(name string, start time.Time, bytes int64) {
+	if h.Phase != nil {
+		h.Phase(name, start, bytes)
+	}
+}
+
+// Client calls the TopView API with one API key and, optionally, the UID of
+// the account. It is safe for concurrent use.
+type Client struct {
+	apiKey       string
+	uid          string
+	baseURL      string
+	pollInterval time.Duration
+	pollTimeout  time.Duration
+	rest         rest.Client
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends requests, uploads included, through hc instead of
+// http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.rest.SetHTTP(hc) }
+}
+
+// WithBaseURL replaces DefaultBaseURL, e.g. for a proxy.
+func WithBaseURL(u string) Option {
+	return func(c *Client) { c.baseURL = strings.TrimSuffix(u, "/") }
+}
+
+// WithLogger logs raw responses at debug level to l. Logs are discarded
+// by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) { c.rest.SetLogger(l) }
+}
+
+// WithPolling replaces DefaultPollInterval and DefaultPollTimeout.
+func WithPolling(interval, timeout time.Duration) Option {
+	return func(c *Client) { c.pollInterval, c.pollTimeout = interval, timeout }
+}
+
+// New returns a client authenticated with apiKey and uid, which may be
+// empty.
+func New(apiKey, uid string, opts ...Option) *Client {
+	c := &Client{
+		apiKey:       apiKey,
+		uid:          uid,
+		baseURL:      DefaultBaseURL,
+		pollInterval: DefaultPollInterval,
+		pollTimeout:  DefaultPollTimeout,
+		rest:         rest.New(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// SubmitURL returns the endpoint video avatar tasks are submitted to.
+func (c *Client) SubmitURL() string {
+	return c.baseURL + "/video_avatar/task/submit"
+}
+
+// Headers returns the authentication headers of every API request.
+func (c *Client) Headers() map[string]string {
+	h := map[string]string{
+		"Authorization": "Bearer " + c.apiKey,
+	}
+	if c.uid != "" {
+		h["Topview-Uid"] = c.uid
+	}
+	return h
+}
+
+// call sends an API request and returns the result of the response
+// envelope.
+func (c *Client) call(ctx context.Context, method, endpoint, msg string, body []byte) (json.RawMessage, error) {
+	respBody, statusCode, err := c.rest.Do(ctx, method, endpoint, c.Headers(), body)
+	if err != nil {
+		return nil, err
+	}
+	c.rest.Debug(ctx, msg, "status_code", statusCode, "response", json.RawMessage(respBody))
+
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", statusCode, string(respBody))
+	}
+
+	var resp apiResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("parse response: %w\nraw: %s", err, string(respBody))
+	}
+
+	if resp.Code != "200" {
+		return nil, fmt.Errorf("TopView API error: %s (code: %s)", resp.Message, resp.Code)
+	}
+
+	return resp.Result, nil
+}
+
+// Upload flow: credential → S3 PUT → check
+
+func (c *Client) uploadCredential(ctx context.Context, format string) (*uploadCredential, error) {
+	result, err := c.call(ctx, http.MethodGet, c.baseURL+"/upload/credential?format="+url.QueryEscape(format), "topview upload credential", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var cred uploadCredential
+	if err := json.Unmarshal(result, &cred); err != nil {
+		return nil, fmt.Errorf("parse credential: %w", err)
+	}
+
+	return &cred, nil
+}
+
+func (c *Client) put(ctx context.Context, uploadURL string, body io.Reader, size int64, contentType string, progress func(sent, total int64)) error {
+	if progress != nil {
+		body = httpclient.ProgressReader(body, size, progress)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, uploadURL, body)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	// Uploads may be large, so no overall timeout applies. The presigned URL
+	// carries its own credentials, so the request has no auth headers.
+	resp, err := c.rest.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+func (c *Client) checkUpload(ctx context.Context, fileID string) (bool, error) {
+	result, err := c.call(ctx, http.MethodGet, c.baseURL+"/upload/check?fileId="+url.QueryEscape(fileID), "topview upload check", nil)
+	if err != nil {
+		return false, err
+	}
+
+	var ok bool
+	if err := json.Unmarshal(result, &ok); err != nil {
+		return false, fmt.Errorf("parse result: %w", err)
+	}
+
+	return ok, nil
+}
+
+// Upload streams size bytes from body through the credential → S3 PUT →
+// check flow and returns the TopView fileId. format is the upload format
+// (see ImageFormat and AudioFormat) and contentType the MIME type.
+func (c *Client) Upload(ctx context.Context, body io.Reader, size int64, format, contentType string, hooks UploadHooks) (string, error) {
+	start := time.Now()
+	cred, err := c.uploadCredential(ctx, format)
+	if err != nil {
+		return "", fmt.Errorf("get credential: %w", err)
+	}
+	hooks.phase(PhaseCredential, start, 0)
+
+	start = time.Now()
+	if err := c.put(ctx, cred.UploadURL, body, size, contentType, hooks.Progress); err != nil {
+		return "", fmt.Errorf("S3 upload: %w", err)
+	}
+	hooks.phase(PhasePut, start, size)
+
+	start = time.Now()
+	for i := 0; i < 10; i++ {
+		ok, err := c.checkUpload(ctx, cred.FileID)
+		if err != nil {
+			return "", fmt.Errorf("check upload: %w", err)
+		}
+		if ok {
+			hooks.phase(PhaseCheck, start, 0)
+			return cred.FileID, nil
+		}
+		if err := rest.Sleep(ctx, 2*time.Second); err != nil {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("upload check timed out for fileId: %s", cred.FileID)
+}
+
+// Task flow: submit → poll → download
+
+// SubmitVideoAvatar submits an avatar4 task for the uploaded image and
+// audio.
+func (c *Client) SubmitVideoAvatar(ctx context.Context, req VideoAvatarRequest) (*SubmitResult, error) {
+	body, err := json.Marshal(req.Body())
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	result, err := c.call(ctx, http.MethodPost, c.SubmitURL(), "topview submit", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var submitted SubmitResult
+	if err := json.Unmarshal(result, &submitted); err != nil {
+		return nil, fmt.Errorf("parse submit result: %w", err)
+	}
+
+	return &submitted, nil
+}
+
+// QueryTask fetches the current state of a video avatar task.
+func (c *Client) QueryTask(ctx context.Context, taskID string) (*QueryResult, error) {
+	result, err := c.call(ctx, http.MethodGet, c.baseURL+"/video_avatar/task/query?taskId="+url.QueryEscape(taskID), "topview query", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var state QueryResult
+	if err := json.Unmarshal(result, &state); err != nil {
+		return nil, fmt.Errorf("parse query result: %w", err)
+	}
+
+	return &state, nil
+}
+
+// WaitForTask polls until the task finishes, times out or ctx is done.
+// onStatus, if non-nil, is called with the status after every unfinished poll;
+// onQueryError, if non-nil, is called when a single poll fails and is retried.
+func (c *Client) WaitForTask(ctx context.Context, taskID string, onStatus func(status string), onQueryError func(attempt int, err error)) (*QueryResult, error) {
+	deadline := time.Now().Add(c.pollTimeout)
+
+	for i := 0; i < maxPollAttempts; i++ {
+		result, err := c.QueryTask(ctx, taskID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if onQueryError != nil {
+				onQueryError(i+1, err)
+			}
+			if err := rest.Sleep(ctx, c.pollInterval); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		switch result.Status {
+		case "done", "completed", "success":
+			if result.OutputVideoURL == "" {
+				return nil, fmt.Errorf("task completed but no output video URL")
+			}
+			return result, nil
+		case "failed", "error":
+			errMsg := result.ErrorMsg
+			if errMsg == "" {
+				errMsg = "unknown error"
+			}
+			return nil, fmt.Errorf("task failed: %s", errMsg)
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout after %v, task still in status: %s", c.pollTimeout, result.Status)
+		}
+
+		if onStatus != nil {
+			onStatus(result.Status)
+		}
+		if err := rest.Sleep(ctx, c.pollInterval); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("polling timed out after %d attempts", maxPollAttempts)
+}
+
+// CheckCredentials requests an upload credential without uploading
+// anything. It is the cheapest authenticated call, suitable for verifying
+// the API key and UID.
+func (c *Client) CheckCredentials(ctx context.Context) error {
+	_, err := c.uploadCredential(ctx, "png")
+	return err
+}
+
+// File format helpers
+
+// ImageFormat returns the TopView upload format for an image MIME type.
+func ImageFormat(mimeType string) string {
+	switch mimeType {
+	case "image/png":
+		return "png"
+	case "image/webp":
+		return "webp"
+	default:
+		return "jpg"
+	}
+}
+
+// AudioFormat returns the TopView upload format for an audio MIME type.
+func AudioFormat(mimeType string) string {
+	switch mimeType {
+	case "audio/wave", "audio/wav", "audio/x-wav":
+		return "wav"
+	case "audio/mp4", "audio/m4a", "audio/x-m4a":
+		return "m4a"
+	case "audio/aac":
+		return "aac"
+	default:
+		return "mp3"
+	}
+}
@@ -5,10 +5,32 @@ PLUGIN_DIR="$(cd "$(dirname "$0")/.." && pwd)"
 BIN_DIR="${PLUGIN_DIR}/bin"
 VERSION_FILE="${PLUGIN_DIR}/scripts/version"
 LOCAL_VERSION_FILE="${BIN_DIR}/.version"
+# Checksums of the installed binaries, also written by '<cli> self-update'
+LOCAL_SUMS_FILE="${BIN_DIR}/.sha256sums"
+PREVIOUS_DIR="${BIN_DIR}/.previous"
 
 REPO="llm-net/llm-api-plugin"
 
-TOOLS=(gemini-cli ark-cli topview-cli jimeng-cli)
+TOOLS=(gemini-cli ark-cli topview-cli jimeng-cli llm-api)
+
+sha256() {
+    if command -v sha256sum &>/dev/null; then
+        sha256sum "$@"
+    else
+        shasum -a 256 "$@"
+    fi
+}
+
+fetch() {
+    if command -v curl &>/dev/null; then
+        curl -fsSL -o "$1" "$2"
+    elif command -v wget &>/dev/null; then
+        wget -q -O "$1" "$2"
+    else
+        echo "Error: neither curl nor wget found"
+        exit 1
+    fi
+}
 
 # Read required version
 REQUIRED_VERSION="$(cat "$VERSION_FILE" | tr -d '[:space:]')"
@@ -19,14 +41,17 @@ if [[ -f "$LOCAL_VERSION_FILE" ]]; then
     LOCAL_VERSION="$(cat "$LOCAL_VERSION_FILE" | tr -d '[:space:]')"
 fi
 
-# Skip if already up to date
+# Skip if already up to date and every binary still matches its checksum
 if [[ "$LOCAL_VERSION" == "$REQUIRED_VERSION" ]]; then
-    echo "llm-api-plugin: binaries already at ${REQUIRED_VERSION}, skipping."
-    exit 0
+    if [[ -f "$LOCAL_SUMS_FILE" ]] && (cd "$BIN_DIR" && sha256 -c --status .sha256sums 2>/dev/null); then
+        echo "llm-api-plugin: binaries already at ${REQUIRED_VERSION}, skipping."
+        exit 0
+    fi
+    echo "llm-api-plugin: binaries at ${REQUIRED_VERSION} are missing or corrupt, reinstalling..."
+else
+    echo "llm-api-plugin: upgrading from ${LOCAL_VERSION:-none} to ${REQUIRED_VERSION}..."
 fi
 
-echo "llm-api-plugin: upgrading from ${LOCAL_VERSION:-none} to ${REQUIRED_VERSION}..."
-
 # Detect OS and ARCH
 OS="$(uname -s | tr '[:upper:]' '[:lower:]')"
 ARCH="$(uname -m)"
@@ -37,25 +62,43 @@ case "$ARCH" in
 esac
 
 mkdir -p "$BIN_DIR"
+STAGING="$(mktemp -d "${BIN_DIR}/.setup-XXXXXX")"
+trap 'rm -rf "$STAGING"' EXIT
+
+BASE_URL="https://github.com/${REPO}/releases/download/${REQUIRED_VERSION}"
 
-# Download each tool
+# Download the checksum manifest, then every tool into the staging directory
+fetch "${STAGING}/SHA256SUMS" "${BASE_URL}/SHA256SUMS"
 for TOOL in "${TOOLS[@]}"; do
-    URL="https://github.com/${REPO}/releases/download/${REQUIRED_VERSION}/${TOOL}-${OS}-${ARCH}"
+    ASSET="${TOOL}-${OS}-${ARCH}"
     echo "Downloading ${TOOL} (${OS}/${ARCH})..."
+    fetch "${STAGING}/${ASSET}" "${BASE_URL}/${ASSET}"
 
-    if command -v curl &>/dev/null; then
-        curl -fSL -o "${BIN_DIR}/${TOOL}" "$URL"
-    elif command -v wget &>/dev/null; then
-        wget -q -O "${BIN_DIR}/${TOOL}" "$URL"
-    else
-        echo "Error: neither curl nor wget found"
+    EXPECTED="$(awk -v f="$ASSET" '$2 == f || $2 == "*" f { print $1 }' "${STAGING}/SHA256SUMS")"
+    ACTUAL="$(sha256 "${STAGING}/${ASSET}" | awk '{ print $1 }')"
+    if [[ -z "$EXPECTED" || "$EXPECTED" != "$ACTUAL" ]]; then
+        echo "Error: checksum mismatch for ${ASSET} (expected ${EXPECTED:-none}, got ${ACTUAL}); nothing was installed"
         exit 1
     fi
+    chmod +x "${STAGING}/${ASSET}"
+done
 
-    chmod +x "${BIN_DIR}/${TOOL}"
+# Keep the current binaries for '<cli> self-update --rollback', then move
+# each verified binary into place with a single rename
+rm -rf "$PREVIOUS_DIR"
+mkdir -p "$PREVIOUS_DIR"
+if [[ -n "$LOCAL_VERSION" ]]; then
+    echo "$LOCAL_VERSION" > "${PREVIOUS_DIR}/.version"
+fi
+for TOOL in "${TOOLS[@]}"; do
+    if [[ -f "${BIN_DIR}/${TOOL}" ]]; then
+        ln "${BIN_DIR}/${TOOL}" "${PREVIOUS_DIR}/${TOOL}" 2>/dev/null || cp -p "${BIN_DIR}/${TOOL}" "${PREVIOUS_DIR}/${TOOL}"
+    fi
+    mv -f "${STAGING}/${TOOL}-${OS}-${ARCH}" "${BIN_DIR}/${TOOL}"
     echo "  -> ${BIN_DIR}/${TOOL}"
 done
 
-# Record installed version
+# Record installed version and checksums
+(cd "$BIN_DIR" && sha256 "${TOOLS[@]}" > .sha256sums)
 echo "$REQUIRED_VERSION" > "$LOCAL_VERSION_FILE"
 echo "llm-api-plugin: done. Version ${REQUIRED_VERSION} installed."